@@ -15,6 +15,7 @@ func main() {
 	}
 
 	app := cli.NewApp(cfg)
+	cli.AddExtraCommands(app)
 
 	if err := app.Run(os.Args); err != nil {
 		fmt.Println(err)
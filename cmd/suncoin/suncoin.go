@@ -1,7 +1,6 @@
 package main
 
 import (
-	"bytes"
 	"flag"
 	"fmt"
 	"io"
@@ -13,6 +12,7 @@ import (
 	"path/filepath"
 	"runtime/debug"
 	"runtime/pprof"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -21,12 +21,14 @@ import (
 	"github.com/skycoin/skycoin/src/cipher"
 	"github.com/skycoin/skycoin/src/coin"
 	"github.com/skycoin/skycoin/src/daemon"
+	"github.com/skycoin/skycoin/src/daemon/strand"
 	"github.com/skycoin/skycoin/src/gui"
 	"github.com/skycoin/skycoin/src/util/browser"
 	"github.com/skycoin/skycoin/src/util/cert"
 	"github.com/skycoin/skycoin/src/util/file"
 	"github.com/skycoin/skycoin/src/util/logging"
 	"github.com/skycoin/skycoin/src/visor"
+	"github.com/skycoin/skycoin/src/visor/blockdb"
 )
 
 var (
@@ -152,12 +154,45 @@ type Config struct {
 	// to show up as a peer
 	ConnectTo string
 
-	DBPath       string
-	Arbitrating  bool
-	RPCThreadNum uint // rpc number
-	Logtofile    bool
-	Logtogui     bool
-	LogBuffSize  int
+	DBPath        string
+	Arbitrating   bool
+	RPCThreadNum  uint // rpc number
+	Logtofile     bool
+	Logtogui      bool
+	LogBufRecords int // number of log records kept for the gui
+
+	// Rebuild the unspent pool and history db from the stored blocks before starting
+	Reindex bool
+	// With -reindex, only rebuild the history db
+	ReindexHistoryOnly bool
+	// Keep only the bodies of the last Prune blocks, 0 disables pruning
+	Prune uint64
+
+	// Block production policy of the master node
+	BlockInterval    time.Duration
+	BlockMinTxns     int
+	BlockMaxTxns     int
+	BlockMaxSize     int
+	BlockTxnOrdering string
+
+	// Transaction status tracking
+	TrackTxnsMax           int
+	TrackTxnsConfirmations uint64
+
+	// Comma separated hosts of the callback and webhook urls which may resolve to a
+	// loopback, link-local or private address
+	CallbackAllowedHosts string
+
+	// Address activity webhooks
+	// Defaults to ${DataDirectory}/webhooks.json
+	WebhooksFile    string
+	WebhookAttempts uint64
+
+	// Health and readiness checks
+	HealthTimeout        time.Duration
+	ReadyMaxBlocksBehind uint64
+	ReadyMinConnections  int
+	ReadyMaxPoolUsage    float64
 }
 
 func (c *Config) register() {
@@ -248,7 +283,26 @@ func (c *Config) register() {
 		"Run on localhost and only connect to localhost peers")
 	flag.BoolVar(&c.Arbitrating, "arbitrating", c.Arbitrating, "Run node in arbitrating mode")
 	flag.BoolVar(&c.Logtogui, "logtogui", true, "log to gui")
-	flag.IntVar(&c.LogBuffSize, "logbufsize", c.LogBuffSize, "Log size saved in memeory for gui show")
+	flag.IntVar(&c.LogBufRecords, "logbufrecords", c.LogBufRecords, "Number of log records kept in memory for the gui")
+	// Deprecated alias of -logbufrecords, kept for the existing launch scripts
+	flag.IntVar(&c.LogBufRecords, "logbufsize", c.LogBufRecords, "Deprecated, use -logbufrecords")
+	flag.BoolVar(&c.Reindex, "reindex", c.Reindex, "Rebuild the unspent pool and history db from the stored blocks before starting")
+	flag.BoolVar(&c.ReindexHistoryOnly, "reindex-history-only", c.ReindexHistoryOnly, "With -reindex, only rebuild the history db")
+	flag.DurationVar(&c.BlockInterval, "block-interval", c.BlockInterval, "Time between two blocks created by the master node")
+	flag.IntVar(&c.BlockMinTxns, "block-min-txns", c.BlockMinTxns, "Minimum number of transactions of a block created by the master node")
+	flag.IntVar(&c.BlockMaxTxns, "block-max-txns", c.BlockMaxTxns, "Maximum number of transactions of a block created by the master node, 0 is unlimited")
+	flag.IntVar(&c.BlockMaxSize, "block-max-size", c.BlockMaxSize, "Maximum size in bytes of the transactions of a block created by the master node")
+	flag.StringVar(&c.BlockTxnOrdering, "block-txn-ordering", c.BlockTxnOrdering, "Order of the unconfirmed transactions in a block, fee or fee_per_byte")
+	flag.IntVar(&c.TrackTxnsMax, "track-txns-max", c.TrackTxnsMax, "Maximum number of transactions whose status is tracked for clients")
+	flag.Uint64Var(&c.TrackTxnsConfirmations, "track-txns-confirmations", c.TrackTxnsConfirmations, "Confirmations after which a tracked transaction is final, unless the client requests otherwise")
+	flag.StringVar(&c.CallbackAllowedHosts, "callback-allowed-hosts", c.CallbackAllowedHosts, "Comma separated hosts of callback and webhook urls which may resolve to a loopback, link-local or private address")
+	flag.StringVar(&c.WebhooksFile, "webhooks-file", c.WebhooksFile, "File storing the registered address activity webhooks (defaults to ${data-dir}/webhooks.json)")
+	flag.Uint64Var(&c.WebhookAttempts, "webhook-attempts", c.WebhookAttempts, "Number of attempts to deliver an address activity webhook")
+	flag.DurationVar(&c.HealthTimeout, "health-timeout", c.HealthTimeout, "Time the data directory and the daemon loop have to answer a /health or /ready check")
+	flag.Uint64Var(&c.ReadyMaxBlocksBehind, "ready-max-blocks-behind", c.ReadyMaxBlocksBehind, "Maximum number of blocks behind the highest block of the peers for /ready")
+	flag.IntVar(&c.ReadyMinConnections, "ready-min-connections", c.ReadyMinConnections, "Minimum number of connections for /ready")
+	flag.Float64Var(&c.ReadyMaxPoolUsage, "ready-max-pool-usage", c.ReadyMaxPoolUsage, "Usage of the unconfirmed pool limits, from 0 to 1, above which /ready fails, 0 disables the check")
+	flag.Uint64Var(&c.Prune, "prune", c.Prune, "Keep only the bodies of the last N blocks, 0 disables pruning. The history api is disabled when pruning")
 }
 
 var devConfig Config = Config{
@@ -321,8 +375,28 @@ var devConfig Config = Config{
 	HTTPProf: false,
 	// Will force it to connect to this ip:port, instead of waiting for it
 	// to show up as a peer
-	ConnectTo:   "",
-	LogBuffSize: 8388608, //1024*1024*8
+	ConnectTo:     "",
+	LogBufRecords: 20000,
+
+	// Block production policy
+	BlockInterval:    10 * time.Second,
+	BlockMinTxns:     1,
+	BlockMaxTxns:     0,
+	BlockMaxSize:     32 * 1024,
+	BlockTxnOrdering: visor.TxnOrderingFeePerByte,
+
+	// Transaction status tracking
+	TrackTxnsMax:           100000,
+	TrackTxnsConfirmations: 6,
+
+	// Address activity webhooks
+	WebhookAttempts: 8,
+
+	// Health and readiness checks
+	HealthTimeout:        5 * time.Second,
+	ReadyMaxBlocksBehind: 10,
+	ReadyMinConnections:  1,
+	ReadyMaxPoolUsage:    0.9,
 }
 
 func (c *Config) Parse() {
@@ -375,6 +449,10 @@ func (c *Config) postProcess() {
 	if c.DBPath == "" {
 		c.DBPath = filepath.Join(c.DataDirectory, "data.db")
 	}
+
+	if c.WebhooksFile == "" {
+		c.WebhooksFile = filepath.Join(c.DataDirectory, "webhooks.json")
+	}
 }
 
 func panicIfError(err error, msg string, args ...interface{}) {
@@ -384,6 +462,8 @@ func panicIfError(err error, msg string, args ...interface{}) {
 }
 
 func printProgramStatus() {
+	logger.Info("Strand call statistics:\n%s", strand.GetStats().String())
+
 	fn := "goroutine.prof"
 	logger.Debug("Writing goroutine profile to %s", fn)
 	p := pprof.Lookup("goroutine")
@@ -422,11 +502,12 @@ func catchDebug() {
 }
 
 // init logging settings
-func initLogging(dataDir string, level string, color, logtofile, logtogui bool, logbuf *bytes.Buffer) (func(), error) {
+func initLogging(dataDir string, level string, color, logtofile bool, logStore *logging.Store) (func(), error) {
 	logCfg := logging.DevLogConfig(logModules)
 	logCfg.Format = logFormat
 	logCfg.Colors = color
 	logCfg.Level = level
+	logCfg.Store = logStore
 
 	var fd *os.File
 	if logtofile {
@@ -446,16 +527,7 @@ func initLogging(dataDir string, level string, color, logtofile, logtogui bool,
 			return nil, err
 		}
 
-		if logtogui {
-			logCfg.Output = io.MultiWriter(os.Stdout, fd, logbuf)
-		} else {
-			logCfg.Output = io.MultiWriter(os.Stdout, fd)
-		}
-
-	} else {
-		if logtogui {
-			logCfg.Output = io.MultiWriter(os.Stdout, logbuf)
-		}
+		logCfg.Output = io.MultiWriter(os.Stdout, fd)
 	}
 
 	logCfg.InitLogger()
@@ -581,34 +653,77 @@ func Run(c *Config) {
 		return
 	}
 
+	if c.Reindex {
+		if err := visor.Reindex(db, blockdb.NewSignerSchedule(c.BlockchainPubkey), visor.ReindexConfig{
+			HistoryOnly: c.ReindexHistoryOnly,
+			Progress: func(seq, headSeq uint64) {
+				if seq%1000 == 0 || seq == headSeq {
+					logger.Info("Reindexed block %d/%d", seq, headSeq)
+				}
+			},
+		}); err != nil {
+			logger.Error("Reindex failed: %v", err)
+			return
+		}
+	}
+
 	d, err := daemon.NewDaemon(dconf, db, DefaultConnections)
 	if err != nil {
 		logger.Error("%v", err)
 		return
 	}
 
-	closelog, err := initLogging(c.DataDirectory, c.LogLevel, c.ColorLog, c.Logtofile, c.Logtogui, &d.LogBuff)
-	if err != nil {
-		fmt.Println(err)
+	if c.Prune > 0 {
+		if err := d.Visor.EnablePruning(c.Prune); err != nil {
+			logger.Error("Enable pruning failed: %v", err)
+			return
+		}
+	}
+
+	if err := d.Visor.SetBlockPolicy(visor.BlockPolicy{
+		Interval:     c.BlockInterval,
+		MinTxns:      c.BlockMinTxns,
+		MaxTxns:      c.BlockMaxTxns,
+		MaxBlockSize: c.BlockMaxSize,
+		TxnOrdering:  c.BlockTxnOrdering,
+	}); err != nil {
+		logger.Error("Invalid block policy: %v", err)
 		return
 	}
+
+	txnTrackerConfig := daemon.NewTxnTrackerConfig()
+	txnTrackerConfig.MaxTracked = c.TrackTxnsMax
+	txnTrackerConfig.Confirmations = c.TrackTxnsConfirmations
+	txnTrackerConfig.CallbackAllowedHosts = splitHosts(c.CallbackAllowedHosts)
+	d.Visor.EnableTxnTracking(txnTrackerConfig)
+
+	webhookConfig := daemon.NewWebhookConfig()
+	webhookConfig.Filename = c.WebhooksFile
+	webhookConfig.Attempts = c.WebhookAttempts
+	webhookConfig.AllowedHosts = splitHosts(c.CallbackAllowedHosts)
+	if err := d.Visor.EnableWebhooks(webhookConfig); err != nil {
+		logger.Error("Enable webhooks failed: %v", err)
+		return
+	}
+
+	healthConfig := daemon.NewHealthConfig()
+	healthConfig.Filename = filepath.Join(c.DataDirectory, "health")
+	healthConfig.Timeout = c.HealthTimeout
+	healthConfig.MaxBlocksBehind = c.ReadyMaxBlocksBehind
+	healthConfig.MinConnections = c.ReadyMinConnections
+	healthConfig.MaxPoolUsage = c.ReadyMaxPoolUsage
+	d.Visor.EnableHealthChecks(healthConfig)
+
+	d.Visor.EnableEvents()
+
 	if c.Logtogui {
-		go func(buf *bytes.Buffer, quit chan struct{}) {
-			for {
-				select {
-				case <-quit:
-					logger.Info("Logbuff service closed normally")
-					return
-				case <-time.After(1 * time.Second): //insure logbuff size not exceed required size, like lru
-					for buf.Len() > c.LogBuffSize {
-						_, err := buf.ReadString(byte('\n')) //discard one line
-						if err != nil {
-							continue
-						}
-					}
-				}
-			}
-		}(&d.LogBuff, quit)
+		d.LogStore = logging.NewStore(c.LogBufRecords)
+	}
+
+	closelog, err := initLogging(c.DataDirectory, c.LogLevel, c.ColorLog, c.Logtofile, d.LogStore)
+	if err != nil {
+		fmt.Println(err)
+		return
 	}
 
 	errC := make(chan error, 1)
@@ -619,6 +734,9 @@ func Run(c *Config) {
 		d.Run()
 	}()
 
+	// Refresh the tracked transactions for the unconfirmed pool changes
+	go d.Gateway.RunTxnTracker(quit)
+
 	var rpc *webrpc.WebRPC
 	// start the webrpc
 	if c.RPCInterface {
@@ -628,6 +746,12 @@ func Run(c *Config) {
 			logger.Error("%v", err)
 			return
 		}
+		for method, h := range webrpc.ExtraHandlers() {
+			if err := rpc.HandleFunc(method, h); err != nil {
+				logger.Error("%v", err)
+				return
+			}
+		}
 		rpc.ChanBuffSize = 1000
 		rpc.WorkerNum = c.RPCThreadNum
 
@@ -736,6 +860,17 @@ func main() {
 	Run(&devConfig)
 }
 
+// splitHosts splits a comma separated list of hosts
+func splitHosts(s string) []string {
+	var hosts []string
+	for _, h := range strings.Split(s, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
 //addresses for storage of coins
 var AddrList []string = []string{
 	"2JEc8JFzN2TGFy3wqeoe6eru3vwgq45sVSR",
@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	gcli "github.com/urfave/cli"
+
+	"github.com/skycoin/skycoin/src/api/webrpc"
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/util/droplet"
+)
+
+// balanceAtFlag makes addressBalance return the confirmed balance as of a past block
+var balanceAtFlag = gcli.StringFlag{
+	Name: "at",
+	Usage: `Confirmed balance as of a block seq, or as of a date in RFC3339 format or
+	YYYY-MM-DD (UTC, end of the day)`,
+}
+
+// addressBalanceAtCmd is the addressBalance command of NewApp with the --at flag,
+// it replaces that command
+func addressBalanceAtCmd() gcli.Command {
+	cmd := addressBalanceCmd()
+	cmd.Description = `Check balance of specific addresses, join multiple addresses with space.
+		example: addressBalance "$addr1 $addr2 $addr3"
+		example: addressBalance --at 2018-01-31 "$addr1 $addr2"`
+	cmd.Flags = append(cmd.Flags, balanceAtFlag)
+	cmd.Action = addrBalanceAt
+	return cmd
+}
+
+func addrBalanceAt(c *gcli.Context) error {
+	at := c.String(balanceAtFlag.Name)
+	if at == "" {
+		return addrBalance(c)
+	}
+
+	rpcClient := RpcClientFromContext(c)
+
+	addrs := make([]string, c.NArg())
+	for i := 0; i < c.NArg(); i++ {
+		addrs[i] = c.Args().Get(i)
+		if _, err := cipher.DecodeBase58Address(addrs[i]); err != nil {
+			return fmt.Errorf("invalid address: %v, err: %v", addrs[i], err)
+		}
+	}
+
+	res, err := getBalanceAt(rpcClient, addrs, at)
+	if err != nil {
+		return err
+	}
+
+	return printJson(res)
+}
+
+// BalanceAtResult the confirmed balances of addresses as of a block
+type BalanceAtResult struct {
+	Seq       uint64             `json:"seq"`
+	Time      string             `json:"time"`
+	Confirmed Balance            `json:"confirmed"`
+	Addresses []AddressBalanceAt `json:"addresses"`
+}
+
+// AddressBalanceAt the confirmed balance of an address as of a block
+type AddressBalanceAt struct {
+	Confirmed Balance `json:"confirmed"`
+	Address   string  `json:"address"`
+}
+
+// parseBalanceAt parses the --at value. Returns the block seq, or the unix time
+// of the date with isTime true.
+func parseBalanceAt(s string) (uint64, bool, error) {
+	if seq, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return seq, false, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		if t.Unix() < 0 {
+			return 0, false, fmt.Errorf("invalid --at date %s", s)
+		}
+		return uint64(t.Unix()), true, nil
+	}
+
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil || d.Unix() < 0 {
+		return 0, false, fmt.Errorf("invalid --at value %s, must be a block seq or a date", s)
+	}
+
+	// The balance at the end of the day
+	return uint64(d.AddDate(0, 0, 1).Unix() - 1), true, nil
+}
+
+// getBalanceAt returns the confirmed balances of the addresses as of the block
+// or date of the --at value
+func getBalanceAt(c *webrpc.Client, addrs []string, at string) (*BalanceAtResult, error) {
+	v, isTime, err := parseBalanceAt(at)
+	if err != nil {
+		return nil, err
+	}
+
+	var res *webrpc.HistoricalBalanceResult
+	if isTime {
+		res, err = c.GetBalanceAtTime(addrs, v)
+	} else {
+		res, err = c.GetBalanceAtSeq(addrs, v)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return newBalanceAtResult(res)
+}
+
+func newBalanceAtResult(res *webrpc.HistoricalBalanceResult) (*BalanceAtResult, error) {
+	r := &BalanceAtResult{
+		Seq:       res.Seq,
+		Time:      time.Unix(int64(res.Time), 0).UTC().Format(time.RFC3339),
+		Addresses: make([]AddressBalanceAt, 0, len(res.Balances)),
+	}
+
+	var totalCoins, totalHours uint64
+	for _, b := range res.Balances {
+		coins, err := droplet.FromString(b.Coins)
+		if err != nil {
+			return nil, err
+		}
+
+		totalCoins += coins
+		totalHours += b.Hours
+
+		r.Addresses = append(r.Addresses, AddressBalanceAt{
+			Confirmed: Balance{
+				Coins: b.Coins,
+				Hours: strconv.FormatUint(b.Hours, 10),
+			},
+			Address: b.Address,
+		})
+	}
+
+	coins, err := droplet.ToString(totalCoins)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Confirmed = Balance{
+		Coins: coins,
+		Hours: strconv.FormatUint(totalHours, 10),
+	}
+
+	return r, nil
+}
@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/api/webrpc"
+)
+
+func TestParseBalanceAt(t *testing.T) {
+	cases := []struct {
+		at     string
+		v      uint64
+		isTime bool
+		err    bool
+	}{
+		{"100", 100, false, false},
+		{"2018-01-02T15:04:05Z", uint64(time.Date(2018, 1, 2, 15, 4, 5, 0, time.UTC).Unix()), true, false},
+		{"2018-01-02", uint64(time.Date(2018, 1, 3, 0, 0, 0, 0, time.UTC).Unix() - 1), true, false},
+		{"-1", 0, false, true},
+		{"yesterday", 0, false, true},
+		{"1969-01-01", 0, false, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.at, func(t *testing.T) {
+			v, isTime, err := parseBalanceAt(tc.at)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.v, v)
+			require.Equal(t, tc.isTime, isTime)
+		})
+	}
+}
+
+func TestNewBalanceAtResult(t *testing.T) {
+	res, err := newBalanceAtResult(&webrpc.HistoricalBalanceResult{
+		Seq:  10,
+		Time: uint64(time.Date(2018, 1, 2, 15, 4, 5, 0, time.UTC).Unix()),
+		Balances: []webrpc.HistoricalAddressBalance{
+			{Address: "a", Coins: "1.500000", Hours: 10},
+			{Address: "b", Coins: "0.000001", Hours: 0},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, &BalanceAtResult{
+		Seq:  10,
+		Time: "2018-01-02T15:04:05Z",
+		Confirmed: Balance{
+			Coins: "1.500001",
+			Hours: "10",
+		},
+		Addresses: []AddressBalanceAt{
+			{Confirmed: Balance{Coins: "1.500000", Hours: "10"}, Address: "a"},
+			{Confirmed: Balance{Coins: "0.000001", Hours: "0"}, Address: "b"},
+		},
+	}, res)
+
+	_, err = newBalanceAtResult(&webrpc.HistoricalBalanceResult{
+		Balances: []webrpc.HistoricalAddressBalance{{Address: "a", Coins: "x"}},
+	})
+	require.Error(t, err)
+}
@@ -1,7 +1,10 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"time"
 
@@ -10,19 +13,58 @@ import (
 
 	"github.com/skycoin/skycoin/src/cipher"
 	"github.com/skycoin/skycoin/src/visor"
+	"github.com/skycoin/skycoin/src/visor/blockdb"
 )
 
 const (
-	genesisPubkey = "0328c576d3f420e7682058a981173a4b374c7cc5ff55bf394d3cf57059bbe6456a"
+	// masterPubkey is the public key signing the SunCoin blocks, BlockchainPubkeyStr of cmd/suncoin
+	masterPubkey = "0255434580f86e14a26e1d5c59b0626dfa28003741c475155aeedaa92af797d043"
 )
 
+// signersFlag sets the block signer schedule used to verify the block signatures
+var signersFlag = gcli.StringFlag{
+	Name:  "signers",
+	Usage: "Block signers as pubkey:activate[:deactivate],... Defaults to the SunCoin master pubkey",
+}
+
+// blockSigners returns the block signer schedule of --signers, or the master pubkey if not set
+func blockSigners(c *gcli.Context) (blockdb.SignerSchedule, error) {
+	if s := c.String("signers"); s != "" {
+		signers, err := blockdb.ParseSignerSchedule(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --signers: %v", err)
+		}
+		return signers, nil
+	}
+
+	pubkey, err := cipher.PubKeyFromHex(masterPubkey)
+	if err != nil {
+		return nil, fmt.Errorf("decode master pubkey failed: %v", err)
+	}
+
+	return blockdb.NewSignerSchedule(pubkey), nil
+}
+
 func checkdbCmd() gcli.Command {
 	name := "checkdb"
 	return gcli.Command{
-		Name:         name,
-		Usage:        "Verify the database",
-		ArgsUsage:    "[db path]",
-		Description:  "If no argument is specificed, the default data.db in $HOME/.$COIN/ will be checked.",
+		Name:      name,
+		Usage:     "Verify the database",
+		ArgsUsage: "[db path]",
+		Description: `If no argument is specificed, the default data.db in $HOME/.$COIN/ will be checked.
+		The db file is copied to a temporary file without opening it, so it can be checked
+		while the node is running, and only the copy is checked, so the db is never written. All block signatures, prev hashes, body hashes and
+		uxhashes are verified, the unspent pool is recomputed and compared with the stored one,
+		and the history db address indexes are cross-checked. The discrepancies are printed as JSON.
+		With --repair the db is opened in place (the node must be stopped) and the unspent pool
+		and history db are rebuilt from the blocks if they are inconsistent.`,
+		Flags: []gcli.Flag{
+			gcli.BoolFlag{
+				Name:  "repair",
+				Usage: "Rebuild the unspent pool and history db in place if they are inconsistent",
+			},
+			signersFlag,
+		},
 		OnUsageError: onCommandUsageError(name),
 		Action:       checkdb,
 	}
@@ -42,26 +84,144 @@ func checkdb(c *gcli.Context) error {
 		return fmt.Errorf("db file: %v does not exist", dbpath)
 	}
 
-	db, err := bolt.Open(dbpath, 0600, &bolt.Options{
+	signers, err := blockSigners(c)
+	if err != nil {
+		return err
+	}
+
+	repair := c.Bool("repair")
+
+	openPath := dbpath
+	if !repair {
+		openPath, err = copyDB(dbpath)
+		if err != nil {
+			return fmt.Errorf("copy db failed: %v", err)
+		}
+		defer os.Remove(openPath)
+	}
+
+	db, err := bolt.Open(openPath, 0600, &bolt.Options{
 		Timeout: 5 * time.Second,
 	})
-
 	if err != nil {
 		return fmt.Errorf("open db failed: %v", err)
 	}
-	pubkey, err := cipher.PubKeyFromHex(genesisPubkey)
+	defer db.Close()
+
+	res, err := visor.VerifyDB(db, signers)
 	if err != nil {
-		return fmt.Errorf("decode genesis pubkey failed: %v", err)
+		return fmt.Errorf("checkdb failed: %v", err)
 	}
 
-	if err := IntegrityCheck(db, pubkey); err != nil {
-		return fmt.Errorf("checkdb failed: %v", err)
+	if err := printJson(res); err != nil {
+		return err
+	}
+
+	if len(res.Issues) == 0 {
+		return nil
 	}
 
-	fmt.Println("check db success")
+	if !repair {
+		return errors.New("checkdb found inconsistencies")
+	}
+
+	if res.BlocksCorrupted() {
+		return errors.New("blocks are corrupted, the db can't be repaired")
+	}
+
+	if err := visor.Reindex(db, signers, visor.ReindexConfig{
+		HistoryOnly: !res.UnspentCorrupted(),
+	}); err != nil {
+		return fmt.Errorf("repair failed: %v", err)
+	}
+
+	fmt.Println("repair success")
 	return nil
 }
 
+// copyDBAttempts is how many times the db is copied before giving up when the copies are inconsistent
+const copyDBAttempts = 3
+
+// copyDB copies the db file into a temporary file and returns the path of the copy. The db is
+// not opened, so that it can be copied while a node holds its lock. The node may write the db
+// during the copy, so the copy is checked and taken again if it is inconsistent.
+func copyDB(dbpath string) (string, error) {
+	var err error
+	for i := 0; i < copyDBAttempts; i++ {
+		var tmpPath string
+		tmpPath, err = copyDBFile(dbpath)
+		if err != nil {
+			return "", err
+		}
+
+		err = checkDBCopy(tmpPath)
+		if err == nil {
+			return tmpPath, nil
+		}
+
+		os.Remove(tmpPath)
+	}
+
+	return "", fmt.Errorf("the copies of the db are inconsistent, the db may be written too often: %v", err)
+}
+
+// copyDBFile copies the db file into a temporary file
+func copyDBFile(dbpath string) (string, error) {
+	f, err := os.Open(dbpath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	tmp, err := ioutil.TempFile("", "checkdb")
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := io.Copy(tmp, f); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	return tmp.Name(), nil
+}
+
+// checkDBCopy opens the copy of a db and checks the consistency of its pages
+func checkDBCopy(path string) (err error) {
+	// bolt panics on some corrupted pages
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{
+		Timeout: 5 * time.Second,
+	})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.View(func(tx *bolt.Tx) error {
+		var err error
+		// The channel must be drained for the check to return
+		for cerr := range tx.Check() {
+			if err == nil {
+				err = cerr
+			}
+		}
+		return err
+	})
+}
+
+// IntegrityCheck loads the blockchain, which verifies the signature of every block
 func IntegrityCheck(db *bolt.DB, genesisPubkey cipher.PubKey) error {
 	_, err := visor.NewBlockchain(db, genesisPubkey, visor.Arbitrating(true))
 	return err
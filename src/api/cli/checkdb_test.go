@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/testutil"
+)
+
+func TestCopyDB(t *testing.T) {
+	// The open db holds the lock of the file, as a running node does
+	db, closeDB := testutil.PrepareDB(t)
+	defer closeDB()
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("blocks"))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte("a"), []byte("1"))
+	})
+	require.NoError(t, err)
+
+	path, err := copyDB(db.Path())
+	require.NoError(t, err)
+	defer os.Remove(path)
+
+	cp, err := bolt.Open(path, 0600, nil)
+	require.NoError(t, err)
+	defer cp.Close()
+
+	err = cp.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("blocks"))
+		require.NotNil(t, b)
+		require.Equal(t, []byte("1"), b.Get([]byte("a")))
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestCopyDBInvalid(t *testing.T) {
+	f, err := ioutil.TempFile("", "checkdb")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+
+	_, err = f.Write([]byte("not a bolt db"))
+	require.NoError(t, err)
+	f.Close()
+
+	_, err = copyDB(f.Name())
+	require.Error(t, err)
+}
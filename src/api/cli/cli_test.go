@@ -1,14 +1,18 @@
 package cli
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	gcli "github.com/urfave/cli"
 
+	"github.com/skycoin/skycoin/src/cipher"
 	"github.com/skycoin/skycoin/src/util/file"
+	"github.com/skycoin/skycoin/src/visor/blockdb"
 )
 
 func Example() {
@@ -181,3 +185,23 @@ func TestResolveWalletPath(t *testing.T) {
 		})
 	}
 }
+
+func TestExtraCommands(t *testing.T) {
+	names := []string{}
+	for _, cmd := range ExtraCommands() {
+		names = append(names, cmd.Name)
+	}
+	require.Contains(t, names, "reindex")
+}
+
+func TestBlockSignersDefault(t *testing.T) {
+	set := flag.NewFlagSet("reindex", flag.ContinueOnError)
+	signersFlag.Apply(set)
+
+	signers, err := blockSigners(gcli.NewContext(nil, set, nil))
+	require.NoError(t, err)
+
+	pubkey, err := cipher.PubKeyFromHex(masterPubkey)
+	require.NoError(t, err)
+	require.Equal(t, blockdb.NewSignerSchedule(pubkey), signers)
+}
@@ -0,0 +1,37 @@
+package cli
+
+import (
+	gcli "github.com/urfave/cli"
+)
+
+// ExtraCommands returns the commands which are not part of the command list of NewApp,
+// they are added to the app commands by cmd/cli with AddExtraCommands
+func ExtraCommands() []gcli.Command {
+	return []gcli.Command{
+		reindexCmd(),
+		migrateCmd(),
+		rollbackCmd(),
+		addressHistoryExportCmd(),
+		walletHistoryExportCmd(),
+		addressBalanceAtCmd(),
+	}
+}
+
+// AddExtraCommands adds the ExtraCommands to the app commands, an extra command
+// replaces the app command of the same name
+func AddExtraCommands(app *App) {
+	for _, cmd := range ExtraCommands() {
+		replaced := false
+		for i := range app.Commands {
+			if app.Commands[i].Name == cmd.Name {
+				app.Commands[i] = cmd
+				replaced = true
+				break
+			}
+		}
+
+		if !replaced {
+			app.Commands = append(app.Commands, cmd)
+		}
+	}
+}
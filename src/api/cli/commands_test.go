@@ -0,0 +1,25 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	gcli "github.com/urfave/cli"
+)
+
+func TestAddExtraCommands(t *testing.T) {
+	app := &App{}
+	app.Commands = []gcli.Command{
+		addressBalanceCmd(),
+		{Name: "status"},
+	}
+
+	AddExtraCommands(app)
+	require.Len(t, app.Commands, len(ExtraCommands())+1)
+
+	// addressBalance is replaced by the command with the --at flag
+	require.Equal(t, "addressBalance", app.Commands[0].Name)
+	require.Len(t, app.Commands[0].Flags, 1)
+	require.Equal(t, balanceAtFlag.Name, app.Commands[0].Flags[0].GetName())
+	require.Equal(t, "status", app.Commands[1].Name)
+}
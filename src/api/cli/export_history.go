@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	gcli "github.com/urfave/cli"
+
+	"github.com/skycoin/skycoin/src/api/webrpc"
+	"github.com/skycoin/skycoin/src/visor"
+	"github.com/skycoin/skycoin/src/wallet"
+)
+
+var historyExportFlags = []gcli.Flag{
+	gcli.StringFlag{
+		Name:  "format",
+		Value: visor.HistoryFormatCSV,
+		Usage: "csv, one row per transaction after a header row, or json, one object per line",
+	},
+	gcli.StringFlag{
+		Name:  "o",
+		Usage: "Output file, stdout if not set",
+	},
+}
+
+func addressHistoryExportCmd() gcli.Command {
+	name := "addressHistoryExport"
+	return gcli.Command{
+		Name:      name,
+		Usage:     "Export the confirmed transactions of addresses as csv or json lines",
+		ArgsUsage: "[addresses]",
+		Description: `Each transaction has the block time, block seq, txid, direction
+		(received, sent or self, relative to the addresses), counterparties, amount,
+		coin hours, burn fee and note. A transaction between the addresses is exported once.`,
+		Flags:        historyExportFlags,
+		OnUsageError: onCommandUsageError(name),
+		Action: func(c *gcli.Context) error {
+			addrs := c.Args()
+			if len(addrs) == 0 {
+				return errors.New("at least one address is required")
+			}
+
+			return exportHistoryToOutput(c, addrs)
+		},
+	}
+}
+
+func walletHistoryExportCmd() gcli.Command {
+	name := "walletHistoryExport"
+	return gcli.Command{
+		Name:      name,
+		Usage:     "Export the confirmed transactions of a wallet as csv or json lines",
+		ArgsUsage: "[wallet]",
+		Description: `The transactions of all the addresses of the wallet are exported
+		like with addressHistoryExport. If no wallet is specified, the default wallet
+		$HOME/.$COIN/wallets/$WALLET_NAME is used.`,
+		Flags:        historyExportFlags,
+		OnUsageError: onCommandUsageError(name),
+		Action: func(c *gcli.Context) error {
+			cfg := ConfigFromContext(c)
+
+			wltPath, err := resolveWalletPath(cfg, c.Args().First())
+			if err != nil {
+				return err
+			}
+
+			wlt, err := wallet.Load(wltPath)
+			if err != nil {
+				return err
+			}
+
+			addrs := wlt.GetAddresses()
+			strAddrs := make([]string, 0, len(addrs))
+			for _, a := range addrs {
+				strAddrs = append(strAddrs, a.String())
+			}
+
+			return exportHistoryToOutput(c, strAddrs)
+		},
+	}
+}
+
+// exportHistoryToOutput exports the history of the addresses to the -o file or stdout
+func exportHistoryToOutput(c *gcli.Context, addrs []string) error {
+	var w io.Writer = os.Stdout
+	if path := c.String("o"); path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	return exportHistory(RPCClientFromContext(c), addrs, c.String("format"), w)
+}
+
+// exportHistory writes the confirmed transactions of the addresses to w in the given format
+func exportHistory(c *webrpc.Client, addrs []string, format string, w io.Writer) error {
+	if format != visor.HistoryFormatCSV && format != visor.HistoryFormatJSON {
+		return fmt.Errorf("invalid --format %s, must be csv or json", format)
+	}
+
+	res, err := c.GetAddressHistory(addrs)
+	if err != nil {
+		return err
+	}
+
+	return visor.WriteHistory(w, format, res.Records)
+}
@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/boltdb/bolt"
+	gcli "github.com/urfave/cli"
+
+	"github.com/skycoin/skycoin/src/visor"
+)
+
+func migrateCmd() gcli.Command {
+	name := "migrate"
+	return gcli.Command{
+		Name:      name,
+		Usage:     "Migrate the database to the current schema version",
+		ArgsUsage: "[db path]",
+		Description: `The node must be stopped. If no argument is specificed, the default
+		data.db in $HOME/.$COIN/ will be migrated. The db is backed up before migrating.`,
+		Flags: []gcli.Flag{
+			gcli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Only list the pending migrations",
+			},
+		},
+		OnUsageError: onCommandUsageError(name),
+		Action:       migrate,
+	}
+}
+
+type migrationJSON struct {
+	Version uint64 `json:"version"`
+	Name    string `json:"name"`
+}
+
+type migrateResult struct {
+	SchemaVersion uint64          `json:"schema_version"`
+	TargetVersion uint64          `json:"target_version"`
+	Pending       []migrationJSON `json:"pending"`
+	Backup        string          `json:"backup,omitempty"`
+}
+
+func migrate(c *gcli.Context) error {
+	cfg := ConfigFromContext(c)
+
+	dbpath, err := resolveDBPath(cfg, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(dbpath); os.IsNotExist(err) {
+		return fmt.Errorf("db file: %v does not exist", dbpath)
+	}
+
+	dryRun := c.Bool("dry-run")
+
+	db, err := bolt.Open(dbpath, 0600, &bolt.Options{
+		Timeout:  5 * time.Second,
+		ReadOnly: dryRun,
+	})
+	if err != nil {
+		return fmt.Errorf("open db failed: %v", err)
+	}
+	defer db.Close()
+
+	v, err := visor.GetSchemaVersion(db)
+	if err != nil {
+		return err
+	}
+
+	ms, err := visor.PendingMigrations(db)
+	if err != nil {
+		return err
+	}
+
+	res := migrateResult{
+		SchemaVersion: v,
+		TargetVersion: visor.SchemaVersion(),
+		Pending:       make([]migrationJSON, 0, len(ms)),
+	}
+	for _, m := range ms {
+		res.Pending = append(res.Pending, migrationJSON{
+			Version: m.Version,
+			Name:    m.Name,
+		})
+	}
+
+	if !dryRun {
+		res.Backup, err = visor.MigrateDB(db, true)
+		if err != nil {
+			return fmt.Errorf("migrate failed: %v", err)
+		}
+	}
+
+	return printJson(res)
+}
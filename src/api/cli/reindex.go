@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/boltdb/bolt"
+	gcli "github.com/urfave/cli"
+
+	"github.com/skycoin/skycoin/src/visor"
+)
+
+// reindexProgressInterval is how many blocks to replay between progress reports
+const reindexProgressInterval = 1000
+
+func reindexCmd() gcli.Command {
+	name := "reindex"
+	return gcli.Command{
+		Name:      name,
+		Usage:     "Rebuild the unspent pool and history db from the stored blocks",
+		ArgsUsage: "[db path]",
+		Description: `The node must be stopped. If no argument is specificed, the default
+		data.db in $HOME/.$COIN/ will be reindexed.`,
+		Flags: []gcli.Flag{
+			gcli.BoolFlag{
+				Name:  "history-only",
+				Usage: "Only rebuild the history db, keep the unspent pool",
+			},
+			signersFlag,
+		},
+		OnUsageError: onCommandUsageError(name),
+		Action:       reindex,
+	}
+}
+
+func reindex(c *gcli.Context) error {
+	cfg := ConfigFromContext(c)
+
+	dbpath, err := resolveDBPath(cfg, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(dbpath); os.IsNotExist(err) {
+		return fmt.Errorf("db file: %v does not exist", dbpath)
+	}
+
+	db, err := bolt.Open(dbpath, 0600, &bolt.Options{
+		Timeout: 5 * time.Second,
+	})
+	if err != nil {
+		return fmt.Errorf("open db failed: %v", err)
+	}
+	defer db.Close()
+
+	signers, err := blockSigners(c)
+	if err != nil {
+		return err
+	}
+
+	if err := visor.Reindex(db, signers, visor.ReindexConfig{
+		HistoryOnly: c.Bool("history-only"),
+		Progress: func(seq, headSeq uint64) {
+			if seq%reindexProgressInterval == 0 || seq == headSeq {
+				fmt.Printf("reindexed block %d/%d\n", seq, headSeq)
+			}
+		},
+	}); err != nil {
+		return fmt.Errorf("reindex failed: %v", err)
+	}
+
+	fmt.Println("reindex success")
+	return nil
+}
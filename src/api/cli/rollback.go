@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/boltdb/bolt"
+	gcli "github.com/urfave/cli"
+
+	"github.com/skycoin/skycoin/src/visor"
+)
+
+func rollbackCmd() gcli.Command {
+	name := "rollback"
+	return gcli.Command{
+		Name:      name,
+		Usage:     "Remove the blocks above a given seq from the database",
+		ArgsUsage: "[db path]",
+		Description: `The node must be stopped. If no argument is specificed, the default
+		data.db in $HOME/.$COIN/ will be rolled back. Back up the db first, the removed
+		blocks can't be restored.`,
+		Flags: []gcli.Flag{
+			gcli.StringFlag{
+				Name:  "to-seq",
+				Usage: "[required] Seq of the block that becomes the new head",
+			},
+		},
+		OnUsageError: onCommandUsageError(name),
+		Action:       rollback,
+	}
+}
+
+func rollback(c *gcli.Context) error {
+	cfg := ConfigFromContext(c)
+
+	if !c.IsSet("to-seq") {
+		return errors.New("--to-seq is required")
+	}
+
+	toSeq, err := strconv.ParseUint(c.String("to-seq"), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid --to-seq: %v", err)
+	}
+
+	dbpath, err := resolveDBPath(cfg, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(dbpath); os.IsNotExist(err) {
+		return fmt.Errorf("db file: %v does not exist", dbpath)
+	}
+
+	db, err := bolt.Open(dbpath, 0600, &bolt.Options{
+		Timeout: 5 * time.Second,
+	})
+	if err != nil {
+		return fmt.Errorf("open db failed: %v", err)
+	}
+	defer db.Close()
+
+	if err := visor.Rollback(db, toSeq, func(seq uint64) {
+		fmt.Printf("removed block %d\n", seq)
+	}); err != nil {
+		return fmt.Errorf("rollback failed: %v", err)
+	}
+
+	fmt.Println("rollback success")
+	return nil
+}
@@ -0,0 +1,125 @@
+package webrpc
+
+import (
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/daemon"
+	"github.com/skycoin/skycoin/src/util/droplet"
+	"github.com/skycoin/skycoin/src/visor"
+)
+
+// HistoricalBalanceGetter is implemented by the gateways which compute the balances of
+// addresses as of a past block
+type HistoricalBalanceGetter interface {
+	GetBalanceOfAddrsAt(addrs []cipher.Address, seq uint64) (*visor.HistoricalBalances, error)
+	GetBalanceOfAddrsAtTime(addrs []cipher.Address, t uint64) (*visor.HistoricalBalances, error)
+}
+
+// HistoricalAddressBalance the confirmed balance of an address as of a block
+type HistoricalAddressBalance struct {
+	Address string `json:"address"`
+	Coins   string `json:"coins"`
+	Hours   uint64 `json:"hours"`
+}
+
+// HistoricalBalanceResult the confirmed balances of addresses as of the block of seq Seq
+type HistoricalBalanceResult struct {
+	Seq      uint64                     `json:"seq"`
+	Time     uint64                     `json:"time"`
+	Balances []HistoricalAddressBalance `json:"balances"`
+}
+
+// params: {"addresses": [...], "seq": 100} or {"addresses": [...], "time": 1514764800}
+func getBalanceAtHandler(req Request, gateway Gatewayer) Response {
+	getter, ok := gateway.(HistoricalBalanceGetter)
+	if !ok {
+		return makeErrorResponse(errCodeInvalidRequest, "historical balances are not supported")
+	}
+
+	var params struct {
+		Addresses []string `json:"addresses"`
+		Seq       *uint64  `json:"seq"`
+		Time      *uint64  `json:"time"`
+	}
+	if err := req.DecodeParams(&params); err != nil {
+		logger.Critical("decode params failed:%v", err)
+		return makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams)
+	}
+
+	if len(params.Addresses) == 0 || (params.Seq == nil) == (params.Time == nil) {
+		return makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams)
+	}
+
+	addrs := make([]cipher.Address, 0, len(params.Addresses))
+	for _, s := range params.Addresses {
+		addr, err := cipher.DecodeBase58Address(s)
+		if err != nil {
+			logger.Critical("decode address err:%v", err)
+			return makeErrorResponse(errCodeInvalidParams, "invalid address")
+		}
+		addrs = append(addrs, addr)
+	}
+
+	var bals *visor.HistoricalBalances
+	var err error
+	if params.Seq != nil {
+		bals, err = getter.GetBalanceOfAddrsAt(addrs, *params.Seq)
+	} else {
+		bals, err = getter.GetBalanceOfAddrsAtTime(addrs, *params.Time)
+	}
+
+	switch err {
+	case nil:
+	case daemon.ErrBlockNotExist:
+		return makeErrorResponse(errCodeInvalidRequest, err.Error())
+	default:
+		logger.Error("get historical balance failed: %v", err)
+		return makeErrorResponse(errCodeInternalError, errMsgInternalError)
+	}
+
+	res := HistoricalBalanceResult{
+		Seq:      bals.Seq,
+		Time:     bals.Time,
+		Balances: make([]HistoricalAddressBalance, 0, len(bals.Balances)),
+	}
+
+	for _, b := range bals.Balances {
+		coins, err := droplet.ToString(b.Coins)
+		if err != nil {
+			logger.Error("convert coins to string failed: %v", err)
+			return makeErrorResponse(errCodeInternalError, errMsgInternalError)
+		}
+
+		res.Balances = append(res.Balances, HistoricalAddressBalance{
+			Address: b.Address.String(),
+			Coins:   coins,
+			Hours:   b.Hours,
+		})
+	}
+
+	return makeSuccessResponse(req.ID, res)
+}
+
+// GetBalanceAtSeq returns the confirmed balances of the addresses as of the block of given seq
+func (c *Client) GetBalanceAtSeq(addrs []string, seq uint64) (*HistoricalBalanceResult, error) {
+	res := HistoricalBalanceResult{}
+	if err := c.Do(&res, "get_balance_at", struct {
+		Addresses []string `json:"addresses"`
+		Seq       uint64   `json:"seq"`
+	}{addrs, seq}); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// GetBalanceAtTime returns the confirmed balances of the addresses as of the last block
+// at or before the unix time t
+func (c *Client) GetBalanceAtTime(addrs []string, t uint64) (*HistoricalBalanceResult, error) {
+	res := HistoricalBalanceResult{}
+	if err := c.Do(&res, "get_balance_at", struct {
+		Addresses []string `json:"addresses"`
+		Time      uint64   `json:"time"`
+	}{addrs, t}); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
@@ -0,0 +1,142 @@
+package webrpc
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/daemon"
+	"github.com/skycoin/skycoin/src/testutil"
+	"github.com/skycoin/skycoin/src/visor"
+)
+
+type fakeHistoricalBalanceGateway struct {
+	fakeGateway
+	headSeq uint64
+	err     error
+}
+
+func (fg *fakeHistoricalBalanceGateway) GetBalanceOfAddrsAt(addrs []cipher.Address, seq uint64) (*visor.HistoricalBalances, error) {
+	if fg.err != nil {
+		return nil, fg.err
+	}
+
+	if seq > fg.headSeq {
+		return nil, daemon.ErrBlockNotExist
+	}
+
+	bals := &visor.HistoricalBalances{
+		Seq:  seq,
+		Time: 1000 + seq*10,
+	}
+	for _, a := range addrs {
+		bals.Balances = append(bals.Balances, visor.HistoricalBalance{
+			Address: a,
+			Coins:   seq * 1e6,
+			Hours:   seq,
+		})
+	}
+	return bals, nil
+}
+
+func (fg *fakeHistoricalBalanceGateway) GetBalanceOfAddrsAtTime(addrs []cipher.Address, t uint64) (*visor.HistoricalBalances, error) {
+	if t < 1000 {
+		return nil, daemon.ErrBlockNotExist
+	}
+	return fg.GetBalanceOfAddrsAt(addrs, (t-1000)/10)
+}
+
+func Test_getBalanceAtHandler(t *testing.T) {
+	addr := testutil.MakeAddress().String()
+
+	makeReq := func(params string) Request {
+		return Request{
+			ID:      "1",
+			Jsonrpc: jsonRPC,
+			Method:  "get_balance_at",
+			Params:  []byte(params),
+		}
+	}
+
+	expected := makeSuccessResponse("1", HistoricalBalanceResult{
+		Seq:  5,
+		Time: 1050,
+		Balances: []HistoricalAddressBalance{{
+			Address: addr,
+			Coins:   "5.000000",
+			Hours:   5,
+		}},
+	})
+
+	tests := []struct {
+		name    string
+		req     Request
+		gateway Gatewayer
+		want    Response
+	}{
+		{
+			"by seq",
+			makeReq(fmt.Sprintf(`{"addresses": [%q], "seq": 5}`, addr)),
+			&fakeHistoricalBalanceGateway{headSeq: 10},
+			expected,
+		},
+		{
+			"by time",
+			makeReq(fmt.Sprintf(`{"addresses": [%q], "time": 1055}`, addr)),
+			&fakeHistoricalBalanceGateway{headSeq: 10},
+			expected,
+		},
+		{
+			"seq after head",
+			makeReq(fmt.Sprintf(`{"addresses": [%q], "seq": 11}`, addr)),
+			&fakeHistoricalBalanceGateway{headSeq: 10},
+			makeErrorResponse(errCodeInvalidRequest, daemon.ErrBlockNotExist.Error()),
+		},
+		{
+			"seq and time",
+			makeReq(fmt.Sprintf(`{"addresses": [%q], "seq": 5, "time": 1055}`, addr)),
+			&fakeHistoricalBalanceGateway{headSeq: 10},
+			makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams),
+		},
+		{
+			"no block",
+			makeReq(fmt.Sprintf(`{"addresses": [%q]}`, addr)),
+			&fakeHistoricalBalanceGateway{headSeq: 10},
+			makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams),
+		},
+		{
+			"no addresses",
+			makeReq(`{"seq": 5}`),
+			&fakeHistoricalBalanceGateway{headSeq: 10},
+			makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams),
+		},
+		{
+			"invalid address",
+			makeReq(`{"addresses": ["abc"], "seq": 5}`),
+			&fakeHistoricalBalanceGateway{headSeq: 10},
+			makeErrorResponse(errCodeInvalidParams, "invalid address"),
+		},
+		{
+			"gateway error",
+			makeReq(fmt.Sprintf(`{"addresses": [%q], "seq": 5}`, addr)),
+			&fakeHistoricalBalanceGateway{headSeq: 10, err: errors.New("failed")},
+			makeErrorResponse(errCodeInternalError, errMsgInternalError),
+		},
+		{
+			"not supported",
+			makeReq(fmt.Sprintf(`{"addresses": [%q], "seq": 5}`, addr)),
+			&fakeGateway{},
+			makeErrorResponse(errCodeInvalidRequest, "historical balances are not supported"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := getBalanceAtHandler(tt.req, tt.gateway)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
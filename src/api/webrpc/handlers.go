@@ -0,0 +1,9 @@
+package webrpc
+
+// ExtraHandlers returns the handlers which are not registered by New, they are
+// registered with HandleFunc by cmd/suncoin
+func ExtraHandlers() map[string]HandlerFunc {
+	return map[string]HandlerFunc{
+		"get_balance_at": getBalanceAtHandler,
+	}
+}
@@ -0,0 +1,73 @@
+package webrpc
+
+import (
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/visor"
+)
+
+// AddressHistoryGetter is implemented by the gateways which export the transaction
+// history of addresses
+type AddressHistoryGetter interface {
+	GetAddressesHistory(addrs []cipher.Address) ([]visor.HistoryRecord, error)
+}
+
+// AddressHistoryResult the confirmed transactions of addresses, ordered by block seq
+// then index in the block
+type AddressHistoryResult struct {
+	Records []visor.ReadableHistoryRecord `json:"records"`
+}
+
+// params: {"addresses": [...]}
+func getAddressHistoryHandler(req Request, gateway Gatewayer) Response {
+	getter, ok := gateway.(AddressHistoryGetter)
+	if !ok {
+		return makeErrorResponse(errCodeInvalidRequest, "address history is not supported")
+	}
+
+	var params struct {
+		Addresses []string `json:"addresses"`
+	}
+	if err := req.DecodeParams(&params); err != nil {
+		logger.Critical("decode params failed:%v", err)
+		return makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams)
+	}
+
+	if len(params.Addresses) == 0 {
+		return makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams)
+	}
+
+	addrs := make([]cipher.Address, 0, len(params.Addresses))
+	for _, s := range params.Addresses {
+		addr, err := cipher.DecodeBase58Address(s)
+		if err != nil {
+			logger.Critical("decode address err:%v", err)
+			return makeErrorResponse(errCodeInvalidParams, "invalid address")
+		}
+		addrs = append(addrs, addr)
+	}
+
+	records, err := getter.GetAddressesHistory(addrs)
+	if err != nil {
+		logger.Error("get address history failed: %v", err)
+		return makeErrorResponse(errCodeInternalError, errMsgInternalError)
+	}
+
+	rs, err := visor.NewReadableHistoryRecords(records)
+	if err != nil {
+		logger.Error("convert history records failed: %v", err)
+		return makeErrorResponse(errCodeInternalError, errMsgInternalError)
+	}
+
+	return makeSuccessResponse(req.ID, AddressHistoryResult{Records: rs})
+}
+
+// GetAddressHistory returns the confirmed transactions of the addresses
+func (c *Client) GetAddressHistory(addrs []string) (*AddressHistoryResult, error) {
+	res := AddressHistoryResult{}
+	if err := c.Do(&res, "get_address_history", struct {
+		Addresses []string `json:"addresses"`
+	}{addrs}); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
@@ -0,0 +1,105 @@
+package webrpc
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/testutil"
+	"github.com/skycoin/skycoin/src/visor"
+)
+
+type fakeAddressHistoryGateway struct {
+	fakeGateway
+	records []visor.HistoryRecord
+	err     error
+}
+
+func (fg *fakeAddressHistoryGateway) GetAddressesHistory(addrs []cipher.Address) ([]visor.HistoryRecord, error) {
+	return fg.records, fg.err
+}
+
+func Test_getAddressHistoryHandler(t *testing.T) {
+	addr := testutil.MakeAddress()
+	other := testutil.MakeAddress()
+	txid := testutil.RandSHA256(t)
+
+	makeReq := func(params string) Request {
+		return Request{
+			ID:      "1",
+			Jsonrpc: jsonRPC,
+			Method:  "get_address_history",
+			Params:  []byte(params),
+		}
+	}
+
+	gateway := &fakeAddressHistoryGateway{
+		records: []visor.HistoryRecord{{
+			Time:           1514764800,
+			BlockSeq:       3,
+			Txid:           txid,
+			Direction:      visor.HistoryReceived,
+			Counterparties: []cipher.Address{other},
+			Coins:          2e6,
+			Hours:          4,
+		}},
+	}
+
+	tests := []struct {
+		name    string
+		req     Request
+		gateway Gatewayer
+		want    Response
+	}{
+		{
+			"ok",
+			makeReq(fmt.Sprintf(`{"addresses": [%q]}`, addr.String())),
+			gateway,
+			makeSuccessResponse("1", AddressHistoryResult{
+				Records: []visor.ReadableHistoryRecord{{
+					Timestamp:      "2018-01-01T00:00:00Z",
+					BlockSeq:       3,
+					Txid:           txid.Hex(),
+					Direction:      visor.HistoryReceived,
+					Counterparties: []string{other.String()},
+					Amount:         "2.000000",
+					Hours:          4,
+				}},
+			}),
+		},
+		{
+			"no addresses",
+			makeReq(`{"addresses": []}`),
+			gateway,
+			makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams),
+		},
+		{
+			"invalid address",
+			makeReq(`{"addresses": ["abc"]}`),
+			gateway,
+			makeErrorResponse(errCodeInvalidParams, "invalid address"),
+		},
+		{
+			"gateway error",
+			makeReq(fmt.Sprintf(`{"addresses": [%q]}`, addr.String())),
+			&fakeAddressHistoryGateway{err: errors.New("failed")},
+			makeErrorResponse(errCodeInternalError, errMsgInternalError),
+		},
+		{
+			"not supported",
+			makeReq(fmt.Sprintf(`{"addresses": [%q]}`, addr.String())),
+			&fakeGateway{},
+			makeErrorResponse(errCodeInvalidRequest, "address history is not supported"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := getAddressHistoryHandler(tt.req, tt.gateway)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package webrpc
+
+import (
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/daemon"
+)
+
+// TxnTracker is implemented by the gateways which track the status of transactions
+type TxnTracker interface {
+	TrackTransactions(req daemon.TrackTxnsRequest) error
+	GetTrackedTransactions() []daemon.TrackedTxn
+}
+
+// TrackedTxnsResult the tracked transactions and their last known status
+type TrackedTxnsResult struct {
+	Transactions []daemon.TrackedTxn `json:"transactions"`
+}
+
+func trackTransactionsHandler(req Request, gateway Gatewayer) Response {
+	tracker, ok := gateway.(TxnTracker)
+	if !ok {
+		return makeErrorResponse(errCodeInvalidRequest, "transaction tracking is not supported")
+	}
+
+	var params struct {
+		Txids         []string `json:"txids"`
+		CallbackURL   string   `json:"callback_url"`
+		Confirmations uint64   `json:"confirmations"`
+	}
+	if err := req.DecodeParams(&params); err != nil {
+		logger.Critical("decode params failed:%v", err)
+		return makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams)
+	}
+
+	if len(params.Txids) == 0 {
+		return makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams)
+	}
+
+	txids := make([]cipher.SHA256, 0, len(params.Txids))
+	for _, s := range params.Txids {
+		txid, err := cipher.SHA256FromHex(s)
+		if err != nil {
+			logger.Critical("decode txid err:%v", err)
+			return makeErrorResponse(errCodeInvalidParams, "invalid transaction hash")
+		}
+		txids = append(txids, txid)
+	}
+
+	if err := tracker.TrackTransactions(daemon.TrackTxnsRequest{
+		Txids:         txids,
+		CallbackURL:   params.CallbackURL,
+		Confirmations: params.Confirmations,
+	}); err != nil {
+		return makeErrorResponse(errCodeInvalidRequest, err.Error())
+	}
+
+	return makeSuccessResponse(req.ID, TrackedTxnsResult{tracker.GetTrackedTransactions()})
+}
+
+func getTrackedTransactionsHandler(req Request, gateway Gatewayer) Response {
+	tracker, ok := gateway.(TxnTracker)
+	if !ok {
+		return makeErrorResponse(errCodeInvalidRequest, "transaction tracking is not supported")
+	}
+
+	if len(req.Params) > 0 {
+		return makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams)
+	}
+
+	txns := tracker.GetTrackedTransactions()
+	if txns == nil {
+		txns = []daemon.TrackedTxn{}
+	}
+
+	return makeSuccessResponse(req.ID, TrackedTxnsResult{txns})
+}
@@ -0,0 +1,134 @@
+package webrpc
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/daemon"
+)
+
+type fakeTxnTrackerGateway struct {
+	fakeGateway
+	tracked []daemon.TrackedTxn
+	err     error
+}
+
+func (fg *fakeTxnTrackerGateway) TrackTransactions(req daemon.TrackTxnsRequest) error {
+	if fg.err != nil {
+		return fg.err
+	}
+
+	for _, txid := range req.Txids {
+		fg.tracked = append(fg.tracked, daemon.TrackedTxn{
+			Txid:                txid.Hex(),
+			State:               daemon.TxnStateUnknown,
+			TargetConfirmations: req.Confirmations,
+			CallbackURL:         req.CallbackURL,
+		})
+	}
+	return nil
+}
+
+func (fg *fakeTxnTrackerGateway) GetTrackedTransactions() []daemon.TrackedTxn {
+	return fg.tracked
+}
+
+func Test_trackTransactionsHandler(t *testing.T) {
+	txid := "3e52703a21bf9462799f52ab0cedb314efcf7c43aadb815429cd79f35f040954"
+
+	tests := []struct {
+		name    string
+		req     Request
+		gateway Gatewayer
+		want    Response
+	}{
+		{
+			"normal",
+			Request{
+				ID:      "1",
+				Jsonrpc: jsonRPC,
+				Method:  "track_transactions",
+				Params:  []byte(fmt.Sprintf(`{"txids": [%q], "callback_url": "http://127.0.0.1/cb", "confirmations": 3}`, txid)),
+			},
+			&fakeTxnTrackerGateway{},
+			makeSuccessResponse("1", TrackedTxnsResult{[]daemon.TrackedTxn{{
+				Txid:                txid,
+				State:               daemon.TxnStateUnknown,
+				TargetConfirmations: 3,
+				CallbackURL:         "http://127.0.0.1/cb",
+			}}}),
+		},
+		{
+			"invalid txid",
+			Request{
+				ID:      "1",
+				Jsonrpc: jsonRPC,
+				Method:  "track_transactions",
+				Params:  []byte(`{"txids": ["abc"]}`),
+			},
+			&fakeTxnTrackerGateway{},
+			makeErrorResponse(errCodeInvalidParams, "invalid transaction hash"),
+		},
+		{
+			"no txids",
+			Request{
+				ID:      "1",
+				Jsonrpc: jsonRPC,
+				Method:  "track_transactions",
+				Params:  []byte(`{}`),
+			},
+			&fakeTxnTrackerGateway{},
+			makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams),
+		},
+		{
+			"track failed",
+			Request{
+				ID:      "1",
+				Jsonrpc: jsonRPC,
+				Method:  "track_transactions",
+				Params:  []byte(fmt.Sprintf(`{"txids": [%q]}`, txid)),
+			},
+			&fakeTxnTrackerGateway{err: errors.New("Too many tracked transactions")},
+			makeErrorResponse(errCodeInvalidRequest, "Too many tracked transactions"),
+		},
+		{
+			"tracking not supported",
+			Request{
+				ID:      "1",
+				Jsonrpc: jsonRPC,
+				Method:  "track_transactions",
+				Params:  []byte(fmt.Sprintf(`{"txids": [%q]}`, txid)),
+			},
+			&fakeGateway{},
+			makeErrorResponse(errCodeInvalidRequest, "transaction tracking is not supported"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := trackTransactionsHandler(tt.req, tt.gateway)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_getTrackedTransactionsHandler(t *testing.T) {
+	gateway := &fakeTxnTrackerGateway{}
+	got := getTrackedTransactionsHandler(Request{
+		ID:      "1",
+		Jsonrpc: jsonRPC,
+		Method:  "get_tracked_transactions",
+	}, gateway)
+	require.Equal(t, makeSuccessResponse("1", TrackedTxnsResult{[]daemon.TrackedTxn{}}), got)
+
+	got = getTrackedTransactionsHandler(Request{
+		ID:      "1",
+		Jsonrpc: jsonRPC,
+		Method:  "get_tracked_transactions",
+		Params:  []byte(`["abc"]`),
+	}, gateway)
+	require.Equal(t, makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams), got)
+}
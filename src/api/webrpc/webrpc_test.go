@@ -117,6 +117,13 @@ func Test_rpcHandler_HandlerFunc(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestExtraHandlers(t *testing.T) {
+	rpc := setupWebRPC(t)
+	for method, h := range ExtraHandlers() {
+		require.NoError(t, rpc.HandleFunc(method, h), method)
+	}
+}
+
 func Test_rpcHandler_Handler(t *testing.T) {
 	rpc := setupWebRPC(t)
 	errC := make(chan error, 1)
@@ -0,0 +1,109 @@
+package ws
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// maxEventSize is the maximum size of an event received by a Client
+const maxEventSize = 32 * 1024 * 1024
+
+// Event is a message received by a Client, Data is decoded by the caller according to the topic:
+//
+//	block: visor.ReadableBlock
+//	unconfirmed_txn: visor.ReadableTransaction
+//	address: daemon.AddressActivity
+//	peer: daemon.PeerEvent
+//	sync: daemon.SyncProgress
+//	txn_status: daemon.TxnStatusEvent
+type Event struct {
+	Topic   string          `json:"topic"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Dropped uint64          `json:"dropped,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// Client subscribes to the event stream of a node
+type Client struct {
+	sync.Mutex
+	conn   *websocket.Conn
+	events chan Event
+	err    error
+}
+
+// Dial connects to the websocket endpoint of a node, e.g. ws://127.0.0.1:6420/ws
+func Dial(url string) (*Client, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetReadLimit(maxEventSize)
+
+	c := &Client{
+		conn:   conn,
+		events: make(chan Event, 256),
+	}
+
+	go c.read()
+
+	return c, nil
+}
+
+func (c *Client) read() {
+	defer close(c.events)
+	for {
+		var ev Event
+		if err := c.conn.ReadJSON(&ev); err != nil {
+			c.Lock()
+			c.err = err
+			c.Unlock()
+			return
+		}
+		c.events <- ev
+	}
+}
+
+// Events returns the channel receiving the events, it is closed when the connection ends
+func (c *Client) Events() <-chan Event {
+	return c.events
+}
+
+// Err returns the error which ended the connection, once the events channel is closed
+func (c *Client) Err() error {
+	c.Lock()
+	defer c.Unlock()
+	return c.err
+}
+
+// Subscribe subscribes to the topic, the address topic requires addresses.
+// The node answers with a "subscribed" or "error" event.
+func (c *Client) Subscribe(topic string, addrs ...string) error {
+	return c.send(Request{
+		Action:    ActionSubscribe,
+		Topic:     topic,
+		Addresses: addrs,
+	})
+}
+
+// Unsubscribe unsubscribes from the topic, or from the given addresses of the address topic.
+// The node answers with an "unsubscribed" or "error" event.
+func (c *Client) Unsubscribe(topic string, addrs ...string) error {
+	return c.send(Request{
+		Action:    ActionUnsubscribe,
+		Topic:     topic,
+		Addresses: addrs,
+	})
+}
+
+func (c *Client) send(req Request) error {
+	c.Lock()
+	defer c.Unlock()
+	return c.conn.WriteJSON(req)
+}
+
+// Close closes the connection
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
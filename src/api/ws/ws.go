@@ -0,0 +1,349 @@
+/*
+Package ws streams the daemon events to websocket clients.
+
+A client sends requests to subscribe to topics:
+
+	{"action": "subscribe", "topic": "block"}
+	{"action": "subscribe", "topic": "address", "addresses": ["2JEc8JFzN2TGFy3wqeoe6eru3vwgq45sVSR"]}
+	{"action": "unsubscribe", "topic": "block"}
+
+and receives a message for each event of its topics:
+
+	{"topic": "block", "data": {...}}
+
+When the client doesn't read fast enough, events are dropped and the number of
+dropped events is set on the next message. Requests are acknowledged with a
+"subscribed" or "unsubscribed" message, invalid requests get an "error" message.
+*/
+package ws
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/daemon"
+	"github.com/skycoin/skycoin/src/util/logging"
+)
+
+var logger = logging.MustGetLogger("ws")
+
+// Topics a client may subscribe to
+const (
+	TopicBlock          = daemon.EventTopicBlock
+	TopicUnconfirmedTxn = daemon.EventTopicUnconfirmedTxn
+	TopicAddress        = daemon.EventTopicAddress
+	TopicPeer           = daemon.EventTopicPeer
+	TopicSync           = daemon.EventTopicSync
+	// TopicTxnStatus status changes of the transactions tracked with /transactions/track
+	TopicTxnStatus = "txn_status"
+)
+
+// Topics of the messages answering requests
+const (
+	TopicSubscribed   = "subscribed"
+	TopicUnsubscribed = "unsubscribed"
+	TopicError        = "error"
+)
+
+// Request actions
+const (
+	ActionSubscribe   = "subscribe"
+	ActionUnsubscribe = "unsubscribe"
+)
+
+var topics = map[string]struct{}{
+	TopicBlock:          {},
+	TopicUnconfirmedTxn: {},
+	TopicAddress:        {},
+	TopicPeer:           {},
+	TopicSync:           {},
+	TopicTxnStatus:      {},
+}
+
+// Request is sent by a client to change its subscriptions
+type Request struct {
+	Action string `json:"action"`
+	Topic  string `json:"topic"`
+	// Addresses of the address topic
+	Addresses []string `json:"addresses,omitempty"`
+}
+
+// Message is sent to a client
+type Message struct {
+	Topic string      `json:"topic"`
+	Data  interface{} `json:"data,omitempty"`
+	// Number of events dropped before this message because the client didn't keep up
+	Dropped uint64 `json:"dropped,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Gatewayer provides the events streamed to the clients
+type Gatewayer interface {
+	SubscribeEvents(size int) (<-chan daemon.Event, func())
+	SubscribeTxnStatus(size int) (<-chan daemon.TxnStatusEvent, func())
+}
+
+// Config configures the websocket handler
+type Config struct {
+	// Number of events buffered for a client before they are dropped
+	BufferSize int
+	// A client which doesn't accept a message within WriteTimeout is disconnected
+	WriteTimeout time.Duration
+	// Maximum size of a client request
+	MaxRequestSize int64
+	// Origins allowed besides the node's own host, e.g. https://wallet.example.com.
+	// Browsers send the Origin of the page opening the connection, other clients send none.
+	AllowedOrigins []string
+}
+
+// NewConfig creates default websocket handler config
+func NewConfig() Config {
+	return Config{
+		BufferSize:     256,
+		WriteTimeout:   10 * time.Second,
+		MaxRequestSize: 64 * 1024,
+	}
+}
+
+// NewHandler creates the websocket handler streaming the gateway events
+func NewHandler(gateway Gatewayer, cfg Config) http.Handler {
+	upgrader := websocket.Upgrader{
+		// Refuse the pages of other sites, a browser lets any page open a websocket
+		CheckOrigin: cfg.originAllowed,
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Upgrade responds with the error if the handshake fails
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			logger.Info("Websocket handshake from %s failed: %v", r.RemoteAddr, err)
+			return
+		}
+		defer c.Close()
+
+		c.SetReadLimit(cfg.MaxRequestSize)
+		newSession(gateway, cfg).serve(c)
+	})
+}
+
+// originAllowed returns true if the request has no Origin, if the Origin is the host
+// of the request or if it is one of the allowed origins
+func (cfg Config) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	for _, o := range cfg.AllowedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			return true
+		}
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// session holds the subscriptions of a client
+type session struct {
+	sync.Mutex
+	gateway Gatewayer
+	cfg     Config
+	topics  map[string]struct{}
+	addrs   map[string]struct{}
+	// events dropped by the gateway, reported on the next message
+	dropped uint64
+	replies chan Message
+}
+
+func newSession(gateway Gatewayer, cfg Config) *session {
+	return &session{
+		gateway: gateway,
+		cfg:     cfg,
+		topics:  make(map[string]struct{}),
+		addrs:   make(map[string]struct{}),
+		replies: make(chan Message, 16),
+	}
+}
+
+// serve reads the client requests and writes the events of its topics until the connection fails
+func (s *session) serve(c *websocket.Conn) {
+	events, unsubscribeEvents := s.gateway.SubscribeEvents(s.cfg.BufferSize)
+	defer unsubscribeEvents()
+
+	statuses, unsubscribeStatus := s.gateway.SubscribeTxnStatus(s.cfg.BufferSize)
+	defer unsubscribeStatus()
+
+	done := make(chan struct{})
+	quit := make(chan struct{})
+	defer close(quit)
+
+	go func() {
+		defer close(done)
+		for {
+			var req Request
+			if err := c.ReadJSON(&req); err != nil {
+				return
+			}
+
+			select {
+			case s.replies <- s.handle(req):
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	for {
+		var msg Message
+		select {
+		case <-done:
+			return
+		case msg = <-s.replies:
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			if !s.wants(ev) {
+				continue
+			}
+			msg = Message{
+				Topic: ev.Topic,
+				Data:  ev.Data,
+			}
+		case st := <-statuses:
+			if !s.subscribed(TopicTxnStatus) {
+				continue
+			}
+			msg = Message{
+				Topic: TopicTxnStatus,
+				Data:  st,
+			}
+		}
+
+		msg.Dropped = s.takeDropped()
+
+		if err := c.SetWriteDeadline(time.Now().Add(s.cfg.WriteTimeout)); err != nil {
+			return
+		}
+
+		if err := c.WriteJSON(msg); err != nil {
+			logger.Info("Websocket client %s disconnected: %v", c.RemoteAddr(), err)
+			return
+		}
+	}
+}
+
+// handle applies the request, returns the reply message
+func (s *session) handle(req Request) Message {
+	if _, ok := topics[req.Topic]; !ok {
+		return Message{
+			Topic: TopicError,
+			Error: fmt.Sprintf("unknown topic %q", req.Topic),
+		}
+	}
+
+	for _, a := range req.Addresses {
+		if _, err := cipher.DecodeBase58Address(a); err != nil {
+			return Message{
+				Topic: TopicError,
+				Error: fmt.Sprintf("invalid address %q: %v", a, err),
+			}
+		}
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	switch req.Action {
+	case ActionSubscribe:
+		if req.Topic == TopicAddress && len(req.Addresses) == 0 {
+			return Message{
+				Topic: TopicError,
+				Error: "addresses are required for the address topic",
+			}
+		}
+
+		s.topics[req.Topic] = struct{}{}
+		for _, a := range req.Addresses {
+			s.addrs[a] = struct{}{}
+		}
+
+		return Message{
+			Topic: TopicSubscribed,
+			Data:  req,
+		}
+
+	case ActionUnsubscribe:
+		if req.Topic == TopicAddress && len(req.Addresses) > 0 {
+			for _, a := range req.Addresses {
+				delete(s.addrs, a)
+			}
+			if len(s.addrs) == 0 {
+				delete(s.topics, TopicAddress)
+			}
+		} else {
+			delete(s.topics, req.Topic)
+			if req.Topic == TopicAddress {
+				s.addrs = make(map[string]struct{})
+			}
+		}
+
+		return Message{
+			Topic: TopicUnsubscribed,
+			Data:  req,
+		}
+
+	default:
+		return Message{
+			Topic: TopicError,
+			Error: fmt.Sprintf("unknown action %q", req.Action),
+		}
+	}
+}
+
+// wants returns true if the client subscribed to the event. Events dropped
+// by the gateway are counted even if the client didn't subscribe to the event.
+func (s *session) wants(ev daemon.Event) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	s.dropped += ev.Dropped
+
+	if _, ok := s.topics[ev.Topic]; !ok {
+		return false
+	}
+
+	if ev.Topic == TopicAddress {
+		_, ok := s.addrs[ev.Address]
+		return ok
+	}
+
+	return true
+}
+
+func (s *session) subscribed(topic string) bool {
+	s.Lock()
+	defer s.Unlock()
+	_, ok := s.topics[topic]
+	return ok
+}
+
+func (s *session) takeDropped() uint64 {
+	s.Lock()
+	defer s.Unlock()
+	n := s.dropped
+	s.dropped = 0
+	return n
+}
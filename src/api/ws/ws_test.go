@@ -0,0 +1,248 @@
+package ws
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/daemon"
+	"github.com/skycoin/skycoin/src/testutil"
+)
+
+type fakeGateway struct {
+	events   chan daemon.Event
+	statuses chan daemon.TxnStatusEvent
+}
+
+func newFakeGateway() *fakeGateway {
+	return &fakeGateway{
+		events:   make(chan daemon.Event, 10),
+		statuses: make(chan daemon.TxnStatusEvent, 10),
+	}
+}
+
+func (fg *fakeGateway) SubscribeEvents(size int) (<-chan daemon.Event, func()) {
+	return fg.events, func() {}
+}
+
+func (fg *fakeGateway) SubscribeTxnStatus(size int) (<-chan daemon.TxnStatusEvent, func()) {
+	return fg.statuses, func() {}
+}
+
+func setupClient(t *testing.T, fg *fakeGateway) (*Client, func()) {
+	srv := httptest.NewServer(NewHandler(fg, NewConfig()))
+	c, err := Dial("ws://" + srv.Listener.Addr().String())
+	require.NoError(t, err)
+
+	return c, func() {
+		c.Close()
+		srv.Close()
+	}
+}
+
+func nextEvent(t *testing.T, c *Client) Event {
+	select {
+	case ev, ok := <-c.Events():
+		require.True(t, ok, "connection closed: %v", c.Err())
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+		return Event{}
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	fg := newFakeGateway()
+	c, teardown := setupClient(t, fg)
+	defer teardown()
+
+	require.NoError(t, c.Subscribe(TopicPeer))
+	ev := nextEvent(t, c)
+	require.Equal(t, TopicSubscribed, ev.Topic)
+
+	// Not subscribed, the block is skipped but the dropped events are reported
+	fg.events <- daemon.Event{Topic: TopicBlock, Dropped: 3}
+	fg.events <- daemon.Event{
+		Topic: TopicPeer,
+		Data:  daemon.PeerEvent{Address: "1.2.3.4:6000", Connected: true},
+	}
+
+	ev = nextEvent(t, c)
+	require.Equal(t, TopicPeer, ev.Topic)
+	require.Equal(t, uint64(3), ev.Dropped)
+
+	var pe daemon.PeerEvent
+	require.NoError(t, json.Unmarshal(ev.Data, &pe))
+	require.Equal(t, daemon.PeerEvent{Address: "1.2.3.4:6000", Connected: true}, pe)
+
+	require.NoError(t, c.Unsubscribe(TopicPeer))
+	ev = nextEvent(t, c)
+	require.Equal(t, TopicUnsubscribed, ev.Topic)
+}
+
+func TestSubscribeAddress(t *testing.T) {
+	fg := newFakeGateway()
+	c, teardown := setupClient(t, fg)
+	defer teardown()
+
+	watched := testutil.MakeAddress().String()
+	other := testutil.MakeAddress().String()
+
+	require.NoError(t, c.Subscribe(TopicAddress, watched))
+	require.Equal(t, TopicSubscribed, nextEvent(t, c).Topic)
+
+	for _, a := range []string{other, watched} {
+		fg.events <- daemon.Event{
+			Topic:   TopicAddress,
+			Address: a,
+			Data:    daemon.AddressActivity{Address: a, Txid: "abc"},
+		}
+	}
+
+	ev := nextEvent(t, c)
+	require.Equal(t, TopicAddress, ev.Topic)
+
+	var act daemon.AddressActivity
+	require.NoError(t, json.Unmarshal(ev.Data, &act))
+	require.Equal(t, watched, act.Address)
+}
+
+func TestSubscribeTxnStatus(t *testing.T) {
+	fg := newFakeGateway()
+	c, teardown := setupClient(t, fg)
+	defer teardown()
+
+	require.NoError(t, c.Subscribe(TopicTxnStatus))
+	require.Equal(t, TopicSubscribed, nextEvent(t, c).Topic)
+
+	fg.statuses <- daemon.TxnStatusEvent{Txid: "abc", State: daemon.TxnStatePending}
+
+	ev := nextEvent(t, c)
+	require.Equal(t, TopicTxnStatus, ev.Topic)
+
+	var st daemon.TxnStatusEvent
+	require.NoError(t, json.Unmarshal(ev.Data, &st))
+	require.Equal(t, "abc", st.Txid)
+	require.Equal(t, daemon.TxnStatePending, st.State)
+}
+
+func TestSubscribeInvalid(t *testing.T) {
+	fg := newFakeGateway()
+	c, teardown := setupClient(t, fg)
+	defer teardown()
+
+	cases := []struct {
+		name  string
+		topic string
+		addrs []string
+		err   string
+	}{
+		{
+			name:  "unknown topic",
+			topic: "foo",
+			err:   `unknown topic "foo"`,
+		},
+		{
+			name:  "no addresses",
+			topic: TopicAddress,
+			err:   "addresses are required for the address topic",
+		},
+		{
+			name:  "invalid address",
+			topic: TopicAddress,
+			addrs: []string{"xyz"},
+			err:   `invalid address "xyz"`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.NoError(t, c.Subscribe(tc.topic, tc.addrs...))
+			ev := nextEvent(t, c)
+			require.Equal(t, TopicError, ev.Topic)
+			require.True(t, strings.HasPrefix(ev.Error, tc.err), ev.Error)
+		})
+	}
+}
+
+func TestHandlerRequestTooLarge(t *testing.T) {
+	cfg := NewConfig()
+	cfg.MaxRequestSize = 128
+	srv := httptest.NewServer(NewHandler(newFakeGateway(), cfg))
+	defer srv.Close()
+
+	c, err := Dial("ws://" + srv.Listener.Addr().String())
+	require.NoError(t, err)
+	defer c.Close()
+
+	addrs := make([]string, 10)
+	for i := range addrs {
+		addrs[i] = testutil.MakeAddress().String()
+	}
+	require.NoError(t, c.Subscribe(TopicAddress, addrs...))
+
+	select {
+	case _, ok := <-c.Events():
+		require.False(t, ok)
+		require.Error(t, c.Err())
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection not closed")
+	}
+}
+
+func TestHandlerOrigin(t *testing.T) {
+	cfg := NewConfig()
+	cfg.AllowedOrigins = []string{"https://wallet.example.com"}
+	srv := httptest.NewServer(NewHandler(newFakeGateway(), cfg))
+	defer srv.Close()
+
+	cases := []struct {
+		name   string
+		origin string
+		status int
+	}{
+		{
+			name:   "no origin",
+			status: http.StatusSwitchingProtocols,
+		},
+		{
+			name:   "same host",
+			origin: "http://" + srv.Listener.Addr().String(),
+			status: http.StatusSwitchingProtocols,
+		},
+		{
+			name:   "allowed origin",
+			origin: "https://wallet.example.com",
+			status: http.StatusSwitchingProtocols,
+		},
+		{
+			name:   "other site",
+			origin: "https://evil.example.com",
+			status: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+			require.NoError(t, err)
+			req.Header.Set("Connection", "Upgrade")
+			req.Header.Set("Upgrade", "websocket")
+			req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+			req.Header.Set("Sec-WebSocket-Version", "13")
+			if tc.origin != "" {
+				req.Header.Set("Origin", tc.origin)
+			}
+
+			rsp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			rsp.Body.Close()
+			require.Equal(t, tc.status, rsp.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package daemon
+
+import (
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/visor/historydb"
+)
+
+// GetAddressStats returns the statistics of the address, nil if it has no confirmed transaction
+func (gw *Gateway) GetAddressStats(addr cipher.Address) (*historydb.AddressStats, error) {
+	var s *historydb.AddressStats
+	var err error
+	gw.strand("GetAddressStats", func() {
+		s, err = gw.d.Visor.v.GetAddressStats(addr)
+	})
+	return s, err
+}
+
+// GetRichlist returns up to limit addresses with the highest balances, richest first.
+// The distribution addresses are skipped unless includeDistribution is true.
+func (gw *Gateway) GetRichlist(limit int, includeDistribution bool) ([]historydb.AddressBalance, error) {
+	var balances []historydb.AddressBalance
+	var err error
+	gw.strand("GetRichlist", func() {
+		balances, err = gw.d.Visor.v.GetRichlist(limit, includeDistribution)
+	})
+	return balances, err
+}
+
+// AddressCount returns the number of addresses with a non zero balance
+func (gw *Gateway) AddressCount() (uint64, error) {
+	var n uint64
+	var err error
+	gw.strand("AddressCount", func() {
+		n, err = gw.d.Visor.v.AddressCount()
+	})
+	return n, err
+}
@@ -0,0 +1,177 @@
+package daemon
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/util/utc"
+	"github.com/skycoin/skycoin/src/visor"
+)
+
+// maxBlockMetrics is the number of created blocks whose metrics are kept
+const maxBlockMetrics = 100
+
+// blockProducer holds the block policy of the master node, which can be replaced while
+// the daemon runs, and the metrics of the last created blocks
+type blockProducer struct {
+	sync.Mutex
+	policy  visor.BlockPolicy
+	metrics []visor.BlockMetrics
+	// receives a value when the policy is replaced, so the block creation ticker can be reset
+	policyChanged chan struct{}
+}
+
+func newBlockProducer(p visor.BlockPolicy) (*blockProducer, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &blockProducer{
+		policy:        p,
+		policyChanged: make(chan struct{}, 1),
+	}, nil
+}
+
+func (bp *blockProducer) getPolicy() visor.BlockPolicy {
+	bp.Lock()
+	defer bp.Unlock()
+	return bp.policy
+}
+
+func (bp *blockProducer) setPolicy(p visor.BlockPolicy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	bp.Lock()
+	bp.policy = p
+	bp.Unlock()
+
+	select {
+	case bp.policyChanged <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+func (bp *blockProducer) record(m visor.BlockMetrics) {
+	bp.Lock()
+	defer bp.Unlock()
+
+	bp.metrics = append(bp.metrics, m)
+	if len(bp.metrics) > maxBlockMetrics {
+		bp.metrics = bp.metrics[len(bp.metrics)-maxBlockMetrics:]
+	}
+}
+
+// getMetrics returns the metrics of the last created blocks, newest first
+func (bp *blockProducer) getMetrics() []visor.BlockMetrics {
+	bp.Lock()
+	defer bp.Unlock()
+
+	metrics := make([]visor.BlockMetrics, 0, len(bp.metrics))
+	for i := len(bp.metrics) - 1; i >= 0; i-- {
+		metrics = append(metrics, bp.metrics[i])
+	}
+	return metrics
+}
+
+// SetBlockPolicy replaces the block policy of the master node
+func (vs *Visor) SetBlockPolicy(p visor.BlockPolicy) error {
+	if vs.producer == nil {
+		bp, err := newBlockProducer(p)
+		if err != nil {
+			return err
+		}
+		vs.producer = bp
+		return nil
+	}
+
+	if err := vs.producer.setPolicy(p); err != nil {
+		return err
+	}
+
+	logger.Info("Block policy updated: %+v", p)
+	return nil
+}
+
+// BlockPolicyChanged returns a channel which receives a value when the block policy
+// is replaced, the block creation loop resets its ticker to the new interval
+func (vs *Visor) BlockPolicyChanged() <-chan struct{} {
+	if vs.producer == nil {
+		return nil
+	}
+	return vs.producer.policyChanged
+}
+
+// BlockInterval returns the block creation interval of the block policy
+func (vs *Visor) BlockInterval() time.Duration {
+	if vs.producer == nil {
+		return visor.NewBlockPolicy().Interval
+	}
+	return vs.producer.getPolicy().Interval
+}
+
+// ProduceBlock creates a block according to the block policy, executes it and sends it
+// to the network. Returns ErrNoBlockTxns if the policy doesn't allow a block to be created.
+func (vs *Visor) ProduceBlock(pool *Pool) (coin.SignedBlock, error) {
+	if vs.Config.DisableNetworking {
+		return coin.SignedBlock{}, errors.New("Visor disabled")
+	}
+
+	if vs.producer == nil {
+		return coin.SignedBlock{}, errors.New("Block policy is not set")
+	}
+
+	sb, metrics, err := vs.v.CreateBlockWithPolicy(uint64(utc.UnixNow()), vs.producer.getPolicy())
+	if err != nil {
+		return sb, err
+	}
+
+	if err := vs.ExecuteSignedBlockWithEvents(sb); err != nil {
+		return sb, err
+	}
+
+	vs.producer.record(metrics)
+	logger.Info("Created block %d with %d txns, %d bytes, %d fee in %dms",
+		metrics.Seq, metrics.Txns, metrics.Size, metrics.Fee, metrics.CreationMs)
+
+	return sb, vs.broadcastBlock(sb, pool)
+}
+
+// GetBlockPolicy returns the block policy of the master node
+func (gw *Gateway) GetBlockPolicy() visor.BlockPolicy {
+	var p visor.BlockPolicy
+	gw.strand("GetBlockPolicy", func() {
+		if gw.d.Visor.producer == nil {
+			p = visor.NewBlockPolicy()
+			return
+		}
+		p = gw.d.Visor.producer.getPolicy()
+	})
+	return p
+}
+
+// SetBlockPolicy replaces the block policy of the master node
+func (gw *Gateway) SetBlockPolicy(p visor.BlockPolicy) error {
+	var err error
+	gw.strand("SetBlockPolicy", func() {
+		err = gw.d.Visor.SetBlockPolicy(p)
+	})
+	return err
+}
+
+// GetBlockMetrics returns the metrics of the last blocks created by the master node, newest first
+func (gw *Gateway) GetBlockMetrics() []visor.BlockMetrics {
+	var metrics []visor.BlockMetrics
+	gw.strand("GetBlockMetrics", func() {
+		if gw.d.Visor.producer == nil {
+			return
+		}
+		metrics = gw.d.Visor.producer.getMetrics()
+	})
+	return metrics
+}
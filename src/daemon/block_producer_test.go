@@ -0,0 +1,46 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/visor"
+)
+
+func TestBlockProducer(t *testing.T) {
+	bad := visor.NewBlockPolicy()
+	bad.MinTxns = 0
+	_, err := newBlockProducer(bad)
+	require.Error(t, err)
+
+	bp, err := newBlockProducer(visor.NewBlockPolicy())
+	require.NoError(t, err)
+	require.Equal(t, visor.NewBlockPolicy(), bp.getPolicy())
+
+	// Invalid policies are not applied
+	require.Error(t, bp.setPolicy(bad))
+	require.Equal(t, visor.NewBlockPolicy(), bp.getPolicy())
+
+	p := visor.NewBlockPolicy()
+	p.Interval = time.Minute
+	require.NoError(t, bp.setPolicy(p))
+	require.Equal(t, p, bp.getPolicy())
+
+	select {
+	case <-bp.policyChanged:
+	default:
+		t.Fatal("policy change was not signalled")
+	}
+
+	// Only the last maxBlockMetrics are kept, newest first
+	for i := 0; i < maxBlockMetrics+10; i++ {
+		bp.record(visor.BlockMetrics{Seq: uint64(i)})
+	}
+
+	metrics := bp.getMetrics()
+	require.Len(t, metrics, maxBlockMetrics)
+	require.Equal(t, uint64(maxBlockMetrics+9), metrics[0].Seq)
+	require.Equal(t, uint64(10), metrics[maxBlockMetrics-1].Seq)
+}
@@ -0,0 +1,351 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/visor"
+)
+
+// MaxBlockStatsWindow is the maximum number of blocks of a blockchain statistics window,
+// and the number of block statistics kept in the cache
+const MaxBlockStatsWindow = 10000
+
+// blockStatsBatchSize is the number of blocks of a window aggregated per strand call,
+// so that a window of uncached blocks doesn't hold the daemon loop
+const blockStatsBatchSize = 200
+
+// ErrNoBlocksInWindow is returned when no block is in the statistics window
+var ErrNoBlocksInWindow = errors.New("no blocks in the window")
+
+// BlockchainStats are the statistics of the blocks FromSeq to ToSeq
+type BlockchainStats struct {
+	FromSeq  uint64
+	ToSeq    uint64
+	FromTime uint64
+	ToTime   uint64
+	Blocks   uint64
+	Txns     uint64
+	// Average number of transactions per block
+	TxnsPerBlock float64
+	// Average number of seconds between two consecutive blocks, 0 for a single block
+	AvgBlockInterval float64
+	// Coins spent by the transactions, in droplets
+	CoinsMoved uint64
+	// Coin hours burned by the transaction fees
+	HoursBurned uint64
+	// Distinct addresses spending from or receiving to in the window
+	ActiveAddresses uint64
+	// Number of outputs created and spent, their difference is the growth of the unspent output set
+	UxOutsCreated uint64
+	UxOutsSpent   uint64
+}
+
+type blockStatsGetter interface {
+	GetBlockStats(b *coin.SignedBlock) (*visor.BlockStats, error)
+}
+
+// blockStatsCache caches the statistics of the blocks so that the statistics windows are
+// only computed once per block, the blocks are executed as they arrive. Entries are checked
+// against the block hash, so blocks replaced by a rollback are computed again.
+type blockStatsCache struct {
+	sync.Mutex
+	size int
+	// highest seq cached, the window of size blocks ending at head is never evicted
+	head  uint64
+	stats map[uint64]*visor.BlockStats
+}
+
+func newBlockStatsCache(size int) *blockStatsCache {
+	return &blockStatsCache{
+		size:  size,
+		stats: make(map[uint64]*visor.BlockStats),
+	}
+}
+
+// get returns the statistics of the block, computing and caching them if they are not cached
+func (bc *blockStatsCache) get(bs blockStatsGetter, b *coin.SignedBlock) (*visor.BlockStats, error) {
+	bc.Lock()
+	s, ok := bc.stats[b.Seq()]
+	bc.Unlock()
+
+	if ok && s.Hash == b.HashHeader() {
+		return s, nil
+	}
+
+	s, err := bs.GetBlockStats(b)
+	if err != nil {
+		return nil, err
+	}
+
+	bc.add(s)
+	return s, nil
+}
+
+// add caches the statistics. When the cache is full, the entries before the head window
+// are evicted, the statistics of a block before the head window are not cached if there
+// is no such entry to evict.
+func (bc *blockStatsCache) add(s *visor.BlockStats) {
+	bc.Lock()
+	defer bc.Unlock()
+
+	if s.Seq > bc.head {
+		bc.head = s.Seq
+	}
+
+	bc.stats[s.Seq] = s
+
+	for seq := range bc.stats {
+		if len(bc.stats) <= bc.size {
+			return
+		}
+		if seq != s.Seq && !bc.inHeadWindow(seq) {
+			delete(bc.stats, seq)
+		}
+	}
+
+	if len(bc.stats) > bc.size {
+		delete(bc.stats, s.Seq)
+	}
+}
+
+// inHeadWindow returns true if the block is one of the last size blocks, must be called with the lock held
+func (bc *blockStatsCache) inHeadWindow(seq uint64) bool {
+	return seq+uint64(bc.size) > bc.head
+}
+
+// blockStatsWindow is a statistics window being aggregated
+type blockStatsWindow struct {
+	stats *BlockchainStats
+	addrs map[cipher.Address]struct{}
+	// seq of the next block to aggregate
+	next uint64
+}
+
+// newBlockStatsWindow creates the window of the blocks from to to
+func newBlockStatsWindow(from, to uint64) (*blockStatsWindow, error) {
+	if from > to {
+		return nil, fmt.Errorf("from seq %d is after to seq %d", from, to)
+	}
+
+	if to-from+1 > MaxBlockStatsWindow {
+		return nil, fmt.Errorf("window must not exceed %d blocks", MaxBlockStatsWindow)
+	}
+
+	return &blockStatsWindow{
+		stats: &BlockchainStats{
+			FromSeq: from,
+			ToSeq:   to,
+			Blocks:  to - from + 1,
+		},
+		addrs: make(map[cipher.Address]struct{}),
+		next:  from,
+	}, nil
+}
+
+// done returns true once all the blocks of the window are aggregated
+func (w *blockStatsWindow) done() bool {
+	return w.next > w.stats.ToSeq
+}
+
+// result returns the statistics of the aggregated window
+func (w *blockStatsWindow) result() *BlockchainStats {
+	stats := *w.stats
+	stats.ActiveAddresses = uint64(len(w.addrs))
+	stats.TxnsPerBlock = float64(stats.Txns) / float64(stats.Blocks)
+	if stats.Blocks > 1 {
+		stats.AvgBlockInterval = float64(stats.ToTime-stats.FromTime) / float64(stats.Blocks-1)
+	}
+	return &stats
+}
+
+// aggregate adds the statistics of the next n blocks of the window
+func (bc *blockStatsCache) aggregate(w *blockStatsWindow, blocks blockBySeqGetter, bs blockStatsGetter, n uint64) error {
+	stats := w.stats
+	for ; n > 0 && !w.done(); n-- {
+		seq := w.next
+
+		b, err := blocks.GetBlockBySeq(seq)
+		if err != nil {
+			return err
+		}
+		if b == nil {
+			return fmt.Errorf("block %d does not exist", seq)
+		}
+
+		s, err := bc.get(bs, b)
+		if err != nil {
+			return err
+		}
+
+		if seq == stats.FromSeq {
+			stats.FromTime = s.Time
+		}
+		if seq == stats.ToSeq {
+			stats.ToTime = s.Time
+		}
+
+		stats.Txns += s.TxnCount
+		stats.CoinsMoved += s.CoinsMoved
+		stats.HoursBurned += s.HoursBurned
+		stats.UxOutsCreated += s.UxOutsCreated
+		stats.UxOutsSpent += s.UxOutsSpent
+		for _, a := range s.Addresses {
+			w.addrs[a] = struct{}{}
+		}
+
+		w.next++
+	}
+
+	return nil
+}
+
+// seqRangeByTime returns the seqs of the first and last blocks with a time between from and to,
+// searching the blocks up to head. The block times are increasing.
+func seqRangeByTime(blocks blockBySeqGetter, head, from, to uint64) (uint64, uint64, error) {
+	if from > to {
+		return 0, 0, fmt.Errorf("from time %d is after to time %d", from, to)
+	}
+
+	// returns the first seq whose block time matches after, head+1 if none
+	search := func(after func(uint64) bool) (uint64, error) {
+		lo, hi := uint64(0), head+1
+		for lo < hi {
+			mid := lo + (hi-lo)/2
+			b, err := blocks.GetBlockBySeq(mid)
+			if err != nil {
+				return 0, err
+			}
+			if b == nil {
+				return 0, fmt.Errorf("block %d does not exist", mid)
+			}
+
+			if after(b.Head.Time) {
+				hi = mid
+			} else {
+				lo = mid + 1
+			}
+		}
+		return lo, nil
+	}
+
+	first, err := search(func(t uint64) bool { return t >= from })
+	if err != nil {
+		return 0, 0, err
+	}
+
+	end, err := search(func(t uint64) bool { return t > to })
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if first >= end {
+		return 0, 0, ErrNoBlocksInWindow
+	}
+
+	return first, end - 1, nil
+}
+
+// cacheBlockStats computes the statistics of an executed block, so the statistics
+// windows ending at the head are updated incrementally
+func (vs *Visor) cacheBlockStats(sb *coin.SignedBlock) {
+	if _, err := vs.blockStats.get(vs.v, sb); err != nil {
+		logger.Error("Compute statistics of block %d failed: %v", sb.Seq(), err)
+	}
+}
+
+// aggregateBlockStats computes the statistics of the blocks from to to, a batch of blocks per strand call
+func (gw *Gateway) aggregateBlockStats(name string, from, to uint64) (*BlockchainStats, error) {
+	w, err := newBlockStatsWindow(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	for !w.done() {
+		gw.strand(name, func() {
+			err = gw.d.Visor.blockStats.aggregate(w, gw.d.Visor.v.Blockchain, gw.d.Visor.v, blockStatsBatchSize)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return w.result(), nil
+}
+
+// GetBlockchainStats returns the statistics of the blocks fromSeq to toSeq,
+// toSeq is lowered to the head seq
+func (gw *Gateway) GetBlockchainStats(fromSeq, toSeq uint64) (*BlockchainStats, error) {
+	var err error
+	gw.strand("GetBlockchainStats", func() {
+		var head *coin.SignedBlock
+		head, err = gw.d.Visor.v.Blockchain.Head()
+		if err != nil {
+			return
+		}
+
+		if fromSeq > head.Seq() {
+			err = ErrNoBlocksInWindow
+			return
+		}
+
+		if toSeq > head.Seq() {
+			toSeq = head.Seq()
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return gw.aggregateBlockStats("GetBlockchainStats", fromSeq, toSeq)
+}
+
+// GetBlockchainStatsByTime returns the statistics of the blocks with a time between fromTime and toTime
+func (gw *Gateway) GetBlockchainStatsByTime(fromTime, toTime uint64) (*BlockchainStats, error) {
+	var from, to uint64
+	var err error
+	gw.strand("GetBlockchainStatsByTime", func() {
+		var head *coin.SignedBlock
+		head, err = gw.d.Visor.v.Blockchain.Head()
+		if err != nil {
+			return
+		}
+
+		from, to, err = seqRangeByTime(gw.d.Visor.v.Blockchain, head.Seq(), fromTime, toTime)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return gw.aggregateBlockStats("GetBlockchainStatsByTime", from, to)
+}
+
+// GetLastBlockchainStats returns the statistics of the last n blocks
+func (gw *Gateway) GetLastBlockchainStats(n uint64) (*BlockchainStats, error) {
+	if n == 0 {
+		return nil, ErrNoBlocksInWindow
+	}
+
+	var from, to uint64
+	var err error
+	gw.strand("GetLastBlockchainStats", func() {
+		var head *coin.SignedBlock
+		head, err = gw.d.Visor.v.Blockchain.Head()
+		if err != nil {
+			return
+		}
+
+		to = head.Seq()
+		if to+1 > n {
+			from = to + 1 - n
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return gw.aggregateBlockStats("GetLastBlockchainStats", from, to)
+}
@@ -0,0 +1,204 @@
+package daemon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/testutil"
+	"github.com/skycoin/skycoin/src/visor"
+)
+
+// fakeBlockStats computes the statistics of a block from its seq and counts the computations
+type fakeBlockStats struct {
+	addrs []cipher.Address
+	calls int
+}
+
+func (fs *fakeBlockStats) GetBlockStats(b *coin.SignedBlock) (*visor.BlockStats, error) {
+	fs.calls++
+	return &visor.BlockStats{
+		Seq:           b.Seq(),
+		Hash:          b.HashHeader(),
+		Time:          b.Head.Time,
+		TxnCount:      b.Seq(),
+		CoinsMoved:    b.Seq() * 1e6,
+		HoursBurned:   b.Seq() * 10,
+		UxOutsCreated: 2 * b.Seq(),
+		UxOutsSpent:   b.Seq(),
+		Addresses:     fs.addrs[b.Seq()%uint64(len(fs.addrs)):],
+	}, nil
+}
+
+func makeStatsBlocks(n int) fakeBlocks {
+	blocks := make(fakeBlocks)
+	for i := 0; i < n; i++ {
+		blocks[uint64(i)] = &coin.SignedBlock{
+			Block: coin.Block{
+				Head: coin.BlockHeader{
+					BkSeq: uint64(i),
+					Time:  1000 + uint64(i)*10,
+				},
+			},
+		}
+	}
+	return blocks
+}
+
+// aggregateWindow aggregates the window batch blocks at a time, returns the number of batches
+func aggregateWindow(cache *blockStatsCache, blocks fakeBlocks, bs blockStatsGetter, from, to, batch uint64) (*BlockchainStats, int, error) {
+	w, err := newBlockStatsWindow(from, to)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var batches int
+	for !w.done() {
+		if err := cache.aggregate(w, blocks, bs, batch); err != nil {
+			return nil, batches, err
+		}
+		batches++
+	}
+
+	return w.result(), batches, nil
+}
+
+func TestBlockStatsCacheAggregate(t *testing.T) {
+	blocks := makeStatsBlocks(10)
+	bs := &fakeBlockStats{
+		addrs: []cipher.Address{testutil.MakeAddress(), testutil.MakeAddress(), testutil.MakeAddress()},
+	}
+	cache := newBlockStatsCache(MaxBlockStatsWindow)
+
+	stats, batches, err := aggregateWindow(cache, blocks, bs, 2, 5, 3)
+	require.NoError(t, err)
+	require.Equal(t, 2, batches)
+	require.Equal(t, &BlockchainStats{
+		FromSeq:          2,
+		ToSeq:            5,
+		FromTime:         1020,
+		ToTime:           1050,
+		Blocks:           4,
+		Txns:             14,
+		TxnsPerBlock:     3.5,
+		AvgBlockInterval: 10,
+		CoinsMoved:       14e6,
+		HoursBurned:      140,
+		ActiveAddresses:  3,
+		UxOutsCreated:    28,
+		UxOutsSpent:      14,
+	}, stats)
+	require.Equal(t, 4, bs.calls)
+
+	// The cached blocks are not computed again as the window moves
+	_, _, err = aggregateWindow(cache, blocks, bs, 3, 6, 10)
+	require.NoError(t, err)
+	require.Equal(t, 5, bs.calls)
+
+	// A block replaced by a rollback is computed again
+	blocks[6] = &coin.SignedBlock{
+		Block: coin.Block{
+			Head: coin.BlockHeader{
+				BkSeq: 6,
+				Time:  1065,
+			},
+		},
+	}
+	stats, _, err = aggregateWindow(cache, blocks, bs, 6, 6, 10)
+	require.NoError(t, err)
+	require.Equal(t, 6, bs.calls)
+	require.Equal(t, uint64(1065), stats.ToTime)
+	require.Equal(t, float64(0), stats.AvgBlockInterval)
+
+	_, _, err = aggregateWindow(cache, blocks, bs, 5, 4, 10)
+	require.Error(t, err)
+
+	_, _, err = aggregateWindow(cache, blocks, bs, 0, MaxBlockStatsWindow, 10)
+	testutil.RequireError(t, err, "window must not exceed 10000 blocks")
+
+	_, _, err = aggregateWindow(cache, blocks, bs, 8, 12, 10)
+	testutil.RequireError(t, err, "block 10 does not exist")
+}
+
+func TestBlockStatsCacheEviction(t *testing.T) {
+	blocks := makeStatsBlocks(10)
+	bs := &fakeBlockStats{
+		addrs: []cipher.Address{testutil.MakeAddress()},
+	}
+	cache := newBlockStatsCache(3)
+
+	for seq := uint64(0); seq < 10; seq++ {
+		_, err := cache.get(bs, blocks[seq])
+		require.NoError(t, err)
+		require.True(t, len(cache.stats) <= 3)
+	}
+
+	// The last blocks are kept
+	for seq := uint64(7); seq < 10; seq++ {
+		require.Contains(t, cache.stats, seq)
+	}
+
+	// Blocks before the head window don't evict it and are not cached
+	_, err := cache.get(bs, blocks[0])
+	require.NoError(t, err)
+	require.Len(t, cache.stats, 3)
+	require.NotContains(t, cache.stats, uint64(0))
+	for seq := uint64(7); seq < 10; seq++ {
+		require.Contains(t, cache.stats, seq)
+	}
+
+	// They are cached while the head window isn't full, and evicted first
+	cache = newBlockStatsCache(4)
+	for _, seq := range []uint64{7, 8, 9, 0, 1} {
+		_, err := cache.get(bs, blocks[seq])
+		require.NoError(t, err)
+	}
+	require.Len(t, cache.stats, 4)
+	require.Contains(t, cache.stats, uint64(1))
+	require.NotContains(t, cache.stats, uint64(0))
+
+	_, err = cache.get(bs, blocks[6])
+	require.NoError(t, err)
+	require.Len(t, cache.stats, 4)
+	for seq := uint64(6); seq < 10; seq++ {
+		require.Contains(t, cache.stats, seq)
+	}
+}
+
+func TestSeqRangeByTime(t *testing.T) {
+	// Block times are 1000, 1010, ..., 1090
+	blocks := makeStatsBlocks(10)
+
+	cases := []struct {
+		name     string
+		from, to uint64
+		first    uint64
+		last     uint64
+		err      error
+	}{
+		{"all", 0, 5000, 0, 9, nil},
+		{"exact", 1020, 1050, 2, 5, nil},
+		{"between blocks", 1015, 1055, 2, 5, nil},
+		{"single", 1030, 1030, 3, 3, nil},
+		{"before", 0, 999, 0, 0, ErrNoBlocksInWindow},
+		{"after", 1091, 2000, 0, 0, ErrNoBlocksInWindow},
+		{"gap", 1011, 1019, 0, 0, ErrNoBlocksInWindow},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			first, last, err := seqRangeByTime(blocks, 9, tc.from, tc.to)
+			require.Equal(t, tc.err, err)
+			if err != nil {
+				return
+			}
+			require.Equal(t, tc.first, first)
+			require.Equal(t, tc.last, last)
+		})
+	}
+
+	_, _, err := seqRangeByTime(blocks, 9, 2000, 1000)
+	require.Error(t, err)
+}
@@ -0,0 +1,122 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// errCallbackAddrNotAllowed is returned when a callback url resolves to an address the node must not connect to
+var errCallbackAddrNotAllowed = errors.New("callback address is not allowed")
+
+// privateNets are the ranges of the private networks, the loopback and link-local
+// ranges are checked with the net.IP methods
+var privateNets = mustParseCIDRs(
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"100.64.0.0/10",
+	"fc00::/7",
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+// isPublicIP returns false for the loopback, link-local, private, multicast and unspecified addresses
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+
+	for _, n := range privateNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// validateCallbackURL checks the url of a webhook or callback is an http(s) url
+func validateCallbackURL(rawurl string) error {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return fmt.Errorf("invalid url: %v", err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid url scheme %q", u.Scheme)
+	}
+
+	if u.Hostname() == "" {
+		return errors.New("invalid url: missing host")
+	}
+
+	return nil
+}
+
+// newCallbackClient creates the http client of the webhooks and callbacks. Since their urls
+// are given by API clients, it only connects to public addresses, unless the host of the url
+// is one of allowedHosts. The address is checked after the host is resolved and on every
+// redirect, so a public name resolving to a private address is refused.
+func newCallbackClient(timeout time.Duration, allowedHosts []string) *http.Client {
+	allowed := make(map[string]struct{}, len(allowedHosts))
+	for _, h := range allowedHosts {
+		allowed[h] = struct{}{}
+	}
+
+	dialer := &net.Dialer{
+		Timeout: timeout,
+	}
+
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := allowed[host]; ok {
+			return dialer.DialContext(ctx, network, addr)
+		}
+
+		ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ip := range ips {
+			if !isPublicIP(ip.IP) {
+				return nil, fmt.Errorf("%v: %s resolves to %s", errCallbackAddrNotAllowed, host, ip.IP)
+			}
+		}
+
+		if len(ips) == 0 {
+			return nil, fmt.Errorf("no address found for %s", host)
+		}
+
+		// Connect to the checked address, resolving again could return another one
+		return dialer.DialContext(ctx, network, net.JoinHostPort(ips[0].IP.String(), port))
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dial,
+			TLSHandshakeTimeout: timeout,
+		},
+	}
+}
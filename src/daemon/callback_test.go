@@ -0,0 +1,71 @@
+package daemon
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	for _, tc := range []struct {
+		ip     string
+		public bool
+	}{
+		{"8.8.8.8", true},
+		{"2001:4860:4860::8888", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"0.0.0.0", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"100.64.0.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"224.0.0.1", false},
+	} {
+		t.Run(tc.ip, func(t *testing.T) {
+			require.Equal(t, tc.public, isPublicIP(net.ParseIP(tc.ip)))
+		})
+	}
+}
+
+func TestValidateCallbackURL(t *testing.T) {
+	require.NoError(t, validateCallbackURL("https://example.com/hook"))
+	require.Error(t, validateCallbackURL("ftp://example.com"))
+	require.Error(t, validateCallbackURL("http://"))
+	require.Error(t, validateCallbackURL("://"))
+}
+
+func TestCallbackClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	// Loopback is refused
+	client := newCallbackClient(time.Second, nil)
+	_, err := client.Get(srv.URL)
+	require.Error(t, err)
+	require.True(t, strings.Contains(err.Error(), errCallbackAddrNotAllowed.Error()), err.Error())
+
+	// Unless the host is allowed
+	client = newCallbackClient(time.Second, []string{"127.0.0.1"})
+	rsp, err := client.Get(srv.URL)
+	require.NoError(t, err)
+	rsp.Body.Close()
+
+	// A redirect to a refused address is refused
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://localhost:"+strings.Split(srv.Listener.Addr().String(), ":")[1], http.StatusFound)
+	}))
+	defer redirect.Close()
+
+	_, err = client.Get(redirect.URL)
+	require.Error(t, err)
+	require.True(t, strings.Contains(err.Error(), errCallbackAddrNotAllowed.Error()), err.Error())
+}
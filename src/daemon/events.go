@@ -0,0 +1,284 @@
+package daemon
+
+import (
+	"sync"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/visor"
+)
+
+// Event topics
+const (
+	// EventTopicBlock a block was executed, data is a *visor.ReadableBlock
+	EventTopicBlock = "block"
+	// EventTopicUnconfirmedTxn a transaction entered the unconfirmed pool, data is a *visor.ReadableTransaction
+	EventTopicUnconfirmedTxn = "unconfirmed_txn"
+	// EventTopicAddress a transaction spends from or sends to an address, data is an AddressActivity
+	EventTopicAddress = "address"
+	// EventTopicPeer a peer connected or disconnected, data is a PeerEvent
+	EventTopicPeer = "peer"
+	// EventTopicSync the blockchain sync progressed, data is a SyncProgress
+	EventTopicSync = "sync"
+)
+
+// Event is published to the event subscribers
+type Event struct {
+	Topic string
+	Data  interface{}
+	// Address of an EventTopicAddress event
+	Address string
+	// Number of events the subscriber missed before this one because it didn't keep up
+	Dropped uint64
+}
+
+// AddressActivity is a transaction spending from or sending to an address
+type AddressActivity struct {
+	Address   string `json:"address"`
+	Txid      string `json:"txid"`
+	Confirmed bool   `json:"confirmed"`
+	// Seq of the block of a confirmed transaction
+	BlockSeq uint64 `json:"block_seq"`
+}
+
+// PeerEvent is a peer connection or disconnection
+type PeerEvent struct {
+	Address   string `json:"address"`
+	Connected bool   `json:"connected"`
+}
+
+// SyncProgress is the local chain height compared to the highest height announced by peers
+type SyncProgress struct {
+	Current uint64 `json:"current"`
+	Highest uint64 `json:"highest"`
+}
+
+type eventSub struct {
+	ch      chan Event
+	dropped uint64
+}
+
+// eventBus publishes events to the subscribers without blocking.
+// A subscriber whose buffer is full misses the events, the count of missed
+// events is set on the next event it receives.
+type eventBus struct {
+	sync.Mutex
+	subs map[*eventSub]struct{}
+	// last published sync progress
+	lastSync SyncProgress
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{
+		subs: make(map[*eventSub]struct{}),
+	}
+}
+
+// subscribe returns a channel receiving the events, and a function to unsubscribe which closes it
+func (eb *eventBus) subscribe(size int) (<-chan Event, func()) {
+	sub := &eventSub{
+		ch: make(chan Event, size),
+	}
+
+	eb.Lock()
+	eb.subs[sub] = struct{}{}
+	eb.Unlock()
+
+	var once sync.Once
+	return sub.ch, func() {
+		once.Do(func() {
+			eb.Lock()
+			delete(eb.subs, sub)
+			close(sub.ch)
+			eb.Unlock()
+		})
+	}
+}
+
+func (eb *eventBus) publish(ev Event) {
+	eb.Lock()
+	defer eb.Unlock()
+
+	for sub := range eb.subs {
+		e := ev
+		e.Dropped = sub.dropped
+		select {
+		case sub.ch <- e:
+			sub.dropped = 0
+		default:
+			sub.dropped++
+		}
+	}
+}
+
+// publishSync publishes the sync progress if it changed
+func (eb *eventBus) publishSync(p SyncProgress) {
+	eb.Lock()
+	changed := eb.lastSync != p
+	eb.lastSync = p
+	eb.Unlock()
+
+	if changed {
+		eb.publish(Event{
+			Topic: EventTopicSync,
+			Data:  p,
+		})
+	}
+}
+
+// publishAddressActivity publishes an address event for each distinct address of the transaction.
+// The spent outputs are looked up with getUx, inputs which are not found are skipped.
+func (eb *eventBus) publishAddressActivity(txn coin.Transaction, getUx func(cipher.SHA256) (coin.UxOut, bool), confirmed bool, seq uint64) {
+	addrs := make(map[cipher.Address]struct{})
+	var ordered []cipher.Address
+	add := func(a cipher.Address) {
+		if _, ok := addrs[a]; ok {
+			return
+		}
+		addrs[a] = struct{}{}
+		ordered = append(ordered, a)
+	}
+
+	for _, in := range txn.In {
+		if ux, ok := getUx(in); ok {
+			add(ux.Body.Address)
+		}
+	}
+
+	for _, o := range txn.Out {
+		add(o.Address)
+	}
+
+	txid := txn.Hash().Hex()
+	for _, a := range ordered {
+		eb.publish(Event{
+			Topic:   EventTopicAddress,
+			Address: a.String(),
+			Data: AddressActivity{
+				Address:   a.String(),
+				Txid:      txid,
+				Confirmed: confirmed,
+				BlockSeq:  seq,
+			},
+		})
+	}
+}
+
+// ExecuteSignedBlockWithEvents executes the block, publishes the block and address events,
+// notifies the webhooks, refreshes the tracked transactions and updates the block statistics
+func (vs *Visor) ExecuteSignedBlockWithEvents(sb coin.SignedBlock) error {
+	if vs.events == nil {
+		if err := vs.executeSignedBlock(sb); err != nil {
+			return err
+		}
+		vs.NotifyWebhooks(&sb.Block)
+		vs.RefreshTrackedTxns()
+		vs.cacheBlockStats(&sb)
+		return nil
+	}
+
+	// The outputs spent by the block are removed from the unspent pool when it is executed
+	spent := make(map[cipher.SHA256]coin.UxOut)
+	for _, txn := range sb.Body.Transactions {
+		for _, in := range txn.In {
+			if ux, ok := vs.v.Blockchain.Unspent().Get(in); ok {
+				spent[in] = ux
+			}
+		}
+	}
+
+	if err := vs.executeSignedBlock(sb); err != nil {
+		return err
+	}
+
+	vs.NotifyWebhooks(&sb.Block)
+	vs.RefreshTrackedTxns()
+	vs.cacheBlockStats(&sb)
+
+	rb, err := visor.NewReadableBlock(&sb.Block)
+	if err != nil {
+		logger.Error("Create readable block %d failed: %v", sb.Seq(), err)
+		return nil
+	}
+
+	vs.events.publish(Event{
+		Topic: EventTopicBlock,
+		Data:  rb,
+	})
+
+	getUx := func(h cipher.SHA256) (coin.UxOut, bool) {
+		ux, ok := spent[h]
+		return ux, ok
+	}
+	for _, txn := range sb.Body.Transactions {
+		vs.events.publishAddressActivity(txn, getUx, true, sb.Seq())
+	}
+
+	return nil
+}
+
+// PublishUnconfirmedTxn publishes the unconfirmed transaction and address events,
+// called when a transaction enters the unconfirmed pool
+func (vs *Visor) PublishUnconfirmedTxn(txn coin.Transaction) {
+	if vs.events == nil {
+		return
+	}
+
+	rtxn, err := visor.NewReadableTransaction(&visor.Transaction{
+		Txn:    txn,
+		Status: visor.TransactionStatus{Unconfirmed: true},
+	})
+	if err != nil {
+		logger.Error("Create readable transaction %s failed: %v", txn.Hash().Hex(), err)
+		return
+	}
+
+	vs.events.publish(Event{
+		Topic: EventTopicUnconfirmedTxn,
+		Data:  rtxn,
+	})
+
+	vs.events.publishAddressActivity(txn, vs.v.Blockchain.Unspent().Get, false, 0)
+}
+
+// PublishPeerEvent publishes a peer connection or disconnection
+func (vs *Visor) PublishPeerEvent(addr string, connected bool) {
+	if vs.events == nil {
+		return
+	}
+
+	vs.events.publish(Event{
+		Topic: EventTopicPeer,
+		Data: PeerEvent{
+			Address:   addr,
+			Connected: connected,
+		},
+	})
+}
+
+// PublishSyncProgress publishes the sync progress when it changes
+func (vs *Visor) PublishSyncProgress(current, highest uint64) {
+	if vs.events == nil {
+		return
+	}
+
+	vs.events.publishSync(SyncProgress{
+		Current: current,
+		Highest: highest,
+	})
+}
+
+// EnableEvents starts publishing the daemon events to the subscribers.
+// Must be called before the daemon runs.
+func (vs *Visor) EnableEvents() {
+	vs.events = newEventBus()
+}
+
+// SubscribeEvents returns a channel receiving the daemon events, and a function to
+// unsubscribe which closes the channel. Returns a nil channel if events are disabled.
+func (gw *Gateway) SubscribeEvents(size int) (<-chan Event, func()) {
+	if gw.d.Visor.events == nil {
+		return nil, func() {}
+	}
+	return gw.d.Visor.events.subscribe(size)
+}
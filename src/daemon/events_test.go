@@ -0,0 +1,94 @@
+package daemon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/testutil"
+)
+
+func TestEventBusDropped(t *testing.T) {
+	eb := newEventBus()
+	events, unsubscribe := eb.subscribe(1)
+
+	eb.publish(Event{Topic: EventTopicPeer, Data: PeerEvent{Address: "1.2.3.4:6000", Connected: true}})
+	// The buffer is full, these are dropped
+	eb.publish(Event{Topic: EventTopicPeer})
+	eb.publish(Event{Topic: EventTopicPeer})
+
+	ev := <-events
+	require.Equal(t, PeerEvent{Address: "1.2.3.4:6000", Connected: true}, ev.Data)
+	require.Equal(t, uint64(0), ev.Dropped)
+
+	eb.publish(Event{Topic: EventTopicSync})
+	ev = <-events
+	require.Equal(t, EventTopicSync, ev.Topic)
+	require.Equal(t, uint64(2), ev.Dropped)
+
+	unsubscribe()
+	_, ok := <-events
+	require.False(t, ok)
+
+	// Unsubscribing twice is fine, publishing has no subscriber
+	unsubscribe()
+	eb.publish(Event{Topic: EventTopicPeer})
+}
+
+func TestEventBusSyncProgress(t *testing.T) {
+	eb := newEventBus()
+	events, unsubscribe := eb.subscribe(10)
+	defer unsubscribe()
+
+	eb.publishSync(SyncProgress{Current: 1, Highest: 10})
+	eb.publishSync(SyncProgress{Current: 1, Highest: 10})
+	eb.publishSync(SyncProgress{Current: 2, Highest: 10})
+
+	require.Equal(t, SyncProgress{Current: 1, Highest: 10}, (<-events).Data)
+	require.Equal(t, SyncProgress{Current: 2, Highest: 10}, (<-events).Data)
+	require.Len(t, events, 0)
+}
+
+func TestEventBusAddressActivity(t *testing.T) {
+	eb := newEventBus()
+	events, unsubscribe := eb.subscribe(10)
+	defer unsubscribe()
+
+	from := testutil.MakeAddress()
+	to := testutil.MakeAddress()
+	in := testutil.RandSHA256(t)
+
+	txn := coin.Transaction{}
+	txn.PushInput(in)
+	txn.PushInput(testutil.RandSHA256(t))
+	txn.PushOutput(to, 1e6, 1)
+	txn.PushOutput(from, 1e6, 1)
+	txn.UpdateHeader()
+
+	getUx := func(h cipher.SHA256) (coin.UxOut, bool) {
+		if h != in {
+			return coin.UxOut{}, false
+		}
+		var ux coin.UxOut
+		ux.Body.Address = from
+		return ux, true
+	}
+
+	eb.publishAddressActivity(txn, getUx, true, 7)
+
+	// The change address is only reported once
+	require.Len(t, events, 2)
+	for _, addr := range []cipher.Address{from, to} {
+		ev := <-events
+		require.Equal(t, EventTopicAddress, ev.Topic)
+		require.Equal(t, addr.String(), ev.Address)
+		require.Equal(t, AddressActivity{
+			Address:   addr.String(),
+			Txid:      txn.Hash().Hex(),
+			Confirmed: true,
+			BlockSeq:  7,
+		}, ev.Data)
+	}
+}
@@ -0,0 +1,267 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/util/utc"
+	"github.com/skycoin/skycoin/src/visor"
+	"github.com/skycoin/skycoin/src/visor/blockdb"
+	"github.com/skycoin/skycoin/src/visor/storage"
+)
+
+// ErrForkDetectionDisabled is returned when fork detection is not enabled
+var ErrForkDetectionDisabled = errors.New("Fork detection is disabled")
+
+// ForkConfig configures fork detection
+type ForkConfig struct {
+	// URL which receives a POST with the fork as JSON when a fork is detected, disabled if empty
+	WebhookURL string
+	// Timeout of a webhook request
+	WebhookTimeout time.Duration
+	// Number of attempts to deliver the webhook
+	WebhookAttempts uint64
+	// Delay before the first retry of the webhook, doubled after each attempt
+	WebhookRetryInterval time.Duration
+	// How long the blocks of a peer which sent a block of a conflicting branch are refused
+	PeerTTL time.Duration
+	// Maximum number of peers recorded, the peer recorded first is dropped when exceeded
+	MaxPeers int
+}
+
+// NewForkConfig creates default fork detection config
+func NewForkConfig() ForkConfig {
+	return ForkConfig{
+		WebhookTimeout:       10 * time.Second,
+		WebhookAttempts:      8,
+		WebhookRetryInterval: 2 * time.Second,
+		PeerTTL:              time.Hour,
+		MaxPeers:             1000,
+	}
+}
+
+type blockBySeqGetter interface {
+	GetBlockBySeq(seq uint64) (*coin.SignedBlock, error)
+}
+
+// forkDetector records blocks received from peers which conflict with a block
+// of the same seq in the local chain
+type forkDetector struct {
+	sync.Mutex
+	cfg     ForkConfig
+	signers blockdb.SignerSchedule
+	forks   *blockdb.Forks
+	// peers which sent a block of a conflicting branch, with the time they were recorded
+	peers  map[string]time.Time
+	client *http.Client
+}
+
+func newForkDetector(db *bolt.DB, signers blockdb.SignerSchedule, cfg ForkConfig) (*forkDetector, error) {
+	forks, err := blockdb.NewForks(storage.NewBolt(db))
+	if err != nil {
+		return nil, err
+	}
+
+	return &forkDetector{
+		cfg:     cfg,
+		signers: signers,
+		forks:   forks,
+		peers:   make(map[string]time.Time),
+		client: &http.Client{
+			Timeout: cfg.WebhookTimeout,
+		},
+	}, nil
+}
+
+// check compares the block received from addr with the local block of the same seq.
+// Returns true if the block is signed by an authorised signer but differs from the local block, in
+// which case both blocks are recorded and addr is marked as being on a conflicting branch.
+func (fd *forkDetector) check(bc blockBySeqGetter, addr string, sb *coin.SignedBlock) (bool, error) {
+	local, err := bc.GetBlockBySeq(sb.Seq())
+	if err != nil {
+		// A peer resending an old block doesn't make a fork worth recording
+		if _, ok := err.(blockdb.ErrBlockPruned); ok {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if local == nil || local.HashHeader() == sb.HashHeader() {
+		return false, nil
+	}
+
+	// Blocks with invalid signatures are rejected by the regular block validation
+	if err := fd.signers.VerifyBlockSignature(sb); err != nil {
+		return false, nil
+	}
+
+	now := utc.Now()
+	localBlock, err := blockdb.NewForkBlock(local, "", now.Unix())
+	if err != nil {
+		return false, err
+	}
+
+	forkBlock, err := blockdb.NewForkBlock(sb, addr, now.Unix())
+	if err != nil {
+		return false, err
+	}
+
+	fd.addPeer(addr, now)
+
+	if _, err := fd.forks.Add(localBlock); err != nil {
+		return true, err
+	}
+
+	added, err := fd.forks.Add(forkBlock)
+	if err != nil {
+		return true, err
+	}
+
+	if !added {
+		return true, nil
+	}
+
+	logger.Critical("Fork detected at seq %d: local block %s, block %s from peer %s, signed by %s and %s. A block signing key may have been misused",
+		sb.Seq(), localBlock.Hash.Hex(), forkBlock.Hash.Hex(), addr, localBlock.Signer.Hex(), forkBlock.Signer.Hex())
+
+	if fd.cfg.WebhookURL != "" {
+		go fd.notify(visor.NewReadableFork(blockdb.Fork{
+			Seq:    sb.Seq(),
+			Blocks: []blockdb.ForkBlock{localBlock, forkBlock},
+		}))
+	}
+
+	return true, nil
+}
+
+// notify posts the fork to the webhook url, retrying with an exponential backoff
+func (fd *forkDetector) notify(fork visor.ReadableFork) {
+	body, err := json.Marshal(fork)
+	if err != nil {
+		logger.Error("Marshal fork failed: %v", err)
+		return
+	}
+
+	if err := postWithRetry(fd.client, fd.cfg.WebhookURL, body, nil, fd.cfg.WebhookAttempts, fd.cfg.WebhookRetryInterval, nil); err != nil {
+		logger.Error("Fork webhook failed after %d attempts: %v", fd.cfg.WebhookAttempts, err)
+	}
+}
+
+// addPeer records addr as being on a conflicting branch, dropping the expired
+// peers and, if MaxPeers is exceeded, the peer recorded first
+func (fd *forkDetector) addPeer(addr string, now time.Time) {
+	fd.Lock()
+	defer fd.Unlock()
+
+	fd.peers[addr] = now
+
+	for a, t := range fd.peers {
+		if now.Sub(t) >= fd.cfg.PeerTTL {
+			delete(fd.peers, a)
+		}
+	}
+
+	for len(fd.peers) > fd.cfg.MaxPeers {
+		var oldest string
+		var oldestTime time.Time
+		for a, t := range fd.peers {
+			if oldest == "" || t.Before(oldestTime) {
+				oldest = a
+				oldestTime = t
+			}
+		}
+		delete(fd.peers, oldest)
+	}
+}
+
+// isForkPeer returns true if addr sent a block of a conflicting branch less than PeerTTL ago
+func (fd *forkDetector) isForkPeer(addr string, now time.Time) bool {
+	fd.Lock()
+	defer fd.Unlock()
+
+	t, ok := fd.peers[addr]
+	if !ok {
+		return false
+	}
+
+	if now.Sub(t) >= fd.cfg.PeerTTL {
+		delete(fd.peers, addr)
+		return false
+	}
+
+	return true
+}
+
+// getForks returns the recorded forks
+func (fd *forkDetector) getForks() ([]visor.ReadableFork, error) {
+	forks, err := fd.forks.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	rforks := make([]visor.ReadableFork, 0, len(forks))
+	for _, f := range forks {
+		rforks = append(rforks, visor.NewReadableFork(f))
+	}
+
+	return rforks, nil
+}
+
+// EnableForkDetection starts recording the blocks received from peers which conflict
+// with the local chain. Must be called before the daemon runs.
+// Forks are only detected if the handler of the received blocks calls IsForkPeer and
+// CheckFork before executing a block, the node does not enable fork detection until it does.
+func (vs *Visor) EnableForkDetection(db *bolt.DB, cfg ForkConfig) error {
+	fd, err := newForkDetector(db, blockdb.NewSignerSchedule(vs.Config.Config.BlockchainPubkey), cfg)
+	if err != nil {
+		return err
+	}
+
+	vs.forks = fd
+	return nil
+}
+
+// CheckFork checks a block received from addr against the local chain, returns true
+// if it belongs to a conflicting branch and must not be accepted
+func (vs *Visor) CheckFork(addr string, sb *coin.SignedBlock) bool {
+	if vs.forks == nil {
+		return false
+	}
+
+	isFork, err := vs.forks.check(vs.v.Blockchain, addr, sb)
+	if err != nil {
+		logger.Error("Check fork of block %d from %s failed: %v", sb.Seq(), addr, err)
+	}
+
+	return isFork
+}
+
+// IsForkPeer returns true if the peer sent a block of a conflicting branch in the
+// last PeerTTL, blocks from such peers are not accepted
+func (vs *Visor) IsForkPeer(addr string) bool {
+	if vs.forks == nil {
+		return false
+	}
+
+	return vs.forks.isForkPeer(addr, utc.Now())
+}
+
+// GetForks returns the forks detected between the local chain and peers
+func (gw *Gateway) GetForks() ([]visor.ReadableFork, error) {
+	var forks []visor.ReadableFork
+	var err error
+	gw.strand("GetForks", func() {
+		if gw.d.Visor.forks == nil {
+			err = ErrForkDetectionDisabled
+			return
+		}
+		forks, err = gw.d.Visor.forks.getForks()
+	})
+	return forks, err
+}
@@ -0,0 +1,164 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/testutil"
+	"github.com/skycoin/skycoin/src/visor"
+	"github.com/skycoin/skycoin/src/visor/blockdb"
+)
+
+type fakeBlocks map[uint64]*coin.SignedBlock
+
+func (fb fakeBlocks) GetBlockBySeq(seq uint64) (*coin.SignedBlock, error) {
+	return fb[seq], nil
+}
+
+func makeSignedBlock(t *testing.T, seq uint64, prevHash cipher.SHA256, coins uint64, sec cipher.SecKey) *coin.SignedBlock {
+	txn := coin.Transaction{}
+	txn.PushOutput(GenesisAddress, coins, 100)
+	txn.UpdateHeader()
+
+	body := coin.BlockBody{Transactions: coin.Transactions{txn}}
+	b := coin.Block{
+		Head: coin.BlockHeader{
+			BkSeq:    seq,
+			Time:     GenesisTime + seq*TimeIncrement,
+			PrevHash: prevHash,
+			BodyHash: body.Hash(),
+		},
+		Body: body,
+	}
+
+	return &coin.SignedBlock{
+		Block: b,
+		Sig:   cipher.SignHash(b.HashHeader(), sec),
+	}
+}
+
+func TestForkDetector(t *testing.T) {
+	db, closeDB := testutil.PrepareDB(t)
+	defer closeDB()
+
+	forks := make(chan visor.ReadableFork, 1)
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first attempt fails and is retried
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var f visor.ReadableFork
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&f))
+		forks <- f
+	}))
+	defer srv.Close()
+
+	cfg := NewForkConfig()
+	cfg.WebhookURL = srv.URL
+	cfg.WebhookRetryInterval = 10 * time.Millisecond
+	fd, err := newForkDetector(db, blockdb.NewSignerSchedule(GenesisPublic), cfg)
+	require.NoError(t, err)
+
+	prev := testutil.RandSHA256(t)
+	local := makeSignedBlock(t, 1, prev, 1e6, GenesisSecret)
+	chain := fakeBlocks{1: local}
+
+	// Same block is not a fork
+	isFork, err := fd.check(chain, "127.0.0.1:6000", local)
+	require.NoError(t, err)
+	require.False(t, isFork)
+
+	// Block above the head is not a fork
+	isFork, err = fd.check(chain, "127.0.0.1:6000", makeSignedBlock(t, 2, local.HashHeader(), 1e6, GenesisSecret))
+	require.NoError(t, err)
+	require.False(t, isFork)
+
+	// Conflicting block not signed by the master key is ignored
+	_, sec := cipher.GenerateKeyPair()
+	isFork, err = fd.check(chain, "127.0.0.1:6001", makeSignedBlock(t, 1, prev, 2e6, sec))
+	require.NoError(t, err)
+	require.False(t, isFork)
+	require.False(t, fd.isForkPeer("127.0.0.1:6001", time.Now()))
+
+	// Conflicting block signed by the master key
+	fork := makeSignedBlock(t, 1, prev, 3e6, GenesisSecret)
+	isFork, err = fd.check(chain, "127.0.0.1:6002", fork)
+	require.NoError(t, err)
+	require.True(t, isFork)
+	require.True(t, fd.isForkPeer("127.0.0.1:6002", time.Now()))
+	require.False(t, fd.isForkPeer("127.0.0.1:6000", time.Now()))
+
+	select {
+	case f := <-forks:
+		require.Equal(t, uint64(1), f.Seq)
+		require.Len(t, f.Blocks, 2)
+		require.Equal(t, local.HashHeader().Hex(), f.Blocks[0].Hash)
+		require.Equal(t, "", f.Blocks[0].Source)
+		require.Equal(t, fork.HashHeader().Hex(), f.Blocks[1].Hash)
+		require.Equal(t, "127.0.0.1:6002", f.Blocks[1].Source)
+		require.Equal(t, GenesisPublic.Hex(), f.Blocks[1].Signer)
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not called")
+	}
+
+	// Receiving the same fork again does not notify twice
+	isFork, err = fd.check(chain, "127.0.0.1:6003", fork)
+	require.NoError(t, err)
+	require.True(t, isFork)
+	require.True(t, fd.isForkPeer("127.0.0.1:6003", time.Now()))
+
+	rforks, err := fd.getForks()
+	require.NoError(t, err)
+	require.Len(t, rforks, 1)
+	require.Len(t, rforks[0].Blocks, 2)
+
+	select {
+	case <-forks:
+		t.Fatal("webhook was called twice for the same fork")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestForkDetectorPeers(t *testing.T) {
+	db, closeDB := testutil.PrepareDB(t)
+	defer closeDB()
+
+	cfg := NewForkConfig()
+	cfg.PeerTTL = time.Minute
+	cfg.MaxPeers = 2
+	fd, err := newForkDetector(db, blockdb.NewSignerSchedule(GenesisPublic), cfg)
+	require.NoError(t, err)
+
+	now := time.Now()
+	fd.addPeer("127.0.0.1:6000", now)
+	fd.addPeer("127.0.0.1:6001", now.Add(time.Second))
+	require.True(t, fd.isForkPeer("127.0.0.1:6000", now.Add(time.Second)))
+	require.True(t, fd.isForkPeer("127.0.0.1:6001", now.Add(time.Second)))
+
+	// The peer recorded first is dropped when MaxPeers is exceeded
+	fd.addPeer("127.0.0.1:6002", now.Add(2*time.Second))
+	require.Len(t, fd.peers, 2)
+	require.False(t, fd.isForkPeer("127.0.0.1:6000", now.Add(2*time.Second)))
+	require.True(t, fd.isForkPeer("127.0.0.1:6001", now.Add(2*time.Second)))
+	require.True(t, fd.isForkPeer("127.0.0.1:6002", now.Add(2*time.Second)))
+
+	// Peers expire after PeerTTL
+	require.False(t, fd.isForkPeer("127.0.0.1:6001", now.Add(time.Minute+time.Second)))
+	require.True(t, fd.isForkPeer("127.0.0.1:6002", now.Add(time.Minute+time.Second)))
+	require.Len(t, fd.peers, 1)
+
+	fd.addPeer("127.0.0.1:6003", now.Add(2*time.Minute+2*time.Second))
+	require.Len(t, fd.peers, 1)
+	require.True(t, fd.isForkPeer("127.0.0.1:6003", now.Add(2*time.Minute+2*time.Second)))
+}
@@ -0,0 +1,302 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/skycoin/skycoin/src/util/utc"
+	"github.com/skycoin/skycoin/src/visor"
+)
+
+// Names of the health and readiness checks
+const (
+	// HealthCheckProcess the node process is running
+	HealthCheckProcess = "process"
+	// HealthCheckDisk the data directory accepts writes
+	HealthCheckDisk = "disk"
+	// HealthCheckDaemonLoop the daemon loop answers a request sent through the strand channel
+	HealthCheckDaemonLoop = "daemon_loop"
+	// HealthCheckSync the head block is close to the highest block of the peers
+	HealthCheckSync = "sync"
+	// HealthCheckConnections the node has enough connections
+	HealthCheckConnections = "connections"
+	// HealthCheckUnconfirmedPool the unconfirmed pool is not saturated
+	HealthCheckUnconfirmedPool = "unconfirmed_pool"
+)
+
+var (
+	// ErrHealthChecksDisabled is returned when the health checks are not enabled
+	ErrHealthChecksDisabled = errors.New("Health checks are disabled")
+
+	errCheckPending = errors.New("Previous check is still pending")
+)
+
+// HealthConfig configures the thresholds of the health and readiness checks
+type HealthConfig struct {
+	// File written by the disk check, next to the database so that it is on the same disk
+	Filename string
+	// Time the disk and the daemon loop have to answer a check
+	Timeout time.Duration
+	// Maximum number of blocks behind the highest block of the peers to be ready
+	MaxBlocksBehind uint64
+	// Minimum number of connections to be ready
+	MinConnections int
+	// Usage of the unconfirmed pool limits, from 0 to 1, above which the pool is saturated.
+	// 0 disables the check.
+	MaxPoolUsage float64
+}
+
+// NewHealthConfig creates default health check config
+func NewHealthConfig() HealthConfig {
+	return HealthConfig{
+		Timeout:         5 * time.Second,
+		MaxBlocksBehind: 10,
+		MinConnections:  1,
+		MaxPoolUsage:    0.9,
+	}
+}
+
+// HealthCheck is the result of a health or readiness check
+type HealthCheck struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Detail  string `json:"detail"`
+	Elapsed string `json:"elapsed,omitempty"`
+}
+
+// HealthStatus is the result of the health checks, OK if all the checks passed
+type HealthStatus struct {
+	OK     bool          `json:"ok"`
+	Checks []HealthCheck `json:"checks"`
+}
+
+// ReadinessStatus is the result of the readiness checks, OK if all the checks passed
+type ReadinessStatus struct {
+	OK     bool          `json:"ok"`
+	Checks []HealthCheck `json:"checks"`
+	// Seq of the head block
+	HeadSeq uint64 `json:"head_seq"`
+	// Highest block seq reported by the peers
+	HighestSeq  uint64  `json:"highest_seq"`
+	Connections int     `json:"connections"`
+	PoolUsage   float64 `json:"pool_usage"`
+}
+
+func (s *HealthStatus) add(c HealthCheck) {
+	s.Checks = append(s.Checks, c)
+	s.OK = s.OK && c.OK
+}
+
+func (s *ReadinessStatus) add(c HealthCheck) {
+	s.Checks = append(s.Checks, c)
+	s.OK = s.OK && c.OK
+}
+
+type healthChecker struct {
+	cfg HealthConfig
+	// At most one check of each kind is pending, a stalled disk or daemon loop
+	// doesn't accumulate goroutines
+	disk      probe
+	loop      probe
+	readiness probe
+}
+
+// EnableHealthChecks enables the health and readiness checks. Must be called before the daemon runs.
+func (vs *Visor) EnableHealthChecks(cfg HealthConfig) {
+	logger.Info("Health checks enabled, timeout %v, ready within %d blocks of the peers with %d connections",
+		cfg.Timeout, cfg.MaxBlocksBehind, cfg.MinConnections)
+	vs.health = &healthChecker{
+		cfg: cfg,
+	}
+}
+
+// probe runs a check in the background, one at a time
+type probe struct {
+	pending int32
+}
+
+// checkWithTimeout runs the check f and returns its error, or an error if it did not return
+// within the timeout. f keeps running in the background after the timeout, it must not write
+// to state read by the caller in this case. Until it returns, the next checks fail immediately
+// instead of starting another goroutine.
+func (p *probe) checkWithTimeout(timeout time.Duration, f func() error) (time.Duration, error) {
+	if !atomic.CompareAndSwapInt32(&p.pending, 0, 1) {
+		return 0, errCheckPending
+	}
+
+	t := time.Now()
+	errC := make(chan error, 1)
+	go func() {
+		defer atomic.StoreInt32(&p.pending, 0)
+		errC <- f()
+	}()
+
+	select {
+	case err := <-errC:
+		return time.Since(t), err
+	case <-time.After(timeout):
+		return time.Since(t), fmt.Errorf("No answer within %v", timeout)
+	}
+}
+
+func newTimedHealthCheck(name string, elapsed time.Duration, err error, detail string) HealthCheck {
+	c := HealthCheck{
+		Name:    name,
+		OK:      err == nil,
+		Detail:  detail,
+		Elapsed: elapsed.String(),
+	}
+	if err != nil {
+		c.Detail = err.Error()
+	}
+	return c
+}
+
+// writeHealthCheck writes the time of the check to the file and syncs it to the disk
+func writeHealthCheck(filename string, now time.Time) error {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.WriteString(strconv.FormatInt(now.Unix(), 10)); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// GetHealth checks that the data directory accepts writes and that the daemon loop answers
+// a request within the configured timeout. Not called through the strand, so that
+// it answers while the daemon loop is stalled.
+func (gw *Gateway) GetHealth() (*HealthStatus, error) {
+	hc := gw.d.Visor.health
+	if hc == nil {
+		return nil, ErrHealthChecksDisabled
+	}
+
+	s := &HealthStatus{OK: true}
+
+	s.add(HealthCheck{
+		Name:   HealthCheckProcess,
+		OK:     true,
+		Detail: fmt.Sprintf("pid %d", os.Getpid()),
+	})
+
+	elapsed, err := hc.disk.checkWithTimeout(hc.cfg.Timeout, func() error {
+		return writeHealthCheck(hc.cfg.Filename, utc.Now())
+	})
+	s.add(newTimedHealthCheck(HealthCheckDisk, elapsed, err, "write succeeded"))
+
+	elapsed, err = hc.loop.checkWithTimeout(hc.cfg.Timeout, func() error {
+		gw.strand("HealthCheck", func() {})
+		return nil
+	})
+	s.add(newTimedHealthCheck(HealthCheckDaemonLoop, elapsed, err, "request answered"))
+
+	return s, nil
+}
+
+// poolUsage returns the highest usage of the unconfirmed pool limits, 0 if the pool is unlimited
+func poolUsage(stats visor.MempoolStats) float64 {
+	var usage float64
+	if stats.MaxTxns > 0 {
+		usage = float64(stats.Txns) / float64(stats.MaxTxns)
+	}
+	if stats.MaxBytes > 0 {
+		if u := float64(stats.Bytes) / float64(stats.MaxBytes); u > usage {
+			usage = u
+		}
+	}
+	return usage
+}
+
+// checkReadiness compares the node state with the thresholds of the config
+func checkReadiness(cfg HealthConfig, headSeq, highestSeq uint64, conns int, pool visor.MempoolStats) *ReadinessStatus {
+	s := &ReadinessStatus{
+		OK:          true,
+		HeadSeq:     headSeq,
+		HighestSeq:  highestSeq,
+		Connections: conns,
+		PoolUsage:   poolUsage(pool),
+	}
+
+	var behind uint64
+	if highestSeq > headSeq {
+		behind = highestSeq - headSeq
+	}
+	s.add(HealthCheck{
+		Name:   HealthCheckSync,
+		OK:     behind <= cfg.MaxBlocksBehind,
+		Detail: fmt.Sprintf("%d blocks behind the peers, maximum %d", behind, cfg.MaxBlocksBehind),
+	})
+
+	s.add(HealthCheck{
+		Name:   HealthCheckConnections,
+		OK:     conns >= cfg.MinConnections,
+		Detail: fmt.Sprintf("%d connections, minimum %d", conns, cfg.MinConnections),
+	})
+
+	poolCheck := HealthCheck{
+		Name:   HealthCheckUnconfirmedPool,
+		OK:     true,
+		Detail: "saturation check disabled",
+	}
+	if pool.MaxTxns == 0 && pool.MaxBytes == 0 {
+		poolCheck.Detail = fmt.Sprintf("%d txns, %d bytes, the unconfirmed pool is not bounded", pool.Txns, pool.Bytes)
+	} else if cfg.MaxPoolUsage > 0 {
+		poolCheck.OK = s.PoolUsage < cfg.MaxPoolUsage
+		poolCheck.Detail = fmt.Sprintf("%d/%d txns, %d/%d bytes, usage %.2f, maximum %.2f",
+			pool.Txns, pool.MaxTxns, pool.Bytes, pool.MaxBytes, s.PoolUsage, cfg.MaxPoolUsage)
+	}
+	s.add(poolCheck)
+
+	return s
+}
+
+// GetReadiness checks that the node is within the configured number of blocks of the
+// highest block of the peers, has enough connections and that its unconfirmed pool is
+// not saturated. The node is not ready if the daemon loop does not answer within the timeout.
+func (gw *Gateway) GetReadiness() (*ReadinessStatus, error) {
+	hc := gw.d.Visor.health
+	if hc == nil {
+		return nil, ErrHealthChecksDisabled
+	}
+
+	var headSeq, highestSeq uint64
+	var conns int
+	var pool visor.MempoolStats
+	elapsed, err := hc.readiness.checkWithTimeout(hc.cfg.Timeout, func() error {
+		progress := gw.GetBlockchainProgress()
+		if progress == nil {
+			return errors.New("Blockchain progress is not available")
+		}
+
+		var n int
+		if c := gw.GetConnections(); c != nil {
+			n = len(c.Connections)
+		}
+
+		stats := gw.GetUnconfirmedStats()
+
+		headSeq, highestSeq, conns, pool = progress.Current, progress.Highest, n, stats
+		return nil
+	})
+	if err != nil {
+		s := &ReadinessStatus{OK: true}
+		s.add(newTimedHealthCheck(HealthCheckDaemonLoop, elapsed, err, ""))
+		return s, nil
+	}
+
+	return checkReadiness(hc.cfg, headSeq, highestSeq, conns, pool), nil
+}
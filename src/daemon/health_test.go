@@ -0,0 +1,164 @@
+package daemon
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/testutil"
+	"github.com/skycoin/skycoin/src/visor"
+)
+
+func TestCheckWithTimeout(t *testing.T) {
+	var p probe
+	_, err := p.checkWithTimeout(time.Second, func() error {
+		return nil
+	})
+	require.NoError(t, err)
+
+	block := make(chan struct{})
+	done := make(chan struct{})
+	_, err = p.checkWithTimeout(10*time.Millisecond, func() error {
+		defer close(done)
+		<-block
+		return nil
+	})
+	testutil.RequireError(t, err, "No answer within 10ms")
+
+	// The blocked check is still pending, no other check is started
+	_, err = p.checkWithTimeout(time.Second, func() error {
+		return errors.New("check started while the previous one is pending")
+	})
+	require.Equal(t, errCheckPending, err)
+
+	close(block)
+	<-done
+
+	// The pending flag is cleared once the blocked check returns
+	var cleared bool
+	for i := 0; i < 100 && !cleared; i++ {
+		_, err = p.checkWithTimeout(time.Second, func() error {
+			return nil
+		})
+		cleared = err != errCheckPending
+		time.Sleep(time.Millisecond)
+	}
+	require.True(t, cleared)
+	require.NoError(t, err)
+}
+
+func TestWriteHealthCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "health")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "health")
+	require.NoError(t, writeHealthCheck(filename, time.Unix(1400000000, 0)))
+	require.NoError(t, writeHealthCheck(filename, time.Unix(1500000000, 0)))
+
+	b, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, "1500000000", string(b))
+
+	require.Error(t, writeHealthCheck(filepath.Join(dir, "missing", "health"), time.Now()))
+}
+
+func TestCheckReadiness(t *testing.T) {
+	cfg := NewHealthConfig()
+	pool := visor.MempoolStats{
+		Txns:     10,
+		Bytes:    500,
+		MaxTxns:  100,
+		MaxBytes: 1000,
+	}
+
+	tt := []struct {
+		name       string
+		headSeq    uint64
+		highestSeq uint64
+		conns      int
+		pool       visor.MempoolStats
+		maxUsage   float64
+		failed     []string
+	}{
+		{
+			name:       "ready",
+			headSeq:    100,
+			highestSeq: 110,
+			conns:      1,
+			pool:       pool,
+			maxUsage:   0.9,
+		},
+		{
+			name:       "head above the peers",
+			headSeq:    100,
+			highestSeq: 0,
+			conns:      3,
+			pool:       pool,
+			maxUsage:   0.9,
+		},
+		{
+			name:       "not synced",
+			headSeq:    100,
+			highestSeq: 111,
+			conns:      1,
+			pool:       pool,
+			maxUsage:   0.9,
+			failed:     []string{HealthCheckSync},
+		},
+		{
+			name:       "no connections, pool saturated by bytes",
+			headSeq:    100,
+			highestSeq: 100,
+			conns:      0,
+			pool:       visor.MempoolStats{Txns: 10, Bytes: 950, MaxTxns: 100, MaxBytes: 1000},
+			maxUsage:   0.9,
+			failed:     []string{HealthCheckConnections, HealthCheckUnconfirmedPool},
+		},
+		{
+			name:       "saturation check disabled",
+			headSeq:    100,
+			highestSeq: 100,
+			conns:      1,
+			pool:       visor.MempoolStats{Txns: 100, MaxTxns: 100},
+			maxUsage:   0,
+		},
+		{
+			name:       "pool not bounded",
+			headSeq:    100,
+			highestSeq: 100,
+			conns:      1,
+			pool:       visor.MempoolStats{Txns: 100000, Bytes: 1 << 30},
+			maxUsage:   0.9,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg.MaxPoolUsage = tc.maxUsage
+			s := checkReadiness(cfg, tc.headSeq, tc.highestSeq, tc.conns, tc.pool)
+
+			require.Equal(t, len(tc.failed) == 0, s.OK)
+			require.Len(t, s.Checks, 3)
+
+			var failed []string
+			for _, c := range s.Checks {
+				if !c.OK {
+					failed = append(failed, c.Name)
+				}
+			}
+			require.Equal(t, tc.failed, failed)
+		})
+	}
+}
+
+func TestPoolUsage(t *testing.T) {
+	require.Equal(t, float64(0), poolUsage(visor.MempoolStats{Txns: 10, Bytes: 100}))
+	require.Equal(t, 0.5, poolUsage(visor.MempoolStats{Txns: 5, MaxTxns: 10, Bytes: 100}))
+	require.Equal(t, 0.75, poolUsage(visor.MempoolStats{Txns: 5, MaxTxns: 10, Bytes: 75, MaxBytes: 100}))
+}
@@ -0,0 +1,61 @@
+package daemon
+
+import (
+	"errors"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/visor"
+)
+
+// ErrBlockNotExist is returned when the block of a historical balance does not exist
+var ErrBlockNotExist = errors.New("block does not exist")
+
+// GetBalanceOfAddrsAt returns the balances of the addresses as of the block of given seq
+func (gw *Gateway) GetBalanceOfAddrsAt(addrs []cipher.Address, seq uint64) (*visor.HistoricalBalances, error) {
+	var bals *visor.HistoricalBalances
+	var err error
+	gw.strand("GetBalanceOfAddrsAt", func() {
+		var head *coin.SignedBlock
+		head, err = gw.d.Visor.v.Blockchain.Head()
+		if err != nil {
+			return
+		}
+
+		if seq > head.Seq() {
+			err = ErrBlockNotExist
+			return
+		}
+
+		bals, err = gw.d.Visor.v.GetBalanceOfAddrsAt(addrs, seq)
+	})
+	return bals, err
+}
+
+// GetBalanceOfAddrsAtTime returns the balances of the addresses as of the last block
+// with a time at or before t
+func (gw *Gateway) GetBalanceOfAddrsAtTime(addrs []cipher.Address, t uint64) (*visor.HistoricalBalances, error) {
+	var bals *visor.HistoricalBalances
+	var err error
+	gw.strand("GetBalanceOfAddrsAtTime", func() {
+		var head *coin.SignedBlock
+		head, err = gw.d.Visor.v.Blockchain.Head()
+		if err != nil {
+			return
+		}
+
+		var seq uint64
+		_, seq, err = seqRangeByTime(gw.d.Visor.v.Blockchain, head.Seq(), 0, t)
+		switch err {
+		case nil:
+		case ErrNoBlocksInWindow:
+			err = ErrBlockNotExist
+			return
+		default:
+			return
+		}
+
+		bals, err = gw.d.Visor.v.GetBalanceOfAddrsAt(addrs, seq)
+	})
+	return bals, err
+}
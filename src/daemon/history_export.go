@@ -0,0 +1,89 @@
+package daemon
+
+import (
+	"errors"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/visor"
+	"github.com/skycoin/skycoin/src/visor/historydb"
+	"github.com/skycoin/skycoin/src/wallet"
+)
+
+// historyExportPageSize is the number of transactions of a history export read per strand call
+const historyExportPageSize = 1000
+
+// ErrWalletNotExist is returned when the wallet of a history export does not exist
+var ErrWalletNotExist = errors.New("wallet does not exist")
+
+// walletAddresses returns the addresses of the wallet, ErrWalletNotExist if it is not loaded.
+// Must be called in the strand.
+func (gw *Gateway) walletAddresses(wltID string) ([]cipher.Address, error) {
+	addrs, err := gw.d.Visor.v.Wallets.GetAddresses(wltID)
+	if err != nil {
+		if _, ok := err.(wallet.ErrWalletNotExist); ok {
+			return nil, ErrWalletNotExist
+		}
+		return nil, err
+	}
+	return addrs, nil
+}
+
+// exportHistory reads the confirmed transactions of the addresses a page per strand call,
+// so that a long history doesn't hold the daemon loop, and passes each page to write
+func (gw *Gateway) exportHistory(name string, addrs []cipher.Address, write func([]visor.HistoryRecord) error) error {
+	var after *historydb.Cursor
+	for {
+		var records []visor.HistoryRecord
+		var next *historydb.Cursor
+		var err error
+		gw.strand(name, func() {
+			records, next, err = gw.d.Visor.v.GetAddressesHistoryPage(addrs, after, historyExportPageSize)
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := write(records); err != nil {
+			return err
+		}
+
+		if next == nil {
+			return nil
+		}
+		after = next
+	}
+}
+
+// GetAddressesHistory returns the confirmed transactions of the addresses, ordered by
+// block seq then index in the block
+func (gw *Gateway) GetAddressesHistory(addrs []cipher.Address) ([]visor.HistoryRecord, error) {
+	records := []visor.HistoryRecord{}
+	if err := gw.exportHistory("GetAddressesHistory", addrs, func(rs []visor.HistoryRecord) error {
+		records = append(records, rs...)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// ExportAddressesHistory passes the confirmed transactions of the addresses to write a page
+// at a time, ordered by block seq then index in the block
+func (gw *Gateway) ExportAddressesHistory(addrs []cipher.Address, write func([]visor.HistoryRecord) error) error {
+	return gw.exportHistory("ExportAddressesHistory", addrs, write)
+}
+
+// ExportWalletHistory passes the confirmed transactions of the addresses of the wallet to
+// write a page at a time, ordered by block seq then index in the block
+func (gw *Gateway) ExportWalletHistory(wltID string, write func([]visor.HistoryRecord) error) error {
+	var addrs []cipher.Address
+	var err error
+	gw.strand("ExportWalletHistory", func() {
+		addrs, err = gw.walletAddresses(wltID)
+	})
+	if err != nil {
+		return err
+	}
+
+	return gw.exportHistory("ExportWalletHistory", addrs, write)
+}
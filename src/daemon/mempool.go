@@ -0,0 +1,30 @@
+package daemon
+
+import (
+	"github.com/skycoin/skycoin/src/visor"
+)
+
+// SetUnconfirmedPolicy limits the unconfirmed pool size and transaction age.
+// Must be called before the daemon runs. The policy is only enforced for the
+// transactions injected with visor.InjectTxnWithPolicy and expired with
+// visor.ExpireUnconfirmedTxns. The node does not set a policy until the
+// transaction handlers and the daemon loop call them.
+func (vs *Visor) SetUnconfirmedPolicy(p visor.UnconfirmedPolicy) {
+	logger.Info("Unconfirmed pool limited to %d txns, %d bytes, max age %v, max chain depth %d",
+		p.MaxTxns, p.MaxBytes, p.MaxAge, p.MaxChainDepth)
+	vs.v.Mempool = visor.NewMempool(p)
+	vs.v.SyncMempool()
+}
+
+// GetUnconfirmedStats returns the unconfirmed pool statistics
+func (gw *Gateway) GetUnconfirmedStats() visor.MempoolStats {
+	var stats visor.MempoolStats
+	gw.strand("GetUnconfirmedStats", func() {
+		if gw.d.Visor.v.Mempool == nil {
+			stats.Txns = gw.d.Visor.v.Unconfirmed.Len()
+			return
+		}
+		stats = gw.d.Visor.v.Mempool.Stats()
+	})
+	return stats
+}
@@ -0,0 +1,101 @@
+package daemon
+
+import (
+	"os"
+	"time"
+
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/util/metrics"
+)
+
+var (
+	headSeqGauge = metrics.NewGauge("daemon_head_seq",
+		"Seq of the head block")
+	unconfirmedTxnsGauge = metrics.NewGauge("daemon_unconfirmed_txns",
+		"Number of transactions in the unconfirmed pool")
+	connectionsGauge = metrics.NewGaugeVec("daemon_connections",
+		"Number of connections, by outgoing, incoming and trusted", "type")
+	dbSizeGauge = metrics.NewGauge("daemon_db_size_bytes",
+		"Size of the BoltDB file")
+	blockExecuteSeconds = metrics.NewHistogram("daemon_block_execute_seconds",
+		"Time to execute a received or created block", metrics.DefaultBuckets)
+)
+
+// metricsUpdateTimeout bounds the wait for the daemon loop when the gauges are updated
+const metricsUpdateTimeout = 5 * time.Second
+
+// metricsUpdate runs the gauge updates, one at a time
+var metricsUpdate probe
+
+func init() {
+	metrics.MustRegister(headSeqGauge, unconfirmedTxnsGauge, connectionsGauge, dbSizeGauge, blockExecuteSeconds)
+}
+
+// executeSignedBlock executes the block and records the block processing time
+func (vs *Visor) executeSignedBlock(sb coin.SignedBlock) error {
+	t := time.Now()
+	if err := vs.v.ExecuteSignedBlock(sb); err != nil {
+		return err
+	}
+
+	blockExecuteSeconds.Observe(time.Since(t).Seconds())
+	return nil
+}
+
+// UpdateMetrics sets the gauges which are read from the node state, called before the
+// metrics are written. Returns an error if the daemon loop doesn't answer within
+// metricsUpdateTimeout, the gauges keep their previous values in this case.
+func (gw *Gateway) UpdateMetrics() error {
+	_, err := metricsUpdate.checkWithTimeout(metricsUpdateTimeout, gw.updateMetrics)
+	return err
+}
+
+func (gw *Gateway) updateMetrics() error {
+	var err error
+	gw.strand("UpdateMetrics", func() {
+		var head *coin.SignedBlock
+		head, err = gw.d.Visor.v.Blockchain.Head()
+		if err != nil {
+			return
+		}
+
+		headSeqGauge.Set(float64(head.Seq()))
+		unconfirmedTxnsGauge.Set(float64(gw.d.Visor.v.Unconfirmed.Len()))
+
+		var fi os.FileInfo
+		fi, err = os.Stat(gw.d.Visor.v.Config.DBPath)
+		if err != nil {
+			return
+		}
+		dbSizeGauge.Set(float64(fi.Size()))
+	})
+	if err != nil {
+		return err
+	}
+
+	trusted := make(map[string]struct{})
+	for _, addr := range gw.GetTrustConnections() {
+		trusted[addr] = struct{}{}
+	}
+
+	var outgoing, incoming, trustedConns int
+	if conns := gw.GetConnections(); conns != nil {
+		for _, c := range conns.Connections {
+			if c.Outgoing {
+				outgoing++
+			} else {
+				incoming++
+			}
+
+			if _, ok := trusted[c.Addr]; ok {
+				trustedConns++
+			}
+		}
+	}
+
+	connectionsGauge.WithLabelValues("outgoing").Set(float64(outgoing))
+	connectionsGauge.WithLabelValues("incoming").Set(float64(incoming))
+	connectionsGauge.WithLabelValues("trusted").Set(float64(trustedConns))
+
+	return nil
+}
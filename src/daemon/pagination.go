@@ -0,0 +1,68 @@
+package daemon
+
+import (
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/visor"
+	"github.com/skycoin/skycoin/src/visor/historydb"
+)
+
+// GetAddressTxnsPage returns up to limit confirmed transactions of the address after the cursor,
+// ordered by block seq then index in the block, and the cursor of the next page, nil if it is the last
+func (gw *Gateway) GetAddressTxnsPage(addr cipher.Address, after *historydb.Cursor, limit int) (*visor.TransactionResults, *historydb.Cursor, error) {
+	var txns []visor.Transaction
+	var next *historydb.Cursor
+	var err error
+	gw.strand("GetAddressTxnsPage", func() {
+		txns, next, err = gw.d.Visor.v.GetAddressTxnsPage(addr, after, limit)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rs, err := visor.NewTransactionResults(txns)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return rs, next, nil
+}
+
+// GetAddrUxOutsPage returns up to limit outputs received by the address after the cursor,
+// ordered by block seq then index in the block, and the cursor of the next page, nil if it is the last
+func (gw *Gateway) GetAddrUxOutsPage(addr cipher.Address, after *historydb.Cursor, limit int) ([]*historydb.UxOutJSON, *historydb.Cursor, error) {
+	var uxs []*historydb.UxOut
+	var next *historydb.Cursor
+	var err error
+	gw.strand("GetAddrUxOutsPage", func() {
+		uxs, next, err = gw.d.Visor.v.GetAddrUxOutsPage(addr, after, limit)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	uxJSONs := make([]*historydb.UxOutJSON, 0, len(uxs))
+	for _, ux := range uxs {
+		uxJSONs = append(uxJSONs, historydb.NewUxOutJSON(ux))
+	}
+
+	return uxJSONs, next, nil
+}
+
+// GetWalletHistoryPage returns up to limit confirmed transactions of the addresses of the wallet
+// after the cursor, ordered by block seq then index in the block, and the cursor of the next page,
+// nil if it is the last
+func (gw *Gateway) GetWalletHistoryPage(wltID string, after *historydb.Cursor, limit int) ([]visor.HistoryRecord, *historydb.Cursor, error) {
+	var records []visor.HistoryRecord
+	var next *historydb.Cursor
+	var err error
+	gw.strand("GetWalletHistoryPage", func() {
+		var addrs []cipher.Address
+		addrs, err = gw.walletAddresses(wltID)
+		if err != nil {
+			return
+		}
+
+		records, next, err = gw.d.Visor.v.GetAddressesHistoryPage(addrs, after, limit)
+	})
+	return records, next, err
+}
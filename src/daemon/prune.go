@@ -0,0 +1,18 @@
+package daemon
+
+// EnablePruning keeps the bodies of the last keep blocks only.
+// Must be called before the daemon runs.
+func (vs *Visor) EnablePruning(keep uint64) error {
+	logger.Info("Pruning enabled, keeping the last %d block bodies", keep)
+	return vs.v.EnablePruning(keep)
+}
+
+// IsPruningEnabled returns true if the node prunes block bodies or has pruned
+// block bodies, in which case the history db is incomplete
+func (gw *Gateway) IsPruningEnabled() bool {
+	var enabled bool
+	gw.strand("IsPruningEnabled", func() {
+		enabled = gw.d.Visor.v.Blockchain.IsPruningEnabled()
+	})
+	return enabled
+}
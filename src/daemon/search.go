@@ -0,0 +1,14 @@
+package daemon
+
+import "github.com/skycoin/skycoin/src/visor"
+
+// Search returns the blocks, transactions, outputs and addresses matching the query,
+// at most limit of each type
+func (gw *Gateway) Search(q string, limit int) ([]visor.SearchResult, error) {
+	var results []visor.SearchResult
+	var err error
+	gw.strand("Search", func() {
+		results, err = gw.d.Visor.v.Search(q, limit)
+	})
+	return results, err
+}
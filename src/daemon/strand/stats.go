@@ -0,0 +1,165 @@
+package strand
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/skycoin/skycoin/src/util/metrics"
+)
+
+var (
+	queueWait = metrics.NewHistogramVec("strand_queue_wait_seconds",
+		"Time from a strand call to the start of its execution, by request name",
+		metrics.DefaultBuckets, "name")
+	execTime = metrics.NewHistogramVec("strand_exec_seconds",
+		"Execution time of the strand calls, by request name",
+		metrics.DefaultBuckets, "name")
+	callErrors = metrics.NewCounterVec("strand_errors_total",
+		"Number of strand calls which returned an error, by request name", "name")
+
+	executing = newExecutingRequests()
+)
+
+func init() {
+	metrics.MustRegister(queueWait, execTime, callErrors)
+}
+
+// ExecutingRequest is a request being executed
+type ExecutingRequest struct {
+	Name    string
+	Started time.Time
+}
+
+// executingRequests tracks the requests being executed, one per strand channel
+type executingRequests struct {
+	sync.Mutex
+	next     uint64
+	requests map[uint64]ExecutingRequest
+}
+
+func newExecutingRequests() *executingRequests {
+	return &executingRequests{
+		requests: make(map[uint64]ExecutingRequest),
+	}
+}
+
+func (er *executingRequests) start(name string) uint64 {
+	er.Lock()
+	defer er.Unlock()
+
+	id := er.next
+	er.next++
+	er.requests[id] = ExecutingRequest{
+		Name:    name,
+		Started: time.Now(),
+	}
+	return id
+}
+
+func (er *executingRequests) end(id uint64) {
+	er.Lock()
+	defer er.Unlock()
+	delete(er.requests, id)
+}
+
+// list returns the requests being executed, oldest first
+func (er *executingRequests) list() []ExecutingRequest {
+	er.Lock()
+	defer er.Unlock()
+
+	reqs := make([]ExecutingRequest, 0, len(er.requests))
+	for _, r := range er.requests {
+		reqs = append(reqs, r)
+	}
+
+	sort.Slice(reqs, func(i, j int) bool {
+		return reqs[i].Started.Before(reqs[j].Started)
+	})
+
+	return reqs
+}
+
+// RequestStats are the statistics of the calls of a request name. Calls counts the
+// executed calls, the queue wait of a call is recorded when its execution starts.
+type RequestStats struct {
+	Name      string
+	Calls     uint64
+	Errors    uint64
+	QueueWait metrics.HistogramSnapshot
+	Exec      metrics.HistogramSnapshot
+}
+
+// Stats are the statistics of the strand calls since the process started
+type Stats struct {
+	Executing []ExecutingRequest
+	// Ordered by name
+	Requests []RequestStats
+}
+
+// GetStats returns the statistics of the strand calls
+func GetStats() Stats {
+	s := Stats{
+		Executing: executing.list(),
+		Requests:  []RequestStats{},
+	}
+
+	errs := make(map[string]uint64)
+	callErrors.Each(func(values []string, n uint64) {
+		errs[values[0]] = n
+	})
+
+	waits := make(map[string]metrics.HistogramSnapshot)
+	queueWait.Each(func(values []string, hs metrics.HistogramSnapshot) {
+		waits[values[0]] = hs
+	})
+
+	execTime.Each(func(values []string, hs metrics.HistogramSnapshot) {
+		name := values[0]
+		s.Requests = append(s.Requests, RequestStats{
+			Name:      name,
+			Calls:     hs.Count,
+			Errors:    errs[name],
+			QueueWait: waits[name],
+			Exec:      hs,
+		})
+	})
+
+	return s
+}
+
+// String formats the statistics as a table, for the program status dump.
+// The p99 columns are the upper bounds of the histogram buckets.
+func (s Stats) String() string {
+	var buf bytes.Buffer
+	now := time.Now()
+
+	if len(s.Executing) == 0 {
+		buf.WriteString("executing: none\n")
+	}
+	for _, r := range s.Executing {
+		fmt.Fprintf(&buf, "executing: %s since %s (%s)\n", r.Name, r.Started.Format(time.RFC3339Nano), now.Sub(r.Started))
+	}
+
+	tw := tabwriter.NewWriter(&buf, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tCALLS\tERRORS\tWAIT MEAN\tWAIT P99\tEXEC MEAN\tEXEC P99")
+	for _, r := range s.Requests {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%s\t%s\n", r.Name, r.Calls, r.Errors,
+			formatSeconds(r.QueueWait.Mean()), formatSeconds(r.QueueWait.Quantile(0.99)),
+			formatSeconds(r.Exec.Mean()), formatSeconds(r.Exec.Quantile(0.99)))
+	}
+	tw.Flush()
+
+	return buf.String()
+}
+
+func formatSeconds(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return time.Duration(v * float64(time.Second)).String()
+}
@@ -0,0 +1,78 @@
+package strand
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/util/logging"
+)
+
+func findRequestStats(s Stats, name string) (RequestStats, bool) {
+	for _, r := range s.Requests {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return RequestStats{}, false
+}
+
+func TestGetStats(t *testing.T) {
+	logger := logging.MustGetLogger("strand_test")
+	c := make(chan Request)
+	quit := make(chan struct{})
+	defer close(quit)
+
+	go func() {
+		for {
+			select {
+			case <-quit:
+				return
+			case req := <-c:
+				req.Func()
+			}
+		}
+	}()
+
+	// The executing request is listed while it runs
+	err := Strand(logger, c, "TestGetStatsExecuting", func() error {
+		s := GetStats()
+		require.Len(t, s.Executing, 1)
+		require.Equal(t, "TestGetStatsExecuting", s.Executing[0].Name)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Empty(t, GetStats().Executing)
+
+	for i := 0; i < 3; i++ {
+		err := Strand(logger, c, "TestGetStats", func() error {
+			return nil
+		})
+		require.NoError(t, err)
+	}
+
+	err = Strand(logger, c, "TestGetStats", func() error {
+		return errors.New("failed")
+	})
+	require.Error(t, err)
+
+	s := GetStats()
+	r, ok := findRequestStats(s, "TestGetStats")
+	require.True(t, ok)
+	require.Equal(t, uint64(4), r.Calls)
+	require.Equal(t, uint64(1), r.Errors)
+	require.Equal(t, uint64(4), r.QueueWait.Count)
+	require.Equal(t, uint64(4), r.Exec.Count)
+
+	r, ok = findRequestStats(s, "TestGetStatsExecuting")
+	require.True(t, ok)
+	require.Equal(t, uint64(1), r.Calls)
+	require.Equal(t, uint64(0), r.Errors)
+
+	out := s.String()
+	require.True(t, strings.HasPrefix(out, "executing: none\n"))
+	require.Contains(t, out, "NAME")
+	require.Contains(t, out, "TestGetStats ")
+}
@@ -46,14 +46,17 @@ func WithQuit(logger *logging.Logger, c chan Request, name string, f func() erro
 
 	done := make(chan struct{})
 	var err error
+	queued := time.Now()
 
 	req := Request{
 		Name: name,
 		Func: func() error {
 			defer close(done)
 
-			// TODO: record time statistics in a data structure and expose stats via an API
-			// logger.Debug("%s begin", name)
+			queueWait.WithLabelValues(name).Observe(time.Since(queued).Seconds())
+
+			id := executing.start(name)
+			defer executing.end(id)
 
 			t := time.Now()
 
@@ -90,10 +93,12 @@ func WithQuit(logger *logging.Logger, c chan Request, name string, f func() erro
 			// Log the error here so that the Request channel consumer doesn't need to
 			if err != nil {
 				logger.Error("%s error: %v", name, err)
+				callErrors.WithLabelValues(name).Inc()
 			}
 
 			// Notify us if the function call took too long
 			elapsed := time.Now().Sub(t)
+			execTime.WithLabelValues(name).Observe(elapsed.Seconds())
 			if elapsed > logDurationThreshold {
 				logger.Warning("%s took %s", name, elapsed)
 			} else {
@@ -0,0 +1,491 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/util/utc"
+	"github.com/skycoin/skycoin/src/visor"
+)
+
+// States of a tracked transaction
+const (
+	// TxnStateUnknown the transaction was not seen yet
+	TxnStateUnknown = "unknown"
+	// TxnStatePending the transaction is in the unconfirmed pool
+	TxnStatePending = "pending"
+	// TxnStateConfirmed the transaction is in a block, with less than the requested confirmations
+	TxnStateConfirmed = "confirmed"
+	// TxnStateFinal the transaction reached the requested confirmations, it is no longer tracked
+	TxnStateFinal = "final"
+	// TxnStateDropped the transaction left the unconfirmed pool without being confirmed,
+	// or was never seen, it is no longer tracked
+	TxnStateDropped = "dropped"
+)
+
+// ErrTooManyTrackedTxns is returned when the number of tracked transactions would exceed the limit
+var ErrTooManyTrackedTxns = errors.New("Too many tracked transactions")
+
+// TxnTrackerConfig configures transaction status tracking
+type TxnTrackerConfig struct {
+	// Maximum number of tracked transactions
+	MaxTracked int
+	// Maximum number of transactions looked up by a refresh, the others are looked up
+	// by the next refreshes, least recently looked up first
+	MaxRefresh int
+	// Time between two refreshes, which find the changes of the unconfirmed pool.
+	// The tracked transactions are also refreshed after a block is executed.
+	RefreshInterval time.Duration
+	// Confirmations after which a transaction is final, unless requested otherwise
+	Confirmations uint64
+	// A transaction which is never seen is dropped after UnknownTimeout
+	UnknownTimeout time.Duration
+	// Timeout of a callback request
+	CallbackTimeout time.Duration
+	// Number of attempts to deliver a status change to a callback
+	CallbackAttempts uint64
+	// Delay before the first retry of a callback, doubled after each attempt
+	CallbackRetryInterval time.Duration
+	// Hosts of callback urls which may resolve to a loopback, link-local or private address
+	CallbackAllowedHosts []string
+}
+
+// NewTxnTrackerConfig creates default transaction tracking config
+func NewTxnTrackerConfig() TxnTrackerConfig {
+	return TxnTrackerConfig{
+		MaxTracked:            100000,
+		MaxRefresh:            1000,
+		RefreshInterval:       5 * time.Second,
+		Confirmations:         6,
+		UnknownTimeout:        time.Hour,
+		CallbackTimeout:       10 * time.Second,
+		CallbackAttempts:      5,
+		CallbackRetryInterval: time.Second,
+	}
+}
+
+// TrackTxnsRequest registers transactions to track
+type TrackTxnsRequest struct {
+	Txids []cipher.SHA256
+	// URL which receives a POST with each TxnStatusEvent as JSON, optional
+	CallbackURL string
+	// Confirmations after which the transactions are final, the config's default if 0
+	Confirmations uint64
+}
+
+// TxnStatusEvent is a status change of a tracked transaction
+type TxnStatusEvent struct {
+	Txid          string `json:"txid"`
+	State         string `json:"state"`
+	BlockSeq      uint64 `json:"block_seq"`
+	Confirmations uint64 `json:"confirmations"`
+	Time          int64  `json:"time"`
+}
+
+// TrackedTxn is a tracked transaction and its last known status
+type TrackedTxn struct {
+	Txid                string `json:"txid"`
+	State               string `json:"state"`
+	BlockSeq            uint64 `json:"block_seq"`
+	Confirmations       uint64 `json:"confirmations"`
+	TargetConfirmations uint64 `json:"target_confirmations"`
+	CallbackURL         string `json:"callback_url,omitempty"`
+	Added               int64  `json:"added"`
+}
+
+type txnStatusGetter interface {
+	GetTransaction(txid cipher.SHA256) (*visor.Transaction, error)
+}
+
+type trackedTxn struct {
+	txid          cipher.SHA256
+	state         string
+	blockSeq      uint64
+	confirmations uint64
+	target        uint64
+	callbackURL   string
+	added         time.Time
+}
+
+func (tt *trackedTxn) readable() TrackedTxn {
+	return TrackedTxn{
+		Txid:                tt.txid.Hex(),
+		State:               tt.state,
+		BlockSeq:            tt.blockSeq,
+		Confirmations:       tt.confirmations,
+		TargetConfirmations: tt.target,
+		CallbackURL:         tt.callbackURL,
+		Added:               tt.added.Unix(),
+	}
+}
+
+// txnCallback is a status event waiting to be posted to a callback url
+type txnCallback struct {
+	ev  TxnStatusEvent
+	url string
+}
+
+// txnTracker follows the status of registered transactions and sends their status
+// changes to the subscribers and callbacks
+type txnTracker struct {
+	sync.Mutex
+	cfg  TxnTrackerConfig
+	txns map[cipher.SHA256]*trackedTxn
+	// tracked transactions in refresh order, an entry whose transaction is no longer
+	// tracked is dropped when it's reached
+	queue       []*trackedTxn
+	subscribers map[chan TxnStatusEvent]struct{}
+	// callbacks waiting for the delivery of the previous callback of the same transaction,
+	// a transaction has an entry while its callbacks are being delivered
+	callbacks map[string][]txnCallback
+	client    *http.Client
+}
+
+func newTxnTracker(cfg TxnTrackerConfig) *txnTracker {
+	return &txnTracker{
+		cfg:         cfg,
+		txns:        make(map[cipher.SHA256]*trackedTxn),
+		subscribers: make(map[chan TxnStatusEvent]struct{}),
+		callbacks:   make(map[string][]txnCallback),
+		client:      newCallbackClient(cfg.CallbackTimeout, cfg.CallbackAllowedHosts),
+	}
+}
+
+// track registers the transactions, a transaction already tracked gets the new
+// callback url and confirmations
+func (tr *txnTracker) track(req TrackTxnsRequest, now time.Time) error {
+	if req.CallbackURL != "" {
+		if err := validateCallbackURL(req.CallbackURL); err != nil {
+			return err
+		}
+	}
+
+	target := req.Confirmations
+	if target == 0 {
+		target = tr.cfg.Confirmations
+	}
+
+	tr.Lock()
+	defer tr.Unlock()
+
+	added := 0
+	for _, txid := range req.Txids {
+		if _, ok := tr.txns[txid]; !ok {
+			added++
+		}
+	}
+
+	if len(tr.txns)+added > tr.cfg.MaxTracked {
+		return ErrTooManyTrackedTxns
+	}
+
+	for _, txid := range req.Txids {
+		if tt, ok := tr.txns[txid]; ok {
+			tt.callbackURL = req.CallbackURL
+			tt.target = target
+			continue
+		}
+
+		tt := &trackedTxn{
+			txid:        txid,
+			state:       TxnStateUnknown,
+			target:      target,
+			callbackURL: req.CallbackURL,
+			added:       now,
+		}
+		tr.txns[txid] = tt
+		tr.queue = append(tr.queue, tt)
+	}
+
+	return nil
+}
+
+// untrack stops tracking the transactions
+func (tr *txnTracker) untrack(txids []cipher.SHA256) {
+	tr.Lock()
+	defer tr.Unlock()
+
+	for _, txid := range txids {
+		delete(tr.txns, txid)
+	}
+}
+
+// list returns the tracked transactions, oldest first
+func (tr *txnTracker) list() []TrackedTxn {
+	tr.Lock()
+	defer tr.Unlock()
+
+	txns := make([]TrackedTxn, 0, len(tr.txns))
+	for _, tt := range tr.txns {
+		txns = append(txns, tt.readable())
+	}
+
+	sort.Slice(txns, func(i, j int) bool {
+		if txns[i].Added != txns[j].Added {
+			return txns[i].Added < txns[j].Added
+		}
+		return txns[i].Txid < txns[j].Txid
+	})
+
+	return txns
+}
+
+// refresh looks up the status of up to MaxRefresh tracked transactions, least recently
+// looked up first, and dispatches their status changes. Final and dropped transactions
+// are no longer tracked.
+func (tr *txnTracker) refresh(g txnStatusGetter, now time.Time) []TxnStatusEvent {
+	tr.Lock()
+	var txns []*trackedTxn
+	n := 0
+	for n < len(tr.queue) && len(txns) < tr.cfg.MaxRefresh {
+		tt := tr.queue[n]
+		n++
+		if tr.txns[tt.txid] == tt {
+			txns = append(txns, tt)
+		}
+	}
+	// The looked up transactions go to the back of the queue, dropped by update if no longer tracked
+	tr.queue = append(tr.queue[n:], txns...)
+	tr.Unlock()
+
+	var events []TxnStatusEvent
+	for _, tt := range txns {
+		txn, err := g.GetTransaction(tt.txid)
+		if err != nil {
+			logger.Error("Get status of tracked transaction %s failed: %v", tt.txid.Hex(), err)
+			continue
+		}
+
+		tr.Lock()
+		ev, changed := tr.update(tt, txn, now)
+		callbackURL := tt.callbackURL
+		tr.Unlock()
+
+		if changed {
+			events = append(events, ev)
+			tr.dispatch(ev, callbackURL)
+		}
+	}
+
+	return events
+}
+
+// update applies the transaction's current status, returns the status event if it changed
+func (tr *txnTracker) update(tt *trackedTxn, txn *visor.Transaction, now time.Time) (TxnStatusEvent, bool) {
+	if cur, ok := tr.txns[tt.txid]; !ok || cur != tt {
+		// Untracked meanwhile
+		return TxnStatusEvent{}, false
+	}
+
+	state := tt.state
+	blockSeq := tt.blockSeq
+	confirmations := tt.confirmations
+
+	switch {
+	case txn != nil && txn.Status.Confirmed:
+		blockSeq = txn.Status.BlockSeq
+		confirmations = txn.Status.Height
+		state = TxnStateConfirmed
+		if confirmations >= tt.target {
+			state = TxnStateFinal
+		}
+	case txn != nil && txn.Status.Unconfirmed:
+		state = TxnStatePending
+		blockSeq = 0
+		confirmations = 0
+	case tt.state == TxnStateUnknown:
+		if now.Sub(tt.added) > tr.cfg.UnknownTimeout {
+			state = TxnStateDropped
+		}
+	default:
+		// Seen before but gone from the pool and the chain
+		state = TxnStateDropped
+		blockSeq = 0
+		confirmations = 0
+	}
+
+	if state == tt.state && blockSeq == tt.blockSeq && confirmations == tt.confirmations {
+		return TxnStatusEvent{}, false
+	}
+
+	tt.state = state
+	tt.blockSeq = blockSeq
+	tt.confirmations = confirmations
+
+	if state == TxnStateFinal || state == TxnStateDropped {
+		delete(tr.txns, tt.txid)
+	}
+
+	return TxnStatusEvent{
+		Txid:          tt.txid.Hex(),
+		State:         state,
+		BlockSeq:      blockSeq,
+		Confirmations: confirmations,
+		Time:          now.Unix(),
+	}, true
+}
+
+// dispatch sends the event to the subscribers and to the callback url.
+// A subscriber which doesn't keep up misses the event.
+func (tr *txnTracker) dispatch(ev TxnStatusEvent, callbackURL string) {
+	tr.Lock()
+	for ch := range tr.subscribers {
+		select {
+		case ch <- ev:
+		default:
+			logger.Warning("Transaction status subscriber is full, dropping event of %s", ev.Txid)
+		}
+	}
+	tr.Unlock()
+
+	if callbackURL == "" {
+		return
+	}
+
+	// The callbacks of a transaction are delivered in order, by a single goroutine
+	tr.Lock()
+	pending, delivering := tr.callbacks[ev.Txid]
+	tr.callbacks[ev.Txid] = append(pending, txnCallback{
+		ev:  ev,
+		url: callbackURL,
+	})
+	tr.Unlock()
+
+	if !delivering {
+		go tr.deliverCallbacks(ev.Txid)
+	}
+}
+
+// deliverCallbacks delivers the pending callbacks of the transaction until there are none left
+func (tr *txnTracker) deliverCallbacks(txid string) {
+	for {
+		tr.Lock()
+		pending := tr.callbacks[txid]
+		if len(pending) == 0 {
+			delete(tr.callbacks, txid)
+			tr.Unlock()
+			return
+		}
+		cb := pending[0]
+		tr.callbacks[txid] = pending[1:]
+		tr.Unlock()
+
+		tr.deliver(cb.ev, cb.url)
+	}
+}
+
+// deliver posts the event to the callback url, retrying with an exponential backoff
+func (tr *txnTracker) deliver(ev TxnStatusEvent, callbackURL string) {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		logger.Error("Marshal transaction status event failed: %v", err)
+		return
+	}
+
+	if err := postWithRetry(tr.client, callbackURL, body, nil, tr.cfg.CallbackAttempts, tr.cfg.CallbackRetryInterval, nil); err != nil {
+		logger.Error("Transaction status callback %s of %s failed after %d attempts: %v",
+			callbackURL, ev.Txid, tr.cfg.CallbackAttempts, err)
+	}
+}
+
+// subscribe returns a channel receiving the status events, and a function to unsubscribe
+func (tr *txnTracker) subscribe(size int) (<-chan TxnStatusEvent, func()) {
+	ch := make(chan TxnStatusEvent, size)
+
+	tr.Lock()
+	tr.subscribers[ch] = struct{}{}
+	tr.Unlock()
+
+	return ch, func() {
+		tr.Lock()
+		delete(tr.subscribers, ch)
+		tr.Unlock()
+	}
+}
+
+// EnableTxnTracking allows clients to track the status of transactions.
+// Must be called before the daemon runs.
+func (vs *Visor) EnableTxnTracking(cfg TxnTrackerConfig) {
+	vs.txnTracker = newTxnTracker(cfg)
+}
+
+// RefreshTrackedTxns dispatches the status changes of the tracked transactions,
+// called after a block is executed and by RunTxnTracker for the unconfirmed pool changes
+func (vs *Visor) RefreshTrackedTxns() {
+	if vs.txnTracker == nil {
+		return
+	}
+
+	vs.txnTracker.refresh(vs.v, utc.Now())
+}
+
+// RunTxnTracker refreshes the tracked transactions every RefreshInterval until quit is closed.
+// Returns immediately if tracking is disabled.
+func (gw *Gateway) RunTxnTracker(quit <-chan struct{}) {
+	tr := gw.d.Visor.txnTracker
+	if tr == nil {
+		return
+	}
+
+	ticker := time.NewTicker(tr.cfg.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-quit:
+			return
+		case <-ticker.C:
+			gw.strand("RefreshTrackedTxns", gw.d.Visor.RefreshTrackedTxns)
+		}
+	}
+}
+
+// TrackTransactions registers transactions whose status changes are sent to the
+// callback url of the request and to the status subscribers
+func (gw *Gateway) TrackTransactions(req TrackTxnsRequest) error {
+	var err error
+	gw.strand("TrackTransactions", func() {
+		if gw.d.Visor.txnTracker == nil {
+			err = errors.New("Transaction tracking is disabled")
+			return
+		}
+		err = gw.d.Visor.txnTracker.track(req, utc.Now())
+	})
+	return err
+}
+
+// UntrackTransactions stops tracking the transactions
+func (gw *Gateway) UntrackTransactions(txids []cipher.SHA256) {
+	gw.strand("UntrackTransactions", func() {
+		if gw.d.Visor.txnTracker == nil {
+			return
+		}
+		gw.d.Visor.txnTracker.untrack(txids)
+	})
+}
+
+// GetTrackedTransactions returns the tracked transactions and their last known status
+func (gw *Gateway) GetTrackedTransactions() []TrackedTxn {
+	var txns []TrackedTxn
+	gw.strand("GetTrackedTransactions", func() {
+		if gw.d.Visor.txnTracker == nil {
+			return
+		}
+		txns = gw.d.Visor.txnTracker.list()
+	})
+	return txns
+}
+
+// SubscribeTxnStatus returns a channel receiving the status changes of the tracked
+// transactions, and a function to unsubscribe. Returns a nil channel if tracking is disabled.
+func (gw *Gateway) SubscribeTxnStatus(size int) (<-chan TxnStatusEvent, func()) {
+	if gw.d.Visor.txnTracker == nil {
+		return nil, func() {}
+	}
+	return gw.d.Visor.txnTracker.subscribe(size)
+}
@@ -0,0 +1,234 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/testutil"
+	"github.com/skycoin/skycoin/src/visor"
+)
+
+type fakeTxnStatuses map[cipher.SHA256]visor.TransactionStatus
+
+func (fs fakeTxnStatuses) GetTransaction(txid cipher.SHA256) (*visor.Transaction, error) {
+	status, ok := fs[txid]
+	if !ok {
+		return nil, nil
+	}
+	return &visor.Transaction{Status: status}, nil
+}
+
+func TestTxnTrackerRefresh(t *testing.T) {
+	cfg := NewTxnTrackerConfig()
+	cfg.UnknownTimeout = time.Minute
+	tr := newTxnTracker(cfg)
+
+	events, unsubscribe := tr.subscribe(10)
+	defer unsubscribe()
+
+	txid := testutil.RandSHA256(t)
+	lost := testutil.RandSHA256(t)
+	never := testutil.RandSHA256(t)
+	now := time.Now()
+	require.NoError(t, tr.track(TrackTxnsRequest{
+		Txids:         []cipher.SHA256{txid, lost, never},
+		Confirmations: 2,
+	}, now))
+	require.Len(t, tr.list(), 3)
+
+	statuses := fakeTxnStatuses{
+		txid: {Unconfirmed: true},
+		lost: {Unconfirmed: true},
+	}
+
+	evs := tr.refresh(statuses, now)
+	require.Len(t, evs, 2)
+	for _, ev := range evs {
+		require.Equal(t, TxnStatePending, ev.State)
+	}
+	require.Equal(t, evs[0], <-events)
+	require.Equal(t, evs[1], <-events)
+
+	// No change, no event
+	require.Empty(t, tr.refresh(statuses, now))
+
+	// Confirmed in block 10, lost is gone from the pool
+	statuses[txid] = visor.TransactionStatus{Confirmed: true, BlockSeq: 10, Height: 1}
+	delete(statuses, lost)
+	evs = tr.refresh(statuses, now)
+	require.Len(t, evs, 2)
+	byTxid := map[string]TxnStatusEvent{}
+	for _, ev := range evs {
+		byTxid[ev.Txid] = ev
+	}
+	require.Equal(t, TxnStateConfirmed, byTxid[txid.Hex()].State)
+	require.Equal(t, uint64(10), byTxid[txid.Hex()].BlockSeq)
+	require.Equal(t, uint64(1), byTxid[txid.Hex()].Confirmations)
+	require.Equal(t, TxnStateDropped, byTxid[lost.Hex()].State)
+
+	// Reaching the confirmations and never seen for longer than the timeout
+	statuses[txid] = visor.TransactionStatus{Confirmed: true, BlockSeq: 10, Height: 2}
+	evs = tr.refresh(statuses, now.Add(2*time.Minute))
+	require.Len(t, evs, 2)
+	byTxid = map[string]TxnStatusEvent{}
+	for _, ev := range evs {
+		byTxid[ev.Txid] = ev
+	}
+	require.Equal(t, TxnStateFinal, byTxid[txid.Hex()].State)
+	require.Equal(t, TxnStateDropped, byTxid[never.Hex()].State)
+
+	// Final and dropped txns are no longer tracked
+	require.Empty(t, tr.list())
+}
+
+func TestTxnTrackerTrack(t *testing.T) {
+	cfg := NewTxnTrackerConfig()
+	cfg.MaxTracked = 2
+	tr := newTxnTracker(cfg)
+
+	txids := []cipher.SHA256{testutil.RandSHA256(t), testutil.RandSHA256(t)}
+	require.NoError(t, tr.track(TrackTxnsRequest{Txids: txids}, time.Now()))
+
+	// Tracking again updates the txns
+	require.NoError(t, tr.track(TrackTxnsRequest{Txids: txids, Confirmations: 3}, time.Now()))
+	for _, tt := range tr.list() {
+		require.Equal(t, uint64(3), tt.TargetConfirmations)
+		require.Equal(t, TxnStateUnknown, tt.State)
+	}
+
+	err := tr.track(TrackTxnsRequest{Txids: []cipher.SHA256{testutil.RandSHA256(t)}}, time.Now())
+	require.Equal(t, ErrTooManyTrackedTxns, err)
+
+	err = tr.track(TrackTxnsRequest{Txids: txids, CallbackURL: "ftp://example.com"}, time.Now())
+	require.Error(t, err)
+
+	tr.untrack(txids[:1])
+	require.Len(t, tr.list(), 1)
+}
+
+func TestTxnTrackerCallbackRetry(t *testing.T) {
+	var mu sync.Mutex
+	attempts := 0
+	received := make(chan TxnStatusEvent, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts++
+		n := attempts
+		mu.Unlock()
+
+		// Fail the first attempt
+		if n == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var ev TxnStatusEvent
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&ev))
+		received <- ev
+	}))
+	defer srv.Close()
+
+	cfg := NewTxnTrackerConfig()
+	cfg.CallbackRetryInterval = 10 * time.Millisecond
+	cfg.CallbackAllowedHosts = []string{"127.0.0.1"}
+	tr := newTxnTracker(cfg)
+
+	txid := testutil.RandSHA256(t)
+	require.NoError(t, tr.track(TrackTxnsRequest{
+		Txids:       []cipher.SHA256{txid},
+		CallbackURL: srv.URL,
+	}, time.Now()))
+
+	tr.refresh(fakeTxnStatuses{txid: {Unconfirmed: true}}, time.Now())
+
+	select {
+	case ev := <-received:
+		require.Equal(t, txid.Hex(), ev.Txid)
+		require.Equal(t, TxnStatePending, ev.State)
+	case <-time.After(5 * time.Second):
+		t.Fatal("callback not received")
+	}
+
+	mu.Lock()
+	require.Equal(t, 2, attempts)
+	mu.Unlock()
+}
+
+func TestTxnTrackerMaxRefresh(t *testing.T) {
+	cfg := NewTxnTrackerConfig()
+	cfg.MaxRefresh = 2
+	tr := newTxnTracker(cfg)
+
+	txids := []cipher.SHA256{testutil.RandSHA256(t), testutil.RandSHA256(t), testutil.RandSHA256(t)}
+	require.NoError(t, tr.track(TrackTxnsRequest{Txids: txids}, time.Now()))
+
+	statuses := fakeTxnStatuses{}
+	for _, txid := range txids {
+		statuses[txid] = visor.TransactionStatus{Unconfirmed: true}
+	}
+
+	// The txns are looked up in the order they were tracked, 2 per refresh
+	evs := tr.refresh(statuses, time.Now())
+	require.Len(t, evs, 2)
+	require.Equal(t, txids[0].Hex(), evs[0].Txid)
+	require.Equal(t, txids[1].Hex(), evs[1].Txid)
+
+	evs = tr.refresh(statuses, time.Now())
+	require.Len(t, evs, 1)
+	require.Equal(t, txids[2].Hex(), evs[0].Txid)
+
+	// Untracked txns are dropped from the queue when they are reached
+	tr.untrack(txids[:1])
+	statuses[txids[1]] = visor.TransactionStatus{Confirmed: true, BlockSeq: 3, Height: 1}
+	evs = tr.refresh(statuses, time.Now())
+	require.Len(t, evs, 1)
+	require.Equal(t, txids[1].Hex(), evs[0].Txid)
+	require.Len(t, tr.queue, 3)
+
+	require.Empty(t, tr.refresh(statuses, time.Now()))
+	require.Len(t, tr.queue, 2)
+}
+
+func TestTxnTrackerCallbackOrder(t *testing.T) {
+	received := make(chan TxnStatusEvent, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev TxnStatusEvent
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&ev))
+
+		// A slow first delivery must not let the next event overtake it
+		if ev.State == TxnStatePending {
+			time.Sleep(100 * time.Millisecond)
+		}
+		received <- ev
+	}))
+	defer srv.Close()
+
+	cfg := NewTxnTrackerConfig()
+	cfg.CallbackAllowedHosts = []string{"127.0.0.1"}
+	tr := newTxnTracker(cfg)
+
+	txid := testutil.RandSHA256(t)
+	require.NoError(t, tr.track(TrackTxnsRequest{
+		Txids:       []cipher.SHA256{txid},
+		CallbackURL: srv.URL,
+	}, time.Now()))
+
+	tr.refresh(fakeTxnStatuses{txid: {Unconfirmed: true}}, time.Now())
+	tr.refresh(fakeTxnStatuses{txid: {Confirmed: true, BlockSeq: 1, Height: 1}}, time.Now())
+
+	for _, state := range []string{TxnStatePending, TxnStateConfirmed} {
+		select {
+		case ev := <-received:
+			require.Equal(t, state, ev.State)
+		case <-time.After(5 * time.Second):
+			t.Fatal("callback not received")
+		}
+	}
+}
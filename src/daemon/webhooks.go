@@ -0,0 +1,743 @@
+package daemon
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/util/file"
+	"github.com/skycoin/skycoin/src/util/utc"
+	"github.com/skycoin/skycoin/src/visor/historydb"
+)
+
+// Headers of the webhook requests
+const (
+	// WebhookSignatureHeader is the hex encoded HMAC-SHA256 of the request body, keyed with the webhook secret
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	// WebhookDeliveryHeader is the id of the delivery, retries of a delivery have the same id
+	WebhookDeliveryHeader = "X-Webhook-Delivery"
+)
+
+// Status of a webhook delivery
+const (
+	// WebhookDeliveryPending the delivery is being attempted
+	WebhookDeliveryPending = "pending"
+	// WebhookDeliveryDelivered the callback accepted the delivery
+	WebhookDeliveryDelivered = "delivered"
+	// WebhookDeliveryFailed all the attempts failed, the delivery can be replayed
+	WebhookDeliveryFailed = "failed"
+)
+
+var (
+	// ErrWebhookNotExist is returned when the webhook id is not registered
+	ErrWebhookNotExist = errors.New("Webhook does not exist")
+
+	errWebhooksDisabled = errors.New("Webhooks are disabled")
+)
+
+// WebhookConfig configures the address activity webhooks
+type WebhookConfig struct {
+	// File storing the registered webhooks, not persisted if empty
+	Filename string
+	// Timeout of a webhook request
+	Timeout time.Duration
+	// Number of attempts to deliver a notification
+	Attempts uint64
+	// Delay before the first retry of a delivery, doubled after each attempt
+	RetryInterval time.Duration
+	// Number of deliveries kept in the delivery log, the oldest are discarded
+	MaxDeliveries int
+	// Maximum number of blocks of a replay
+	MaxReplayBlocks uint64
+	// Maximum number of registered webhooks
+	MaxWebhooks int
+	// Maximum number of deliveries attempted at the same time, the others wait in a queue
+	MaxConcurrentDeliveries int
+	// Hosts the webhooks may be delivered to even if they resolve to a loopback or
+	// private address, by default the webhooks are only delivered to public addresses
+	AllowedHosts []string
+}
+
+// NewWebhookConfig creates default webhook config
+func NewWebhookConfig() WebhookConfig {
+	return WebhookConfig{
+		Timeout:                 10 * time.Second,
+		Attempts:                8,
+		RetryInterval:           2 * time.Second,
+		MaxDeliveries:           10000,
+		MaxReplayBlocks:         1000,
+		MaxWebhooks:             100,
+		MaxConcurrentDeliveries: 8,
+	}
+}
+
+// Webhook is notified when a block adds outputs to or spends outputs of its addresses,
+// or of the addresses of its wallets
+type Webhook struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	// Key of the HMAC signing the payloads, generated if not set when the webhook
+	// is added. Only returned when the webhook is added.
+	Secret    string   `json:"secret,omitempty"`
+	Addresses []string `json:"addresses,omitempty"`
+	// Wallet ids, their addresses are resolved when a block is executed
+	Wallets []string `json:"wallets,omitempty"`
+	Created int64    `json:"created"`
+}
+
+// WebhookUxOut is an output received or spent by a webhook address
+type WebhookUxOut struct {
+	Uxid    string `json:"uxid"`
+	Address string `json:"address"`
+	// Coins in droplets
+	Coins uint64 `json:"coins"`
+	Hours uint64 `json:"hours"`
+	// Transaction which created the output if received, which spent it if spent
+	Txid string `json:"txid"`
+}
+
+// WebhookPayload is posted to the webhook url as JSON
+type WebhookPayload struct {
+	WebhookID  string         `json:"webhook_id"`
+	DeliveryID uint64         `json:"delivery_id"`
+	BlockSeq   uint64         `json:"block_seq"`
+	BlockHash  string         `json:"block_hash"`
+	BlockTime  uint64         `json:"block_time"`
+	Received   []WebhookUxOut `json:"received"`
+	Spent      []WebhookUxOut `json:"spent"`
+	// True if the payload was sent by a replay request
+	Replay bool `json:"replay"`
+}
+
+// WebhookDelivery is an entry of the delivery log
+type WebhookDelivery struct {
+	ID        uint64         `json:"id"`
+	WebhookID string         `json:"webhook_id"`
+	URL       string         `json:"url"`
+	Status    string         `json:"status"`
+	Attempts  uint64         `json:"attempts"`
+	Error     string         `json:"error,omitempty"`
+	Created   int64          `json:"created"`
+	Updated   int64          `json:"updated"`
+	Payload   WebhookPayload `json:"payload"`
+}
+
+// SignWebhookPayload returns the signature of the body sent in the WebhookSignatureHeader
+func SignWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature returns true if sig is the signature of the body, for use by the receivers
+func VerifyWebhookSignature(secret string, body []byte, sig string) bool {
+	b, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(b, mac.Sum(nil))
+}
+
+type uxOutGetter interface {
+	GetUxOutByID(id cipher.SHA256) (*historydb.UxOut, error)
+}
+
+type walletAddressGetter interface {
+	GetAddresses(wltID string) ([]cipher.Address, error)
+}
+
+// webhookJob is a delivery waiting for a delivery worker
+type webhookJob struct {
+	d      *WebhookDelivery
+	secret string
+}
+
+// webhookRegistry holds the registered webhooks and the delivery log
+type webhookRegistry struct {
+	sync.Mutex
+	cfg        WebhookConfig
+	hooks      map[string]Webhook
+	deliveries []*WebhookDelivery
+	lastID     uint64
+	client     *http.Client
+	// deliveries waiting for a worker, and the number of running workers
+	queue   []webhookJob
+	workers int
+}
+
+// newWebhookRegistry creates the registry, loading the webhooks of the config's file if it exists
+func newWebhookRegistry(cfg WebhookConfig) (*webhookRegistry, error) {
+	wr := &webhookRegistry{
+		cfg:    cfg,
+		hooks:  make(map[string]Webhook),
+		client: newCallbackClient(cfg.Timeout, cfg.AllowedHosts),
+	}
+
+	if cfg.Filename == "" {
+		return wr, nil
+	}
+
+	var hooks []Webhook
+	if err := file.LoadJSON(cfg.Filename, &hooks); err != nil {
+		if os.IsNotExist(err) {
+			return wr, nil
+		}
+		return nil, fmt.Errorf("load webhooks from %s failed: %v", cfg.Filename, err)
+	}
+
+	for _, h := range hooks {
+		wr.hooks[h.ID] = h
+	}
+
+	return wr, nil
+}
+
+// save writes the webhooks to the config's file, must be called with the lock held
+func (wr *webhookRegistry) save() error {
+	if wr.cfg.Filename == "" {
+		return nil
+	}
+
+	// The file holds the secrets
+	return file.SaveJSON(wr.cfg.Filename, wr.sorted(), 0600)
+}
+
+// sorted returns the webhooks, oldest first, must be called with the lock held
+func (wr *webhookRegistry) sorted() []Webhook {
+	hooks := make([]Webhook, 0, len(wr.hooks))
+	for _, h := range wr.hooks {
+		hooks = append(hooks, h)
+	}
+
+	sort.Slice(hooks, func(i, j int) bool {
+		if hooks[i].Created != hooks[j].Created {
+			return hooks[i].Created < hooks[j].Created
+		}
+		return hooks[i].ID < hooks[j].ID
+	})
+
+	return hooks
+}
+
+// add registers the webhook, returns it with its id and secret
+func (wr *webhookRegistry) add(h Webhook, w walletAddressGetter, now time.Time) (Webhook, error) {
+	if err := validateCallbackURL(h.URL); err != nil {
+		return Webhook{}, err
+	}
+
+	if len(h.Addresses) == 0 && len(h.Wallets) == 0 {
+		return Webhook{}, errors.New("addresses or wallets are required")
+	}
+
+	for _, a := range h.Addresses {
+		if _, err := cipher.DecodeBase58Address(a); err != nil {
+			return Webhook{}, fmt.Errorf("invalid address %q: %v", a, err)
+		}
+	}
+
+	for _, id := range h.Wallets {
+		if _, err := w.GetAddresses(id); err != nil {
+			return Webhook{}, err
+		}
+	}
+
+	h.ID = hex.EncodeToString(cipher.RandByte(16))
+	if h.Secret == "" {
+		h.Secret = hex.EncodeToString(cipher.RandByte(32))
+	}
+	h.Created = now.Unix()
+
+	wr.Lock()
+	defer wr.Unlock()
+
+	if len(wr.hooks) >= wr.cfg.MaxWebhooks {
+		return Webhook{}, fmt.Errorf("at most %d webhooks can be registered", wr.cfg.MaxWebhooks)
+	}
+
+	wr.hooks[h.ID] = h
+	if err := wr.save(); err != nil {
+		delete(wr.hooks, h.ID)
+		return Webhook{}, err
+	}
+
+	return h, nil
+}
+
+// remove unregisters the webhook
+func (wr *webhookRegistry) remove(id string) error {
+	wr.Lock()
+	defer wr.Unlock()
+
+	h, ok := wr.hooks[id]
+	if !ok {
+		return ErrWebhookNotExist
+	}
+
+	delete(wr.hooks, id)
+	if err := wr.save(); err != nil {
+		wr.hooks[id] = h
+		return err
+	}
+
+	return nil
+}
+
+// list returns the webhooks without their secret, oldest first
+func (wr *webhookRegistry) list() []Webhook {
+	wr.Lock()
+	defer wr.Unlock()
+
+	hooks := wr.sorted()
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+
+	return hooks
+}
+
+// listDeliveries returns the logged deliveries of the webhook, or of all the
+// webhooks if id is empty, with the given status if not empty, newest first
+func (wr *webhookRegistry) listDeliveries(id, status string) []WebhookDelivery {
+	wr.Lock()
+	defer wr.Unlock()
+
+	var ds []WebhookDelivery
+	for i := len(wr.deliveries) - 1; i >= 0; i-- {
+		d := wr.deliveries[i]
+		if id != "" && d.WebhookID != id {
+			continue
+		}
+		if status != "" && d.Status != status {
+			continue
+		}
+		ds = append(ds, *d)
+	}
+
+	return ds
+}
+
+// webhookUxOut is an output received or spent in a block, with its decoded address
+type webhookUxOut struct {
+	addr cipher.Address
+	out  WebhookUxOut
+}
+
+// blockUxOuts are the outputs received and spent in a block, in the order of its transactions
+type blockUxOuts struct {
+	received []webhookUxOut
+	spent    []webhookUxOut
+}
+
+// newBlockUxOuts reads the outputs of the block. The outputs it spends are read from the
+// history db, which must have parsed the block.
+func newBlockUxOuts(hist uxOutGetter, b *coin.Block) (*blockUxOuts, error) {
+	bu := &blockUxOuts{}
+	for i := range b.Body.Transactions {
+		txn := &b.Body.Transactions[i]
+		txid := txn.Hash().Hex()
+
+		for _, in := range txn.In {
+			ux, err := hist.GetUxOutByID(in)
+			if err != nil {
+				return nil, err
+			}
+			if ux == nil {
+				return nil, fmt.Errorf("spent output %s does not exist in history db", in.Hex())
+			}
+
+			bu.spent = append(bu.spent, webhookUxOut{
+				addr: ux.Out.Body.Address,
+				out: WebhookUxOut{
+					Uxid:    in.Hex(),
+					Address: ux.Out.Body.Address.String(),
+					Coins:   ux.Out.Body.Coins,
+					Hours:   ux.Out.Body.Hours,
+					Txid:    txid,
+				},
+			})
+		}
+
+		for _, ux := range coin.CreateUnspents(b.Head, *txn) {
+			bu.received = append(bu.received, webhookUxOut{
+				addr: ux.Body.Address,
+				out: WebhookUxOut{
+					Uxid:    ux.Hash().Hex(),
+					Address: ux.Body.Address.String(),
+					Coins:   ux.Body.Coins,
+					Hours:   ux.Body.Hours,
+					Txid:    txid,
+				},
+			})
+		}
+	}
+
+	return bu, nil
+}
+
+// payload builds the payload of the webhook for the block, returns false if the
+// block has no activity on the webhook's addresses
+func (wr *webhookRegistry) payload(h Webhook, bu *blockUxOuts, w walletAddressGetter, b *coin.Block) (WebhookPayload, bool) {
+	addrs := make(map[cipher.Address]struct{})
+
+	for _, s := range h.Addresses {
+		a, err := cipher.DecodeBase58Address(s)
+		if err != nil {
+			logger.Error("Webhook %s has invalid address %s: %v", h.ID, s, err)
+			continue
+		}
+		addrs[a] = struct{}{}
+	}
+
+	for _, id := range h.Wallets {
+		wltAddrs, err := w.GetAddresses(id)
+		if err != nil {
+			logger.Error("Get addresses of wallet %s of webhook %s failed: %v", id, h.ID, err)
+			continue
+		}
+		for _, a := range wltAddrs {
+			addrs[a] = struct{}{}
+		}
+	}
+
+	p := WebhookPayload{
+		WebhookID: h.ID,
+		BlockSeq:  b.Seq(),
+		BlockHash: b.HashHeader().Hex(),
+		BlockTime: b.Head.Time,
+		Received:  []WebhookUxOut{},
+		Spent:     []WebhookUxOut{},
+	}
+
+	for _, ux := range bu.received {
+		if _, ok := addrs[ux.addr]; ok {
+			p.Received = append(p.Received, ux.out)
+		}
+	}
+
+	for _, ux := range bu.spent {
+		if _, ok := addrs[ux.addr]; ok {
+			p.Spent = append(p.Spent, ux.out)
+		}
+	}
+
+	return p, len(p.Received) > 0 || len(p.Spent) > 0
+}
+
+// notify delivers the activity of the block to the webhooks. The spent outputs are read
+// from the history db, which must have parsed the block.
+func (wr *webhookRegistry) notify(hist uxOutGetter, w walletAddressGetter, b *coin.Block) []WebhookDelivery {
+	wr.Lock()
+	hooks := wr.sorted()
+	wr.Unlock()
+
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	bu, err := newBlockUxOuts(hist, b)
+	if err != nil {
+		logger.Error("Read outputs of block %d for the webhooks failed: %v", b.Seq(), err)
+		return nil
+	}
+
+	var ds []WebhookDelivery
+	for _, h := range hooks {
+		p, ok := wr.payload(h, bu, w, b)
+		if !ok {
+			continue
+		}
+		ds = append(ds, wr.dispatch(h, p))
+	}
+
+	return ds
+}
+
+// replay delivers again the activity of the blocks from seq from to seq to, inclusive,
+// to the webhook
+func (wr *webhookRegistry) replay(id string, from, to uint64, hist uxOutGetter, w walletAddressGetter, bc blockBySeqGetter) ([]WebhookDelivery, error) {
+	wr.Lock()
+	h, ok := wr.hooks[id]
+	wr.Unlock()
+
+	if !ok {
+		return nil, ErrWebhookNotExist
+	}
+
+	if to < from {
+		return nil, errors.New("to_seq must not be lower than from_seq")
+	}
+
+	if to-from >= wr.cfg.MaxReplayBlocks {
+		return nil, fmt.Errorf("at most %d blocks can be replayed at once", wr.cfg.MaxReplayBlocks)
+	}
+
+	ds := []WebhookDelivery{}
+	for seq := from; seq <= to; seq++ {
+		sb, err := bc.GetBlockBySeq(seq)
+		if err != nil {
+			return ds, err
+		}
+		if sb == nil {
+			break
+		}
+
+		bu, err := newBlockUxOuts(hist, &sb.Block)
+		if err != nil {
+			return ds, err
+		}
+
+		p, ok := wr.payload(h, bu, w, &sb.Block)
+		if !ok {
+			continue
+		}
+		p.Replay = true
+
+		ds = append(ds, wr.dispatch(h, p))
+	}
+
+	return ds, nil
+}
+
+// dispatch logs the delivery and queues it, starting a worker if less than
+// MaxConcurrentDeliveries are running
+func (wr *webhookRegistry) dispatch(h Webhook, p WebhookPayload) WebhookDelivery {
+	now := utc.UnixNow()
+
+	wr.Lock()
+	wr.lastID++
+	p.DeliveryID = wr.lastID
+	d := &WebhookDelivery{
+		ID:        wr.lastID,
+		WebhookID: h.ID,
+		URL:       h.URL,
+		Status:    WebhookDeliveryPending,
+		Created:   now,
+		Updated:   now,
+		Payload:   p,
+	}
+
+	wr.deliveries = append(wr.deliveries, d)
+	if n := len(wr.deliveries) - wr.cfg.MaxDeliveries; n > 0 {
+		wr.deliveries = append([]*WebhookDelivery{}, wr.deliveries[n:]...)
+	}
+	logged := *d
+
+	wr.queue = append(wr.queue, webhookJob{d: d, secret: h.Secret})
+	// One worker runs even if MaxConcurrentDeliveries is not set
+	start := wr.workers < wr.cfg.MaxConcurrentDeliveries || wr.workers == 0
+	if start {
+		wr.workers++
+	}
+	wr.Unlock()
+
+	if start {
+		go wr.work()
+	}
+
+	return logged
+}
+
+// work delivers the queued deliveries until the queue is empty
+func (wr *webhookRegistry) work() {
+	for {
+		wr.Lock()
+		if len(wr.queue) == 0 {
+			wr.queue = nil
+			wr.workers--
+			wr.Unlock()
+			return
+		}
+		j := wr.queue[0]
+		wr.queue = wr.queue[1:]
+		wr.Unlock()
+
+		wr.deliver(j.d, j.secret)
+	}
+}
+
+// deliver posts the payload to the webhook url, retrying with an exponential backoff
+func (wr *webhookRegistry) deliver(d *WebhookDelivery, secret string) {
+	body, err := json.Marshal(d.Payload)
+	if err != nil {
+		logger.Error("Marshal webhook payload failed: %v", err)
+		return
+	}
+
+	deliveryID := strconv.FormatUint(d.ID, 10)
+	header := http.Header{}
+	header.Set(WebhookSignatureHeader, SignWebhookPayload(secret, body))
+	header.Set(WebhookDeliveryHeader, deliveryID)
+
+	if err := postWithRetry(wr.client, d.URL, body, header, wr.cfg.Attempts, wr.cfg.RetryInterval, func(err error) {
+		wr.Lock()
+		defer wr.Unlock()
+
+		d.Attempts++
+		d.Updated = utc.UnixNow()
+		if err != nil {
+			d.Error = err.Error()
+		} else {
+			d.Status = WebhookDeliveryDelivered
+			d.Error = ""
+		}
+	}); err != nil {
+		logger.Error("Webhook delivery %s to %s failed after %d attempts: %v", deliveryID, d.URL, wr.cfg.Attempts, err)
+
+		wr.Lock()
+		d.Status = WebhookDeliveryFailed
+		wr.Unlock()
+	}
+}
+
+// postWithRetry posts the JSON body to url, retrying with an exponential backoff starting
+// at interval until attempts requests were made. attempted is called with the result of
+// each request, if not nil.
+func postWithRetry(client *http.Client, url string, body []byte, header http.Header, attempts uint64, interval time.Duration, attempted func(error)) error {
+	post := func() error {
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return backoff.Permanent(err)
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		err = func() error {
+			rsp, err := client.Do(req)
+			if err != nil {
+				return err
+			}
+			defer rsp.Body.Close()
+
+			if rsp.StatusCode < 200 || rsp.StatusCode >= 300 {
+				return fmt.Errorf("status %s", rsp.Status)
+			}
+			return nil
+		}()
+
+		if attempted != nil {
+			attempted(err)
+		}
+
+		return err
+	}
+
+	eb := backoff.NewExponentialBackOff()
+	eb.InitialInterval = interval
+	eb.MaxElapsedTime = 0
+
+	// WithMaxTries retries forever when max is 0
+	var b backoff.BackOff = &backoff.StopBackOff{}
+	if attempts > 1 {
+		b = backoff.WithMaxTries(eb, attempts-1)
+	}
+
+	return backoff.RetryNotify(post, b, func(err error, wait time.Duration) {
+		logger.Warning("POST to %s failed: %v, retrying in %v", url, err, wait)
+	})
+}
+
+// EnableWebhooks notifies the registered webhooks of the address activity of
+// the executed blocks. Must be called before the daemon runs.
+func (vs *Visor) EnableWebhooks(cfg WebhookConfig) error {
+	wr, err := newWebhookRegistry(cfg)
+	if err != nil {
+		return err
+	}
+
+	vs.webhooks = wr
+	return nil
+}
+
+// NotifyWebhooks delivers the address activity of the executed block to the webhooks
+func (vs *Visor) NotifyWebhooks(b *coin.Block) {
+	if vs.webhooks == nil {
+		return
+	}
+
+	vs.webhooks.notify(vs.v, vs.v.Wallets, b)
+}
+
+// AddWebhook registers a webhook, returns it with its id and secret
+func (gw *Gateway) AddWebhook(h Webhook) (Webhook, error) {
+	var err error
+	gw.strand("AddWebhook", func() {
+		if gw.d.Visor.webhooks == nil {
+			err = errWebhooksDisabled
+			return
+		}
+		h, err = gw.d.Visor.webhooks.add(h, gw.d.Visor.v.Wallets, utc.Now())
+	})
+	return h, err
+}
+
+// RemoveWebhook unregisters a webhook
+func (gw *Gateway) RemoveWebhook(id string) error {
+	var err error
+	gw.strand("RemoveWebhook", func() {
+		if gw.d.Visor.webhooks == nil {
+			err = errWebhooksDisabled
+			return
+		}
+		err = gw.d.Visor.webhooks.remove(id)
+	})
+	return err
+}
+
+// GetWebhooks returns the registered webhooks without their secret
+func (gw *Gateway) GetWebhooks() []Webhook {
+	var hooks []Webhook
+	gw.strand("GetWebhooks", func() {
+		if gw.d.Visor.webhooks == nil {
+			return
+		}
+		hooks = gw.d.Visor.webhooks.list()
+	})
+	return hooks
+}
+
+// GetWebhookDeliveries returns the logged deliveries, newest first, filtered
+// by webhook id and status if not empty
+func (gw *Gateway) GetWebhookDeliveries(id, status string) []WebhookDelivery {
+	var ds []WebhookDelivery
+	gw.strand("GetWebhookDeliveries", func() {
+		if gw.d.Visor.webhooks == nil {
+			return
+		}
+		ds = gw.d.Visor.webhooks.listDeliveries(id, status)
+	})
+	return ds
+}
+
+// ReplayWebhook delivers again the address activity of the blocks from seq from to seq to
+// to the webhook, returns the new deliveries
+func (gw *Gateway) ReplayWebhook(id string, from, to uint64) ([]WebhookDelivery, error) {
+	var ds []WebhookDelivery
+	var err error
+	gw.strand("ReplayWebhook", func() {
+		if gw.d.Visor.webhooks == nil {
+			err = errWebhooksDisabled
+			return
+		}
+		ds, err = gw.d.Visor.webhooks.replay(id, from, to, gw.d.Visor.v, gw.d.Visor.v.Wallets, gw.d.Visor.v.Blockchain)
+	})
+	return ds, err
+}
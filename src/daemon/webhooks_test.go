@@ -0,0 +1,493 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/testutil"
+	"github.com/skycoin/skycoin/src/visor/historydb"
+)
+
+type fakeUxOuts map[cipher.SHA256]*historydb.UxOut
+
+func (fh fakeUxOuts) GetUxOutByID(id cipher.SHA256) (*historydb.UxOut, error) {
+	return fh[id], nil
+}
+
+type fakeWalletAddrs map[string][]cipher.Address
+
+func (fw fakeWalletAddrs) GetAddresses(wltID string) ([]cipher.Address, error) {
+	addrs, ok := fw[wltID]
+	if !ok {
+		return nil, errors.New("wallet doesn't exist")
+	}
+	return addrs, nil
+}
+
+func makeHistoryUxOut(addr cipher.Address, seq uint64, coins uint64) *historydb.UxOut {
+	var ux historydb.UxOut
+	ux.Out.Head.BkSeq = seq
+	ux.Out.Body.Address = addr
+	ux.Out.Body.Coins = coins
+	ux.Out.Body.SrcTransaction = cipher.SumSHA256(cipher.RandByte(32))
+	return &ux
+}
+
+func makeWebhookBlock(seq uint64, txns ...coin.Transaction) *coin.SignedBlock {
+	return &coin.SignedBlock{
+		Block: coin.Block{
+			Head: coin.BlockHeader{
+				BkSeq: seq,
+				Time:  1000 + seq,
+			},
+			Body: coin.BlockBody{
+				Transactions: txns,
+			},
+		},
+	}
+}
+
+func makeWebhookTxn(in []cipher.SHA256, to ...cipher.Address) coin.Transaction {
+	txn := coin.Transaction{
+		In: in,
+	}
+	for i, a := range to {
+		txn.Out = append(txn.Out, coin.TransactionOutput{
+			Address: a,
+			Coins:   uint64(i+1) * 1e6,
+			Hours:   10,
+		})
+	}
+	return txn
+}
+
+// newTestWebhookConfig allows the delivery to the test servers
+func newTestWebhookConfig() WebhookConfig {
+	cfg := NewWebhookConfig()
+	cfg.AllowedHosts = []string{"127.0.0.1"}
+	return cfg
+}
+
+// webhookReceiver records the payloads posted with a valid signature,
+// failing the first failures requests
+type webhookReceiver struct {
+	sync.Mutex
+	secret   string
+	failures int
+	requests int
+	payloads chan WebhookPayload
+}
+
+func (wr *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	wr.Lock()
+	wr.requests++
+	n := wr.requests
+	wr.Unlock()
+
+	if n <= wr.failures {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if !VerifyWebhookSignature(wr.secret, body, r.Header.Get(WebhookSignatureHeader)) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var p WebhookPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	wr.payloads <- p
+}
+
+func (wr *webhookReceiver) next(t *testing.T) WebhookPayload {
+	select {
+	case p := <-wr.payloads:
+		return p
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not received")
+		return WebhookPayload{}
+	}
+}
+
+func waitDelivery(t *testing.T, wr *webhookRegistry, id uint64, status string) WebhookDelivery {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, d := range wr.listDeliveries("", status) {
+			if d.ID == id {
+				return d
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("delivery %d is not %s", id, status)
+	return WebhookDelivery{}
+}
+
+func TestWebhookNotify(t *testing.T) {
+	rcv := &webhookReceiver{
+		secret:   "secret",
+		payloads: make(chan WebhookPayload, 10),
+	}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	addr := testutil.MakeAddress()
+	wltAddr := testutil.MakeAddress()
+	other := testutil.MakeAddress()
+
+	spent := makeHistoryUxOut(wltAddr, 3, 3e6)
+	spentID := spent.Out.Hash()
+	otherSpent := makeHistoryUxOut(other, 3, 4e6)
+	otherSpentID := otherSpent.Out.Hash()
+
+	hist := fakeUxOuts{
+		spentID:      spent,
+		otherSpentID: otherSpent,
+	}
+	wallets := fakeWalletAddrs{
+		"foo.wlt": {wltAddr},
+	}
+
+	wr, err := newWebhookRegistry(newTestWebhookConfig())
+	require.NoError(t, err)
+
+	h, err := wr.add(Webhook{
+		URL:       srv.URL,
+		Secret:    rcv.secret,
+		Addresses: []string{addr.String()},
+		Wallets:   []string{"foo.wlt"},
+	}, wallets, time.Now())
+	require.NoError(t, err)
+	require.NotEmpty(t, h.ID)
+
+	// No activity on the webhook's addresses
+	require.Empty(t, wr.notify(hist, wallets, &makeWebhookBlock(6, makeWebhookTxn([]cipher.SHA256{otherSpentID}, other)).Block))
+
+	txn := makeWebhookTxn([]cipher.SHA256{spentID}, addr, other)
+	b := makeWebhookBlock(5, txn)
+	ds := wr.notify(hist, wallets, &b.Block)
+	require.Len(t, ds, 1)
+	require.Equal(t, WebhookDeliveryPending, ds[0].Status)
+
+	p := rcv.next(t)
+	require.Equal(t, ds[0].Payload, p)
+	require.Equal(t, h.ID, p.WebhookID)
+	require.Equal(t, ds[0].ID, p.DeliveryID)
+	require.Equal(t, uint64(5), p.BlockSeq)
+	require.Equal(t, b.HashHeader().Hex(), p.BlockHash)
+	require.Equal(t, uint64(1005), p.BlockTime)
+	require.False(t, p.Replay)
+
+	uxs := coin.CreateUnspents(b.Head, txn)
+	require.Equal(t, []WebhookUxOut{{
+		Uxid:    uxs[0].Hash().Hex(),
+		Address: addr.String(),
+		Coins:   1e6,
+		Hours:   10,
+		Txid:    txn.Hash().Hex(),
+	}}, p.Received)
+
+	require.Equal(t, []WebhookUxOut{{
+		Uxid:    spentID.Hex(),
+		Address: wltAddr.String(),
+		Coins:   3e6,
+		Txid:    txn.Hash().Hex(),
+	}}, p.Spent)
+
+	// The spent outputs must be in the history db
+	require.Empty(t, wr.notify(fakeUxOuts{}, wallets, &b.Block))
+
+	d := waitDelivery(t, wr, ds[0].ID, WebhookDeliveryDelivered)
+	require.Equal(t, uint64(1), d.Attempts)
+	require.Empty(t, d.Error)
+}
+
+func TestWebhookRetryAndReplay(t *testing.T) {
+	rcv := &webhookReceiver{
+		secret:   "secret",
+		failures: 1,
+		payloads: make(chan WebhookPayload, 10),
+	}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	addr := testutil.MakeAddress()
+	hist := fakeUxOuts{}
+	blocks := fakeBlocks{}
+	for seq := uint64(0); seq <= 4; seq++ {
+		to := testutil.MakeAddress()
+		if seq == 2 || seq == 4 {
+			to = addr
+		}
+		blocks[seq] = makeWebhookBlock(seq, makeWebhookTxn(nil, to))
+	}
+
+	cfg := newTestWebhookConfig()
+	cfg.RetryInterval = 10 * time.Millisecond
+	cfg.MaxReplayBlocks = 10
+	wr, err := newWebhookRegistry(cfg)
+	require.NoError(t, err)
+
+	h, err := wr.add(Webhook{
+		URL:       srv.URL,
+		Secret:    rcv.secret,
+		Addresses: []string{addr.String()},
+	}, fakeWalletAddrs{}, time.Now())
+	require.NoError(t, err)
+
+	ds := wr.notify(hist, fakeWalletAddrs{}, &blocks[4].Block)
+	require.Len(t, ds, 1)
+	require.Equal(t, uint64(4), rcv.next(t).BlockSeq)
+
+	d := waitDelivery(t, wr, ds[0].ID, WebhookDeliveryDelivered)
+	require.Equal(t, uint64(2), d.Attempts)
+
+	// Blocks after the head are not replayed
+	ds, err = wr.replay(h.ID, 1, 8, hist, fakeWalletAddrs{}, blocks)
+	require.NoError(t, err)
+	require.Len(t, ds, 2)
+
+	seqs := map[uint64]bool{}
+	for i := 0; i < 2; i++ {
+		p := rcv.next(t)
+		require.True(t, p.Replay)
+		seqs[p.BlockSeq] = true
+	}
+	require.Equal(t, map[uint64]bool{2: true, 4: true}, seqs)
+
+	_, err = wr.replay(h.ID, 0, 10, hist, fakeWalletAddrs{}, blocks)
+	require.Error(t, err)
+
+	_, err = wr.replay("foo", 0, 1, hist, fakeWalletAddrs{}, blocks)
+	require.Equal(t, ErrWebhookNotExist, err)
+
+	// The delivery log is newest first
+	logged := wr.listDeliveries(h.ID, "")
+	require.Len(t, logged, 3)
+	require.Equal(t, ds[1].ID, logged[0].ID)
+}
+
+func TestWebhookConcurrentDeliveries(t *testing.T) {
+	rcv := &webhookReceiver{
+		secret:   "secret",
+		payloads: make(chan WebhookPayload, 10),
+	}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	addr := testutil.MakeAddress()
+	hist := fakeUxOuts{}
+	blocks := fakeBlocks{}
+	for seq := uint64(0); seq < 5; seq++ {
+		blocks[seq] = makeWebhookBlock(seq, makeWebhookTxn(nil, addr))
+	}
+
+	cfg := newTestWebhookConfig()
+	cfg.MaxConcurrentDeliveries = 2
+	wr, err := newWebhookRegistry(cfg)
+	require.NoError(t, err)
+
+	h, err := wr.add(Webhook{
+		URL:       srv.URL,
+		Secret:    rcv.secret,
+		Addresses: []string{addr.String()},
+	}, fakeWalletAddrs{}, time.Now())
+	require.NoError(t, err)
+
+	ds, err := wr.replay(h.ID, 0, 4, hist, fakeWalletAddrs{}, blocks)
+	require.NoError(t, err)
+	require.Len(t, ds, 5)
+
+	wr.Lock()
+	require.True(t, wr.workers <= 2)
+	wr.Unlock()
+
+	for _, d := range ds {
+		waitDelivery(t, wr, d.ID, WebhookDeliveryDelivered)
+	}
+
+	// The workers stop when the queue is empty
+	for i := 0; i < 100; i++ {
+		wr.Lock()
+		workers := wr.workers
+		wr.Unlock()
+		if workers == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("workers did not stop")
+}
+
+func TestWebhookFailed(t *testing.T) {
+	rcv := &webhookReceiver{
+		secret:   "secret",
+		failures: 10,
+		payloads: make(chan WebhookPayload, 10),
+	}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	addr := testutil.MakeAddress()
+	hist := fakeUxOuts{}
+	b := makeWebhookBlock(1, makeWebhookTxn(nil, addr))
+
+	cfg := newTestWebhookConfig()
+	cfg.Attempts = 2
+	cfg.RetryInterval = 10 * time.Millisecond
+	cfg.MaxDeliveries = 1
+	wr, err := newWebhookRegistry(cfg)
+	require.NoError(t, err)
+
+	_, err = wr.add(Webhook{
+		URL:       srv.URL,
+		Addresses: []string{addr.String()},
+	}, fakeWalletAddrs{}, time.Now())
+	require.NoError(t, err)
+
+	ds := wr.notify(hist, fakeWalletAddrs{}, &b.Block)
+	require.Len(t, ds, 1)
+
+	d := waitDelivery(t, wr, ds[0].ID, WebhookDeliveryFailed)
+	require.Equal(t, uint64(2), d.Attempts)
+	require.Equal(t, "status 500 Internal Server Error", d.Error)
+
+	// The log keeps the last MaxDeliveries deliveries
+	ds = wr.notify(hist, fakeWalletAddrs{}, &b.Block)
+	logged := wr.listDeliveries("", "")
+	require.Len(t, logged, 1)
+	require.Equal(t, ds[0].ID, logged[0].ID)
+}
+
+func TestWebhookPrivateHost(t *testing.T) {
+	rcv := &webhookReceiver{
+		payloads: make(chan WebhookPayload, 10),
+	}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	addr := testutil.MakeAddress()
+
+	// The test server listens on the loopback address, which is not allowed by default
+	cfg := NewWebhookConfig()
+	cfg.Attempts = 1
+	wr, err := newWebhookRegistry(cfg)
+	require.NoError(t, err)
+
+	_, err = wr.add(Webhook{
+		URL:       srv.URL,
+		Addresses: []string{addr.String()},
+	}, fakeWalletAddrs{}, time.Now())
+	require.NoError(t, err)
+
+	ds := wr.notify(fakeUxOuts{}, fakeWalletAddrs{}, &makeWebhookBlock(1, makeWebhookTxn(nil, addr)).Block)
+	require.Len(t, ds, 1)
+
+	d := waitDelivery(t, wr, ds[0].ID, WebhookDeliveryFailed)
+	require.Contains(t, d.Error, errCallbackAddrNotAllowed.Error())
+
+	rcv.Lock()
+	require.Equal(t, 0, rcv.requests)
+	rcv.Unlock()
+}
+
+func TestWebhookRegistry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "webhooks")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cfg := NewWebhookConfig()
+	cfg.Filename = filepath.Join(dir, "webhooks.json")
+
+	wr, err := newWebhookRegistry(cfg)
+	require.NoError(t, err)
+	require.Empty(t, wr.list())
+
+	addr := testutil.MakeAddress().String()
+	wallets := fakeWalletAddrs{"foo.wlt": nil}
+
+	cases := []struct {
+		name string
+		hook Webhook
+	}{
+		{
+			name: "invalid url scheme",
+			hook: Webhook{URL: "ftp://example.com", Addresses: []string{addr}},
+		},
+		{
+			name: "no addresses",
+			hook: Webhook{URL: "http://example.com"},
+		},
+		{
+			name: "invalid address",
+			hook: Webhook{URL: "http://example.com", Addresses: []string{"xyz"}},
+		},
+		{
+			name: "unknown wallet",
+			hook: Webhook{URL: "http://example.com", Wallets: []string{"bar.wlt"}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := wr.add(tc.hook, wallets, time.Now())
+			require.Error(t, err)
+		})
+	}
+
+	h1, err := wr.add(Webhook{URL: "http://example.com/a", Addresses: []string{addr}}, wallets, time.Now())
+	require.NoError(t, err)
+	require.Len(t, h1.Secret, 64)
+
+	h2, err := wr.add(Webhook{URL: "http://example.com/b", Wallets: []string{"foo.wlt"}}, wallets, time.Now().Add(time.Second))
+	require.NoError(t, err)
+
+	// At most MaxWebhooks are registered
+	wr.cfg.MaxWebhooks = 2
+	_, err = wr.add(Webhook{URL: "http://example.com/c", Addresses: []string{addr}}, wallets, time.Now())
+	require.EqualError(t, err, "at most 2 webhooks can be registered")
+
+	// The secrets are persisted but not listed
+	loaded, err := newWebhookRegistry(cfg)
+	require.NoError(t, err)
+	require.Equal(t, h1, loaded.hooks[h1.ID])
+	require.Equal(t, h2, loaded.hooks[h2.ID])
+
+	hooks := loaded.list()
+	require.Len(t, hooks, 2)
+	require.Equal(t, h1.ID, hooks[0].ID)
+	require.Empty(t, hooks[0].Secret)
+	require.Empty(t, hooks[1].Secret)
+
+	require.NoError(t, wr.remove(h1.ID))
+	require.Equal(t, ErrWebhookNotExist, wr.remove(h1.ID))
+
+	loaded, err = newWebhookRegistry(cfg)
+	require.NoError(t, err)
+	hooks = loaded.list()
+	require.Len(t, hooks, 1)
+	require.Equal(t, h2.ID, hooks[0].ID)
+}
@@ -0,0 +1,108 @@
+package gui
+
+// Master node administration
+
+import (
+	"encoding/json"
+	"mime"
+	"net"
+	"net/http"
+
+	"github.com/skycoin/skycoin/src/daemon"
+	wh "github.com/skycoin/skycoin/src/util/http" //http,json helpers
+	"github.com/skycoin/skycoin/src/visor"
+)
+
+// RegisterAdminHandlers registers the master node administration handlers,
+// they only serve requests from the loopback interface
+func RegisterAdminHandlers(mux *http.ServeMux, gateway *daemon.Gateway) {
+	// get or update the block production policy
+	mux.HandleFunc("/admin/blockPolicy", localhostOnly(blockPolicyHandler(gateway)))
+	// get the metrics of the last created blocks
+	mux.HandleFunc("/admin/blockMetrics", localhostOnly(blockMetricsHandler(gateway)))
+}
+
+// localhostOnly responds 403 to the requests which don't come from a loopback address
+func localhostOnly(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+
+		if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+			wh.Error403(w)
+			return
+		}
+
+		h(w, r)
+	}
+}
+
+// isJSON checks the Content-Type of the request is application/json. A form post
+// from a browser can't set this content type without a preflight.
+func isJSON(r *http.Request) bool {
+	ct, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && ct == "application/json"
+}
+
+// get or update the block production policy of the master node
+// method: GET, POST
+// url: /admin/blockPolicy
+// POST content type: application/json
+// POST body: JSON object with any of the fields interval, min_txns, max_txns,
+// max_block_size, txn_ordering. Fields which are not
+// set keep their current value. The new policy applies to the next block.
+func blockPolicyHandler(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			wh.SendOr404(w, visor.NewReadableBlockPolicy(gateway.GetBlockPolicy()))
+		case http.MethodPost:
+			if !isJSON(r) {
+				wh.Error415(w)
+				return
+			}
+
+			rp := visor.NewReadableBlockPolicy(gateway.GetBlockPolicy())
+			if err := json.NewDecoder(r.Body).Decode(&rp); err != nil {
+				wh.Error400(w, err.Error())
+				return
+			}
+
+			p, err := rp.ToBlockPolicy()
+			if err != nil {
+				wh.Error400(w, err.Error())
+				return
+			}
+
+			if err := gateway.SetBlockPolicy(p); err != nil {
+				wh.Error400(w, err.Error())
+				return
+			}
+
+			wh.SendOr404(w, visor.NewReadableBlockPolicy(p))
+		default:
+			wh.Error405(w)
+		}
+	}
+}
+
+// get the metrics of the last blocks created by the master node, newest first
+// method: GET
+// url: /admin/blockMetrics
+func blockMetricsHandler(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			wh.Error405(w)
+			return
+		}
+
+		metrics := gateway.GetBlockMetrics()
+		if metrics == nil {
+			metrics = []visor.BlockMetrics{}
+		}
+
+		wh.SendOr404(w, metrics)
+	}
+}
@@ -4,13 +4,17 @@ package gui
 
 import (
 	"fmt"
+	"math"
 	"net/http"
 	"strconv"
 
 	"github.com/skycoin/skycoin/src/cipher"
 	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/util/droplet"
 	wh "github.com/skycoin/skycoin/src/util/http"
+	"github.com/skycoin/skycoin/src/util/utc"
 	"github.com/skycoin/skycoin/src/visor" //http,json helpers
+	"github.com/skycoin/skycoin/src/visor/historydb"
 
 	"github.com/skycoin/skycoin/src/daemon"
 )
@@ -21,6 +25,10 @@ const lastBlockNum = 10
 func RegisterBlockchainHandlers(mux *http.ServeMux, gateway *daemon.Gateway) {
 	mux.HandleFunc("/blockchain/metadata", blockchainHandler(gateway))
 	mux.HandleFunc("/blockchain/progress", blockchainProgressHandler(gateway))
+	// get the competing blocks received from peers
+	mux.HandleFunc("/blockchain/forks", getForks(gateway))
+	// get the statistics of a window of blocks
+	mux.HandleFunc("/blockchain/stats", requireHistory(gateway, getBlockchainStats(gateway)))
 
 	// get block by hash or seq
 	mux.HandleFunc("/block", getBlock(gateway))
@@ -44,6 +52,35 @@ func blockchainProgressHandler(gateway *daemon.Gateway) http.HandlerFunc {
 	}
 }
 
+// get the forks detected between the local chain and peers
+// method: GET
+// url: /blockchain/forks
+func getForks(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			wh.Error405(w)
+			return
+		}
+
+		forks, err := gateway.GetForks()
+		if err != nil {
+			if err == daemon.ErrForkDetectionDisabled {
+				wh.Error501(w)
+				return
+			}
+			logger.Error("gateway.GetForks failed: %v", err)
+			wh.Error500(w)
+			return
+		}
+
+		if forks == nil {
+			forks = []visor.ReadableFork{}
+		}
+
+		wh.SendOr404(w, forks)
+	}
+}
+
 // get block by hash or seq
 // method: GET
 // url: /block?hash=[:hash]  or /block?seq[:seq]
@@ -100,12 +137,28 @@ func getBlock(gate *daemon.Gateway) http.HandlerFunc {
 	}
 }
 
+// Returns the blocks from seq start to seq end. With the limit or cursor params, returns a Page
+// of the blocks from seq start, or after the cursor, and end is ignored.
+// method: GET
+// url: /blocks?start=${start}&end=${end}&limit=${limit}&cursor=${cursor}
 func getBlocks(gateway *daemon.Gateway) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			wh.Error405(w)
 			return
 		}
+
+		p, paged, err := parsePageParams(r)
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		if paged {
+			getBlocksPage(gateway, w, r, p)
+			return
+		}
+
 		sstart := r.FormValue("start")
 		start, err := strconv.ParseUint(sstart, 10, 64)
 		if err != nil {
@@ -128,6 +181,52 @@ func getBlocks(gateway *daemon.Gateway) http.HandlerFunc {
 	}
 }
 
+func getBlocksPage(gateway *daemon.Gateway, w http.ResponseWriter, r *http.Request, p pageParams) {
+	var start uint64
+	if sstart := r.FormValue("start"); sstart != "" {
+		var err error
+		start, err = strconv.ParseUint(sstart, 10, 64)
+		if err != nil {
+			wh.Error400(w, fmt.Sprintf("Invalid start value \"%s\"", sstart))
+			return
+		}
+	}
+
+	after, err := p.historyCursor()
+	if err != nil {
+		wh.Error400(w, err.Error())
+		return
+	}
+	if after != nil {
+		start = after.Seq + 1
+	}
+
+	// One more block tells if there is a next page
+	rb, err := gateway.GetBlocks(start, start+uint64(p.limit))
+	if err != nil {
+		wh.Error400(w, fmt.Sprintf("Get blocks failed: %v", err))
+		return
+	}
+
+	blocks := []visor.ReadableBlock{}
+	if rb != nil {
+		blocks = rb.Blocks
+	}
+
+	var next *historydb.Cursor
+	if len(blocks) > p.limit {
+		blocks = blocks[:p.limit]
+		next = &historydb.Cursor{
+			Seq: blocks[p.limit-1].Head.BkSeq,
+		}
+	}
+
+	wh.SendOr404(w, Page{
+		Items:      blocks,
+		NextCursor: historyNextCursor(next),
+	})
+}
+
 // get last N blocks
 func getLastBlocks(gateway *daemon.Gateway) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -157,3 +256,122 @@ func getLastBlocks(gateway *daemon.Gateway) http.HandlerFunc {
 		wh.SendOr404(w, rb)
 	}
 }
+
+// defaultStatsBlocks is the number of last blocks of the statistics when no window is set
+const defaultStatsBlocks = 100
+
+// BlockchainStats are the statistics of a window of blocks
+type BlockchainStats struct {
+	FromSeq  uint64 `json:"from_seq"`
+	ToSeq    uint64 `json:"to_seq"`
+	FromTime uint64 `json:"from_time"`
+	ToTime   uint64 `json:"to_time"`
+	Blocks   uint64 `json:"blocks"`
+	Txns     uint64 `json:"txns"`
+	// Average number of transactions per block
+	TxnsPerBlock float64 `json:"txns_per_block"`
+	// Average number of seconds between two consecutive blocks
+	AvgBlockInterval float64 `json:"avg_block_interval"`
+	CoinsMoved       string  `json:"coins_moved"`
+	// Coin hours burned by the transaction fees
+	HoursBurned     uint64 `json:"hours_burned"`
+	ActiveAddresses uint64 `json:"active_addresses"`
+	UxOutsCreated   uint64 `json:"uxouts_created"`
+	UxOutsSpent     uint64 `json:"uxouts_spent"`
+	// Growth of the unspent output set, negative if it shrank
+	UxOutSetGrowth int64 `json:"uxout_set_growth"`
+}
+
+// Returns the statistics of the blocks from_seq to to_seq, or of the blocks with a time
+// between from_time and to_time, or of the last 100 blocks if no window is set.
+// A window is at most 10000 blocks.
+// method: GET
+// url: /blockchain/stats?from_seq=${seq}&to_seq=${seq} or /blockchain/stats?from_time=${unix}&to_time=${unix}
+// to_seq defaults to the head seq, to_time to the current time
+func getBlockchainStats(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			wh.Error405(w)
+			return
+		}
+
+		params := make(map[string]uint64)
+		for _, k := range []string{"from_seq", "to_seq", "from_time", "to_time"} {
+			v := r.FormValue(k)
+			if v == "" {
+				continue
+			}
+
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				wh.Error400(w, fmt.Sprintf("Invalid %s value \"%s\"", k, v))
+				return
+			}
+			params[k] = n
+		}
+
+		_, seqFrom := params["from_seq"]
+		_, seqTo := params["to_seq"]
+		_, timeFrom := params["from_time"]
+		_, timeTo := params["to_time"]
+
+		var stats *daemon.BlockchainStats
+		var err error
+		switch {
+		case (seqFrom || seqTo) && (timeFrom || timeTo):
+			wh.Error400(w, "should only specify one window, seq or time")
+			return
+		case seqTo && !seqFrom, timeTo && !timeFrom:
+			wh.Error400(w, "window start is not set")
+			return
+		case seqFrom:
+			to, ok := params["to_seq"]
+			if !ok {
+				to = math.MaxUint64
+			}
+			stats, err = gateway.GetBlockchainStats(params["from_seq"], to)
+		case timeFrom:
+			to, ok := params["to_time"]
+			if !ok {
+				to = uint64(utc.UnixNow())
+			}
+			stats, err = gateway.GetBlockchainStatsByTime(params["from_time"], to)
+		default:
+			stats, err = gateway.GetLastBlockchainStats(defaultStatsBlocks)
+		}
+
+		switch err {
+		case nil:
+		case daemon.ErrNoBlocksInWindow:
+			wh.Error404(w)
+			return
+		default:
+			wh.Error400(w, fmt.Sprintf("Get blockchain stats failed: %v", err))
+			return
+		}
+
+		coinsMoved, err := droplet.ToString(stats.CoinsMoved)
+		if err != nil {
+			logger.Error("Failed to convert coins to string: %v", err)
+			wh.Error500(w)
+			return
+		}
+
+		wh.SendOr404(w, BlockchainStats{
+			FromSeq:          stats.FromSeq,
+			ToSeq:            stats.ToSeq,
+			FromTime:         stats.FromTime,
+			ToTime:           stats.ToTime,
+			Blocks:           stats.Blocks,
+			Txns:             stats.Txns,
+			TxnsPerBlock:     stats.TxnsPerBlock,
+			AvgBlockInterval: stats.AvgBlockInterval,
+			CoinsMoved:       coinsMoved,
+			HoursBurned:      stats.HoursBurned,
+			ActiveAddresses:  stats.ActiveAddresses,
+			UxOutsCreated:    stats.UxOutsCreated,
+			UxOutsSpent:      stats.UxOutsSpent,
+			UxOutSetGrowth:   int64(stats.UxOutsCreated) - int64(stats.UxOutsSpent),
+		})
+	}
+}
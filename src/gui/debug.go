@@ -0,0 +1,114 @@
+package gui
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/skycoin/skycoin/src/daemon/strand"
+	wh "github.com/skycoin/skycoin/src/util/http" //http,json helpers
+	"github.com/skycoin/skycoin/src/util/metrics"
+)
+
+// StrandHistogram is a histogram of durations in seconds
+type StrandHistogram struct {
+	// Cumulated counts by upper bound, the last bound is "+Inf"
+	Buckets     []StrandBucket `json:"buckets"`
+	SumSeconds  float64        `json:"sum_seconds"`
+	MeanSeconds float64        `json:"mean_seconds"`
+}
+
+// StrandBucket is a bucket of a StrandHistogram
+type StrandBucket struct {
+	// Upper bound in seconds
+	LE    string `json:"le"`
+	Count uint64 `json:"count"`
+}
+
+// StrandRequestStats are the statistics of the strand calls of a request name
+type StrandRequestStats struct {
+	Name      string          `json:"name"`
+	Calls     uint64          `json:"calls"`
+	Errors    uint64          `json:"errors"`
+	QueueWait StrandHistogram `json:"queue_wait"`
+	Exec      StrandHistogram `json:"exec"`
+}
+
+// StrandExecutingRequest is the request being executed by the daemon loop
+type StrandExecutingRequest struct {
+	Name    string  `json:"name"`
+	Started string  `json:"started"`
+	Seconds float64 `json:"seconds"`
+}
+
+// StrandStats are the statistics of the strand calls since the node started
+type StrandStats struct {
+	Executing []StrandExecutingRequest `json:"executing"`
+	Requests  []StrandRequestStats     `json:"requests"`
+}
+
+func newStrandHistogram(s metrics.HistogramSnapshot) StrandHistogram {
+	h := StrandHistogram{
+		Buckets:     make([]StrandBucket, 0, len(s.Counts)),
+		SumSeconds:  s.Sum,
+		MeanSeconds: s.Mean(),
+	}
+
+	for i, c := range s.Counts {
+		le := "+Inf"
+		if i < len(s.Buckets) {
+			le = strconv.FormatFloat(s.Buckets[i], 'g', -1, 64)
+		}
+
+		h.Buckets = append(h.Buckets, StrandBucket{
+			LE:    le,
+			Count: c,
+		})
+	}
+
+	return h
+}
+
+func newStrandStats(s strand.Stats) StrandStats {
+	now := time.Now()
+	rs := StrandStats{
+		Executing: make([]StrandExecutingRequest, 0, len(s.Executing)),
+		Requests:  make([]StrandRequestStats, 0, len(s.Requests)),
+	}
+
+	for _, r := range s.Executing {
+		rs.Executing = append(rs.Executing, StrandExecutingRequest{
+			Name:    r.Name,
+			Started: r.Started.UTC().Format(time.RFC3339Nano),
+			Seconds: now.Sub(r.Started).Seconds(),
+		})
+	}
+
+	for _, r := range s.Requests {
+		rs.Requests = append(rs.Requests, StrandRequestStats{
+			Name:      r.Name,
+			Calls:     r.Calls,
+			Errors:    r.Errors,
+			QueueWait: newStrandHistogram(r.QueueWait),
+			Exec:      newStrandHistogram(r.Exec),
+		})
+	}
+
+	return rs
+}
+
+// Returns the statistics of the strand calls: the request being executed by the daemon loop
+// and since when, and for each request name the number of calls and errors and the histograms
+// of the queue wait and execution times. Answers while the daemon loop is stalled.
+// method: GET
+// url: /debug/strand
+func strandStatsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			wh.Error405(w)
+			return
+		}
+
+		wh.SendOr404(w, newStrandStats(strand.GetStats()))
+	}
+}
@@ -1,7 +1,10 @@
 package gui
 
 import (
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/skycoin/skycoin/src/cipher"
 	"github.com/skycoin/skycoin/src/daemon"
@@ -13,11 +16,26 @@ import (
 // RegisterExplorerHandlers register explorer handlers
 func RegisterExplorerHandlers(mux *http.ServeMux, gateway *daemon.Gateway) {
 	// get set of pending transactions
-	mux.HandleFunc("/explorer/address", getTransactionsForAddress(gateway))
+	mux.HandleFunc("/explorer/address", requireHistory(gateway, getTransactionsForAddress(gateway)))
 
 	mux.HandleFunc("/explorer/getEffectiveOutputs", getEffectiveOutputs(gateway))
 
 	mux.HandleFunc("/coinSupply", getCoinSupply(gateway))
+
+	// get the addresses with the highest balances
+	mux.HandleFunc("/explorer/richlist", requireHistory(gateway, getRichlist(gateway)))
+
+	// get the number of addresses with a non zero balance
+	mux.HandleFunc("/explorer/addressCount", requireHistory(gateway, getAddressCount(gateway)))
+
+	// get the statistics of an address
+	mux.HandleFunc("/explorer/addressStats", requireHistory(gateway, getAddressStats(gateway)))
+
+	// search blocks, transactions, outputs and addresses
+	mux.HandleFunc("/explorer/search", requireHistory(gateway, search(gateway)))
+
+	// export the transactions of an address as csv or json lines
+	mux.HandleFunc("/explorer/address/export", requireHistory(gateway, addressHistoryExportHandler(gateway)))
 }
 
 // DeprecatedCoinSupply records the coin supply info
@@ -135,8 +153,10 @@ func coinSupply(gateway *daemon.Gateway, w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// Returns the confirmed transactions of the address. With the limit or cursor params, returns a Page
+// of the transactions ordered by block seq then index in the block.
 // method: GET
-// url: /explorer/address?address=${address}
+// url: /explorer/address?address=${address}&limit=${limit}&cursor=${cursor}
 func getTransactionsForAddress(gateway *daemon.Gateway) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
@@ -156,6 +176,40 @@ func getTransactionsForAddress(gateway *daemon.Gateway) http.HandlerFunc {
 			return
 		}
 
+		p, paged, err := parsePageParams(r)
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		if paged {
+			after, err := p.historyCursor()
+			if err != nil {
+				wh.Error400(w, err.Error())
+				return
+			}
+
+			txns, next, err := gateway.GetAddressTxnsPage(cipherAddr, after, p.limit)
+			if err != nil {
+				logger.Error("Get address transactions page failed: %v", err)
+				wh.Error500(w)
+				return
+			}
+
+			resTxs, err := newReadableAddressTxns(gateway, txns)
+			if err != nil {
+				logger.Error("%v", err)
+				wh.Error500(w)
+				return
+			}
+
+			wh.SendOr404(w, Page{
+				Items:      resTxs,
+				NextCursor: historyNextCursor(next),
+			})
+			return
+		}
+
 		txns, err := gateway.GetAddressTxns(cipherAddr)
 		if err != nil {
 			logger.Error("Get address transactions failed: %v", err)
@@ -163,67 +217,290 @@ func getTransactionsForAddress(gateway *daemon.Gateway) http.HandlerFunc {
 			return
 		}
 
-		resTxs := make([]ReadableTransaction, 0, len(txns.Txns))
+		resTxs, err := newReadableAddressTxns(gateway, txns)
+		if err != nil {
+			logger.Error("%v", err)
+			wh.Error500(w)
+			return
+		}
 
-		for _, tx := range txns.Txns {
-			in := make([]visor.ReadableTransactionInput, len(tx.Transaction.In))
-			for i := range tx.Transaction.In {
-				id, err := cipher.SHA256FromHex(tx.Transaction.In[i])
-				if err != nil {
-					logger.Error("%v", err)
-					wh.Error500(w)
-					return
-				}
+		wh.SendOr404(w, &resTxs)
+	}
+}
 
-				uxout, err := gateway.GetUxOutByID(id)
-				if err != nil {
-					logger.Error("%v", err)
-					wh.Error500(w)
-					return
-				}
+// newReadableAddressTxns resolves the addresses of the inputs of the transactions
+func newReadableAddressTxns(gateway *daemon.Gateway, txns *visor.TransactionResults) ([]ReadableTransaction, error) {
+	resTxs := make([]ReadableTransaction, 0, len(txns.Txns))
 
-				if uxout == nil {
-					logger.Error("uxout of %d does not exist in history db", id)
-					wh.Error500(w)
-					return
-				}
+	for _, tx := range txns.Txns {
+		in := make([]visor.ReadableTransactionInput, len(tx.Transaction.In))
+		for i := range tx.Transaction.In {
+			id, err := cipher.SHA256FromHex(tx.Transaction.In[i])
+			if err != nil {
+				return nil, err
+			}
 
-				in[i] = visor.NewReadableTransactionInput(tx.Transaction.In[i], uxout.Out.Body.Address.String())
+			uxout, err := gateway.GetUxOutByID(id)
+			if err != nil {
+				return nil, err
 			}
 
-			resTxs = append(resTxs, NewReadableTransaction(tx, in))
+			if uxout == nil {
+				return nil, fmt.Errorf("uxout of %s does not exist in history db", id.Hex())
+			}
+
+			in[i] = visor.NewReadableTransactionInput(tx.Transaction.In[i], uxout.Out.Body.Address.String())
 		}
 
-		wh.SendOr404(w, &resTxs)
+		resTxs = append(resTxs, NewReadableTransaction(tx, in))
+	}
+
+	return resTxs, nil
+}
+
+// defaultRichlistLimit is the number of rich list entries returned when the limit is not set
+const defaultRichlistLimit = 20
+
+// RichlistBalance is an entry of the rich list
+type RichlistBalance struct {
+	Address string `json:"address"`
+	Coins   string `json:"coins"`
+	// Locked is true for the locked distribution addresses
+	Locked bool `json:"locked"`
+}
+
+// Returns the addresses with the highest balances, richest first. The distribution
+// addresses are left out unless include_distribution is true.
+// method: GET
+// url: /explorer/richlist?limit=${limit}&include_distribution=${bool}
+// limit defaults to 20
+func getRichlist(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			wh.Error405(w)
+			return
+		}
+
+		limit := defaultRichlistLimit
+		if s := r.FormValue("limit"); s != "" {
+			var err error
+			limit, err = strconv.Atoi(s)
+			if err != nil || limit <= 0 {
+				wh.Error400(w, fmt.Sprintf("Invalid limit value \"%s\"", s))
+				return
+			}
+			if limit > maxPageLimit {
+				wh.Error400(w, fmt.Sprintf("limit must not exceed %d", maxPageLimit))
+				return
+			}
+		}
+
+		var includeDistribution bool
+		if s := r.FormValue("include_distribution"); s != "" {
+			var err error
+			includeDistribution, err = strconv.ParseBool(s)
+			if err != nil {
+				wh.Error400(w, fmt.Sprintf("Invalid include_distribution value \"%s\"", s))
+				return
+			}
+		}
+
+		balances, err := gateway.GetRichlist(limit, includeDistribution)
+		if err != nil {
+			logger.Error("Get rich list failed: %v", err)
+			wh.Error500(w)
+			return
+		}
+
+		locked := make(map[string]struct{})
+		for _, a := range visor.GetLockedDistributionAddresses() {
+			locked[a] = struct{}{}
+		}
+
+		richlist := make([]RichlistBalance, 0, len(balances))
+		for _, b := range balances {
+			coins, err := droplet.ToString(b.Balance)
+			if err != nil {
+				logger.Error("Failed to convert coins to string: %v", err)
+				wh.Error500(w)
+				return
+			}
+
+			addr := b.Address.String()
+			_, isLocked := locked[addr]
+			richlist = append(richlist, RichlistBalance{
+				Address: addr,
+				Coins:   coins,
+				Locked:  isLocked,
+			})
+		}
+
+		wh.SendOr404(w, richlist)
+	}
+}
+
+// Returns the number of addresses with a non zero balance
+// method: GET
+// url: /explorer/addressCount
+func getAddressCount(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			wh.Error405(w)
+			return
+		}
+
+		n, err := gateway.AddressCount()
+		if err != nil {
+			logger.Error("Get address count failed: %v", err)
+			wh.Error500(w)
+			return
+		}
+
+		wh.SendOr404(w, struct {
+			Count uint64 `json:"count"`
+		}{n})
 	}
 }
 
-// ReadableTransaction represents readable address transaction
-type ReadableTransaction struct {
-	Status    visor.TransactionStatus `json:"status"`
-	Length    uint32                  `json:"length"`
-	Type      uint8                   `json:"type"`
-	Hash      string                  `json:"txid"`
-	InnerHash string                  `json:"inner_hash"`
-	Timestamp uint64                  `json:"timestamp,omitempty"`
-
-	Sigs []string                          `json:"sigs"`
-	In   []visor.ReadableTransactionInput  `json:"inputs"`
-	Out  []visor.ReadableTransactionOutput `json:"outputs"`
+// AddressStats are the statistics of an address
+type AddressStats struct {
+	Address  string `json:"address"`
+	Balance  string `json:"balance"`
+	Received string `json:"total_received"`
+	Sent     string `json:"total_sent"`
+	TxnCount uint64 `json:"txn_count"`
+	// Seqs of the first and last blocks with a transaction of the address
+	FirstSeenBlock uint64 `json:"first_seen_block"`
+	LastSeenBlock  uint64 `json:"last_seen_block"`
 }
 
-// NewReadableTransaction creates readable address transaction
-func NewReadableTransaction(t visor.TransactionResult, inputs []visor.ReadableTransactionInput) ReadableTransaction {
-	return ReadableTransaction{
-		Status:    t.Status,
-		Length:    t.Transaction.Length,
-		Type:      t.Transaction.Type,
-		Hash:      t.Transaction.Hash,
-		InnerHash: t.Transaction.InnerHash,
-		Timestamp: t.Time,
-
-		Sigs: t.Transaction.Sigs,
-		In:   inputs,
-		Out:  t.Transaction.Out,
+// Returns the statistics of an address, 404 if it has no confirmed transaction
+// method: GET
+// url: /explorer/addressStats?address=${address}
+func getAddressStats(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			wh.Error405(w)
+			return
+		}
+
+		addr := r.FormValue("address")
+		if addr == "" {
+			wh.Error400(w, "address is empty")
+			return
+		}
+
+		cipherAddr, err := cipher.DecodeBase58Address(addr)
+		if err != nil {
+			wh.Error400(w, "invalid address")
+			return
+		}
+
+		s, err := gateway.GetAddressStats(cipherAddr)
+		if err != nil {
+			logger.Error("Get address stats failed: %v", err)
+			wh.Error500(w)
+			return
+		}
+
+		if s == nil {
+			wh.Error404(w)
+			return
+		}
+
+		var coins [3]string
+		for i, c := range []uint64{s.Balance, s.Received, s.Sent} {
+			coins[i], err = droplet.ToString(c)
+			if err != nil {
+				logger.Error("Failed to convert coins to string: %v", err)
+				wh.Error500(w)
+				return
+			}
+		}
+
+		wh.SendOr404(w, AddressStats{
+			Address:        addr,
+			Balance:        coins[0],
+			Received:       coins[1],
+			Sent:           coins[2],
+			TxnCount:       s.TxnCount,
+			FirstSeenBlock: s.FirstSeq,
+			LastSeenBlock:  s.LastSeq,
+		})
+	}
+}
+
+const (
+	// defaultSearchLimit is the number of results of each type returned when the limit is not set
+	defaultSearchLimit = 10
+	// maxSearchLimit bounds the number of results of each type
+	maxSearchLimit = 100
+)
+
+// SearchResult is a block, transaction, output or address matching a search query
+type SearchResult struct {
+	// block, transaction, uxout or address
+	Type string `json:"type"`
+	// Hash of the block, transaction or output, or the address
+	ID string `json:"id"`
+	// Seq of the block, of the block of a confirmed transaction or of the block which created the output
+	BlockSeq  uint64 `json:"block_seq"`
+	Confirmed bool   `json:"confirmed"`
+	// Exact is false for the hashes matched by a prefix of the query
+	Exact bool `json:"exact"`
+}
+
+// Classifies the query and returns the matching results: a block for a block seq, an address
+// for a valid address, and the blocks, transactions and outputs whose hash starts with the query
+// for a hex string of at least 4 characters
+// method: GET
+// url: /explorer/search?q=${query}&limit=${limit}
+// limit is the maximum number of results of each type, 10 by default
+func search(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			wh.Error405(w)
+			return
+		}
+
+		q := strings.TrimSpace(r.FormValue("q"))
+		if q == "" {
+			wh.Error400(w, "q is empty")
+			return
+		}
+
+		limit := defaultSearchLimit
+		if s := r.FormValue("limit"); s != "" {
+			var err error
+			limit, err = strconv.Atoi(s)
+			if err != nil || limit <= 0 {
+				wh.Error400(w, fmt.Sprintf("Invalid limit value \"%s\"", s))
+				return
+			}
+			if limit > maxSearchLimit {
+				wh.Error400(w, fmt.Sprintf("limit must not exceed %d", maxSearchLimit))
+				return
+			}
+		}
+
+		results, err := gateway.Search(q, limit)
+		if err != nil {
+			logger.Error("Search failed: %v", err)
+			wh.Error500(w)
+			return
+		}
+
+		res := make([]SearchResult, 0, len(results))
+		for _, sr := range results {
+			res = append(res, SearchResult{
+				Type:      sr.Type,
+				ID:        sr.ID,
+				BlockSeq:  sr.BlockSeq,
+				Confirmed: sr.Confirmed,
+				Exact:     sr.Exact,
+			})
+		}
+
+		wh.SendOr404(w, res)
 	}
 }
@@ -0,0 +1,84 @@
+package gui
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/skycoin/skycoin/src/daemon"
+	wh "github.com/skycoin/skycoin/src/util/http" //http,json helpers
+)
+
+// sendHealthStatus sends the status as JSON with 200 if ok, 503 otherwise
+func sendHealthStatus(w http.ResponseWriter, ok bool, status interface{}) {
+	b, err := json.MarshalIndent(status, "", "    ")
+	if err != nil {
+		logger.Error("Marshal health status failed: %v", err)
+		wh.Error500(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if ok {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	if _, err := w.Write(b); err != nil {
+		logger.Error("Send health status failed: %v", err)
+	}
+}
+
+// Returns 200 if the data directory accepts writes and the daemon loop answers a request
+// within the timeout, 503 otherwise, with the result of each check as JSON.
+// Answers while the daemon loop is stalled.
+// method: GET
+// url: /health
+func healthHandler(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			wh.Error405(w)
+			return
+		}
+
+		s, err := gateway.GetHealth()
+		if err != nil {
+			if err == daemon.ErrHealthChecksDisabled {
+				wh.Error501(w)
+				return
+			}
+			logger.Error("Get health failed: %v", err)
+			wh.Error500(w)
+			return
+		}
+
+		sendHealthStatus(w, s.OK, s)
+	}
+}
+
+// Returns 200 if the node is within the configured number of blocks of the highest
+// block of its peers, has enough connections and its unconfirmed pool is not saturated,
+// 503 otherwise, with the result of each check as JSON.
+// method: GET
+// url: /ready
+func readyHandler(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			wh.Error405(w)
+			return
+		}
+
+		s, err := gateway.GetReadiness()
+		if err != nil {
+			if err == daemon.ErrHealthChecksDisabled {
+				wh.Error501(w)
+				return
+			}
+			logger.Error("Get readiness failed: %v", err)
+			wh.Error500(w)
+			return
+		}
+
+		sendHealthStatus(w, s.OK, s)
+	}
+}
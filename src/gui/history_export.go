@@ -0,0 +1,152 @@
+package gui
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/daemon"
+	wh "github.com/skycoin/skycoin/src/util/http" //http,json helpers
+	"github.com/skycoin/skycoin/src/visor"
+)
+
+// historyContentTypes are the content types of the history export formats
+var historyContentTypes = map[string]string{
+	visor.HistoryFormatCSV:  "text/csv",
+	visor.HistoryFormatJSON: "application/x-ndjson",
+}
+
+// parseHistoryFormat parses the format param, csv by default
+func parseHistoryFormat(r *http.Request) (string, error) {
+	format := r.FormValue("format")
+	if format == "" {
+		return visor.HistoryFormatCSV, nil
+	}
+
+	if _, ok := historyContentTypes[format]; !ok {
+		return "", fmt.Errorf("Invalid format value \"%s\", must be csv or json", format)
+	}
+
+	return format, nil
+}
+
+// streamHistory sends the history records passed by export to write as an attachment named
+// name, the extension is added for the format. The records are written as they are read,
+// returns true if the response was started, in which case an error can't be reported
+// with an error status anymore.
+func streamHistory(w http.ResponseWriter, name, format string, export func(write func([]visor.HistoryRecord) error) error) (bool, error) {
+	hw, err := visor.NewHistoryWriter(w, format)
+	if err != nil {
+		return false, err
+	}
+
+	started := false
+	start := func() {
+		if started {
+			return
+		}
+		w.Header().Set("Content-Type", historyContentTypes[format])
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.%s\"", name, format))
+		started = true
+	}
+
+	if err := export(func(records []visor.HistoryRecord) error {
+		rs, err := visor.NewReadableHistoryRecords(records)
+		if err != nil {
+			return err
+		}
+
+		start()
+		return hw.Write(rs)
+	}); err != nil {
+		return started, err
+	}
+
+	// The csv header row of an empty history
+	start()
+	return true, hw.Write(nil)
+}
+
+// Exports the confirmed transactions of the wallet, one per csv row or json line, with the
+// block time, block seq, txid, direction (received, sent or self), counterparties, amount,
+// coin hours, burn fee and note
+// method: GET
+// url: /wallet/history/export?id=${wallet_id}&format=${format}
+// format is csv (default) or json
+func walletHistoryExportHandler(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			wh.Error405(w)
+			return
+		}
+
+		wltID := r.FormValue("id")
+		if wltID == "" {
+			wh.Error400(w, "missing wallet id")
+			return
+		}
+
+		format, err := parseHistoryFormat(r)
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		started, err := streamHistory(w, wltID, format, func(write func([]visor.HistoryRecord) error) error {
+			return gateway.ExportWalletHistory(wltID, write)
+		})
+		switch {
+		case err == nil:
+		case started:
+			logger.Error("Send wallet history export failed: %v", err)
+		case err == daemon.ErrWalletNotExist:
+			wh.Error404(w)
+		default:
+			logger.Error("Get wallet history failed: %v", err)
+			wh.Error500(w)
+		}
+	}
+}
+
+// Exports the confirmed transactions of the address, in the format of /wallet/history/export
+// method: GET
+// url: /explorer/address/export?address=${address}&format=${format}
+// format is csv (default) or json
+func addressHistoryExportHandler(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			wh.Error405(w)
+			return
+		}
+
+		addr := r.FormValue("address")
+		if addr == "" {
+			wh.Error400(w, "address is empty")
+			return
+		}
+
+		cipherAddr, err := cipher.DecodeBase58Address(addr)
+		if err != nil {
+			wh.Error400(w, "invalid address")
+			return
+		}
+
+		format, err := parseHistoryFormat(r)
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		started, err := streamHistory(w, addr, format, func(write func([]visor.HistoryRecord) error) error {
+			return gateway.ExportAddressesHistory([]cipher.Address{cipherAddr}, write)
+		})
+		switch {
+		case err == nil:
+		case started:
+			logger.Error("Send address history export failed: %v", err)
+		default:
+			logger.Error("Get address history failed: %v", err)
+			wh.Error500(w)
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package gui
 
 import (
-	"bytes"
 	"crypto/tls"
 	"fmt"
 	"io/ioutil"
@@ -11,8 +10,10 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/skycoin/skycoin/src/api/ws"
 	"github.com/skycoin/skycoin/src/cipher"
 	"github.com/skycoin/skycoin/src/daemon"
+	"github.com/skycoin/skycoin/src/visor"
 	"github.com/skycoin/skycoin/src/wallet"
 
 	"github.com/skycoin/skycoin/src/util/file"
@@ -51,7 +52,7 @@ func LaunchWebInterface(host, staticDir string, daemon *daemon.Daemon) error {
 	}
 
 	// Runs http.Serve() in a goroutine
-	serve(listener, NewGUIMux(appLoc, daemon), quit)
+	serve(listener, instrumentMux(NewGUIMux(appLoc, daemon)), quit)
 	return nil
 }
 
@@ -80,14 +81,14 @@ func LaunchWebInterfaceHTTPS(host, staticDir string, daemon *daemon.Daemon, cert
 	}
 
 	// Runs http.Serve() in a goroutine
-	serve(listener, NewGUIMux(appLoc, daemon), quit)
+	serve(listener, instrumentMux(NewGUIMux(appLoc, daemon)), quit)
 	return nil
 }
 
-func serve(listener net.Listener, mux *http.ServeMux, q chan struct{}) {
+func serve(listener net.Listener, handler http.Handler, q chan struct{}) {
 	go func() {
 		for {
-			if err := http.Serve(listener, mux); err != nil {
+			if err := http.Serve(listener, handler); err != nil {
 				select {
 				case <-q:
 					return
@@ -123,10 +124,20 @@ func NewGUIMux(appLoc string, daemon *daemon.Daemon) *http.ServeMux {
 		mux.Handle(route, http.FileServer(http.Dir(appLoc)))
 	}
 
-	mux.HandleFunc("/logs", getLogsHandler(&daemon.LogBuff))
+	mux.HandleFunc("/logs", getLogsHandler(daemon.LogStore))
 
 	mux.HandleFunc("/version", versionHandler(daemon.Gateway))
 
+	// node metrics in the Prometheus text exposition format
+	mux.HandleFunc("/metrics", metricsHandler(daemon.Gateway))
+
+	// strand call statistics, for diagnosing daemon loop stalls
+	mux.HandleFunc("/debug/strand", strandStatsHandler())
+
+	// liveness and readiness checks for orchestration
+	mux.HandleFunc("/health", healthHandler(daemon.Gateway))
+	mux.HandleFunc("/ready", readyHandler(daemon.Gateway))
+
 	//get set of unspent outputs
 	mux.HandleFunc("/outputs", getOutputsHandler(daemon.Gateway))
 
@@ -145,6 +156,12 @@ func NewGUIMux(appLoc string, daemon *daemon.Daemon) *http.ServeMux {
 	RegisterUxOutHandlers(mux, daemon.Gateway)
 	// expplorer handler
 	RegisterExplorerHandlers(mux, daemon.Gateway)
+	// master node administration
+	RegisterAdminHandlers(mux, daemon.Gateway)
+	// address activity webhooks
+	RegisterWebhookHandlers(mux, daemon.Gateway)
+	// websocket event stream
+	mux.Handle("/ws", ws.NewHandler(daemon.Gateway, ws.NewConfig()))
 	return mux
 }
 
@@ -215,6 +232,19 @@ func getOutputsHandler(gateway *daemon.Gateway) http.HandlerFunc {
 	}
 }
 
+// HistoricalBalance is the balance of addresses as of a block
+type HistoricalBalance struct {
+	Seq       uint64                    `json:"seq"`
+	Time      uint64                    `json:"time"`
+	Confirmed wallet.Balance            `json:"confirmed"`
+	Addresses map[string]wallet.Balance `json:"addresses"`
+}
+
+// Returns the balance of the addresses. With the seq or time param, returns the confirmed
+// balance as of the block of that seq, or of the last block at or before that unix time,
+// with the coin hours as of the time of the block.
+// method: GET
+// url: /balance?addrs=${addrs}&seq=${seq} or /balance?addrs=${addrs}&time=${unix}
 func getBalanceHandler(gateway *daemon.Gateway) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
@@ -236,6 +266,11 @@ func getBalanceHandler(gateway *daemon.Gateway) http.HandlerFunc {
 			addrs = append(addrs, a)
 		}
 
+		if r.FormValue("seq") != "" || r.FormValue("time") != "" {
+			getHistoricalBalance(gateway, w, r, addrs)
+			return
+		}
+
 		bals, err := gateway.GetBalanceOfAddrs(addrs)
 		if err != nil {
 			logger.Error("Get balance failed: %v", err)
@@ -253,77 +288,78 @@ func getBalanceHandler(gateway *daemon.Gateway) http.HandlerFunc {
 	}
 }
 
-func versionHandler(gateway *daemon.Gateway) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			wh.Error405(w)
+func getHistoricalBalance(gateway *daemon.Gateway, w http.ResponseWriter, r *http.Request, addrs []cipher.Address) {
+	// The history db is incomplete when block pruning is enabled
+	if gateway.IsPruningEnabled() {
+		wh.Error501(w)
+		return
+	}
+
+	sseq := r.FormValue("seq")
+	stime := r.FormValue("time")
+	if sseq != "" && stime != "" {
+		wh.Error400(w, "should only specify one of seq or time")
+		return
+	}
+
+	var bals *visor.HistoricalBalances
+	var err error
+	if sseq != "" {
+		seq, perr := strconv.ParseUint(sseq, 10, 64)
+		if perr != nil {
+			wh.Error400(w, fmt.Sprintf("Invalid seq value \"%s\"", sseq))
 			return
 		}
+		bals, err = gateway.GetBalanceOfAddrsAt(addrs, seq)
+	} else {
+		t, perr := strconv.ParseUint(stime, 10, 64)
+		if perr != nil {
+			wh.Error400(w, fmt.Sprintf("Invalid time value \"%s\"", stime))
+			return
+		}
+		bals, err = gateway.GetBalanceOfAddrsAtTime(addrs, t)
+	}
 
-		wh.SendOr404(w, gateway.GetBuildInfo())
+	switch err {
+	case nil:
+	case daemon.ErrBlockNotExist:
+		wh.Error404(w)
+		return
+	default:
+		logger.Error("Get historical balance failed: %v", err)
+		wh.Error500(w)
+		return
 	}
-}
 
-/*
-attrActualLog remove color char in log
-origin: "\u001b[36m[skycoin.daemon:DEBUG] Trying to connect to 47.88.33.156:6000\u001b[0m",
-*/
-func attrActualLog(logInfo string) string {
-	//return logInfo
-	var actualLog string
-	actualLog = logInfo
-	if strings.HasPrefix(logInfo, "[skycoin") {
-		if strings.Contains(logInfo, "\u001b") {
-			actualLog = logInfo[0 : len(logInfo)-4]
+	res := HistoricalBalance{
+		Seq:       bals.Seq,
+		Time:      bals.Time,
+		Addresses: make(map[string]wallet.Balance, len(bals.Balances)),
+	}
+
+	for _, b := range bals.Balances {
+		if _, ok := res.Addresses[b.Address.String()]; ok {
+			continue
 		}
-	} else {
-		if len(logInfo) > 5 {
-			if strings.Contains(logInfo, "\u001b") {
-				actualLog = logInfo[5 : len(logInfo)-4]
-			}
+
+		bal := wallet.Balance{
+			Coins: b.Coins,
+			Hours: b.Hours,
 		}
+		res.Addresses[b.Address.String()] = bal
+		res.Confirmed = res.Confirmed.Add(bal)
 	}
-	return actualLog
+
+	wh.SendOr404(w, res)
 }
-func getLogsHandler(logbuf *bytes.Buffer) http.HandlerFunc {
+
+func versionHandler(gateway *daemon.Gateway) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			wh.Error405(w)
 			return
 		}
 
-		var err error
-		defaultLineNum := 1000 // default line numbers
-		linenum := defaultLineNum
-		if lines := r.FormValue("lines"); lines != "" {
-			linenum, err = strconv.Atoi(lines)
-			if err != nil {
-				linenum = defaultLineNum
-			}
-		}
-		keyword := r.FormValue("include")
-		excludeKeyword := r.FormValue("exclude")
-		logs := []string{}
-		logList := strings.Split(logbuf.String(), "\n")
-		for _, logInfo := range logList {
-			if excludeKeyword != "" && strings.Contains(logInfo, excludeKeyword) {
-				continue
-			}
-			if keyword != "" && !strings.Contains(logInfo, keyword) {
-				continue
-			}
-
-			if len(logs) >= linenum {
-				logger.Debug("logs size %d,total size:%d", len(logs), len(logList))
-				break
-			}
-			log := attrActualLog(logInfo)
-			if "" != log {
-				logs = append(logs, log)
-			}
-
-		}
-
-		wh.SendOr404(w, logs)
+		wh.SendOr404(w, gateway.GetBuildInfo())
 	}
 }
@@ -0,0 +1,250 @@
+package gui
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	wh "github.com/skycoin/skycoin/src/util/http" //http,json helpers
+	"github.com/skycoin/skycoin/src/util/logging"
+)
+
+// LogRecord is a record of the log store
+type LogRecord struct {
+	ID      uint64 `json:"id"`
+	Time    string `json:"time"`
+	Level   string `json:"level"`
+	Module  string `json:"module"`
+	Message string `json:"message"`
+}
+
+func newLogRecord(r logging.Record) LogRecord {
+	return LogRecord{
+		ID:      r.ID,
+		Time:    r.Time.UTC().Format(time.RFC3339Nano),
+		Level:   r.Level.String(),
+		Module:  r.Module,
+		Message: r.Message,
+	}
+}
+
+// parseLogTime parses a unix time in seconds or an RFC3339 time
+func parseLogTime(s string) (time.Time, error) {
+	if t, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(t, 0), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// parseLogQuery parses the level, module, since, until and pattern params
+func parseLogQuery(r *http.Request) (logging.Query, error) {
+	var q logging.Query
+
+	if s := r.FormValue("level"); s != "" {
+		level, err := logging.ParseLevel(s)
+		if err != nil {
+			return logging.Query{}, fmt.Errorf("Invalid level value \"%s\"", s)
+		}
+		q.Level = &level
+	}
+
+	if s := r.FormValue("module"); s != "" {
+		for _, m := range strings.Split(s, ",") {
+			q.Modules = append(q.Modules, strings.TrimSpace(m))
+		}
+	}
+
+	if s := r.FormValue("since"); s != "" {
+		t, err := parseLogTime(s)
+		if err != nil {
+			return logging.Query{}, fmt.Errorf("Invalid since value \"%s\"", s)
+		}
+		q.Since = t
+	}
+
+	if s := r.FormValue("until"); s != "" {
+		t, err := parseLogTime(s)
+		if err != nil {
+			return logging.Query{}, fmt.Errorf("Invalid until value \"%s\"", s)
+		}
+		q.Until = t
+	}
+
+	if s := r.FormValue("pattern"); s != "" {
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return logging.Query{}, fmt.Errorf("Invalid pattern value \"%s\": %v", s, err)
+		}
+		q.Pattern = re
+	}
+
+	return q, nil
+}
+
+// logQueryParams are the params of the record queries, without them /logs keeps its
+// response of text lines
+var logQueryParams = []string{"level", "module", "since", "until", "pattern", "limit", "cursor", "tail"}
+
+// isLogQuery returns true if any of the record query params is set
+func isLogQuery(r *http.Request) bool {
+	for _, k := range logQueryParams {
+		if r.FormValue(k) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// getLogLines returns up to lines log lines, oldest first, containing include and
+// not containing exclude
+func getLogLines(w http.ResponseWriter, r *http.Request, store *logging.Store) {
+	linenum := 1000 // default line numbers
+	if lines := r.FormValue("lines"); lines != "" {
+		if n, err := strconv.Atoi(lines); err == nil {
+			linenum = n
+		}
+	}
+	include := r.FormValue("include")
+	exclude := r.FormValue("exclude")
+
+	rs, _, _ := store.After(logging.Query{}, 0)
+
+	logs := []string{}
+	for _, rec := range rs {
+		if len(logs) >= linenum {
+			break
+		}
+		if exclude != "" && strings.Contains(rec.Line, exclude) {
+			continue
+		}
+		if include != "" && !strings.Contains(rec.Line, include) {
+			continue
+		}
+		logs = append(logs, rec.Line)
+	}
+
+	wh.SendOr404(w, logs)
+}
+
+// Without any of the record query params, returns up to lines log lines, oldest first,
+// containing include and not containing exclude, as a list of strings.
+//
+// Otherwise returns the records of the log store matching the filters, newest first, as a page.
+// level returns the records of that level and the more severe levels, module is a comma
+// separated list of modules, since and until are unix times or RFC3339 times and pattern
+// is a regular expression matched against the message. With tail=true, streams the records
+// written after the request as newline delimited JSON instead, until the client disconnects.
+// Responds with 501 if the node does not keep the logs (-logtogui=false).
+// method: GET
+// url: /logs?lines=[lines]&include=[word]&exclude=[word]
+// url: /logs?level=[level]&module=[modules]&since=[time]&until=[time]&pattern=[regexp]&limit=[limit]&cursor=[cursor]&tail=[bool]
+func getLogsHandler(store *logging.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			wh.Error405(w)
+			return
+		}
+
+		if store == nil {
+			wh.Error501(w)
+			return
+		}
+
+		if !isLogQuery(r) {
+			getLogLines(w, r, store)
+			return
+		}
+
+		q, err := parseLogQuery(r)
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		if tail := r.FormValue("tail"); tail != "" {
+			ok, err := strconv.ParseBool(tail)
+			if err != nil {
+				wh.Error400(w, fmt.Sprintf("Invalid tail value \"%s\"", tail))
+				return
+			}
+			if ok {
+				tailLogs(w, r, store, q)
+				return
+			}
+		}
+
+		p, paged, err := parsePageParams(r)
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+		if !paged {
+			p.limit = defaultPageLimit
+		}
+
+		var before uint64
+		if p.cursor != "" {
+			before, err = strconv.ParseUint(p.cursor, 10, 64)
+			if err != nil || before == 0 {
+				wh.Error400(w, "invalid cursor")
+				return
+			}
+		}
+
+		rs, next := store.Query(q, before, p.limit)
+
+		items := make([]LogRecord, 0, len(rs))
+		for _, rec := range rs {
+			items = append(items, newLogRecord(rec))
+		}
+
+		var nextCursor string
+		if next != 0 {
+			nextCursor = strconv.FormatUint(next, 10)
+		}
+
+		wh.SendOr404(w, Page{
+			Items:      items,
+			NextCursor: nextCursor,
+		})
+	}
+}
+
+// tailLogs streams the records matching the query written after the request, one JSON
+// object per line, until the client disconnects
+func tailLogs(w http.ResponseWriter, r *http.Request, store *logging.Store, q logging.Query) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		wh.Error501(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// Skip the records written before the request
+	_, last, changed := store.After(q, 0)
+
+	enc := json.NewEncoder(w)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-changed:
+		}
+
+		var rs []logging.Record
+		rs, last, changed = store.After(q, last)
+		for _, rec := range rs {
+			if err := enc.Encode(newLogRecord(rec)); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
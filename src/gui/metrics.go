@@ -0,0 +1,67 @@
+package gui
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+
+	"github.com/skycoin/skycoin/src/daemon"
+	wh "github.com/skycoin/skycoin/src/util/http" //http,json helpers
+	"github.com/skycoin/skycoin/src/util/metrics"
+)
+
+var httpRequestSeconds = metrics.NewHistogramVec("http_request_duration_seconds",
+	"Latency of the HTTP requests, by route", metrics.DefaultBuckets, "route")
+
+func init() {
+	metrics.MustRegister(httpRequestSeconds)
+}
+
+// instrumentMux records the latency of the requests by the route they match, the
+// websocket and log tail streams are skipped as their requests last as long as the connection
+func instrumentMux(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, route := mux.Handler(r)
+		if route == "/ws" || (route == "/logs" && r.URL.Query().Get("tail") != "") {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		if route == "" {
+			route = "unmatched"
+		}
+
+		t := time.Now()
+		mux.ServeHTTP(w, r)
+		httpRequestSeconds.WithLabelValues(route).Observe(time.Since(t).Seconds())
+	})
+}
+
+// Returns the metrics of the node in the Prometheus text exposition format
+// method: GET
+// url: /metrics
+func metricsHandler(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			wh.Error405(w)
+			return
+		}
+
+		// The metrics of a stalled daemon are still served, with the last gauge values
+		if err := gateway.UpdateMetrics(); err != nil {
+			logger.Error("Update metrics failed: %v", err)
+		}
+
+		var buf bytes.Buffer
+		if err := metrics.DefaultRegistry.WritePrometheus(&buf); err != nil {
+			logger.Error("Write metrics failed: %v", err)
+			wh.Error500(w)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			logger.Error("Send metrics failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package gui
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/visor"
+	"github.com/skycoin/skycoin/src/visor/historydb"
+)
+
+const (
+	// defaultPageLimit is the page size when the cursor is set without a limit
+	defaultPageLimit = 100
+	// maxPageLimit bounds the page size
+	maxPageLimit = 1000
+)
+
+// Page is the response of a paginated request
+type Page struct {
+	Items interface{} `json:"items"`
+	// Cursor of the next page, empty on the last page
+	NextCursor string `json:"next_cursor"`
+}
+
+// pageParams are the limit and cursor params of a paginated request
+type pageParams struct {
+	limit  int
+	cursor string
+}
+
+// parsePageParams parses the limit and cursor params. Returns false if neither is set,
+// in which case the endpoint keeps its unpaginated response.
+func parsePageParams(r *http.Request) (pageParams, bool, error) {
+	slimit := r.FormValue("limit")
+	cursor := r.FormValue("cursor")
+	if slimit == "" && cursor == "" {
+		return pageParams{}, false, nil
+	}
+
+	p := pageParams{
+		limit:  defaultPageLimit,
+		cursor: cursor,
+	}
+
+	if slimit != "" {
+		limit, err := strconv.Atoi(slimit)
+		if err != nil || limit <= 0 {
+			return pageParams{}, false, fmt.Errorf("Invalid limit value \"%s\"", slimit)
+		}
+		if limit > maxPageLimit {
+			return pageParams{}, false, fmt.Errorf("limit must not exceed %d", maxPageLimit)
+		}
+		p.limit = limit
+	}
+
+	return p, true, nil
+}
+
+// historyCursor parses the cursor of the endpoints backed by the history db, nil if not set
+func (p pageParams) historyCursor() (*historydb.Cursor, error) {
+	if p.cursor == "" {
+		return nil, nil
+	}
+
+	c, err := historydb.ParseCursor(p.cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+func historyNextCursor(c *historydb.Cursor) string {
+	if c == nil {
+		return ""
+	}
+	return c.String()
+}
+
+// unconfirmedTxnKey orders the unconfirmed transactions by the time they were received, then hash
+func unconfirmedTxnKey(ut visor.UnconfirmedTxn) []byte {
+	h := ut.Txn.Hash()
+	k := make([]byte, 8, 8+len(h))
+	binary.BigEndian.PutUint64(k, uint64(ut.Received))
+	return append(k, h[:]...)
+}
+
+// pageUnconfirmedTxns returns the page of the unconfirmed transactions after the cursor, ordered
+// by the time they were received, and the cursor of the next page, empty if it is the last
+func pageUnconfirmedTxns(txns []visor.UnconfirmedTxn, p pageParams) ([]visor.UnconfirmedTxn, string, error) {
+	var after []byte
+	if p.cursor != "" {
+		var err error
+		after, err = hex.DecodeString(p.cursor)
+		if err != nil || len(after) != 8+len(cipher.SHA256{}) {
+			return nil, "", errors.New("invalid cursor")
+		}
+	}
+
+	keys := make([][]byte, len(txns))
+	idx := make([]int, len(txns))
+	for i := range txns {
+		keys[i] = unconfirmedTxnKey(txns[i])
+		idx[i] = i
+	}
+
+	sort.Slice(idx, func(i, j int) bool {
+		return bytes.Compare(keys[idx[i]], keys[idx[j]]) < 0
+	})
+
+	page := []visor.UnconfirmedTxn{}
+	for _, i := range idx {
+		if after != nil && bytes.Compare(keys[i], after) <= 0 {
+			continue
+		}
+
+		if len(page) == p.limit {
+			return page, hex.EncodeToString(unconfirmedTxnKey(page[len(page)-1])), nil
+		}
+
+		page = append(page, txns[i])
+	}
+
+	return page, "", nil
+}
@@ -0,0 +1,22 @@
+package gui
+
+import (
+	"net/http"
+
+	"github.com/skycoin/skycoin/src/daemon"
+	wh "github.com/skycoin/skycoin/src/util/http" //http,json helpers
+)
+
+// requireHistory wraps a handler which depends on the history db,
+// the history db is incomplete when block pruning is enabled so
+// the handler responds with 501 instead.
+func requireHistory(gateway *daemon.Gateway, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if gateway.IsPruningEnabled() {
+			wh.Error501(w)
+			return
+		}
+
+		h(w, r)
+	}
+}
@@ -18,18 +18,27 @@ import (
 func RegisterTxHandlers(mux *http.ServeMux, gateway *daemon.Gateway) {
 	// get set of pending transactions
 	mux.HandleFunc("/pendingTxs", getPendingTxs(gateway))
+	// get the unconfirmed pool statistics
+	mux.HandleFunc("/pendingTxs/stats", getPendingTxsStats(gateway))
 	// get latest confirmed transactions
-	mux.HandleFunc("/lastTxs", getLastTxs(gateway))
+	mux.HandleFunc("/lastTxs", requireHistory(gateway, getLastTxs(gateway)))
 	// get txn by txid
-	mux.HandleFunc("/transaction", getTransactionByID(gateway))
+	mux.HandleFunc("/transaction", requireHistory(gateway, getTransactionByID(gateway)))
 	//inject a transaction into network
 	mux.HandleFunc("/injectTransaction", injectTransaction(gateway))
 	mux.HandleFunc("/resendUnconfirmedTxns", resendUnconfirmedTxns(gateway))
 	// get raw tx by txid.
-	mux.HandleFunc("/rawtx", getRawTx(gateway))
+	mux.HandleFunc("/rawtx", requireHistory(gateway, getRawTx(gateway)))
+	// track the status of transactions
+	mux.HandleFunc("/transactions/track", trackTransactions(gateway))
+	mux.HandleFunc("/transactions/untrack", untrackTransactions(gateway))
+	mux.HandleFunc("/transactions/tracked", getTrackedTransactions(gateway))
 }
 
-// Returns pending transactions
+// Returns pending transactions. With the limit or cursor params, returns a Page
+// of the transactions ordered by the time they were received.
+// method: GET
+// url: /pendingTxs?limit=${limit}&cursor=${cursor}
 func getPendingTxs(gateway *daemon.Gateway) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
@@ -37,7 +46,23 @@ func getPendingTxs(gateway *daemon.Gateway) http.HandlerFunc {
 			return
 		}
 
+		p, paged, err := parsePageParams(r)
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
 		txns := gateway.GetAllUnconfirmedTxns()
+
+		var next string
+		if paged {
+			txns, next, err = pageUnconfirmedTxns(txns, p)
+			if err != nil {
+				wh.Error400(w, err.Error())
+				return
+			}
+		}
+
 		ret := make([]*visor.ReadableUnconfirmedTxn, 0, len(txns))
 		for _, unconfirmedTxn := range txns {
 			readable, err := visor.NewReadableUnconfirmedTxn(&unconfirmedTxn)
@@ -49,10 +74,32 @@ func getPendingTxs(gateway *daemon.Gateway) http.HandlerFunc {
 			ret = append(ret, readable)
 		}
 
+		if paged {
+			wh.SendOr404(w, Page{
+				Items:      ret,
+				NextCursor: next,
+			})
+			return
+		}
+
 		wh.SendOr404(w, &ret)
 	}
 }
 
+// Returns the unconfirmed pool statistics
+// method: GET
+// url: /pendingTxs/stats
+func getPendingTxsStats(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			wh.Error405(w)
+			return
+		}
+
+		wh.SendOr404(w, gateway.GetUnconfirmedStats())
+	}
+}
+
 // DEPRECATED: last txs can't recover from db when restart
 // , and it's not used actually
 func getLastTxs(gateway *daemon.Gateway) http.HandlerFunc {
@@ -220,3 +267,111 @@ func getRawTx(gate *daemon.Gateway) http.HandlerFunc {
 		return
 	}
 }
+
+func parseTxids(txids []string) ([]cipher.SHA256, error) {
+	hashes := make([]cipher.SHA256, 0, len(txids))
+	for _, txid := range txids {
+		h, err := cipher.SHA256FromHex(txid)
+		if err != nil {
+			return nil, fmt.Errorf("invalid txid %q: %v", txid, err)
+		}
+		hashes = append(hashes, h)
+	}
+	return hashes, nil
+}
+
+// Registers transactions whose status changes are sent to the callback url
+// and to the transaction status subscribers
+// method: POST
+// url: /transactions/track
+// body: {"txids": [...], "callback_url": "...", "confirmations": 6}
+func trackTransactions(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			wh.Error405(w)
+			return
+		}
+
+		v := struct {
+			Txids         []string `json:"txids"`
+			CallbackURL   string   `json:"callback_url"`
+			Confirmations uint64   `json:"confirmations"`
+		}{}
+
+		if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		if len(v.Txids) == 0 {
+			wh.Error400(w, "txids is required")
+			return
+		}
+
+		txids, err := parseTxids(v.Txids)
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		if err := gateway.TrackTransactions(daemon.TrackTxnsRequest{
+			Txids:         txids,
+			CallbackURL:   v.CallbackURL,
+			Confirmations: v.Confirmations,
+		}); err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		wh.SendOr404(w, gateway.GetTrackedTransactions())
+	}
+}
+
+// Stops tracking transactions
+// method: POST
+// url: /transactions/untrack
+// body: {"txids": [...]}
+func untrackTransactions(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			wh.Error405(w)
+			return
+		}
+
+		v := struct {
+			Txids []string `json:"txids"`
+		}{}
+
+		if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		txids, err := parseTxids(v.Txids)
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		gateway.UntrackTransactions(txids)
+		wh.SendOr404(w, gateway.GetTrackedTransactions())
+	}
+}
+
+// Returns the tracked transactions and their last known status
+// method: GET
+// url: /transactions/tracked
+func getTrackedTransactions(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			wh.Error405(w)
+			return
+		}
+
+		txns := gateway.GetTrackedTransactions()
+		if txns == nil {
+			txns = []daemon.TrackedTxn{}
+		}
+		wh.SendOr404(w, txns)
+	}
+}
@@ -12,9 +12,9 @@ import (
 // RegisterUxOutHandlers binds uxout entries.
 func RegisterUxOutHandlers(mux *http.ServeMux, gateway *daemon.Gateway) {
 	// get uxout by id.
-	mux.HandleFunc("/uxout", getUxOutByID(gateway))
+	mux.HandleFunc("/uxout", requireHistory(gateway, getUxOutByID(gateway)))
 	// get all the address affected uxouts.
-	mux.HandleFunc("/address_uxouts", getAddrUxOuts(gateway))
+	mux.HandleFunc("/address_uxouts", requireHistory(gateway, getAddrUxOuts(gateway)))
 }
 
 func getUxOutByID(gateway *daemon.Gateway) http.HandlerFunc {
@@ -51,6 +51,10 @@ func getUxOutByID(gateway *daemon.Gateway) http.HandlerFunc {
 	}
 }
 
+// Returns the outputs received by the address. With the limit or cursor params, returns a Page
+// of the outputs ordered by block seq then index in the block.
+// method: GET
+// url: /address_uxouts?address=${address}&limit=${limit}&cursor=${cursor}
 func getAddrUxOuts(gateway *daemon.Gateway) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
@@ -69,6 +73,32 @@ func getAddrUxOuts(gateway *daemon.Gateway) http.HandlerFunc {
 			return
 		}
 
+		p, paged, err := parsePageParams(r)
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		if paged {
+			after, err := p.historyCursor()
+			if err != nil {
+				wh.Error400(w, err.Error())
+				return
+			}
+
+			uxs, next, err := gateway.GetAddrUxOutsPage(cipherAddr, after, p.limit)
+			if err != nil {
+				wh.Error400(w, err.Error())
+				return
+			}
+
+			wh.SendOr404(w, Page{
+				Items:      uxs,
+				NextCursor: historyNextCursor(next),
+			})
+			return
+		}
+
 		uxs, err := gateway.GetAddrUxOuts(cipherAddr)
 		if err != nil {
 			wh.Error400(w, err.Error())
@@ -10,6 +10,7 @@ import (
 	bip39 "github.com/skycoin/skycoin/src/cipher/go-bip39"
 	"github.com/skycoin/skycoin/src/coin"
 	"github.com/skycoin/skycoin/src/daemon"
+	"github.com/skycoin/skycoin/src/util/droplet"
 	"github.com/skycoin/skycoin/src/visor"
 	"github.com/skycoin/skycoin/src/wallet"
 
@@ -308,7 +309,10 @@ func walletGet(gateway *daemon.Gateway) http.HandlerFunc {
 	}
 }
 
-// Returns JSON of unconfirmed transactions for user's wallet
+// Returns JSON of unconfirmed transactions for user's wallet. With the limit or cursor
+// params, returns a Page of the transactions ordered by the time they were received.
+// method: GET
+// url: /wallet/transactions?id=${wallet id}&limit=${limit}&cursor=${cursor}
 func walletTransactionsHandler(gateway *daemon.Gateway) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
@@ -322,16 +326,139 @@ func walletTransactionsHandler(gateway *daemon.Gateway) http.HandlerFunc {
 			return
 		}
 
+		p, paged, err := parsePageParams(r)
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
 		txns, err := gateway.GetWalletUnconfirmedTxns(wltID)
 		if err != nil {
 			wh.Error400(w, fmt.Sprintf("get wallet unconfirmed transactions failed: %v", err))
 			return
 		}
 
+		if paged {
+			page, next, err := pageUnconfirmedTxns(txns, p)
+			if err != nil {
+				wh.Error400(w, err.Error())
+				return
+			}
+
+			wh.SendOr404(w, Page{
+				Items:      page,
+				NextCursor: next,
+			})
+			return
+		}
+
 		wh.SendOr404(w, txns)
 	}
 }
 
+// WalletHistoryTxn is a confirmed transaction of a wallet
+type WalletHistoryTxn struct {
+	visor.ReadableHistoryRecord
+	// Change of the wallet balance, negative for a sent transaction
+	NetCoins      string `json:"net_coins"`
+	Confirmations uint64 `json:"confirmations"`
+}
+
+// newWalletHistoryTxns converts the history records of a wallet
+func newWalletHistoryTxns(records []visor.HistoryRecord) ([]WalletHistoryTxn, error) {
+	rs, err := visor.NewReadableHistoryRecords(records)
+	if err != nil {
+		return nil, err
+	}
+
+	txns := make([]WalletHistoryTxn, 0, len(rs))
+	for i, r := range rs {
+		// The amount of a self transfer stays in the wallet
+		var net string
+		switch r.Direction {
+		case visor.HistorySent:
+			net = "-" + r.Amount
+		case visor.HistorySelf:
+			net, err = droplet.ToString(0)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			net = r.Amount
+		}
+
+		txns = append(txns, WalletHistoryTxn{
+			ReadableHistoryRecord: r,
+			NetCoins:              net,
+			Confirmations:         records[i].Confirmations,
+		})
+	}
+
+	return txns, nil
+}
+
+// Returns a Page of the confirmed transactions of all the addresses of the wallet, ordered by
+// block seq then index in the block. A transaction between the wallet addresses is returned once.
+// Each transaction has its direction (received, sent or self), the amount received or sent,
+// the net change of the wallet balance and its confirmations.
+// method: GET
+// url: /wallet/history?id=${wallet id}&limit=${limit}&cursor=${cursor}
+// limit is 100 by default
+func walletHistoryHandler(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			wh.Error405(w)
+			return
+		}
+
+		wltID := r.FormValue("id")
+		if wltID == "" {
+			wh.Error400(w, "missing wallet id")
+			return
+		}
+
+		p, paged, err := parsePageParams(r)
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		if !paged {
+			p.limit = defaultPageLimit
+		}
+
+		after, err := p.historyCursor()
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		records, next, err := gateway.GetWalletHistoryPage(wltID, after, p.limit)
+		switch err {
+		case nil:
+		case daemon.ErrWalletNotExist:
+			wh.Error404(w)
+			return
+		default:
+			logger.Error("Get wallet history page failed: %v", err)
+			wh.Error500(w)
+			return
+		}
+
+		txns, err := newWalletHistoryTxns(records)
+		if err != nil {
+			logger.Error("Convert wallet history failed: %v", err)
+			wh.Error500(w)
+			return
+		}
+
+		wh.SendOr404(w, Page{
+			Items:      txns,
+			NextCursor: historyNextCursor(next),
+		})
+	}
+}
+
 // Returns all loaded wallets
 func walletsHandler(gateway *daemon.Gateway) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -429,6 +556,18 @@ func RegisterWalletHandlers(mux *http.ServeMux, gateway *daemon.Gateway) {
 	// Returns all pending transanction for all addresses by selected Wallet
 	mux.HandleFunc("/wallet/transactions", walletTransactionsHandler(gateway))
 
+	// GET Arguments:
+	//		id: Wallet ID
+	//		limit, cursor: pagination
+	// Returns the confirmed transactions of all the addresses of the wallet
+	mux.HandleFunc("/wallet/history", requireHistory(gateway, walletHistoryHandler(gateway)))
+
+	// GET Arguments:
+	//		id: Wallet ID
+	//		format: csv (default) or json
+	// Exports the confirmed transactions of the wallet addresses as csv or json lines
+	mux.HandleFunc("/wallet/history/export", requireHistory(gateway, walletHistoryExportHandler(gateway)))
+
 	// Update wallet label
 	// 		GET Arguments:
 	// 			id: wallet id
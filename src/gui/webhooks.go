@@ -0,0 +1,202 @@
+package gui
+
+// Address activity webhooks
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/skycoin/skycoin/src/daemon"
+	wh "github.com/skycoin/skycoin/src/util/http" //http,json helpers
+)
+
+// RegisterWebhookHandlers registers the webhook handlers. The webhooks send the
+// activity of wallets to other hosts, they are only managed from the local host.
+func RegisterWebhookHandlers(mux *http.ServeMux, gateway *daemon.Gateway) {
+	// list the registered webhooks
+	mux.HandleFunc("/webhooks", localhostOnly(getWebhooks(gateway)))
+	// register a webhook
+	mux.HandleFunc("/webhooks/add", localhostOnly(addWebhook(gateway)))
+	// unregister a webhook
+	mux.HandleFunc("/webhooks/remove", localhostOnly(removeWebhook(gateway)))
+	// get the delivery log
+	mux.HandleFunc("/webhooks/deliveries", localhostOnly(getWebhookDeliveries(gateway)))
+	// deliver again the activity of past blocks
+	mux.HandleFunc("/webhooks/replay", localhostOnly(requireHistory(gateway, replayWebhook(gateway))))
+}
+
+// Returns the registered webhooks, without their secret
+// method: GET
+// url: /webhooks
+func getWebhooks(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			wh.Error405(w)
+			return
+		}
+
+		hooks := gateway.GetWebhooks()
+		if hooks == nil {
+			hooks = []daemon.Webhook{}
+		}
+		wh.SendOr404(w, hooks)
+	}
+}
+
+// Registers a webhook notified when a block adds outputs to or spends outputs of the
+// addresses, or of the addresses of the wallets. The payloads are signed with the
+// secret, which is generated if not set. Returns the webhook with its id and secret.
+// method: POST
+// url: /webhooks/add
+// body: {"url": "...", "secret": "...", "addresses": [...], "wallets": [...]}
+func addWebhook(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			wh.Error405(w)
+			return
+		}
+
+		if !isJSON(r) {
+			wh.Error415(w)
+			return
+		}
+
+		v := struct {
+			URL       string   `json:"url"`
+			Secret    string   `json:"secret"`
+			Addresses []string `json:"addresses"`
+			Wallets   []string `json:"wallets"`
+		}{}
+
+		if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		hook, err := gateway.AddWebhook(daemon.Webhook{
+			URL:       v.URL,
+			Secret:    v.Secret,
+			Addresses: v.Addresses,
+			Wallets:   v.Wallets,
+		})
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		wh.SendOr404(w, hook)
+	}
+}
+
+// Unregisters a webhook
+// method: POST
+// url: /webhooks/remove
+// body: {"id": "..."}
+func removeWebhook(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			wh.Error405(w)
+			return
+		}
+
+		if !isJSON(r) {
+			wh.Error415(w)
+			return
+		}
+
+		v := struct {
+			ID string `json:"id"`
+		}{}
+
+		if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		switch err := gateway.RemoveWebhook(v.ID); err {
+		case nil:
+		case daemon.ErrWebhookNotExist:
+			wh.Error404(w)
+			return
+		default:
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		hooks := gateway.GetWebhooks()
+		if hooks == nil {
+			hooks = []daemon.Webhook{}
+		}
+		wh.SendOr404(w, hooks)
+	}
+}
+
+// Returns the logged deliveries, newest first
+// method: GET
+// url: /webhooks/deliveries?id=[webhook id]&status=[pending|delivered|failed]
+// both params are optional
+func getWebhookDeliveries(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			wh.Error405(w)
+			return
+		}
+
+		status := r.FormValue("status")
+		switch status {
+		case "", daemon.WebhookDeliveryPending, daemon.WebhookDeliveryDelivered, daemon.WebhookDeliveryFailed:
+		default:
+			wh.Error400(w, "invalid status")
+			return
+		}
+
+		ds := gateway.GetWebhookDeliveries(r.FormValue("id"), status)
+		if ds == nil {
+			ds = []daemon.WebhookDelivery{}
+		}
+		wh.SendOr404(w, ds)
+	}
+}
+
+// Delivers again the activity of the blocks from from_seq to to_seq to the webhook,
+// returns the new deliveries
+// method: POST
+// url: /webhooks/replay
+// body: {"id": "...", "from_seq": 100, "to_seq": 200}
+func replayWebhook(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			wh.Error405(w)
+			return
+		}
+
+		if !isJSON(r) {
+			wh.Error415(w)
+			return
+		}
+
+		v := struct {
+			ID      string `json:"id"`
+			FromSeq uint64 `json:"from_seq"`
+			ToSeq   uint64 `json:"to_seq"`
+		}{}
+
+		if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		ds, err := gateway.ReplayWebhook(v.ID, v.FromSeq, v.ToSeq)
+		switch err {
+		case nil:
+		case daemon.ErrWebhookNotExist:
+			wh.Error404(w)
+			return
+		default:
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		wh.SendOr404(w, ds)
+	}
+}
@@ -0,0 +1,36 @@
+package testutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/visor/storage"
+)
+
+// StorageEngines are the storage engines the storage backed tests run against
+var StorageEngines = []storage.Engine{
+	storage.EngineBolt,
+	storage.EngineLevelDB,
+	storage.EngineMemory,
+}
+
+// ForEachStore runs f as a subtest against an empty store of each storage engine
+func ForEachStore(t *testing.T, f func(t *testing.T, s storage.Store)) {
+	for _, engine := range StorageEngines {
+		t.Run(string(engine), func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "teststore")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			s, err := storage.Open(engine, filepath.Join(dir, "data.db"))
+			require.NoError(t, err)
+			defer s.Close()
+
+			f(t, s)
+		})
+	}
+}
@@ -40,3 +40,13 @@ func Error501(w http.ResponseWriter) {
 func Error500(w http.ResponseWriter) {
 	HTTPError(w, http.StatusInternalServerError, "Internal Server Error")
 }
+
+// Error403 response 403 error
+func Error403(w http.ResponseWriter) {
+	HTTPError(w, http.StatusForbidden, "Forbidden")
+}
+
+// Error415 response 415 error
+func Error415(w http.ResponseWriter) {
+	HTTPError(w, http.StatusUnsupportedMediaType, "Unsupported Media Type")
+}
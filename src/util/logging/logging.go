@@ -40,6 +40,20 @@ var levelNames = []string{
 	"DEBUG",
 }
 
+// String returns the name of the level
+func (l Level) String() string {
+	if l < 0 || int(l) >= len(levelNames) {
+		return "UNKNOWN"
+	}
+	return levelNames[l]
+}
+
+// ParseLevel parses the name of a level, case insensitive
+func ParseLevel(level string) (Level, error) {
+	l, err := logging.LogLevel(level)
+	return Level(l), err
+}
+
 // LogConfig logger configurations
 type LogConfig struct {
 	// for internal usage
@@ -54,6 +68,8 @@ type LogConfig struct {
 	Colors bool
 	// output
 	Output io.Writer
+	// records are also kept in Store if set
+	Store *Store
 }
 
 // LogLevel parse the log level string
@@ -110,7 +126,11 @@ func (l *LogConfig) InitLogger() {
 	}
 	stdout := logging.NewLogBackend(l.Output, "", 0)
 	stdout.Color = l.Colors
-	logging.SetBackend(stdout)
+	if l.Store != nil {
+		logging.SetBackend(stdout, l.Store)
+	} else {
+		logging.SetBackend(stdout)
+	}
 }
 
 // MustGetLogger safe initialize global logger
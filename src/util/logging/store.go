@@ -0,0 +1,188 @@
+package logging
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	logging "github.com/op/go-logging"
+)
+
+// Record is a log record kept by a Store
+type Record struct {
+	// ID increases with each record written to the store, starting at 1
+	ID      uint64
+	Time    time.Time
+	Level   Level
+	Module  string
+	Message string
+	// Line is the record formatted with the format of the logger, without colors
+	Line string
+}
+
+// Query filters the records of a Store, zero values match all the records
+type Query struct {
+	// Records of this level and the more severe levels
+	Level *Level
+	// Records of these modules
+	Modules []string
+	// Records written at or after Since and before Until
+	Since time.Time
+	Until time.Time
+	// Records whose message matches Pattern
+	Pattern *regexp.Regexp
+}
+
+// Match returns true if the record matches the query
+func (q Query) Match(r Record) bool {
+	if q.Level != nil && r.Level > *q.Level {
+		return false
+	}
+
+	if len(q.Modules) != 0 {
+		found := false
+		for _, m := range q.Modules {
+			if m == r.Module {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if !q.Since.IsZero() && r.Time.Before(q.Since) {
+		return false
+	}
+
+	if !q.Until.IsZero() && !r.Time.Before(q.Until) {
+		return false
+	}
+
+	if q.Pattern != nil && !q.Pattern.MatchString(r.Message) {
+		return false
+	}
+
+	return true
+}
+
+// Store keeps the last records written by the logging backend in a ring buffer,
+// it is a backend of op/go-logging
+type Store struct {
+	sync.Mutex
+	records []Record
+	// index of the next record to write
+	next int
+	// number of records in the buffer
+	n      int
+	lastID uint64
+	// closed and replaced when a record is written
+	changed chan struct{}
+}
+
+// NewStore creates a Store keeping the last size records
+func NewStore(size int) *Store {
+	if size <= 0 {
+		size = 1
+	}
+
+	return &Store{
+		records: make([]Record, size),
+		changed: make(chan struct{}),
+	}
+}
+
+// Log implements the Backend interface of op/go-logging
+func (s *Store) Log(level logging.Level, calldepth int, rec *logging.Record) error {
+	s.write(Record{
+		Time:    rec.Time,
+		Level:   Level(level),
+		Module:  rec.Module,
+		Message: rec.Message(),
+		Line:    rec.Formatted(calldepth + 1),
+	})
+	return nil
+}
+
+// Write adds a record to the store, the oldest record is discarded if the store is full.
+// The line of the record has the default format.
+func (s *Store) Write(t time.Time, level Level, module, message string) {
+	s.write(Record{
+		Time:    t,
+		Level:   level,
+		Module:  module,
+		Message: message,
+		Line:    fmt.Sprintf("[%s:%s] %s", module, level, message),
+	})
+}
+
+func (s *Store) write(r Record) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.lastID++
+	r.ID = s.lastID
+	s.records[s.next] = r
+
+	s.next = (s.next + 1) % len(s.records)
+	if s.n < len(s.records) {
+		s.n++
+	}
+
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// at returns the i-th record from the oldest, must be called with the lock held
+func (s *Store) at(i int) Record {
+	return s.records[(s.next-s.n+i+len(s.records))%len(s.records)]
+}
+
+// Query returns up to limit records matching the query, newest first, written before the
+// record of id before, or the newest records if before is 0. Also returns the id to pass
+// as before to get the next page, 0 if there are no more records.
+func (s *Store) Query(q Query, before uint64, limit int) ([]Record, uint64) {
+	s.Lock()
+	defer s.Unlock()
+
+	rs := []Record{}
+	for i := s.n - 1; i >= 0; i-- {
+		r := s.at(i)
+		if before != 0 && r.ID >= before {
+			continue
+		}
+
+		if !q.Match(r) {
+			continue
+		}
+
+		if len(rs) == limit {
+			return rs, rs[len(rs)-1].ID
+		}
+
+		rs = append(rs, r)
+	}
+
+	return rs, 0
+}
+
+// After returns the records matching the query written after the record of id after, oldest
+// first, the id of the last record written and a channel closed when a record is written.
+// Tailing the store is done by calling After again with the returned id when the channel closes.
+func (s *Store) After(q Query, after uint64) ([]Record, uint64, <-chan struct{}) {
+	s.Lock()
+	defer s.Unlock()
+
+	rs := []Record{}
+	for i := 0; i < s.n; i++ {
+		r := s.at(i)
+		if r.ID <= after || !q.Match(r) {
+			continue
+		}
+		rs = append(rs, r)
+	}
+
+	return rs, s.lastID, s.changed
+}
@@ -0,0 +1,126 @@
+package logging
+
+import (
+	"io/ioutil"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func recordIDs(rs []Record) []uint64 {
+	ids := make([]uint64, 0, len(rs))
+	for _, r := range rs {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+func TestStoreQuery(t *testing.T) {
+	s := NewStore(4)
+	t0 := time.Unix(1500000000, 0)
+
+	s.Write(t0, INFO, "daemon", "connected to 1.2.3.4")
+	s.Write(t0.Add(time.Second), ERROR, "visor", "execute block failed")
+	s.Write(t0.Add(2*time.Second), DEBUG, "daemon", "ping")
+	s.Write(t0.Add(3*time.Second), WARNING, "gui", "slow request")
+	s.Write(t0.Add(4*time.Second), INFO, "daemon", "connected to 5.6.7.8")
+
+	// The first record is discarded
+	rs, next := s.Query(Query{}, 0, 10)
+	require.Equal(t, []uint64{5, 4, 3, 2}, recordIDs(rs))
+	require.Equal(t, uint64(0), next)
+
+	rs, next = s.Query(Query{}, 0, 2)
+	require.Equal(t, []uint64{5, 4}, recordIDs(rs))
+	require.Equal(t, uint64(4), next)
+
+	rs, next = s.Query(Query{}, next, 2)
+	require.Equal(t, []uint64{3, 2}, recordIDs(rs))
+	require.Equal(t, uint64(0), next)
+
+	level := WARNING
+	rs, _ = s.Query(Query{Level: &level}, 0, 10)
+	require.Equal(t, []uint64{4, 2}, recordIDs(rs))
+
+	rs, _ = s.Query(Query{Modules: []string{"daemon", "gui"}}, 0, 10)
+	require.Equal(t, []uint64{5, 4, 3}, recordIDs(rs))
+
+	rs, _ = s.Query(Query{
+		Since: t0.Add(2 * time.Second),
+		Until: t0.Add(4 * time.Second),
+	}, 0, 10)
+	require.Equal(t, []uint64{4, 3}, recordIDs(rs))
+
+	rs, _ = s.Query(Query{Pattern: regexp.MustCompile(`^connected to \d`)}, 0, 10)
+	require.Equal(t, []uint64{5}, recordIDs(rs))
+	require.Equal(t, "connected to 5.6.7.8", rs[0].Message)
+	require.Equal(t, INFO, rs[0].Level)
+	require.Equal(t, "daemon", rs[0].Module)
+}
+
+func TestStoreAfter(t *testing.T) {
+	s := NewStore(10)
+
+	rs, last, changed := s.After(Query{}, 0)
+	require.Empty(t, rs)
+	require.Equal(t, uint64(0), last)
+
+	s.Write(time.Now(), INFO, "daemon", "a")
+	s.Write(time.Now(), DEBUG, "daemon", "b")
+
+	select {
+	case <-changed:
+	default:
+		t.Fatal("changed is not closed")
+	}
+
+	level := INFO
+	rs, last, changed = s.After(Query{Level: &level}, last)
+	require.Equal(t, []uint64{1}, recordIDs(rs))
+	require.Equal(t, uint64(2), last)
+
+	select {
+	case <-changed:
+		t.Fatal("changed is closed")
+	default:
+	}
+
+	s.Write(time.Now(), INFO, "daemon", "c")
+	<-changed
+
+	rs, last, _ = s.After(Query{}, last)
+	require.Equal(t, []uint64{3}, recordIDs(rs))
+	require.Equal(t, "[daemon:INFO] c", rs[0].Line)
+	require.Equal(t, uint64(3), last)
+}
+
+func TestStoreBackend(t *testing.T) {
+	s := NewStore(10)
+	cfg := DevLogConfig([]string{"store_test"})
+	cfg.Output = ioutil.Discard
+	cfg.Store = s
+	cfg.InitLogger()
+	defer Disable()
+
+	logger := MustGetLogger("store_test")
+	logger.Warning("disk %d%% full", 90)
+
+	rs, _ := s.Query(Query{}, 0, 10)
+	require.Len(t, rs, 1)
+	require.Equal(t, WARNING, rs[0].Level)
+	require.Equal(t, "store_test", rs[0].Module)
+	require.Equal(t, "disk 90% full", rs[0].Message)
+	require.Equal(t, "[store_test:WARNING] disk 90% full", rs[0].Line)
+}
+
+func TestParseLevel(t *testing.T) {
+	l, err := ParseLevel("warning")
+	require.NoError(t, err)
+	require.Equal(t, WARNING, l)
+	require.Equal(t, "WARNING", l.String())
+
+	_, err = ParseLevel("verbose")
+	require.Error(t, err)
+}
@@ -0,0 +1,524 @@
+// Package metrics implements counters, gauges and histograms written in the Prometheus
+// text exposition format, so that the node can be scraped without a client library
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultBuckets are the upper bounds in seconds of the histograms of latencies
+var DefaultBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// DefaultRegistry is the registry of the metrics of the node, exposed by the /metrics endpoint
+var DefaultRegistry = NewRegistry()
+
+// Collector is a metric or a family of labeled metrics
+type Collector interface {
+	// Name returns the metric name
+	Name() string
+	// Write writes the HELP and TYPE lines and the samples
+	Write(w io.Writer) error
+}
+
+// Registry holds the collectors of the exposed metrics
+type Registry struct {
+	sync.Mutex
+	collectors map[string]Collector
+}
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{
+		collectors: make(map[string]Collector),
+	}
+}
+
+// Register adds the collectors, the metric names must be unique
+func (r *Registry) Register(cs ...Collector) error {
+	r.Lock()
+	defer r.Unlock()
+
+	for _, c := range cs {
+		if _, ok := r.collectors[c.Name()]; ok {
+			return fmt.Errorf("metric %s is already registered", c.Name())
+		}
+		r.collectors[c.Name()] = c
+	}
+
+	return nil
+}
+
+// MustRegister registers the collectors in DefaultRegistry, panics if a name is already registered
+func MustRegister(cs ...Collector) {
+	if err := DefaultRegistry.Register(cs...); err != nil {
+		panic(err)
+	}
+}
+
+// WritePrometheus writes the metrics ordered by name in the text exposition format
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	r.Lock()
+	cs := make([]Collector, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		cs = append(cs, c)
+	}
+	r.Unlock()
+
+	sort.Slice(cs, func(i, j int) bool {
+		return cs[i].Name() < cs[j].Name()
+	})
+
+	bw := bufio.NewWriter(w)
+	for _, c := range cs {
+		if err := c.Write(bw); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+func writeHeader(w io.Writer, name, help, typ string) error {
+	help = strings.Replace(strings.Replace(help, `\`, `\\`, -1), "\n", `\n`, -1)
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	return err
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatLabels formats the label pairs as {name="value",...}, extra is appended unescaped
+func formatLabels(names, values []string, extra string) string {
+	if len(names) == 0 && extra == "" {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(names)+1)
+	for i, n := range names {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, n, labelValueReplacer.Replace(values[i])))
+	}
+	if extra != "" {
+		pairs = append(pairs, extra)
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+// Counter is a monotonically increasing value
+type Counter struct {
+	name string
+	help string
+	v    uint64
+}
+
+// NewCounter creates a Counter
+func NewCounter(name, help string) *Counter {
+	return &Counter{
+		name: name,
+		help: help,
+	}
+}
+
+// Inc increments the counter
+func (c *Counter) Inc() {
+	atomic.AddUint64(&c.v, 1)
+}
+
+// Add adds n to the counter
+func (c *Counter) Add(n uint64) {
+	atomic.AddUint64(&c.v, n)
+}
+
+// Value returns the value of the counter
+func (c *Counter) Value() uint64 {
+	return atomic.LoadUint64(&c.v)
+}
+
+// Name implements Collector
+func (c *Counter) Name() string {
+	return c.name
+}
+
+// Write implements Collector
+func (c *Counter) Write(w io.Writer) error {
+	if err := writeHeader(w, c.name, c.help, "counter"); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%s %d\n", c.name, c.Value())
+	return err
+}
+
+// Gauge is a value that can go up and down
+type Gauge struct {
+	name string
+	help string
+	bits uint64
+}
+
+// NewGauge creates a Gauge
+func NewGauge(name, help string) *Gauge {
+	return &Gauge{
+		name: name,
+		help: help,
+	}
+}
+
+// Set sets the gauge
+func (g *Gauge) Set(v float64) {
+	atomic.StoreUint64(&g.bits, math.Float64bits(v))
+}
+
+// Value returns the value of the gauge
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&g.bits))
+}
+
+// Name implements Collector
+func (g *Gauge) Name() string {
+	return g.name
+}
+
+// Write implements Collector
+func (g *Gauge) Write(w io.Writer) error {
+	if err := writeHeader(w, g.name, g.help, "gauge"); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%s %s\n", g.name, formatValue(g.Value()))
+	return err
+}
+
+// Histogram counts observations in buckets of upper bounds
+type Histogram struct {
+	sync.Mutex
+	name    string
+	help    string
+	buckets []float64
+	// counts[i] is the number of observations in bucket i, not cumulated,
+	// the last one is the +Inf bucket
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram creates a Histogram with the bucket upper bounds, sorted in increasing order
+func NewHistogram(name, help string, buckets []float64) *Histogram {
+	return &Histogram{
+		name:    name,
+		help:    help,
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)+1),
+	}
+}
+
+// Observe adds an observation
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.buckets, v)
+
+	h.Lock()
+	defer h.Unlock()
+
+	h.counts[i]++
+	h.sum += v
+	h.count++
+}
+
+// HistogramSnapshot are the cumulated bucket counts of a Histogram, the last one is the +Inf bucket
+type HistogramSnapshot struct {
+	Buckets []float64
+	Counts  []uint64
+	Sum     float64
+	Count   uint64
+}
+
+// Quantile returns the upper bound of the bucket holding the q quantile, +Inf if it is
+// above the last bucket and 0 without observations
+func (s HistogramSnapshot) Quantile(q float64) float64 {
+	if s.Count == 0 {
+		return 0
+	}
+
+	rank := q * float64(s.Count)
+	for i, c := range s.Counts {
+		if float64(c) >= rank && i < len(s.Buckets) {
+			return s.Buckets[i]
+		}
+	}
+
+	return math.Inf(1)
+}
+
+// Mean returns the mean of the observations, 0 without observations
+func (s HistogramSnapshot) Mean() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Sum / float64(s.Count)
+}
+
+// Snapshot returns the cumulated counts of the histogram
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	h.Lock()
+	defer h.Unlock()
+
+	s := HistogramSnapshot{
+		Buckets: h.buckets,
+		Counts:  make([]uint64, len(h.counts)),
+		Sum:     h.sum,
+		Count:   h.count,
+	}
+
+	var n uint64
+	for i, c := range h.counts {
+		n += c
+		s.Counts[i] = n
+	}
+
+	return s
+}
+
+// Name implements Collector
+func (h *Histogram) Name() string {
+	return h.name
+}
+
+// Write implements Collector
+func (h *Histogram) Write(w io.Writer) error {
+	if err := writeHeader(w, h.name, h.help, "histogram"); err != nil {
+		return err
+	}
+	return writeHistogramSamples(w, h.name, nil, nil, h.Snapshot())
+}
+
+func writeHistogramSamples(w io.Writer, name string, labelNames, labelValues []string, s HistogramSnapshot) error {
+	for i, c := range s.Counts {
+		le := math.Inf(1)
+		if i < len(s.Buckets) {
+			le = s.Buckets[i]
+		}
+
+		labels := formatLabels(labelNames, labelValues, fmt.Sprintf(`le="%s"`, formatValue(le)))
+		if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", name, labels, c); err != nil {
+			return err
+		}
+	}
+
+	labels := formatLabels(labelNames, labelValues, "")
+	if _, err := fmt.Fprintf(w, "%s_sum%s %s\n", name, labels, formatValue(s.Sum)); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%s_count%s %d\n", name, labels, s.Count)
+	return err
+}
+
+// vec holds the metrics of a family by label values
+type vec struct {
+	sync.Mutex
+	name       string
+	help       string
+	labelNames []string
+	metrics    map[string]interface{}
+	values     map[string][]string
+}
+
+func newVec(name, help string, labelNames []string) vec {
+	return vec{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		metrics:    make(map[string]interface{}),
+		values:     make(map[string][]string),
+	}
+}
+
+// get returns the metric of the label values, created by create if it does not exist
+func (v *vec) get(values []string, create func() interface{}) interface{} {
+	if len(values) != len(v.labelNames) {
+		panic(fmt.Sprintf("metric %s has %d labels, got %d values", v.name, len(v.labelNames), len(values)))
+	}
+
+	k := strings.Join(values, "\xff")
+
+	v.Lock()
+	defer v.Unlock()
+
+	m, ok := v.metrics[k]
+	if !ok {
+		m = create()
+		v.metrics[k] = m
+		v.values[k] = append([]string{}, values...)
+	}
+
+	return m
+}
+
+// each calls f with the label values and metric of the family, ordered by label values
+func (v *vec) each(f func(values []string, m interface{}) error) error {
+	v.Lock()
+	keys := make([]string, 0, len(v.metrics))
+	for k := range v.metrics {
+		keys = append(keys, k)
+	}
+	metrics := make([]interface{}, 0, len(keys))
+	sort.Strings(keys)
+	for _, k := range keys {
+		metrics = append(metrics, v.metrics[k])
+	}
+	values := make([][]string, 0, len(keys))
+	for _, k := range keys {
+		values = append(values, v.values[k])
+	}
+	v.Unlock()
+
+	for i := range keys {
+		if err := f(values[i], metrics[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CounterVec is a family of counters partitioned by label values
+type CounterVec struct {
+	vec
+}
+
+// NewCounterVec creates a CounterVec with the label names
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{
+		vec: newVec(name, help, labelNames),
+	}
+}
+
+// WithLabelValues returns the counter of the label values, in the order of the label names
+func (cv *CounterVec) WithLabelValues(values ...string) *Counter {
+	return cv.get(values, func() interface{} {
+		return NewCounter(cv.name, cv.help)
+	}).(*Counter)
+}
+
+// Name implements Collector
+func (cv *CounterVec) Name() string {
+	return cv.name
+}
+
+// Write implements Collector
+func (cv *CounterVec) Write(w io.Writer) error {
+	if err := writeHeader(w, cv.name, cv.help, "counter"); err != nil {
+		return err
+	}
+
+	return cv.each(func(values []string, m interface{}) error {
+		_, err := fmt.Fprintf(w, "%s%s %d\n", cv.name, formatLabels(cv.labelNames, values, ""), m.(*Counter).Value())
+		return err
+	})
+}
+
+// Each calls f with the label values and value of each counter, ordered by label values
+func (cv *CounterVec) Each(f func(values []string, v uint64)) {
+	cv.each(func(values []string, m interface{}) error {
+		f(values, m.(*Counter).Value())
+		return nil
+	})
+}
+
+// GaugeVec is a family of gauges partitioned by label values
+type GaugeVec struct {
+	vec
+}
+
+// NewGaugeVec creates a GaugeVec with the label names
+func NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	return &GaugeVec{
+		vec: newVec(name, help, labelNames),
+	}
+}
+
+// WithLabelValues returns the gauge of the label values, in the order of the label names
+func (gv *GaugeVec) WithLabelValues(values ...string) *Gauge {
+	return gv.get(values, func() interface{} {
+		return NewGauge(gv.name, gv.help)
+	}).(*Gauge)
+}
+
+// Name implements Collector
+func (gv *GaugeVec) Name() string {
+	return gv.name
+}
+
+// Write implements Collector
+func (gv *GaugeVec) Write(w io.Writer) error {
+	if err := writeHeader(w, gv.name, gv.help, "gauge"); err != nil {
+		return err
+	}
+
+	return gv.each(func(values []string, m interface{}) error {
+		_, err := fmt.Fprintf(w, "%s%s %s\n", gv.name, formatLabels(gv.labelNames, values, ""), formatValue(m.(*Gauge).Value()))
+		return err
+	})
+}
+
+// HistogramVec is a family of histograms partitioned by label values
+type HistogramVec struct {
+	vec
+	buckets []float64
+}
+
+// NewHistogramVec creates a HistogramVec with the bucket upper bounds and the label names
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	return &HistogramVec{
+		vec:     newVec(name, help, labelNames),
+		buckets: buckets,
+	}
+}
+
+// WithLabelValues returns the histogram of the label values, in the order of the label names
+func (hv *HistogramVec) WithLabelValues(values ...string) *Histogram {
+	return hv.get(values, func() interface{} {
+		return NewHistogram(hv.name, hv.help, hv.buckets)
+	}).(*Histogram)
+}
+
+// Name implements Collector
+func (hv *HistogramVec) Name() string {
+	return hv.name
+}
+
+// Write implements Collector
+func (hv *HistogramVec) Write(w io.Writer) error {
+	if err := writeHeader(w, hv.name, hv.help, "histogram"); err != nil {
+		return err
+	}
+
+	return hv.each(func(values []string, m interface{}) error {
+		return writeHistogramSamples(w, hv.name, hv.labelNames, values, m.(*Histogram).Snapshot())
+	})
+}
+
+// Each calls f with the label values and snapshot of each histogram, ordered by label values
+func (hv *HistogramVec) Each(f func(values []string, s HistogramSnapshot)) {
+	hv.each(func(values []string, m interface{}) error {
+		f(values, m.(*Histogram).Snapshot())
+		return nil
+	})
+}
@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryWritePrometheus(t *testing.T) {
+	r := NewRegistry()
+
+	c := NewCounter("requests_total", "Number of requests")
+	c.Inc()
+	c.Add(2)
+
+	g := NewGauge("head_seq", "Seq of the head block")
+	g.Set(42)
+
+	cv := NewCounterVec("messages_total", "Messages by type", "type")
+	cv.WithLabelValues("GIVB").Add(3)
+	cv.WithLabelValues(`a"b`).Inc()
+
+	gv := NewGaugeVec("connections", "Connections by type", "type")
+	gv.WithLabelValues("outgoing").Set(2)
+
+	h := NewHistogram("latency_seconds", "Latency", []float64{0.1, 1})
+	h.Observe(0.05)
+	h.Observe(0.1)
+	h.Observe(5)
+
+	hv := NewHistogramVec("route_seconds", "Latency by route", []float64{1}, "route")
+	hv.WithLabelValues("/health").Observe(0.5)
+
+	require.NoError(t, r.Register(c, g, cv, gv, h, hv))
+	require.Error(t, r.Register(NewGauge("head_seq", "duplicate")))
+
+	var buf bytes.Buffer
+	require.NoError(t, r.WritePrometheus(&buf))
+
+	require.Equal(t, `# HELP connections Connections by type
+# TYPE connections gauge
+connections{type="outgoing"} 2
+# HELP head_seq Seq of the head block
+# TYPE head_seq gauge
+head_seq 42
+# HELP latency_seconds Latency
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 2
+latency_seconds_bucket{le="1"} 2
+latency_seconds_bucket{le="+Inf"} 3
+latency_seconds_sum 5.15
+latency_seconds_count 3
+# HELP messages_total Messages by type
+# TYPE messages_total counter
+messages_total{type="GIVB"} 3
+messages_total{type="a\"b"} 1
+# HELP requests_total Number of requests
+# TYPE requests_total counter
+requests_total 3
+# HELP route_seconds Latency by route
+# TYPE route_seconds histogram
+route_seconds_bucket{route="/health",le="1"} 1
+route_seconds_bucket{route="/health",le="+Inf"} 1
+route_seconds_sum{route="/health"} 0.5
+route_seconds_count{route="/health"} 1
+`, buf.String())
+}
+
+func TestVecLabelCount(t *testing.T) {
+	cv := NewCounterVec("messages_total", "Messages by type", "type")
+	require.Panics(t, func() {
+		cv.WithLabelValues("a", "b")
+	})
+}
+
+func TestHistogramSnapshot(t *testing.T) {
+	h := NewHistogram("latency_seconds", "Latency", []float64{0.1, 1})
+	require.Equal(t, float64(0), h.Snapshot().Quantile(0.5))
+	require.Equal(t, float64(0), h.Snapshot().Mean())
+
+	for _, v := range []float64{0.05, 0.05, 0.5, 2} {
+		h.Observe(v)
+	}
+
+	s := h.Snapshot()
+	require.Equal(t, []uint64{2, 3, 4}, s.Counts)
+	require.Equal(t, 0.1, s.Quantile(0.5))
+	require.Equal(t, float64(1), s.Quantile(0.75))
+	require.True(t, math.IsInf(s.Quantile(0.99), 1))
+	require.Equal(t, 0.65, s.Mean())
+}
+
+func TestVecEach(t *testing.T) {
+	cv := NewCounterVec("errors_total", "Errors by name", "name")
+	cv.WithLabelValues("b").Add(2)
+	cv.WithLabelValues("a").Inc()
+
+	var names []string
+	var counts []uint64
+	cv.Each(func(values []string, v uint64) {
+		names = append(names, values[0])
+		counts = append(counts, v)
+	})
+	require.Equal(t, []string{"a", "b"}, names)
+	require.Equal(t, []uint64{1, 2}, counts)
+
+	hv := NewHistogramVec("exec_seconds", "Execution time by name", []float64{1}, "name")
+	hv.WithLabelValues("a").Observe(2)
+
+	hv.Each(func(values []string, s HistogramSnapshot) {
+		require.Equal(t, []string{"a"}, values)
+		require.Equal(t, uint64(1), s.Count)
+		require.Equal(t, []uint64{0, 1}, s.Counts)
+	})
+}
@@ -0,0 +1,33 @@
+package visor
+
+import (
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/visor/historydb"
+)
+
+// GetAddressStats returns the statistics of the address, nil if it has no confirmed transaction
+func (vs *Visor) GetAddressStats(addr cipher.Address) (*historydb.AddressStats, error) {
+	return vs.history.GetAddressStats(addr)
+}
+
+// GetRichlist returns up to limit addresses with the highest balances, richest first.
+// The distribution addresses are skipped unless includeDistribution is true.
+func (vs *Visor) GetRichlist(limit int, includeDistribution bool) ([]historydb.AddressBalance, error) {
+	var exclude []cipher.Address
+	if !includeDistribution {
+		for _, a := range GetDistributionAddresses() {
+			addr, err := cipher.DecodeBase58Address(a)
+			if err != nil {
+				return nil, err
+			}
+			exclude = append(exclude, addr)
+		}
+	}
+
+	return vs.history.GetRichlist(limit, exclude)
+}
+
+// AddressCount returns the number of addresses with a non zero balance
+func (vs *Visor) AddressCount() (uint64, error) {
+	return vs.history.AddressCount()
+}
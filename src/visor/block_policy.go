@@ -0,0 +1,327 @@
+package visor
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/util/utc"
+	"github.com/skycoin/skycoin/src/visor/blockdb"
+)
+
+const (
+	// TxnOrderingFee orders the unconfirmed transactions by fee, highest first
+	TxnOrderingFee = "fee"
+	// TxnOrderingFeePerByte orders the unconfirmed transactions by fee per byte, highest first
+	TxnOrderingFeePerByte = "fee_per_byte"
+)
+
+// ErrNoBlockTxns is returned by CreateBlockWithPolicy when the block policy doesn't allow
+// a block to be created from the current unconfirmed transactions
+var ErrNoBlockTxns = errors.New("Not enough transactions to create a block")
+
+// BlockPolicy controls how the master node creates blocks
+type BlockPolicy struct {
+	// Time between two blocks
+	Interval time.Duration
+	// Minimum number of transactions of a block. There is no option to create empty
+	// blocks, Blockchain.processTransactions refuses a block without transactions
+	// so the peers would reject it.
+	MinTxns int
+	// Maximum number of transactions of a block, 0 is unlimited
+	MaxTxns int
+	// Maximum size of the transactions of a block in bytes
+	MaxBlockSize int
+	// Order of the unconfirmed transactions, TxnOrderingFee or TxnOrderingFeePerByte
+	TxnOrdering string
+}
+
+// NewBlockPolicy creates the default block policy
+func NewBlockPolicy() BlockPolicy {
+	return BlockPolicy{
+		Interval:     10 * time.Second,
+		MinTxns:      1,
+		MaxBlockSize: 32 * 1024,
+		TxnOrdering:  TxnOrderingFeePerByte,
+	}
+}
+
+// Validate checks the policy values
+func (p BlockPolicy) Validate() error {
+	if p.Interval < time.Second {
+		return fmt.Errorf("block interval %v is less than 1s", p.Interval)
+	}
+
+	if p.MinTxns < 1 {
+		return errors.New("min txns must be at least 1, blocks without transactions are rejected by the peers")
+	}
+
+	if p.MaxTxns < 0 {
+		return errors.New("max txns must not be negative")
+	}
+
+	if p.MaxTxns != 0 && p.MaxTxns < p.MinTxns {
+		return fmt.Errorf("max txns %d is less than min txns %d", p.MaxTxns, p.MinTxns)
+	}
+
+	if p.MaxBlockSize <= 0 {
+		return errors.New("max block size must be positive")
+	}
+
+	switch p.TxnOrdering {
+	case TxnOrderingFee, TxnOrderingFeePerByte:
+	default:
+		return fmt.Errorf("invalid txn ordering %q", p.TxnOrdering)
+	}
+
+	return nil
+}
+
+type txnWithFee struct {
+	txn  coin.Transaction
+	hash string
+	fee  uint64
+	size int
+	// hashes of the candidate transactions whose outputs txn spends
+	parents []string
+}
+
+// SelectTxns orders the transactions according to the policy and picks the ones which fit
+// in the block. Transactions whose fee can't be calculated are skipped. A transaction spending
+// the output of another transaction is placed after it, and is skipped if its parent is.
+// Returns ErrNoBlockTxns if less than MinTxns transactions are selected.
+func (p BlockPolicy) SelectTxns(txns coin.Transactions, feeCalc coin.FeeCalculator) (coin.Transactions, error) {
+	creators := make(map[cipher.SHA256]string)
+	for i := range txns {
+		for _, h := range txnOutputHashes(txns[i]) {
+			creators[h] = txns[i].Hash().Hex()
+		}
+	}
+
+	candidates := make([]txnWithFee, 0, len(txns))
+	for i := range txns {
+		fee, err := feeCalc(&txns[i])
+		if err != nil {
+			logger.Warning("Skip transaction %s, calculate fee failed: %v", txns[i].Hash().Hex(), err)
+			continue
+		}
+
+		var parents []string
+		for _, in := range txns[i].In {
+			if h, ok := creators[in]; ok {
+				parents = append(parents, h)
+			}
+		}
+
+		candidates = append(candidates, txnWithFee{
+			txn:     txns[i],
+			hash:    txns[i].Hash().Hex(),
+			fee:     fee,
+			size:    txns[i].Size(),
+			parents: parents,
+		})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if c := p.compareFees(a, b); c != 0 {
+			return c > 0
+		}
+		return a.hash < b.hash
+	})
+
+	// A transaction waits for a pass where all its parents are selected,
+	// it is never selected if one of its parents is skipped
+	var selected coin.Transactions
+	included := make(map[string]struct{}, len(candidates))
+	done := make([]bool, len(candidates))
+	size := 0
+	for progress := true; progress; {
+		progress = false
+		for i, c := range candidates {
+			if p.MaxTxns > 0 && len(selected) >= p.MaxTxns {
+				break
+			}
+
+			if done[i] || !parentsIncluded(c, included) {
+				continue
+			}
+			done[i] = true
+
+			if size+c.size > p.MaxBlockSize {
+				continue
+			}
+
+			selected = append(selected, c.txn)
+			included[c.hash] = struct{}{}
+			size += c.size
+			progress = true
+		}
+	}
+
+	if len(selected) < p.MinTxns {
+		return nil, ErrNoBlockTxns
+	}
+
+	return selected, nil
+}
+
+func parentsIncluded(c txnWithFee, included map[string]struct{}) bool {
+	for _, h := range c.parents {
+		if _, ok := included[h]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// compareFees returns 1 if a ranks before b, -1 if b ranks before a, 0 if equal
+func (p BlockPolicy) compareFees(a, b txnWithFee) int {
+	if p.TxnOrdering == TxnOrderingFee {
+		switch {
+		case a.fee > b.fee:
+			return 1
+		case a.fee < b.fee:
+			return -1
+		default:
+			return 0
+		}
+	}
+
+	// a.fee/a.size vs b.fee/b.size, cross multiplied to avoid rounding
+	x := new(big.Int).Mul(new(big.Int).SetUint64(a.fee), big.NewInt(int64(b.size)))
+	y := new(big.Int).Mul(new(big.Int).SetUint64(b.fee), big.NewInt(int64(a.size)))
+	return x.Cmp(y)
+}
+
+// ReadableBlockPolicy represents a readable block policy
+type ReadableBlockPolicy struct {
+	Interval     string `json:"interval"`
+	MinTxns      int    `json:"min_txns"`
+	MaxTxns      int    `json:"max_txns"`
+	MaxBlockSize int    `json:"max_block_size"`
+	TxnOrdering  string `json:"txn_ordering"`
+}
+
+// NewReadableBlockPolicy creates a readable block policy
+func NewReadableBlockPolicy(p BlockPolicy) ReadableBlockPolicy {
+	return ReadableBlockPolicy{
+		Interval:     p.Interval.String(),
+		MinTxns:      p.MinTxns,
+		MaxTxns:      p.MaxTxns,
+		MaxBlockSize: p.MaxBlockSize,
+		TxnOrdering:  p.TxnOrdering,
+	}
+}
+
+// ToBlockPolicy converts to a validated block policy
+func (rp ReadableBlockPolicy) ToBlockPolicy() (BlockPolicy, error) {
+	interval, err := time.ParseDuration(rp.Interval)
+	if err != nil {
+		return BlockPolicy{}, fmt.Errorf("invalid interval: %v", err)
+	}
+
+	p := BlockPolicy{
+		Interval:     interval,
+		MinTxns:      rp.MinTxns,
+		MaxTxns:      rp.MaxTxns,
+		MaxBlockSize: rp.MaxBlockSize,
+		TxnOrdering:  rp.TxnOrdering,
+	}
+
+	if err := p.Validate(); err != nil {
+		return BlockPolicy{}, err
+	}
+
+	return p, nil
+}
+
+// BlockMetrics describes a block created by the master node
+type BlockMetrics struct {
+	Seq  uint64 `json:"seq"`
+	Time uint64 `json:"time"`
+	Txns int    `json:"txns"`
+	// Size of the block transactions in bytes
+	Size int `json:"size"`
+	// Coin hours burned by the block transactions
+	Fee uint64 `json:"fee"`
+	// Unconfirmed transactions left in the pool after the block was created
+	PendingTxns int `json:"pending_txns"`
+	// Time taken to create the block in milliseconds
+	CreationMs int64 `json:"creation_ms"`
+}
+
+// CreateBlockWithPolicy creates a block from the unconfirmed transactions selected by the policy.
+// Returns ErrNoBlockTxns if the policy doesn't allow a block to be created.
+func (vs *Visor) CreateBlockWithPolicy(when uint64, p BlockPolicy) (coin.SignedBlock, BlockMetrics, error) {
+	if !vs.Config.IsMaster {
+		logger.Panic("Only master chain can create blocks")
+	}
+
+	start := utc.Now()
+
+	head, err := vs.Blockchain.Head()
+	if err != nil {
+		return coin.SignedBlock{}, BlockMetrics{}, err
+	}
+
+	// Drop the transactions which can't be included in a block. Parents are verified
+	// first, the outputs of a valid parent are spendable by its children.
+	cu := newChainedUnspents(vs.Blockchain.Unspent(), head.Head)
+	var txns coin.Transactions
+	for _, txn := range sortTxnsByDependency(vs.Unconfirmed.RawTxns()) {
+		if err := vs.verifyChainedTxn(txn, cu); err != nil {
+			logger.Warning("Skip transaction %s: %v", txn.Hash().Hex(), err)
+			continue
+		}
+
+		if err := verifyOutputsPrecision(txn); err != nil {
+			logger.Warning("Skip transaction %s: %v", txn.Hash().Hex(), err)
+			continue
+		}
+
+		cu.addTxn(txn)
+		txns = append(txns, txn)
+	}
+
+	// Before the chained spends fork a child waits in the pool until its parent is confirmed
+	if !blockdb.ChainedSpendsActive(head.Head.BkSeq + 1) {
+		txns = dropChildren(txns)
+	}
+
+	txns, err = p.SelectTxns(txns, cu.TransactionFee)
+	if err != nil {
+		return coin.SignedBlock{}, BlockMetrics{}, err
+	}
+
+	b, err := vs.Blockchain.NewBlock(txns, when)
+	if err != nil {
+		return coin.SignedBlock{}, BlockMetrics{}, err
+	}
+
+	sb := vs.SignBlock(*b)
+
+	return sb, BlockMetrics{
+		Seq:         sb.Seq(),
+		Time:        sb.Time(),
+		Txns:        len(txns),
+		Size:        txns.Size(),
+		Fee:         sb.Head.Fee,
+		PendingTxns: vs.Unconfirmed.Len() - len(txns),
+		CreationMs:  int64(utc.Now().Sub(start) / time.Millisecond),
+	}, nil
+}
+
+// verifyOutputsPrecision checks the coins of the transaction outputs have valid decimal places
+func verifyOutputsPrecision(txn coin.Transaction) error {
+	for _, o := range txn.Out {
+		if err := DropletPrecisionCheck(o.Coins); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package visor
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/testutil"
+)
+
+// makePolicyTxn creates a transaction with nOuts outputs, more outputs make a bigger transaction
+func makePolicyTxn(t *testing.T, nOuts int) coin.Transaction {
+	txn := coin.Transaction{}
+	txn.PushInput(testutil.RandSHA256(t))
+	for i := 0; i < nOuts; i++ {
+		txn.PushOutput(testutil.MakeAddress(), 1e6, 1)
+	}
+	txn.Sigs = append(txn.Sigs, cipher.Sig{})
+	txn.UpdateHeader()
+	return txn
+}
+
+func TestBlockPolicySelectTxns(t *testing.T) {
+	small := makePolicyTxn(t, 1)
+	big := makePolicyTxn(t, 10)
+	medium := makePolicyTxn(t, 4)
+	broken := makePolicyTxn(t, 2)
+	require.True(t, small.Size() < medium.Size())
+	require.True(t, medium.Size() < big.Size())
+
+	fees := map[cipher.SHA256]uint64{
+		small.Hash():  30,
+		medium.Hash(): 40,
+		big.Hash():    50,
+	}
+	feeCalc := func(txn *coin.Transaction) (uint64, error) {
+		f, ok := fees[txn.Hash()]
+		if !ok {
+			return 0, errors.New("unknown fee")
+		}
+		return f, nil
+	}
+
+	txns := coin.Transactions{big, broken, small, medium}
+
+	p := NewBlockPolicy()
+	require.NoError(t, p.Validate())
+
+	// Fee per byte, the smallest txn pays the most per byte
+	selected, err := p.SelectTxns(txns, feeCalc)
+	require.NoError(t, err)
+	require.Equal(t, coin.Transactions{small, medium, big}, selected)
+
+	// Absolute fee
+	p.TxnOrdering = TxnOrderingFee
+	selected, err = p.SelectTxns(txns, feeCalc)
+	require.NoError(t, err)
+	require.Equal(t, coin.Transactions{big, medium, small}, selected)
+
+	// Max txns
+	p.MaxTxns = 2
+	selected, err = p.SelectTxns(txns, feeCalc)
+	require.NoError(t, err)
+	require.Equal(t, coin.Transactions{big, medium}, selected)
+
+	// Max block size skips the txns which don't fit
+	p.MaxTxns = 0
+	p.MaxBlockSize = medium.Size() + small.Size()
+	selected, err = p.SelectTxns(txns, feeCalc)
+	require.NoError(t, err)
+	require.Equal(t, coin.Transactions{medium, small}, selected)
+
+	// Min txns
+	p.MaxBlockSize = 32 * 1024
+	p.MinTxns = 4
+	_, err = p.SelectTxns(txns, feeCalc)
+	require.Equal(t, ErrNoBlockTxns, err)
+
+	// No transactions
+	p.MinTxns = 1
+	_, err = p.SelectTxns(nil, feeCalc)
+	require.Equal(t, ErrNoBlockTxns, err)
+}
+
+func TestBlockPolicySelectChainedTxns(t *testing.T) {
+	parent := makePolicyTxn(t, 10)
+	child := coin.Transaction{}
+	child.PushInput(txnOutputHashes(parent)[0])
+	child.PushOutput(testutil.MakeAddress(), 1e6, 1)
+	child.Sigs = append(child.Sigs, cipher.Sig{})
+	child.UpdateHeader()
+	other := makePolicyTxn(t, 1)
+
+	// The child pays the most, but can't come before its parent
+	fees := map[cipher.SHA256]uint64{
+		parent.Hash(): 10,
+		child.Hash():  1000,
+		other.Hash():  20,
+	}
+	feeCalc := func(txn *coin.Transaction) (uint64, error) {
+		return fees[txn.Hash()], nil
+	}
+
+	p := NewBlockPolicy()
+	p.TxnOrdering = TxnOrderingFee
+	selected, err := p.SelectTxns(coin.Transactions{child, other, parent}, feeCalc)
+	require.NoError(t, err)
+	require.Equal(t, coin.Transactions{other, parent, child}, selected)
+
+	// The parent doesn't fit, the child is skipped with it
+	p.MaxBlockSize = child.Size() + other.Size()
+	selected, err = p.SelectTxns(coin.Transactions{child, other, parent}, feeCalc)
+	require.NoError(t, err)
+	require.Equal(t, coin.Transactions{other}, selected)
+}
+
+func TestSortTxnsByDependency(t *testing.T) {
+	a := makePolicyTxn(t, 1)
+	b := makeMempoolTxn(t, txnOutputHashes(a)[0])
+	c := makeMempoolTxn(t, txnOutputHashes(b)[0], testutil.RandSHA256(t))
+	d := makePolicyTxn(t, 2)
+
+	sorted := sortTxnsByDependency(coin.Transactions{c, d, b, a})
+	require.Equal(t, coin.Transactions{a, b, c, d}, sorted)
+}
+
+func TestBlockPolicyValidate(t *testing.T) {
+	tt := []struct {
+		name   string
+		update func(p *BlockPolicy)
+		err    bool
+	}{
+		{"default", func(p *BlockPolicy) {}, false},
+		{"interval too short", func(p *BlockPolicy) { p.Interval = time.Millisecond }, true},
+		{"min txns zero", func(p *BlockPolicy) { p.MinTxns = 0 }, true},
+		{"max txns negative", func(p *BlockPolicy) { p.MaxTxns = -1 }, true},
+		{"max txns below min txns", func(p *BlockPolicy) { p.MinTxns = 5; p.MaxTxns = 2 }, true},
+		{"max block size zero", func(p *BlockPolicy) { p.MaxBlockSize = 0 }, true},
+		{"invalid ordering", func(p *BlockPolicy) { p.TxnOrdering = "random" }, true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			p := NewBlockPolicy()
+			tc.update(&p)
+			err := p.Validate()
+			if tc.err {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestReadableBlockPolicy(t *testing.T) {
+	p := NewBlockPolicy()
+	p.Interval = 90 * time.Second
+	p.MaxTxns = 100
+
+	rp := NewReadableBlockPolicy(p)
+	require.Equal(t, "1m30s", rp.Interval)
+
+	p2, err := rp.ToBlockPolicy()
+	require.NoError(t, err)
+	require.Equal(t, p, p2)
+
+	rp.Interval = "soon"
+	_, err = rp.ToBlockPolicy()
+	require.Error(t, err)
+}
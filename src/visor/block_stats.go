@@ -0,0 +1,97 @@
+package visor
+
+import (
+	"fmt"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/util/fee"
+)
+
+// BlockStats are the statistics of a single block, aggregated over a window of blocks
+// for the blockchain statistics
+type BlockStats struct {
+	Seq  uint64
+	Hash cipher.SHA256
+	Time uint64
+	// Number of transactions
+	TxnCount uint64
+	// Coins spent by the transactions, in droplets
+	CoinsMoved uint64
+	// Coin hours burned by the transaction fees
+	HoursBurned uint64
+	// Number of outputs created and spent
+	UxOutsCreated uint64
+	UxOutsSpent   uint64
+	// Distinct addresses spending from or receiving to in the block
+	Addresses []cipher.Address
+}
+
+// GetBlockStats computes the statistics of a confirmed block, the outputs it spends
+// are read from the history db
+func (vs *Visor) GetBlockStats(b *coin.SignedBlock) (*BlockStats, error) {
+	s := &BlockStats{
+		Seq:      b.Seq(),
+		Hash:     b.HashHeader(),
+		Time:     b.Head.Time,
+		TxnCount: uint64(len(b.Body.Transactions)),
+	}
+
+	// The fees are paid with the coin hours of the inputs at the time of the previous block
+	var prevTime uint64
+	if b.Seq() > 0 {
+		prev, err := vs.Blockchain.GetBlockBySeq(b.Seq() - 1)
+		if err != nil {
+			return nil, err
+		}
+		if prev == nil {
+			return nil, fmt.Errorf("block %d does not exist", b.Seq()-1)
+		}
+		prevTime = prev.Head.Time
+	}
+
+	addrs := make(map[cipher.Address]struct{})
+	for i := range b.Body.Transactions {
+		txn := &b.Body.Transactions[i]
+
+		inUxs := make(coin.UxArray, 0, len(txn.In))
+		for _, in := range txn.In {
+			ux, err := vs.history.GetUxout(in)
+			if err != nil {
+				return nil, err
+			}
+			if ux == nil {
+				return nil, fmt.Errorf("spent output %s does not exist in history db", in.Hex())
+			}
+
+			inUxs = append(inUxs, ux.Out)
+			s.CoinsMoved += ux.Out.Body.Coins
+			addrs[ux.Out.Body.Address] = struct{}{}
+		}
+
+		for _, o := range txn.Out {
+			addrs[o.Address] = struct{}{}
+		}
+
+		s.UxOutsCreated += uint64(len(txn.Out))
+		s.UxOutsSpent += uint64(len(txn.In))
+
+		// The genesis transaction has no inputs and pays no fee
+		if len(inUxs) == 0 {
+			continue
+		}
+
+		f, err := fee.TransactionFee(txn, prevTime, inUxs)
+		if err != nil {
+			return nil, err
+		}
+		s.HoursBurned += f
+	}
+
+	s.Addresses = make([]cipher.Address, 0, len(addrs))
+	for a := range addrs {
+		s.Addresses = append(s.Addresses, a)
+	}
+
+	return s, nil
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/skycoin/skycoin/src/cipher"
 	"github.com/skycoin/skycoin/src/coin"
 	"github.com/skycoin/skycoin/src/visor/bucket"
+	"github.com/skycoin/skycoin/src/visor/storage"
 )
 
 var (
@@ -17,6 +18,8 @@ var (
 	blockchainMetaBkt = []byte("blockchain_meta")
 	// blockchain head sequence number
 	headSeqKey = []byte("head_seq")
+	// highest sequence number of the blocks whose body was pruned
+	prunedSeqKey = []byte("pruned_seq")
 )
 
 // ErrMissingSignature is returned if no matching signature is found for a block in the db
@@ -34,21 +37,21 @@ func (e ErrMissingSignature) Error() string {
 }
 
 type chainMeta struct {
-	bucket.Bucket
+	bucket.StoreBucket
 }
 
-func newChainMeta(db *bolt.DB) (*chainMeta, error) {
-	bkt, err := bucket.New(blockchainMetaBkt, db)
+func newChainMeta(db storage.Store) (*chainMeta, error) {
+	bkt, err := bucket.NewStoreBucket(blockchainMetaBkt, db)
 	if err != nil {
 		return nil, err
 	}
 
 	return &chainMeta{
-		Bucket: *bkt,
+		StoreBucket: *bkt,
 	}, nil
 }
 
-func (m chainMeta) setHeadSeqWithTx(tx *bolt.Tx, seq uint64) error {
+func (m chainMeta) setHeadSeqWithTx(tx storage.Tx, seq uint64) error {
 	return m.PutWithTx(tx, headSeqKey, bucket.Itob(seq))
 }
 
@@ -57,6 +60,8 @@ type BlockTree interface {
 	AddBlockWithTx(tx *bolt.Tx, b *coin.Block) error
 	GetBlock(hash cipher.SHA256) *coin.Block
 	GetBlockInDepth(dep uint64, filter func(hps []coin.HashPair) cipher.SHA256) *coin.Block
+	RemoveBlock(b *coin.Block) error
+	PruneBodyWithTx(tx *bolt.Tx, hash cipher.SHA256) error
 }
 
 // BlockSigs block signature storage
@@ -74,6 +79,7 @@ type UnspentPool interface {
 	GetUxHash() cipher.SHA256
 	GetUnspentsOfAddrs(addrs []cipher.Address) coin.AddressUxOuts
 	ProcessBlock(*coin.SignedBlock) bucket.TxHandler
+	RollbackBlock(b *coin.SignedBlock, spent coin.UxArray) bucket.TxHandler
 	Contains(cipher.SHA256) bool
 }
 
@@ -88,9 +94,13 @@ type Blockchain struct {
 	tree    BlockTree
 	sigs    BlockSigs
 	walker  Walker
-	cache   struct {
+	// called before the body of a block is pruned
+	pruneHook PruneHook
+	cache     struct {
 		headSeq      uint64 // head block seq
 		genesisBlock *coin.SignedBlock
+		pruneKeep    uint64 // number of block bodies to keep, 0 disables pruning
+		prunedSeq    uint64 // highest seq of the pruned blocks, 0 if none
 	}
 	sync.RWMutex // cache lock
 }
@@ -129,7 +139,7 @@ func createBlockchain(db *bolt.DB,
 	sigs BlockSigs,
 	unspent UnspentPool,
 ) (*Blockchain, error) {
-	meta, err := newChainMeta(db)
+	meta, err := newChainMeta(storage.NewBolt(db))
 	if err != nil {
 		return nil, err
 	}
@@ -173,7 +183,30 @@ func (bc *Blockchain) processBlockWithTx(tx *bolt.Tx, b *coin.SignedBlock) error
 	return bc.updateWithTx(tx,
 		bc.updateHeadSeq(b),
 		bc.unspent.ProcessBlock(b),
-		bc.cacheGenesisBlock(b))
+		bc.cacheGenesisBlock(b),
+		bc.pruneBlocks(b))
+}
+
+// RollbackHeadWithTx undoes the head block in the unspent pool and moves the head to
+// the previous block, spent are the outputs the head block spent. The block itself is
+// kept in the block tree until RemoveBlock is called.
+func (bc *Blockchain) RollbackHeadWithTx(tx *bolt.Tx, sb *coin.SignedBlock, spent coin.UxArray) error {
+	if sb.Seq() != bc.HeadSeq() {
+		return fmt.Errorf("block %d is not the head block %d", sb.Seq(), bc.HeadSeq())
+	}
+
+	if sb.Seq() == 0 {
+		return errors.New("can't roll back the genesis block")
+	}
+
+	return bc.updateWithTx(tx,
+		bc.unspent.RollbackBlock(sb, spent),
+		bc.rewindHeadSeq(sb))
+}
+
+// RemoveBlock removes the block from the block tree
+func (bc *Blockchain) RemoveBlock(sb *coin.SignedBlock) error {
+	return bc.tree.RemoveBlock(&sb.Block)
 }
 
 // Head returns head block, returns error if no block does exist
@@ -212,8 +245,22 @@ func (bc *Blockchain) Len() uint64 {
 	return uint64(bc.cache.headSeq + 1)
 }
 
-// GetBlockByHash returns signed block of given hash
+// GetBlockByHash returns signed block of given hash, ErrBlockPruned if the body of the block was pruned
 func (bc *Blockchain) GetBlockByHash(hash cipher.SHA256) (*coin.SignedBlock, error) {
+	b, err := bc.GetHeaderByHash(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if b != nil && bc.IsPruned(b.Seq()) {
+		return nil, ErrBlockPruned{Seq: b.Seq()}
+	}
+
+	return b, nil
+}
+
+// GetHeaderByHash returns signed block of given hash, whose body is empty if it was pruned
+func (bc *Blockchain) GetHeaderByHash(hash cipher.SHA256) (*coin.SignedBlock, error) {
 	b := bc.tree.GetBlock(hash)
 	if b == nil {
 		return nil, nil
@@ -237,8 +284,17 @@ func (bc *Blockchain) GetBlockByHash(hash cipher.SHA256) (*coin.SignedBlock, err
 	}, nil
 }
 
-// GetBlockBySeq returns signed block of given seq
+// GetBlockBySeq returns signed block of given seq, ErrBlockPruned if the body of the block was pruned
 func (bc *Blockchain) GetBlockBySeq(seq uint64) (*coin.SignedBlock, error) {
+	if bc.IsPruned(seq) {
+		return nil, ErrBlockPruned{Seq: seq}
+	}
+
+	return bc.GetHeaderBySeq(seq)
+}
+
+// GetHeaderBySeq returns signed block of given seq, whose body is empty if it was pruned
+func (bc *Blockchain) GetHeaderBySeq(seq uint64) (*coin.SignedBlock, error) {
 	b := bc.tree.GetBlockInDepth(seq, bc.walker)
 	if b == nil {
 		return nil, nil
@@ -273,10 +329,11 @@ func (bc *Blockchain) syncCache() error {
 	bc.Lock()
 	defer bc.Unlock()
 	bc.cache.headSeq = bc.getHeadSeqFromDB()
+	bc.cache.prunedSeq = bc.getPrunedSeqFromDB()
 
-	// load genesis block
+	// load genesis block, its body is never pruned and GetBlockBySeq would take the cache lock
 	if bc.cache.genesisBlock == nil {
-		b, err := bc.GetBlockBySeq(0)
+		b, err := bc.GetHeaderBySeq(0)
 		if err != nil {
 			return err
 		}
@@ -321,8 +378,7 @@ func (bc *Blockchain) updateWithTx(tx *bolt.Tx, ps ...bucket.TxHandler) error {
 
 func (bc *Blockchain) updateHeadSeq(b *coin.SignedBlock) bucket.TxHandler {
 	return func(tx *bolt.Tx) (bucket.Rollback, error) {
-		// meta := chainMeta{tx.Bucket(bc.meta.Name)}
-		if err := bc.meta.setHeadSeqWithTx(tx, b.Seq()); err != nil {
+		if err := bc.meta.setHeadSeqWithTx(storage.BoltTx(tx), b.Seq()); err != nil {
 			return func() {}, err
 		}
 
@@ -343,6 +399,26 @@ func (bc *Blockchain) updateHeadSeq(b *coin.SignedBlock) bucket.TxHandler {
 	}
 }
 
+// rewindHeadSeq sets the head seq to the seq of the block before b
+func (bc *Blockchain) rewindHeadSeq(b *coin.SignedBlock) bucket.TxHandler {
+	return func(tx *bolt.Tx) (bucket.Rollback, error) {
+		if err := bc.meta.setHeadSeqWithTx(storage.BoltTx(tx), b.Seq()-1); err != nil {
+			return func() {}, err
+		}
+
+		bc.Lock()
+		seq := bc.cache.headSeq
+		bc.cache.headSeq = b.Seq() - 1
+		bc.Unlock()
+
+		return func() {
+			bc.Lock()
+			bc.cache.headSeq = seq
+			bc.Unlock()
+		}, nil
+	}
+}
+
 // cacheGenesisBlock will cache genesis block if the current block is genesis
 func (bc *Blockchain) cacheGenesisBlock(b *coin.SignedBlock) bucket.TxHandler {
 	return func(tx *bolt.Tx) (bucket.Rollback, error) {
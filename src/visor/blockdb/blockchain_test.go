@@ -67,6 +67,20 @@ func (bt fakeBlockTree) GetBlockInDepth(dep uint64, filter func(hps []coin.HashP
 	return nil
 }
 
+func (bt fakeBlockTree) RemoveBlock(b *coin.Block) error {
+	delete(bt.blocks, b.HashHeader().Hex())
+	return nil
+}
+
+func (bt fakeBlockTree) PruneBodyWithTx(tx *bolt.Tx, hash cipher.SHA256) error {
+	b, ok := bt.blocks[hash.Hex()]
+	if !ok {
+		return fmt.Errorf("block %s does not exist", hash.Hex())
+	}
+	bt.blocks[hash.Hex()] = &coin.Block{Head: b.Head}
+	return nil
+}
+
 type fakeSignatureStore struct {
 	db         *bolt.DB
 	sigs       map[string]cipher.Sig
@@ -170,6 +184,12 @@ func (fup fakeUnspentPool) ProcessBlock(b *coin.SignedBlock) bucket.TxHandler {
 	}
 }
 
+func (fup fakeUnspentPool) RollbackBlock(b *coin.SignedBlock, spent coin.UxArray) bucket.TxHandler {
+	return func(tx *bolt.Tx) (bucket.Rollback, error) {
+		return func() {}, nil
+	}
+}
+
 func (fup fakeUnspentPool) Contains(h cipher.SHA256) bool {
 	_, ok := fup.outs[h]
 	return ok
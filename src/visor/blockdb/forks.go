@@ -0,0 +1,114 @@
+package blockdb
+
+import (
+	"fmt"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/encoder"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/visor/bucket"
+	"github.com/skycoin/skycoin/src/visor/storage"
+)
+
+var forksBkt = []byte("forks")
+
+// ForkBlock is a block which competes with another block of the same seq
+type ForkBlock struct {
+	Seq      uint64
+	Hash     cipher.SHA256
+	PrevHash cipher.SHA256
+	// Signer is the pubkey recovered from the block signature
+	Signer cipher.PubKey
+	// Source is the address of the peer which sent the block, empty for the local block
+	Source string
+	// Received is the unix time when the fork was detected
+	Received int64
+}
+
+// NewForkBlock creates a ForkBlock from a signed block, the signer is recovered from the signature
+func NewForkBlock(sb *coin.SignedBlock, source string, received int64) (ForkBlock, error) {
+	hash := sb.HashHeader()
+	signer, err := cipher.PubKeyFromSig(sb.Sig, hash)
+	if err != nil {
+		return ForkBlock{}, fmt.Errorf("recover signer of block %d failed: %v", sb.Seq(), err)
+	}
+
+	return ForkBlock{
+		Seq:      sb.Seq(),
+		Hash:     hash,
+		PrevHash: sb.Head.PrevHash,
+		Signer:   signer,
+		Source:   source,
+		Received: received,
+	}, nil
+}
+
+// Fork is a set of competing blocks of the same seq
+type Fork struct {
+	Seq    uint64
+	Blocks []ForkBlock
+}
+
+// Forks stores the detected forks, key is seq and block hash, value is the serialized ForkBlock
+type Forks struct {
+	bkt *bucket.StoreBucket
+}
+
+// NewForks creates the forks bucket
+func NewForks(db storage.Store) (*Forks, error) {
+	bkt, err := bucket.NewStoreBucket(forksBkt, db)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Forks{bkt: bkt}, nil
+}
+
+func forkKey(seq uint64, hash cipher.SHA256) []byte {
+	return append(bucket.Itob(seq), hash[:]...)
+}
+
+// Add records the fork block, returns false if the block was already recorded
+func (f *Forks) Add(b ForkBlock) (bool, error) {
+	key := forkKey(b.Seq, b.Hash)
+	if f.bkt.Get(key) != nil {
+		return false, nil
+	}
+
+	if err := f.bkt.Put(key, encoder.Serialize(b)); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// GetAll returns all forks ordered by seq
+func (f *Forks) GetAll() ([]Fork, error) {
+	var forks []Fork
+	if err := f.bkt.ForEach(func(k, v []byte) error {
+		var b ForkBlock
+		if err := encoder.DeserializeRaw(v, &b); err != nil {
+			return err
+		}
+
+		if n := len(forks); n > 0 && forks[n-1].Seq == b.Seq {
+			forks[n-1].Blocks = append(forks[n-1].Blocks, b)
+			return nil
+		}
+
+		forks = append(forks, Fork{
+			Seq:    b.Seq,
+			Blocks: []ForkBlock{b},
+		})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return forks, nil
+}
+
+// Len returns the number of recorded fork blocks
+func (f *Forks) Len() int {
+	return f.bkt.Len()
+}
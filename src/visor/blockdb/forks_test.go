@@ -0,0 +1,71 @@
+package blockdb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/testutil"
+	"github.com/skycoin/skycoin/src/visor/storage"
+)
+
+func TestForks(t *testing.T) {
+	testutil.ForEachStore(t, func(t *testing.T, s storage.Store) {
+		forks, err := NewForks(s)
+		require.NoError(t, err)
+
+		gb := makeGenesisBlock(t)
+		b1 := makeNextBlock(t, &gb, 1e6)
+		b1Fork := makeNextBlock(t, &gb, 2e6)
+		b2 := makeNextBlock(t, &b1, 1e6)
+		b2Fork := makeNextBlock(t, &b1, 3e6)
+		require.NotEqual(t, b1.HashHeader(), b1Fork.HashHeader())
+
+		fb, err := NewForkBlock(&b1Fork, "127.0.0.1:6000", 100)
+		require.NoError(t, err)
+		require.Equal(t, uint64(1), fb.Seq)
+		require.Equal(t, b1Fork.HashHeader(), fb.Hash)
+		require.Equal(t, gb.HashHeader(), fb.PrevHash)
+		require.Equal(t, cipher.PubKeyFromSecKey(genSecret), fb.Signer)
+
+		for _, b := range []ForkBlock{
+			mustForkBlock(t, &b2, ""),
+			mustForkBlock(t, &b1, ""),
+			fb,
+			mustForkBlock(t, &b2Fork, "127.0.0.1:6001"),
+		} {
+			added, err := forks.Add(b)
+			require.NoError(t, err)
+			require.True(t, added)
+		}
+
+		// Recording the same block again is a no-op
+		added, err := forks.Add(fb)
+		require.NoError(t, err)
+		require.False(t, added)
+		require.Equal(t, 4, forks.Len())
+
+		all, err := forks.GetAll()
+		require.NoError(t, err)
+		require.Len(t, all, 2)
+		require.Equal(t, uint64(1), all[0].Seq)
+		require.Len(t, all[0].Blocks, 2)
+		require.Equal(t, uint64(2), all[1].Seq)
+		require.Len(t, all[1].Blocks, 2)
+
+		var sources []string
+		for _, b := range all[0].Blocks {
+			sources = append(sources, b.Source)
+		}
+		require.Contains(t, sources, "127.0.0.1:6000")
+		require.Contains(t, sources, "")
+	})
+}
+
+func mustForkBlock(t *testing.T, sb *coin.SignedBlock, source string) ForkBlock {
+	fb, err := NewForkBlock(sb, source, 100)
+	require.NoError(t, err)
+	return fb
+}
@@ -0,0 +1,147 @@
+package blockdb
+
+import (
+	"fmt"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/encoder"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/visor/bucket"
+	"github.com/skycoin/skycoin/src/visor/storage"
+)
+
+// ErrBlockPruned is returned when the body of a requested block was pruned
+type ErrBlockPruned struct {
+	Seq uint64
+}
+
+func (e ErrBlockPruned) Error() string {
+	return fmt.Sprintf("body of block %d was pruned", e.Seq)
+}
+
+// PruneBodyWithTx replaces the stored block of given hash with its header only
+func (bt *blockTree) PruneBodyWithTx(tx *bolt.Tx, hash cipher.SHA256) error {
+	bkt := tx.Bucket(blocksBkt)
+	if bkt == nil {
+		return fmt.Errorf("bucket %s does not exist", string(blocksBkt))
+	}
+
+	v := bkt.Get(hash[:])
+	if v == nil {
+		return fmt.Errorf("block %s does not exist", hash.Hex())
+	}
+
+	var b coin.Block
+	if err := encoder.DeserializeRaw(v, &b); err != nil {
+		return err
+	}
+
+	b.Body = coin.BlockBody{}
+	return bkt.Put(hash[:], encoder.Serialize(b))
+}
+
+// PruneHook is called with each block before its body is pruned, in the transaction which adds
+// the new block. Returns false if the body can't be pruned yet, the pruning then stops before
+// this block and is retried when the next block is added.
+type PruneHook func(tx *bolt.Tx, b *coin.Block) (bool, error)
+
+// EnablePruning keeps the bodies of the last keep blocks only, the bodies of older
+// blocks are removed as new blocks are added. Headers and signatures are always kept,
+// and the genesis block is never pruned. A keep of 0 disables pruning. hook may be nil.
+func (bc *Blockchain) EnablePruning(keep uint64, hook PruneHook) {
+	bc.Lock()
+	defer bc.Unlock()
+	bc.cache.pruneKeep = keep
+	bc.pruneHook = hook
+}
+
+// PruneDepth returns the number of block bodies kept, 0 if pruning is disabled
+func (bc *Blockchain) PruneDepth() uint64 {
+	bc.RLock()
+	defer bc.RUnlock()
+	return bc.cache.pruneKeep
+}
+
+// PrunedSeq returns the highest seq of the blocks whose body was pruned, 0 if none was pruned
+func (bc *Blockchain) PrunedSeq() uint64 {
+	bc.RLock()
+	defer bc.RUnlock()
+	return bc.cache.prunedSeq
+}
+
+// IsPruned returns true if the body of the block of given seq was pruned
+func (bc *Blockchain) IsPruned(seq uint64) bool {
+	prunedSeq := bc.PrunedSeq()
+	return seq > 0 && seq <= prunedSeq
+}
+
+func (bc *Blockchain) getPrunedSeqFromDB() uint64 {
+	if v := bc.meta.Get(prunedSeqKey); v != nil {
+		return bucket.Btoi(v)
+	}
+
+	return 0
+}
+
+// pruneBlocks prunes the bodies of the blocks that fall out of the kept range once b is added
+func (bc *Blockchain) pruneBlocks(b *coin.SignedBlock) bucket.TxHandler {
+	return func(tx *bolt.Tx) (bucket.Rollback, error) {
+		bc.RLock()
+		keep := bc.cache.pruneKeep
+		prunedSeq := bc.cache.prunedSeq
+		hook := bc.pruneHook
+		bc.RUnlock()
+
+		if keep == 0 || b.Seq() <= keep {
+			return func() {}, nil
+		}
+
+		target := b.Seq() - keep
+		if target <= prunedSeq {
+			return func() {}, nil
+		}
+
+		for seq := prunedSeq + 1; seq <= target; seq++ {
+			pb := bc.tree.GetBlockInDepth(seq, bc.walker)
+			if pb == nil {
+				return func() {}, fmt.Errorf("prune block %d failed: block does not exist", seq)
+			}
+
+			if hook != nil {
+				ok, err := hook(tx, pb)
+				if err != nil {
+					return func() {}, fmt.Errorf("prune block %d failed: %v", seq, err)
+				}
+
+				if !ok {
+					target = seq - 1
+					break
+				}
+			}
+
+			if err := bc.tree.PruneBodyWithTx(tx, pb.HashHeader()); err != nil {
+				return func() {}, fmt.Errorf("prune block %d failed: %v", seq, err)
+			}
+		}
+
+		if target == prunedSeq {
+			return func() {}, nil
+		}
+
+		if err := bc.meta.PutWithTx(storage.BoltTx(tx), prunedSeqKey, bucket.Itob(target)); err != nil {
+			return func() {}, err
+		}
+
+		bc.Lock()
+		bc.cache.prunedSeq = target
+		bc.Unlock()
+
+		return func() {
+			bc.Lock()
+			bc.cache.prunedSeq = prunedSeq
+			bc.Unlock()
+		}, nil
+	}
+}
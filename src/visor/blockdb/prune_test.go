@@ -0,0 +1,135 @@
+package blockdb
+
+import (
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/testutil"
+)
+
+// makeNextBlock creates a signed block on top of prev with a single transaction
+// that creates an output without spending any
+func makeNextBlock(t *testing.T, prev *coin.SignedBlock, coins uint64) coin.SignedBlock {
+	txn := coin.Transaction{}
+	txn.PushOutput(genAddress, coins, 100)
+	txn.UpdateHeader()
+
+	body := coin.BlockBody{Transactions: coin.Transactions{txn}}
+	b := coin.Block{
+		Head: coin.BlockHeader{
+			BkSeq:    prev.Seq() + 1,
+			Time:     prev.Time() + incTime,
+			PrevHash: prev.HashHeader(),
+			BodyHash: body.Hash(),
+		},
+		Body: body,
+	}
+
+	return coin.SignedBlock{
+		Block: b,
+		Sig:   cipher.SignHash(b.HashHeader(), genSecret),
+	}
+}
+
+func TestBlockchainPruning(t *testing.T) {
+	db, closeDB := testutil.PrepareDB(t)
+	defer closeDB()
+
+	bc, err := NewBlockchain(db, DefaultWalker)
+	require.NoError(t, err)
+	bc.EnablePruning(2, nil)
+	require.Equal(t, uint64(2), bc.PruneDepth())
+
+	gb := makeGenesisBlock(t)
+	blocks := []coin.SignedBlock{gb}
+	for i := 1; i < 5; i++ {
+		blocks = append(blocks, makeNextBlock(t, &blocks[i-1], uint64(i)*1e6))
+	}
+
+	for i := range blocks {
+		err := db.Update(func(tx *bolt.Tx) error {
+			return bc.AddBlockWithTx(tx, &blocks[i])
+		})
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, uint64(2), bc.PrunedSeq())
+	require.False(t, bc.IsPruned(0))
+	require.True(t, bc.IsPruned(1))
+	require.True(t, bc.IsPruned(2))
+	require.False(t, bc.IsPruned(3))
+
+	for i, sb := range blocks {
+		b, err := bc.GetHeaderBySeq(uint64(i))
+		require.NoError(t, err)
+		require.NotNil(t, b)
+		require.Equal(t, sb.Head, b.Head)
+		require.Equal(t, sb.Sig, b.Sig)
+
+		if !bc.IsPruned(uint64(i)) {
+			require.Equal(t, sb.Body, b.Body)
+			continue
+		}
+
+		require.Empty(t, b.Body.Transactions)
+
+		// Pruned blocks are never returned as full blocks
+		_, err = bc.GetBlockBySeq(uint64(i))
+		require.Equal(t, ErrBlockPruned{Seq: uint64(i)}, err)
+		_, err = bc.GetBlockByHash(sb.HashHeader())
+		require.Equal(t, ErrBlockPruned{Seq: uint64(i)}, err)
+	}
+
+	// The pruned seq is persisted
+	bc2, err := NewBlockchain(db, DefaultWalker)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), bc2.PrunedSeq())
+	require.Equal(t, uint64(0), bc2.PruneDepth())
+}
+
+func TestBlockchainPruneHook(t *testing.T) {
+	db, closeDB := testutil.PrepareDB(t)
+	defer closeDB()
+
+	// Only the blocks up to parsed can be pruned
+	var parsed uint64 = 1
+	var hooked []uint64
+	bc, err := NewBlockchain(db, DefaultWalker)
+	require.NoError(t, err)
+	bc.EnablePruning(1, func(tx *bolt.Tx, b *coin.Block) (bool, error) {
+		if b.Seq() > parsed {
+			return false, nil
+		}
+		hooked = append(hooked, b.Seq())
+		return true, nil
+	})
+
+	gb := makeGenesisBlock(t)
+	blocks := []coin.SignedBlock{gb}
+	for i := 1; i < 5; i++ {
+		blocks = append(blocks, makeNextBlock(t, &blocks[i-1], uint64(i)*1e6))
+	}
+
+	addBlock := func(i int) {
+		err := db.Update(func(tx *bolt.Tx) error {
+			return bc.AddBlockWithTx(tx, &blocks[i])
+		})
+		require.NoError(t, err)
+	}
+
+	for i := 0; i < 4; i++ {
+		addBlock(i)
+	}
+
+	require.Equal(t, uint64(1), bc.PrunedSeq())
+	require.Equal(t, []uint64{1}, hooked)
+
+	parsed = 3
+	addBlock(4)
+	require.Equal(t, uint64(3), bc.PrunedSeq())
+	require.Equal(t, []uint64{1, 2, 3}, hooked)
+}
@@ -0,0 +1,44 @@
+package blockdb
+
+import (
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/visor/bucket"
+	"github.com/skycoin/skycoin/src/visor/storage"
+)
+
+// SearchBlocks returns up to limit blocks of the chain whose hash starts with hexPrefix,
+// in hash order. The blocks of the block tree which are not in the chain are skipped.
+// The bodies of the pruned blocks are empty.
+func (bc *Blockchain) SearchBlocks(hexPrefix string, limit int) ([]*coin.SignedBlock, error) {
+	var blocks []*coin.SignedBlock
+	inChain := func(k []byte) (bool, error) {
+		var h cipher.SHA256
+		copy(h[:], k)
+
+		b, err := bc.GetHeaderByHash(h)
+		if err != nil {
+			return false, err
+		}
+		if b == nil {
+			return false, nil
+		}
+
+		cb, err := bc.GetHeaderBySeq(b.Seq())
+		if err != nil {
+			return false, err
+		}
+		if cb == nil || cb.HashHeader() != h {
+			return false, nil
+		}
+
+		blocks = append(blocks, cb)
+		return true, nil
+	}
+
+	if _, err := bucket.HexPrefixKeys(storage.NewBolt(bc.db), blocksBkt, hexPrefix, limit, inChain); err != nil {
+		return nil, err
+	}
+
+	return blocks, nil
+}
@@ -0,0 +1,145 @@
+package blockdb
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+)
+
+// Signer is a key authorised to sign the blocks from ActivateSeq until DeactivateSeq,
+// DeactivateSeq is exclusive and 0 means the key never expires
+type Signer struct {
+	PubKey        cipher.PubKey
+	ActivateSeq   uint64
+	DeactivateSeq uint64
+}
+
+// IsActive returns true if the signer may sign the block of given seq
+func (s Signer) IsActive(seq uint64) bool {
+	return seq >= s.ActivateSeq && (s.DeactivateSeq == 0 || seq < s.DeactivateSeq)
+}
+
+// String returns the signer in the pubkey:activate[:deactivate] format
+func (s Signer) String() string {
+	if s.DeactivateSeq == 0 {
+		return fmt.Sprintf("%s:%d", s.PubKey.Hex(), s.ActivateSeq)
+	}
+	return fmt.Sprintf("%s:%d:%d", s.PubKey.Hex(), s.ActivateSeq, s.DeactivateSeq)
+}
+
+// SignerSchedule is the set of keys authorised to sign blocks, with the block heights
+// at which each of them is valid. Several keys may be valid at the same height.
+type SignerSchedule []Signer
+
+// NewSignerSchedule creates a schedule where pubkey signs all blocks
+func NewSignerSchedule(pubkey cipher.PubKey) SignerSchedule {
+	return SignerSchedule{{PubKey: pubkey}}
+}
+
+// ParseSignerSchedule parses a comma separated list of pubkey:activate[:deactivate] signers
+func ParseSignerSchedule(s string) (SignerSchedule, error) {
+	var ss SignerSchedule
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		fields := strings.Split(item, ":")
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("invalid signer %q, must be pubkey:activate[:deactivate]", item)
+		}
+
+		pubkey, err := cipher.PubKeyFromHex(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid signer pubkey %q: %v", fields[0], err)
+		}
+
+		signer := Signer{PubKey: pubkey}
+		signer.ActivateSeq, err = strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signer activate seq %q: %v", fields[1], err)
+		}
+
+		if len(fields) == 3 {
+			signer.DeactivateSeq, err = strconv.ParseUint(fields[2], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid signer deactivate seq %q: %v", fields[2], err)
+			}
+		}
+
+		ss = append(ss, signer)
+	}
+
+	if err := ss.Validate(); err != nil {
+		return nil, err
+	}
+
+	return ss, nil
+}
+
+// String returns the schedule in the format accepted by ParseSignerSchedule
+func (ss SignerSchedule) String() string {
+	items := make([]string, 0, len(ss))
+	for _, s := range ss {
+		items = append(items, s.String())
+	}
+	return strings.Join(items, ",")
+}
+
+// Validate checks that the schedule has a signer for the genesis block
+// and that the signer ranges are not empty
+func (ss SignerSchedule) Validate() error {
+	if len(ss) == 0 {
+		return errors.New("no block signers")
+	}
+
+	for _, s := range ss {
+		if s.PubKey == (cipher.PubKey{}) {
+			return errors.New("block signer pubkey is empty")
+		}
+
+		if s.DeactivateSeq != 0 && s.DeactivateSeq <= s.ActivateSeq {
+			return fmt.Errorf("block signer %s is deactivated at seq %d before it is activated at seq %d",
+				s.PubKey.Hex(), s.DeactivateSeq, s.ActivateSeq)
+		}
+	}
+
+	if len(ss.SignersAt(0)) == 0 {
+		return errors.New("no block signer for the genesis block")
+	}
+
+	return nil
+}
+
+// SignersAt returns the keys authorised to sign the block of given seq
+func (ss SignerSchedule) SignersAt(seq uint64) []cipher.PubKey {
+	var pubkeys []cipher.PubKey
+	for _, s := range ss {
+		if s.IsActive(seq) {
+			pubkeys = append(pubkeys, s.PubKey)
+		}
+	}
+	return pubkeys
+}
+
+// VerifyBlockSignature checks that the block is signed by a key valid at the block's seq
+func (ss SignerSchedule) VerifyBlockSignature(b *coin.SignedBlock) error {
+	pubkeys := ss.SignersAt(b.Seq())
+	if len(pubkeys) == 0 {
+		return fmt.Errorf("no block signer is active at seq %d", b.Seq())
+	}
+
+	hash := b.HashHeader()
+	for _, pubkey := range pubkeys {
+		if err := cipher.VerifySignature(pubkey, b.Sig, hash); err == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("block %d is not signed by a key active at that seq", b.Seq())
+}
@@ -0,0 +1,108 @@
+package blockdb
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+func TestParseSignerSchedule(t *testing.T) {
+	p1, _ := cipher.GenerateKeyPair()
+	p2, _ := cipher.GenerateKeyPair()
+
+	tt := []struct {
+		name    string
+		s       string
+		signers SignerSchedule
+		err     bool
+	}{
+		{
+			name:    "single key",
+			s:       fmt.Sprintf("%s:0", p1.Hex()),
+			signers: SignerSchedule{{PubKey: p1}},
+		},
+		{
+			name: "rotation",
+			s:    fmt.Sprintf("%s:0:100, %s:100", p1.Hex(), p2.Hex()),
+			signers: SignerSchedule{
+				{PubKey: p1, DeactivateSeq: 100},
+				{PubKey: p2, ActivateSeq: 100},
+			},
+		},
+		{
+			name: "empty",
+			s:    "",
+			err:  true,
+		},
+		{
+			name: "missing activate seq",
+			s:    p1.Hex(),
+			err:  true,
+		},
+		{
+			name: "invalid pubkey",
+			s:    "abc:0",
+			err:  true,
+		},
+		{
+			name: "deactivated before activated",
+			s:    fmt.Sprintf("%s:0,%s:10:5", p1.Hex(), p2.Hex()),
+			err:  true,
+		},
+		{
+			name: "no genesis signer",
+			s:    fmt.Sprintf("%s:1", p1.Hex()),
+			err:  true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			signers, err := ParseSignerSchedule(tc.s)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.signers, signers)
+
+			// String round trips
+			signers2, err := ParseSignerSchedule(signers.String())
+			require.NoError(t, err)
+			require.Equal(t, signers, signers2)
+		})
+	}
+}
+
+func TestSignerScheduleVerifyBlockSignature(t *testing.T) {
+	p2, s2 := cipher.GenerateKeyPair()
+	signers := SignerSchedule{
+		{PubKey: genPublic, DeactivateSeq: 2},
+		{PubKey: p2, ActivateSeq: 2},
+	}
+	require.NoError(t, signers.Validate())
+
+	gb := makeGenesisBlock(t)
+	require.NoError(t, signers.VerifyBlockSignature(&gb))
+
+	b1 := makeNextBlock(t, &gb, 1e6)
+	require.NoError(t, signers.VerifyBlockSignature(&b1))
+
+	// Signed by the old key after its deactivation
+	b2 := makeNextBlock(t, &b1, 1e6)
+	require.Error(t, signers.VerifyBlockSignature(&b2))
+
+	b2.Sig = cipher.SignHash(b2.HashHeader(), s2)
+	require.NoError(t, signers.VerifyBlockSignature(&b2))
+
+	// Signed by the new key before its activation
+	b1.Sig = cipher.SignHash(b1.HashHeader(), s2)
+	require.Error(t, signers.VerifyBlockSignature(&b1))
+
+	require.Equal(t, []cipher.PubKey{genPublic}, signers.SignersAt(1))
+	require.Equal(t, []cipher.PubKey{p2}, signers.SignersAt(1000))
+}
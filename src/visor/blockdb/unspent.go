@@ -2,6 +2,7 @@ package blockdb
 
 import (
 	"fmt"
+	"math"
 	"sync"
 
 	"github.com/boltdb/bolt"
@@ -10,6 +11,7 @@ import (
 	"github.com/skycoin/skycoin/src/cipher/encoder"
 	"github.com/skycoin/skycoin/src/coin"
 	"github.com/skycoin/skycoin/src/visor/bucket"
+	"github.com/skycoin/skycoin/src/visor/storage"
 )
 
 var (
@@ -19,8 +21,24 @@ var (
 	unspentPoolBkt = []byte("unspent_pool")
 	// bucket for unspent meta info
 	unspentMetaBkt = []byte("unspent_meta")
+
+	// buckets the unspent pool is rebuilt into before it replaces the live pool
+	reindexPoolBkt = []byte("unspent_pool_reindex")
+	reindexMetaBkt = []byte("unspent_meta_reindex")
 )
 
+// ChainedSpendsForkSeq is the seq of the first block in which a transaction may spend an
+// output created by a previous transaction of the same block. Nodes without this rule
+// reject such a block, so it is a hard fork: the rule stays inactive until the network
+// agrees on the seq.
+var ChainedSpendsForkSeq uint64 = math.MaxUint64
+
+// ChainedSpendsActive returns true if a transaction of the block of given seq
+// may spend an output created by a previous transaction of the block
+func ChainedSpendsActive(seq uint64) bool {
+	return seq >= ChainedSpendsForkSeq
+}
+
 // UnspentGetter provides unspend pool related
 // querying methods
 type UnspentGetter interface {
@@ -31,7 +49,7 @@ type UnspentGetter interface {
 
 // Unspents unspent outputs pool
 type Unspents struct {
-	db    *bolt.DB
+	db    storage.Store
 	pool  *pool
 	meta  *unspentMeta
 	cache struct {
@@ -42,21 +60,21 @@ type Unspents struct {
 }
 
 type unspentMeta struct {
-	bucket.Bucket
+	bucket.StoreBucket
 }
 
-func newUnspentMeta(db *bolt.DB) (*unspentMeta, error) {
-	bkt, err := bucket.New(unspentMetaBkt, db)
+func newUnspentMeta(db storage.Store, name []byte) (*unspentMeta, error) {
+	bkt, err := bucket.NewStoreBucket(name, db)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create unspent_meta bucket: %v", err)
+		return nil, fmt.Errorf("failed to create %s bucket: %v", string(name), err)
 	}
 
 	return &unspentMeta{
-		Bucket: *bkt,
+		StoreBucket: *bkt,
 	}, nil
 }
 
-func (m unspentMeta) getXorHashWithTx(tx *bolt.Tx) (cipher.SHA256, error) {
+func (m unspentMeta) getXorHashWithTx(tx storage.Tx) (cipher.SHA256, error) {
 	if v := m.GetWithTx(tx, xorhashKey); v != nil {
 		var hash cipher.SHA256
 		copy(hash[:], v[:])
@@ -66,26 +84,26 @@ func (m unspentMeta) getXorHashWithTx(tx *bolt.Tx) (cipher.SHA256, error) {
 	return cipher.SHA256{}, nil
 }
 
-func (m *unspentMeta) setXorHashWithTx(tx *bolt.Tx, hash cipher.SHA256) error {
+func (m *unspentMeta) setXorHashWithTx(tx storage.Tx, hash cipher.SHA256) error {
 	return m.PutWithTx(tx, xorhashKey, hash[:])
 }
 
 type pool struct {
-	bucket.Bucket
+	bucket.StoreBucket
 }
 
-func newPool(db *bolt.DB) (*pool, error) {
-	bkt, err := bucket.New(unspentPoolBkt, db)
+func newPool(db storage.Store, name []byte) (*pool, error) {
+	bkt, err := bucket.NewStoreBucket(name, db)
 	if err != nil {
 		return nil, err
 	}
 
 	return &pool{
-		Bucket: *bkt,
+		StoreBucket: *bkt,
 	}, nil
 }
 
-func (pl pool) getWithTx(tx *bolt.Tx, hash cipher.SHA256) (*coin.UxOut, bool, error) {
+func (pl pool) getWithTx(tx storage.Tx, hash cipher.SHA256) (*coin.UxOut, bool, error) {
 	if v := pl.GetWithTx(tx, hash[:]); v != nil {
 		var out coin.UxOut
 		if err := encoder.DeserializeRaw(v, &out); err != nil {
@@ -96,27 +114,31 @@ func (pl pool) getWithTx(tx *bolt.Tx, hash cipher.SHA256) (*coin.UxOut, bool, er
 	return nil, false, nil
 }
 
-func (pl pool) setWithTx(tx *bolt.Tx, hash cipher.SHA256, ux coin.UxOut) error {
+func (pl pool) setWithTx(tx storage.Tx, hash cipher.SHA256, ux coin.UxOut) error {
 	v := encoder.Serialize(ux)
 	return pl.PutWithTx(tx, hash[:], v)
 }
 
-func (pl *pool) deleteWithTx(tx *bolt.Tx, hash cipher.SHA256) error {
+func (pl *pool) deleteWithTx(tx storage.Tx, hash cipher.SHA256) error {
 	return pl.DeleteWithTx(tx, hash[:])
 }
 
 // NewUnspentPool creates new unspent pool instance
 func NewUnspentPool(db *bolt.DB) (*Unspents, error) {
+	return newUnspents(storage.NewBolt(db), unspentPoolBkt, unspentMetaBkt)
+}
+
+func newUnspents(db storage.Store, poolBkt, metaBkt []byte) (*Unspents, error) {
 	up := &Unspents{db: db}
 	up.cache.pool = make(map[string]coin.UxOut)
 
-	pool, err := newPool(db)
+	pool, err := newPool(db, poolBkt)
 	if err != nil {
 		return nil, err
 	}
 	up.pool = pool
 
-	meta, err := newUnspentMeta(db)
+	meta, err := newUnspentMeta(db, metaBkt)
 	if err != nil {
 		return nil, err
 	}
@@ -130,6 +152,63 @@ func NewUnspentPool(db *bolt.DB) (*Unspents, error) {
 	return up, nil
 }
 
+// NewReindexUnspentPool creates an empty unspent pool in scratch buckets, left over
+// scratch buckets of a failed rebuild are dropped. The live pool is not changed until
+// ReplaceUnspentPool is called once the scratch pool is fully rebuilt.
+func NewReindexUnspentPool(db *bolt.DB) (*Unspents, error) {
+	s := storage.NewBolt(db)
+	if err := s.Update(func(tx storage.Tx) error {
+		for _, name := range [][]byte{reindexPoolBkt, reindexMetaBkt} {
+			if err := tx.DeleteBucket(name); err != nil && err != storage.ErrBucketNotFound {
+				return fmt.Errorf("delete bucket %s failed: %v", string(name), err)
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return newUnspents(s, reindexPoolBkt, reindexMetaBkt)
+}
+
+// ReplaceUnspentPool replaces the live unspent pool with the pool rebuilt by
+// NewReindexUnspentPool in a single transaction, and drops the scratch buckets.
+// Unspents created before the call must not be used afterwards.
+func ReplaceUnspentPool(db *bolt.DB) error {
+	return storage.NewBolt(db).Update(func(tx storage.Tx) error {
+		if err := replaceBucketWithTx(tx, unspentPoolBkt, reindexPoolBkt); err != nil {
+			return err
+		}
+		return replaceBucketWithTx(tx, unspentMetaBkt, reindexMetaBkt)
+	})
+}
+
+// replaceBucketWithTx replaces the content of the dst bucket with the content of the
+// src bucket, and deletes src
+func replaceBucketWithTx(tx storage.Tx, dst, src []byte) error {
+	sb := tx.Bucket(src)
+	if sb == nil {
+		return fmt.Errorf("bucket %s does not exist", string(src))
+	}
+
+	if err := tx.DeleteBucket(dst); err != nil && err != storage.ErrBucketNotFound {
+		return fmt.Errorf("delete bucket %s failed: %v", string(dst), err)
+	}
+
+	db, err := tx.CreateBucketIfNotExists(dst)
+	if err != nil {
+		return fmt.Errorf("create bucket %s failed: %v", string(dst), err)
+	}
+
+	if err := sb.ForEach(func(k, v []byte) error {
+		return db.Put(append([]byte(nil), k...), append([]byte(nil), v...))
+	}); err != nil {
+		return err
+	}
+
+	return tx.DeleteBucket(src)
+}
+
 func (up *Unspents) syncCache() error {
 	// load unspent outputs
 	if err := up.pool.ForEach(func(k, v []byte) error {
@@ -157,59 +236,220 @@ func (up *Unspents) syncCache() error {
 	return nil
 }
 
+// ProcessBlock spends the inputs and adds the outputs of the block's transactions, in order.
+// From ChainedSpendsForkSeq a transaction may spend the outputs created by a previous
+// transaction of the block.
 func (up *Unspents) ProcessBlock(b *coin.SignedBlock) bucket.TxHandler {
 	return func(tx *bolt.Tx) (bucket.Rollback, error) {
-		var (
-			delUxs    []coin.UxOut
-			addUxs    []coin.UxOut
-			uxHash    cipher.SHA256
-			oldUxHash = up.cache.uxhash
-		)
-
-		for _, txn := range b.Body.Transactions {
-			// get uxouts that need to be deleted
-			uxs, err := up.getArray(txn.In)
-			if err != nil {
+		return up.processBlockWithTx(storage.BoltTx(tx), b)
+	}
+}
+
+func (up *Unspents) processBlockWithTx(tx storage.Tx, b *coin.SignedBlock) (bucket.Rollback, error) {
+	var (
+		delUxs    []coin.UxOut
+		addUxs    []coin.UxOut
+		oldUxHash = up.cache.uxhash
+	)
+
+	// The cache is updated once the whole block is applied, the outputs created
+	// and spent by the previous transactions of the block are tracked here
+	created := make(map[cipher.SHA256]coin.UxOut)
+	spent := make(map[cipher.SHA256]struct{})
+
+	for _, txn := range b.Body.Transactions {
+		// get uxouts that need to be deleted
+		uxs, err := up.getBlockInputs(b.Seq(), txn.In, created, spent)
+		if err != nil {
+			return func() {}, err
+		}
+
+		delUxs = append(delUxs, uxs...)
+		for _, in := range txn.In {
+			spent[in] = struct{}{}
+		}
+
+		// Remove spent outputs
+		if _, err = up.deleteWithTx(tx, txn.In); err != nil {
+			return func() {}, err
+		}
+
+		// Create new outputs
+		txUxs := coin.CreateUnspents(b.Head, txn)
+		addUxs = append(addUxs, txUxs...)
+		for i := range txUxs {
+			if _, err := up.addWithTx(tx, txUxs[i]); err != nil {
 				return func() {}, err
 			}
+			created[txUxs[i].Hash()] = txUxs[i]
+		}
+	}
 
-			delUxs = append(delUxs, uxs...)
+	uxHash, err := up.meta.getXorHashWithTx(tx)
+	if err != nil {
+		return func() {}, err
+	}
 
-			// Remove spent outputs
-			if _, err = up.deleteWithTx(tx, txn.In); err != nil {
-				return func() {}, err
+	// The outputs created and spent by the block were never in the cache
+	delUxs, addUxs = dropCommonUxs(delUxs, addUxs)
+
+	// update caches
+	up.Lock()
+	up.deleteUxFromCache(delUxs)
+	up.addUxToCache(addUxs)
+	up.updateUxHashInCache(uxHash)
+	up.Unlock()
+
+	return func() {
+		up.Lock()
+		// reverse the cache
+		up.deleteUxFromCache(addUxs)
+		up.addUxToCache(delUxs)
+		up.updateUxHashInCache(oldUxHash)
+		up.Unlock()
+	}, nil
+}
+
+// getBlockInputs returns the outputs spent by a transaction of the block of given seq.
+// An input is an output of the pool, or from ChainedSpendsForkSeq one created by a previous
+// transaction of the block, and must not be spent by a previous transaction of the block.
+func (up *Unspents) getBlockInputs(seq uint64, hashes []cipher.SHA256, created map[cipher.SHA256]coin.UxOut, spent map[cipher.SHA256]struct{}) (coin.UxArray, error) {
+	uxs := make(coin.UxArray, 0, len(hashes))
+	for _, h := range hashes {
+		if _, ok := spent[h]; ok {
+			return nil, fmt.Errorf("unspent output of %s is spent twice in the block", h.Hex())
+		}
+
+		if ux, ok := created[h]; ok {
+			if !ChainedSpendsActive(seq) {
+				return nil, fmt.Errorf("unspent output of %s is created in the same block %d, before the chained spends fork seq", h.Hex(), seq)
+			}
+			uxs = append(uxs, ux)
+			continue
+		}
+
+		ux, ok := up.Get(h)
+		if !ok {
+			return nil, fmt.Errorf("unspent output of %s does not exist", h.Hex())
+		}
+		uxs = append(uxs, ux)
+	}
+	return uxs, nil
+}
+
+// dropCommonUxs removes the outputs which are both in a and b
+func dropCommonUxs(a, b []coin.UxOut) ([]coin.UxOut, []coin.UxOut) {
+	inA := make(map[cipher.SHA256]struct{}, len(a))
+	for _, ux := range a {
+		inA[ux.Hash()] = struct{}{}
+	}
+
+	common := make(map[cipher.SHA256]struct{})
+	var keptB []coin.UxOut
+	for _, ux := range b {
+		h := ux.Hash()
+		if _, ok := inA[h]; ok {
+			common[h] = struct{}{}
+			continue
+		}
+		keptB = append(keptB, ux)
+	}
+
+	var keptA []coin.UxOut
+	for _, ux := range a {
+		if _, ok := common[ux.Hash()]; !ok {
+			keptA = append(keptA, ux)
+		}
+	}
+
+	return keptA, keptB
+}
+
+// RollbackBlock undoes ProcessBlock: the outputs created by the block are removed
+// and the outputs it spent are added back, the transactions are undone in reverse order.
+// spent must contain every output spent by the block, since they are no longer in the pool.
+func (up *Unspents) RollbackBlock(b *coin.SignedBlock, spent coin.UxArray) bucket.TxHandler {
+	return func(tx *bolt.Tx) (bucket.Rollback, error) {
+		return up.rollbackBlockWithTx(storage.BoltTx(tx), b, spent)
+	}
+}
+
+func (up *Unspents) rollbackBlockWithTx(tx storage.Tx, b *coin.SignedBlock, spent coin.UxArray) (bucket.Rollback, error) {
+	var (
+		delUxs    []coin.UxOut
+		addUxs    []coin.UxOut
+		oldUxHash = up.cache.uxhash
+	)
+
+	spentm := make(map[cipher.SHA256]coin.UxOut, len(spent))
+	for _, ux := range spent {
+		spentm[ux.Hash()] = ux
+	}
+
+	// outputs added back by the transactions already undone, an output created by a
+	// transaction of the block and spent by a later one is restored before it's removed
+	restored := make(map[cipher.SHA256]struct{})
+
+	txns := b.Body.Transactions
+	for i := len(txns) - 1; i >= 0; i-- {
+		txn := txns[i]
+
+		// Remove created outputs
+		txUxs := coin.CreateUnspents(b.Head, txn)
+		hashes := make([]cipher.SHA256, 0, len(txUxs))
+		for j := range txUxs {
+			h := txUxs[j].Hash()
+			if _, ok := restored[h]; !ok && !up.Contains(h) {
+				return func() {}, fmt.Errorf("unspent output %s created by block %d does not exist", h.Hex(), b.Seq())
+			}
+			delete(restored, h)
+			hashes = append(hashes, h)
+		}
+
+		if _, err := up.deleteWithTx(tx, hashes); err != nil {
+			return func() {}, err
+		}
+		delUxs = append(delUxs, txUxs...)
+
+		// Restore spent outputs
+		for _, in := range txn.In {
+			ux, ok := spentm[in]
+			if !ok {
+				return func() {}, fmt.Errorf("spent output %s of block %d is not provided", in.Hex(), b.Seq())
 			}
 
-			// Create new outputs
-			txUxs := coin.CreateUnspents(b.Head, txn)
-			addUxs = append(addUxs, txUxs...)
-			for i := range txUxs {
-				uxHash, err = up.addWithTx(tx, txUxs[i])
-				if err != nil {
-					return func() {}, err
-				}
+			if _, err := up.addWithTx(tx, ux); err != nil {
+				return func() {}, err
 			}
+			addUxs = append(addUxs, ux)
+			restored[in] = struct{}{}
 		}
+	}
+
+	uxHash, err := up.meta.getXorHashWithTx(tx)
+	if err != nil {
+		return func() {}, err
+	}
+
+	// The outputs created and spent by the block were never in the cache
+	delUxs, addUxs = dropCommonUxs(delUxs, addUxs)
 
-		// update caches
+	up.Lock()
+	up.deleteUxFromCache(delUxs)
+	up.addUxToCache(addUxs)
+	up.updateUxHashInCache(uxHash)
+	up.Unlock()
+
+	return func() {
 		up.Lock()
-		up.deleteUxFromCache(delUxs)
-		up.addUxToCache(addUxs)
-		up.updateUxHashInCache(uxHash)
+		up.deleteUxFromCache(addUxs)
+		up.addUxToCache(delUxs)
+		up.updateUxHashInCache(oldUxHash)
 		up.Unlock()
-
-		return func() {
-			up.Lock()
-			// reverse the cache
-			up.deleteUxFromCache(addUxs)
-			up.addUxToCache(delUxs)
-			up.updateUxHashInCache(oldUxHash)
-			up.Unlock()
-		}, nil
-	}
+	}, nil
 }
 
-func (up *Unspents) addWithTx(tx *bolt.Tx, ux coin.UxOut) (uxhash cipher.SHA256, err error) {
+func (up *Unspents) addWithTx(tx storage.Tx, ux coin.UxOut) (uxhash cipher.SHA256, err error) {
 	// will rollback all updates if return is not nil
 	// in case of unexpected panic, we must catch it and return error
 	defer func() {
@@ -301,7 +541,7 @@ func (up *Unspents) GetAll() (coin.UxArray, error) {
 }
 
 // delete delete unspent of given hashes
-func (up *Unspents) deleteWithTx(tx *bolt.Tx, hashes []cipher.SHA256) (cipher.SHA256, error) {
+func (up *Unspents) deleteWithTx(tx storage.Tx, hashes []cipher.SHA256) (cipher.SHA256, error) {
 	var uxHash cipher.SHA256
 	for _, hash := range hashes {
 		ux, ok, err := up.pool.getWithTx(tx, hash)
@@ -16,6 +16,7 @@ import (
 	"github.com/skycoin/skycoin/src/cipher"
 	"github.com/skycoin/skycoin/src/coin"
 	"github.com/skycoin/skycoin/src/testutil"
+	"github.com/skycoin/skycoin/src/visor/storage"
 )
 
 type spending struct {
@@ -55,21 +56,21 @@ func makeUxOutWithSecret(t *testing.T) (coin.UxOut, cipher.SecKey) {
 }
 
 func TestNewUnspentPool(t *testing.T) {
-	db, teardown := testutil.PrepareDB(t)
-	defer teardown()
+	testutil.ForEachStore(t, func(t *testing.T, s storage.Store) {
 
-	up, err := NewUnspentPool(db)
-	assert.Nil(t, err)
+		up, err := newUnspents(s, unspentPoolBkt, unspentMetaBkt)
+		assert.Nil(t, err)
 
-	assert.Equal(t, 0, up.pool.Len())
-	v := up.meta.Get(xorhashKey)
-	assert.Nil(t, v)
+		assert.Equal(t, 0, up.pool.Len())
+		v := up.meta.Get(xorhashKey)
+		assert.Nil(t, v)
+	})
 }
 
 func addUxOut(up *Unspents, ux coin.UxOut) error {
 	var uxHash cipher.SHA256
 	var err error
-	if err := up.db.Update(func(tx *bolt.Tx) error {
+	if err := up.db.Update(func(tx storage.Tx) error {
 		uxHash, err = up.addWithTx(tx, ux)
 		return err
 	}); err != nil {
@@ -88,23 +89,23 @@ func TestUnspentPoolSyncCache(t *testing.T) {
 		uxs = append(uxs, ux)
 	}
 
-	db, closedb := testutil.PrepareDB(t)
-	defer closedb()
+	testutil.ForEachStore(t, func(t *testing.T, s storage.Store) {
 
-	up, err := NewUnspentPool(db)
-	assert.Nil(t, err)
+		up, err := newUnspents(s, unspentPoolBkt, unspentMetaBkt)
+		assert.Nil(t, err)
 
-	for _, ux := range uxs {
-		assert.Nil(t, addUxOut(up, ux))
-	}
+		for _, ux := range uxs {
+			assert.Nil(t, addUxOut(up, ux))
+		}
 
-	up2, err := NewUnspentPool(db)
-	require.NoError(t, err)
-	for k, v := range up.cache.pool {
-		v2, ok := up2.cache.pool[k]
-		require.True(t, ok)
-		require.Equal(t, v, v2)
-	}
+		up2, err := newUnspents(s, unspentPoolBkt, unspentMetaBkt)
+		require.NoError(t, err)
+		for k, v := range up.cache.pool {
+			v2, ok := up2.cache.pool[k]
+			require.True(t, ok)
+			require.Equal(t, v, v2)
+		}
+	})
 }
 
 func TestUnspentPoolRemoveUxFromCache(t *testing.T) {
@@ -114,19 +115,19 @@ func TestUnspentPoolRemoveUxFromCache(t *testing.T) {
 		uxs = append(uxs, ux)
 	}
 
-	db, closedb := testutil.PrepareDB(t)
-	defer closedb()
+	testutil.ForEachStore(t, func(t *testing.T, s storage.Store) {
 
-	up, err := NewUnspentPool(db)
-	assert.Nil(t, err)
+		up, err := newUnspents(s, unspentPoolBkt, unspentMetaBkt)
+		assert.Nil(t, err)
 
-	for _, ux := range uxs {
-		assert.Nil(t, addUxOut(up, ux))
-	}
+		for _, ux := range uxs {
+			assert.Nil(t, addUxOut(up, ux))
+		}
 
-	up.deleteUxFromCache(uxs[:1])
-	_, ok := up.cache.pool[uxs[0].Hash().Hex()]
-	require.False(t, ok)
+		up.deleteUxFromCache(uxs[:1])
+		_, ok := up.cache.pool[uxs[0].Hash().Hex()]
+		require.False(t, ok)
+	})
 }
 
 func TestUnspentPoolGet(t *testing.T) {
@@ -188,17 +189,17 @@ func TestUnspentPoolLen(t *testing.T) {
 		uxs = append(uxs, ux)
 	}
 
-	db, closedb := testutil.PrepareDB(t)
-	defer closedb()
+	testutil.ForEachStore(t, func(t *testing.T, s storage.Store) {
 
-	up, err := NewUnspentPool(db)
-	assert.Nil(t, err)
+		up, err := newUnspents(s, unspentPoolBkt, unspentMetaBkt)
+		assert.Nil(t, err)
 
-	for _, ux := range uxs {
-		assert.Nil(t, addUxOut(up, ux))
-	}
+		for _, ux := range uxs {
+			assert.Nil(t, addUxOut(up, ux))
+		}
 
-	require.Equal(t, uint64(5), up.Len())
+		require.Equal(t, uint64(5), up.Len())
+	})
 }
 
 func TestUnspentPoolGetUxHash(t *testing.T) {
@@ -208,83 +209,83 @@ func TestUnspentPoolGetUxHash(t *testing.T) {
 		uxs = append(uxs, ux)
 	}
 
-	db, closedb := testutil.PrepareDB(t)
-	defer closedb()
+	testutil.ForEachStore(t, func(t *testing.T, s storage.Store) {
 
-	up, err := NewUnspentPool(db)
-	assert.Nil(t, err)
+		up, err := newUnspents(s, unspentPoolBkt, unspentMetaBkt)
+		assert.Nil(t, err)
 
-	for _, ux := range uxs {
-		assert.Nil(t, addUxOut(up, ux))
-		uxHash := up.GetUxHash()
-		db.Update(func(tx *bolt.Tx) error {
-			xorhash, err := up.meta.getXorHashWithTx(tx)
-			require.NoError(t, err)
-			require.Equal(t, xorhash.Hex(), uxHash.Hex())
-			return nil
-		})
-	}
+		for _, ux := range uxs {
+			assert.Nil(t, addUxOut(up, ux))
+			uxHash := up.GetUxHash()
+			s.Update(func(tx storage.Tx) error {
+				xorhash, err := up.meta.getXorHashWithTx(tx)
+				require.NoError(t, err)
+				require.Equal(t, xorhash.Hex(), uxHash.Hex())
+				return nil
+			})
+		}
+	})
 }
 
 func TestUnspentPoolGetArray(t *testing.T) {
-	db, teardown := testutil.PrepareDB(t)
-	defer teardown()
+	testutil.ForEachStore(t, func(t *testing.T, s storage.Store) {
 
-	up, err := NewUnspentPool(db)
-	assert.Nil(t, err)
-
-	var uxs coin.UxArray
-	for i := 0; i < 5; i++ {
-		ux := makeUxOut(t)
-		err = addUxOut(up, ux)
+		up, err := newUnspents(s, unspentPoolBkt, unspentMetaBkt)
 		assert.Nil(t, err)
-		uxs = append(uxs, ux)
-	}
 
-	outsideUx := makeUxOut(t)
+		var uxs coin.UxArray
+		for i := 0; i < 5; i++ {
+			ux := makeUxOut(t)
+			err = addUxOut(up, ux)
+			assert.Nil(t, err)
+			uxs = append(uxs, ux)
+		}
 
-	testCases := []struct {
-		name     string
-		hashes   []cipher.SHA256
-		err      error
-		unspents coin.UxArray
-	}{
-		{
-			"get first",
-			[]cipher.SHA256{uxs[0].Hash()},
-			nil,
-			uxs[:1],
-		},
-		{
-			"get second",
-			[]cipher.SHA256{uxs[1].Hash()},
-			nil,
-			uxs[1:2],
-		},
-		{
-			"get two",
-			[]cipher.SHA256{uxs[0].Hash(), uxs[1].Hash()},
-			nil,
-			uxs[0:2],
-		},
-		{
-			"get not exist",
-			[]cipher.SHA256{outsideUx.Hash()},
-			fmt.Errorf("unspent output of %s does not exist", outsideUx.Hash().Hex()),
-			coin.UxArray{},
-		},
-	}
+		outsideUx := makeUxOut(t)
+
+		testCases := []struct {
+			name     string
+			hashes   []cipher.SHA256
+			err      error
+			unspents coin.UxArray
+		}{
+			{
+				"get first",
+				[]cipher.SHA256{uxs[0].Hash()},
+				nil,
+				uxs[:1],
+			},
+			{
+				"get second",
+				[]cipher.SHA256{uxs[1].Hash()},
+				nil,
+				uxs[1:2],
+			},
+			{
+				"get two",
+				[]cipher.SHA256{uxs[0].Hash(), uxs[1].Hash()},
+				nil,
+				uxs[0:2],
+			},
+			{
+				"get not exist",
+				[]cipher.SHA256{outsideUx.Hash()},
+				fmt.Errorf("unspent output of %s does not exist", outsideUx.Hash().Hex()),
+				coin.UxArray{},
+			},
+		}
 
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			uxs, err := up.GetArray(tc.hashes)
-			assert.Equal(t, tc.err, err)
-			if err != nil {
-				return
-			}
-			assert.Equal(t, tc.unspents, uxs)
-		})
-	}
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				uxs, err := up.GetArray(tc.hashes)
+				assert.Equal(t, tc.err, err)
+				if err != nil {
+					return
+				}
+				assert.Equal(t, tc.unspents, uxs)
+			})
+		}
+	})
 }
 
 func TestUnspentPoolGetAll(t *testing.T) {
@@ -437,7 +438,7 @@ func TestUnspentPoolDeleteWithTx(t *testing.T) {
 				assert.Nil(t, addUxOut(up, ux))
 			}
 
-			err = up.db.Update(func(tx *bolt.Tx) error {
+			err = up.db.Update(func(tx storage.Tx) error {
 				if _, err := up.deleteWithTx(tx, tc.deleteHashes); err != nil {
 					return err
 				}
@@ -636,3 +637,249 @@ func TestUnspentProcessBlock(t *testing.T) {
 	}
 
 }
+
+func TestReplaceUnspentPool(t *testing.T) {
+	db, closedb := testutil.PrepareDB(t)
+	defer closedb()
+
+	up, err := NewUnspentPool(db)
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, addUxOut(up, makeUxOut(t)))
+	}
+	liveUxHash := up.GetUxHash()
+
+	// Left over scratch buckets of a failed rebuild are dropped
+	stale, err := NewReindexUnspentPool(db)
+	require.NoError(t, err)
+	require.NoError(t, addUxOut(stale, makeUxOut(t)))
+
+	rp, err := NewReindexUnspentPool(db)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), rp.Len())
+
+	ux := makeUxOut(t)
+	require.NoError(t, addUxOut(rp, ux))
+	rebuiltUxHash := rp.GetUxHash()
+
+	// The live pool is not changed by the rebuild
+	up2, err := NewUnspentPool(db)
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), up2.Len())
+	require.Equal(t, liveUxHash, up2.GetUxHash())
+
+	require.NoError(t, ReplaceUnspentPool(db))
+
+	up3, err := NewUnspentPool(db)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), up3.Len())
+	require.True(t, up3.Contains(ux.Hash()))
+	require.Equal(t, rebuiltUxHash, up3.GetUxHash())
+
+	err = db.View(func(tx *bolt.Tx) error {
+		require.Nil(t, tx.Bucket(reindexPoolBkt))
+		require.Nil(t, tx.Bucket(reindexMetaBkt))
+		return nil
+	})
+	require.NoError(t, err)
+
+	// Replacing without a rebuilt pool fails
+	testutil.RequireError(t, ReplaceUnspentPool(db), "bucket unspent_pool_reindex does not exist")
+}
+
+func TestReplaceBucket(t *testing.T) {
+	testutil.ForEachStore(t, func(t *testing.T, s storage.Store) {
+		err := s.Update(func(tx storage.Tx) error {
+			dst, err := tx.CreateBucketIfNotExists([]byte("dst"))
+			if err != nil {
+				return err
+			}
+			if err := dst.Put([]byte("old"), []byte{1}); err != nil {
+				return err
+			}
+
+			src, err := tx.CreateBucketIfNotExists([]byte("src"))
+			if err != nil {
+				return err
+			}
+			return src.Put([]byte("new"), []byte{2})
+		})
+		require.NoError(t, err)
+
+		err = s.Update(func(tx storage.Tx) error {
+			return replaceBucketWithTx(tx, []byte("dst"), []byte("src"))
+		})
+		require.NoError(t, err)
+
+		err = s.View(func(tx storage.Tx) error {
+			require.Nil(t, tx.Bucket([]byte("src")))
+
+			dst := tx.Bucket([]byte("dst"))
+			require.NotNil(t, dst)
+			require.Nil(t, dst.Get([]byte("old")))
+			require.Equal(t, []byte{2}, dst.Get([]byte("new")))
+			return nil
+		})
+		require.NoError(t, err)
+
+		err = s.Update(func(tx storage.Tx) error {
+			return replaceBucketWithTx(tx, []byte("dst"), []byte("src"))
+		})
+		testutil.RequireError(t, err, "bucket src does not exist")
+	})
+}
+
+func TestUnspentPoolRollbackBlock(t *testing.T) {
+	testutil.ForEachStore(t, func(t *testing.T, s storage.Store) {
+
+		up, err := newUnspents(s, unspentPoolBkt, unspentMetaBkt)
+		require.NoError(t, err)
+
+		ux := makeUxOut(t)
+		other := makeUxOut(t)
+		require.NoError(t, addUxOut(up, ux))
+		require.NoError(t, addUxOut(up, other))
+		oldUxHash := up.GetUxHash()
+
+		tx := coin.Transaction{}
+		tx.PushInput(ux.Hash())
+		tx.PushOutput(testutil.MakeAddress(), 1e6, ux.Body.Hours/2)
+
+		block, err := coin.NewBlock(coin.Block{}, uint64(time.Now().Unix()), oldUxHash, coin.Transactions{tx}, _feeCalc)
+		require.NoError(t, err)
+		sb := &coin.SignedBlock{Block: *block}
+
+		err = s.Update(func(tx storage.Tx) error {
+			_, err := up.processBlockWithTx(tx, sb)
+			return err
+		})
+		require.NoError(t, err)
+		require.False(t, up.Contains(ux.Hash()))
+
+		// Missing spent output
+		err = s.Update(func(tx storage.Tx) error {
+			rb, err := up.rollbackBlockWithTx(tx, sb, nil)
+			if err != nil {
+				rb()
+			}
+			return err
+		})
+		require.Error(t, err)
+		require.Equal(t, uint64(2), up.Len())
+		require.False(t, up.Contains(ux.Hash()))
+
+		err = s.Update(func(tx storage.Tx) error {
+			_, err := up.rollbackBlockWithTx(tx, sb, coin.UxArray{ux})
+			return err
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, uint64(2), up.Len())
+		require.True(t, up.Contains(ux.Hash()))
+		require.True(t, up.Contains(other.Hash()))
+		txOuts := coin.CreateUnspents(block.Head, tx)
+		require.False(t, up.Contains(txOuts[0].Hash()))
+		require.Equal(t, oldUxHash.Hex(), up.GetUxHash().Hex())
+
+		// The restored state is persisted
+		up2, err := newUnspents(s, unspentPoolBkt, unspentMetaBkt)
+		require.NoError(t, err)
+		require.Equal(t, uint64(2), up2.Len())
+		require.Equal(t, oldUxHash.Hex(), up2.GetUxHash().Hex())
+	})
+}
+
+func TestUnspentProcessBlockChained(t *testing.T) {
+	forkSeq := ChainedSpendsForkSeq
+	defer func() {
+		ChainedSpendsForkSeq = forkSeq
+	}()
+
+	testutil.ForEachStore(t, func(t *testing.T, s storage.Store) {
+		up, err := newUnspents(s, unspentPoolBkt, unspentMetaBkt)
+		require.NoError(t, err)
+
+		ux := makeUxOut(t)
+		require.NoError(t, addUxOut(up, ux))
+		oldUxHash := up.GetUxHash()
+
+		// The child spends the output of its parent, in the same block
+		parent := coin.Transaction{}
+		parent.PushInput(ux.Hash())
+		parent.PushOutput(testutil.MakeAddress(), 1e6, ux.Body.Hours/2)
+
+		// The block is the block 1 of the chain
+		parentOut := coin.CreateUnspents(coin.BlockHeader{BkSeq: 1}, parent)[0]
+		child := coin.Transaction{}
+		child.PushInput(parentOut.Hash())
+		child.PushOutput(testutil.MakeAddress(), 1e6, ux.Body.Hours/4)
+
+		block, err := coin.NewBlock(coin.Block{}, uint64(time.Now().Unix()), oldUxHash, coin.Transactions{parent, child}, _feeCalc)
+		require.NoError(t, err)
+		require.Equal(t, uint64(1), block.Seq())
+		sb := &coin.SignedBlock{Block: *block}
+		childOut := coin.CreateUnspents(block.Head, child)[0]
+
+		// Rejected before the fork seq
+		ChainedSpendsForkSeq = 2
+		err = s.Update(func(tx storage.Tx) error {
+			_, err := up.processBlockWithTx(tx, sb)
+			return err
+		})
+		testutil.RequireError(t, err, fmt.Sprintf("unspent output of %s is created in the same block 1, before the chained spends fork seq", parentOut.Hash().Hex()))
+		require.Equal(t, uint64(1), up.Len())
+		require.True(t, up.Contains(ux.Hash()))
+		require.Equal(t, oldUxHash.Hex(), up.GetUxHash().Hex())
+
+		ChainedSpendsForkSeq = 1
+		err = s.Update(func(tx storage.Tx) error {
+			_, err := up.processBlockWithTx(tx, sb)
+			return err
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, uint64(1), up.Len())
+		require.False(t, up.Contains(ux.Hash()))
+		require.False(t, up.Contains(parentOut.Hash()))
+		require.True(t, up.Contains(childOut.Hash()))
+		uxHash := oldUxHash.Xor(ux.SnapshotHash()).Xor(childOut.SnapshotHash())
+		require.Equal(t, uxHash.Hex(), up.GetUxHash().Hex())
+
+		// The cache matches the db
+		up2, err := newUnspents(s, unspentPoolBkt, unspentMetaBkt)
+		require.NoError(t, err)
+		require.Equal(t, uint64(1), up2.Len())
+		require.True(t, up2.Contains(childOut.Hash()))
+		require.Equal(t, uxHash.Hex(), up2.GetUxHash().Hex())
+
+		err = s.Update(func(tx storage.Tx) error {
+			_, err := up.rollbackBlockWithTx(tx, sb, coin.UxArray{ux, parentOut})
+			return err
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, uint64(1), up.Len())
+		require.True(t, up.Contains(ux.Hash()))
+		require.False(t, up.Contains(parentOut.Hash()))
+		require.False(t, up.Contains(childOut.Hash()))
+		require.Equal(t, oldUxHash.Hex(), up.GetUxHash().Hex())
+
+		// An output spent twice in the same block
+		spender := coin.Transaction{}
+		spender.PushInput(ux.Hash())
+		spender.PushOutput(testutil.MakeAddress(), 1e6, ux.Body.Hours/4)
+
+		block, err = coin.NewBlock(coin.Block{}, uint64(time.Now().Unix()), oldUxHash, coin.Transactions{parent, spender}, _feeCalc)
+		require.NoError(t, err)
+
+		err = s.Update(func(tx storage.Tx) error {
+			_, err := up.processBlockWithTx(tx, &coin.SignedBlock{Block: *block})
+			return err
+		})
+		testutil.RequireError(t, err, fmt.Sprintf("unspent output of %s is spent twice in the block", ux.Hash().Hex()))
+		require.Equal(t, uint64(1), up.Len())
+		require.True(t, up.Contains(ux.Hash()))
+		require.Equal(t, oldUxHash.Hex(), up.GetUxHash().Hex())
+	})
+}
@@ -0,0 +1,75 @@
+package bucket
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/skycoin/skycoin/src/visor/storage"
+)
+
+// HexPrefixKeys returns up to limit keys of the bucket whose hex encoding starts with
+// hexPrefix and which are accepted by filter, in key order. hexPrefix may have an odd length.
+// A nil filter accepts all the keys. filter is called outside of the db transactions, so it
+// may read the db.
+func HexPrefixKeys(s storage.Store, name []byte, hexPrefix string, limit int, filter func(k []byte) (bool, error)) ([][]byte, error) {
+	if limit <= 0 {
+		return nil, errors.New("limit must be positive")
+	}
+
+	// The keys matching the prefix are contiguous, starting at the prefix padded with zeros
+	hexPrefix = strings.ToLower(hexPrefix)
+	start, err := hex.DecodeString(hexPrefix + strings.Repeat("0", len(hexPrefix)%2))
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex prefix: %v", err)
+	}
+
+	var keys [][]byte
+	for len(keys) < limit {
+		// Read the keys missing to reach the limit, the filter may reject some of them
+		n := limit - len(keys)
+		var batch [][]byte
+		err = s.View(func(tx storage.Tx) error {
+			bkt := tx.Bucket(name)
+			if bkt == nil {
+				return fmt.Errorf("bucket %s does not exist", string(name))
+			}
+
+			c := bkt.Cursor()
+			for k, _ := c.Seek(start); k != nil && len(batch) < n; k, _ = c.Next() {
+				if !strings.HasPrefix(hex.EncodeToString(k), hexPrefix) {
+					break
+				}
+				batch = append(batch, append([]byte{}, k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, k := range batch {
+			if filter != nil {
+				ok, err := filter(k)
+				if err != nil {
+					return nil, err
+				}
+				if !ok {
+					continue
+				}
+			}
+			keys = append(keys, k)
+		}
+
+		if len(batch) < n {
+			break
+		}
+
+		// The next batch starts after the last key read
+		last := batch[len(batch)-1]
+		start = append(append(make([]byte, 0, len(last)+1), last...), 0)
+	}
+
+	return keys, nil
+}
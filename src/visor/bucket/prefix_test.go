@@ -0,0 +1,91 @@
+package bucket
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/testutil"
+	"github.com/skycoin/skycoin/src/visor/storage"
+)
+
+func TestHexPrefixKeys(t *testing.T) {
+	testutil.ForEachStore(t, testHexPrefixKeys)
+}
+
+func testHexPrefixKeys(t *testing.T, s storage.Store) {
+	name := []byte("prefix")
+	err := s.Update(func(tx storage.Tx) error {
+		bkt, err := tx.CreateBucketIfNotExists(name)
+		if err != nil {
+			return err
+		}
+		for _, k := range [][]byte{{0x12, 0x30}, {0x12, 0x34}, {0x12, 0x35}, {0x12, 0x40}, {0x13}, {0xab, 0xcd}} {
+			if err := bkt.Put(k, []byte{1}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	cases := []struct {
+		prefix string
+		limit  int
+		keys   [][]byte
+	}{
+		{"12", 10, [][]byte{{0x12, 0x30}, {0x12, 0x34}, {0x12, 0x35}, {0x12, 0x40}}},
+		{"123", 10, [][]byte{{0x12, 0x30}, {0x12, 0x34}, {0x12, 0x35}}},
+		{"1234", 10, [][]byte{{0x12, 0x34}}},
+		{"124", 10, [][]byte{{0x12, 0x40}}},
+		{"1", 2, [][]byte{{0x12, 0x30}, {0x12, 0x34}}},
+		{"ABC", 10, [][]byte{{0xab, 0xcd}}},
+		{"ff", 10, nil},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.prefix, func(t *testing.T) {
+			keys, err := HexPrefixKeys(s, name, tc.prefix, tc.limit, nil)
+			require.NoError(t, err)
+			require.Equal(t, tc.keys, keys)
+		})
+	}
+
+	// The limit applies to the keys accepted by the filter
+	var filtered [][]byte
+	skipOdd := func(k []byte) (bool, error) {
+		filtered = append(filtered, k)
+		return k[len(k)-1]%2 == 0, nil
+	}
+	keys, err := HexPrefixKeys(s, name, "12", 2, skipOdd)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{{0x12, 0x30}, {0x12, 0x34}}, keys)
+	require.Equal(t, [][]byte{{0x12, 0x30}, {0x12, 0x34}}, filtered)
+
+	filtered = nil
+	keys, err = HexPrefixKeys(s, name, "12", 3, skipOdd)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{{0x12, 0x30}, {0x12, 0x34}, {0x12, 0x40}}, keys)
+	require.Equal(t, [][]byte{{0x12, 0x30}, {0x12, 0x34}, {0x12, 0x35}, {0x12, 0x40}}, filtered)
+
+	keys, err = HexPrefixKeys(s, name, "1", 10, func(k []byte) (bool, error) {
+		return len(k) == 1, nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{{0x13}}, keys)
+
+	_, err = HexPrefixKeys(s, name, "12", 10, func(k []byte) (bool, error) {
+		return false, errors.New("filter failed")
+	})
+	require.EqualError(t, err, "filter failed")
+
+	_, err = HexPrefixKeys(s, name, "xyz", 10, nil)
+	require.Error(t, err)
+
+	_, err = HexPrefixKeys(s, name, "12", 0, nil)
+	require.Error(t, err)
+
+	_, err = HexPrefixKeys(s, []byte("missing"), "12", 10, nil)
+	require.Error(t, err)
+}
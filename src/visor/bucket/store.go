@@ -0,0 +1,128 @@
+package bucket
+
+import (
+	"fmt"
+
+	"github.com/skycoin/skycoin/src/visor/storage"
+)
+
+// StoreBucket is a bucket of a storage.Store, it has the methods of Bucket
+// with the transactions of the store instead of *bolt.Tx
+type StoreBucket struct {
+	Name []byte
+	db   storage.Store
+}
+
+// NewStoreBucket creates the bucket in the store if it does not exist
+func NewStoreBucket(name []byte, db storage.Store) (*StoreBucket, error) {
+	if err := db.Update(func(tx storage.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(name)
+		return err
+	}); err != nil {
+		return nil, fmt.Errorf("create bucket %s failed: %v", string(name), err)
+	}
+
+	return &StoreBucket{
+		Name: name,
+		db:   db,
+	}, nil
+}
+
+// Reset deletes all the keys of the bucket
+func (b *StoreBucket) Reset() error {
+	return b.db.Update(func(tx storage.Tx) error {
+		if err := tx.DeleteBucket(b.Name); err != nil && err != storage.ErrBucketNotFound {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(b.Name)
+		return err
+	})
+}
+
+// Get returns a copy of the value of key, or nil if it does not exist
+func (b StoreBucket) Get(key []byte) []byte {
+	var value []byte
+	b.db.View(func(tx storage.Tx) error {
+		if v := b.GetWithTx(tx, key); v != nil {
+			value = append([]byte{}, v...)
+		}
+		return nil
+	})
+	return value
+}
+
+// GetWithTx returns the value of key in the transaction, or nil if it does not exist.
+// The value is only valid until the transaction ends.
+func (b StoreBucket) GetWithTx(tx storage.Tx, key []byte) []byte {
+	bkt := tx.Bucket(b.Name)
+	if bkt == nil {
+		return nil
+	}
+	return bkt.Get(key)
+}
+
+// Put sets the value of key
+func (b StoreBucket) Put(key []byte, value []byte) error {
+	return b.db.Update(func(tx storage.Tx) error {
+		return b.PutWithTx(tx, key, value)
+	})
+}
+
+// PutWithTx sets the value of key in the transaction
+func (b StoreBucket) PutWithTx(tx storage.Tx, key []byte, value []byte) error {
+	bkt := tx.Bucket(b.Name)
+	if bkt == nil {
+		return fmt.Errorf("bucket %s does not exist", string(b.Name))
+	}
+	return bkt.Put(key, value)
+}
+
+// Delete removes key
+func (b StoreBucket) Delete(key []byte) error {
+	return b.db.Update(func(tx storage.Tx) error {
+		return b.DeleteWithTx(tx, key)
+	})
+}
+
+// DeleteWithTx removes key in the transaction
+func (b StoreBucket) DeleteWithTx(tx storage.Tx, key []byte) error {
+	bkt := tx.Bucket(b.Name)
+	if bkt == nil {
+		return fmt.Errorf("bucket %s does not exist", string(b.Name))
+	}
+	return bkt.Delete(key)
+}
+
+// IsEmpty checks if the bucket has no key
+func (b StoreBucket) IsEmpty() bool {
+	empty := true
+	b.db.View(func(tx storage.Tx) error {
+		if bkt := tx.Bucket(b.Name); bkt != nil {
+			k, _ := bkt.Cursor().First()
+			empty = k == nil
+		}
+		return nil
+	})
+	return empty
+}
+
+// ForEach calls f for each key-value pair in key order, in a read only transaction
+func (b StoreBucket) ForEach(f func(k, v []byte) error) error {
+	return b.db.View(func(tx storage.Tx) error {
+		bkt := tx.Bucket(b.Name)
+		if bkt == nil {
+			return fmt.Errorf("bucket %s does not exist", string(b.Name))
+		}
+		return bkt.ForEach(f)
+	})
+}
+
+// Len returns the number of keys of the bucket
+func (b StoreBucket) Len() int {
+	var n int
+	b.ForEach(func(k, v []byte) error {
+		n++
+		return nil
+	})
+	return n
+}
@@ -0,0 +1,57 @@
+package bucket
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/testutil"
+	"github.com/skycoin/skycoin/src/visor/storage"
+)
+
+func TestStoreBucket(t *testing.T) {
+	testutil.ForEachStore(t, func(t *testing.T, s storage.Store) {
+		b, err := NewStoreBucket([]byte("bkt"), s)
+		require.NoError(t, err)
+		require.True(t, b.IsEmpty())
+		require.Equal(t, 0, b.Len())
+		require.Nil(t, b.Get([]byte("k1")))
+
+		require.NoError(t, b.Put([]byte("k2"), []byte("v2")))
+		require.NoError(t, b.Put([]byte("k1"), []byte("v1")))
+		require.False(t, b.IsEmpty())
+		require.Equal(t, 2, b.Len())
+		require.Equal(t, []byte("v1"), b.Get([]byte("k1")))
+
+		var keys []string
+		require.NoError(t, b.ForEach(func(k, v []byte) error {
+			keys = append(keys, string(k))
+			return nil
+		}))
+		require.Equal(t, []string{"k1", "k2"}, keys)
+
+		err = s.Update(func(tx storage.Tx) error {
+			if err := b.PutWithTx(tx, []byte("k3"), []byte("v3")); err != nil {
+				return err
+			}
+			require.Equal(t, []byte("v3"), b.GetWithTx(tx, []byte("k3")))
+			return b.DeleteWithTx(tx, []byte("k2"))
+		})
+		require.NoError(t, err)
+		require.Nil(t, b.Get([]byte("k2")))
+		require.Equal(t, []byte("v3"), b.Get([]byte("k3")))
+
+		require.NoError(t, b.Delete([]byte("k1")))
+		require.Nil(t, b.Get([]byte("k1")))
+
+		// creating the bucket again keeps its content
+		b, err = NewStoreBucket([]byte("bkt"), s)
+		require.NoError(t, err)
+		require.Equal(t, 1, b.Len())
+
+		require.NoError(t, b.Reset())
+		require.True(t, b.IsEmpty())
+		require.NoError(t, b.Put([]byte("k1"), []byte("v1")))
+		require.Equal(t, []byte("v1"), b.Get([]byte("k1")))
+	})
+}
@@ -59,7 +59,8 @@ func loadBlockchain(db *bolt.DB, pubkey cipher.PubKey, arbitrating bool) (*bolt.
 	return db, bc, nil
 }
 
-// OpenDB opens the blockdb
+// OpenDB opens the blockdb and migrates it to the current schema version,
+// the db is backed up before any migration runs
 func OpenDB(dbFile string) (*bolt.DB, error) {
 	db, err := bolt.Open(dbFile, 0600, &bolt.Options{
 		Timeout: 500 * time.Millisecond,
@@ -68,6 +69,11 @@ func OpenDB(dbFile string) (*bolt.DB, error) {
 		return nil, fmt.Errorf("Open boltdb failed, %v", err)
 	}
 
+	if _, err := MigrateDB(db, true); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("Migrate db failed, %v", err)
+	}
+
 	return db, nil
 }
 
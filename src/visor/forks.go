@@ -0,0 +1,46 @@
+package visor
+
+import (
+	"github.com/skycoin/skycoin/src/visor/blockdb"
+)
+
+// ReadableForkBlock represents a readable fork block
+type ReadableForkBlock struct {
+	Seq      uint64 `json:"seq"`
+	Hash     string `json:"block_hash"`
+	PrevHash string `json:"previous_block_hash"`
+	Signer   string `json:"signer"`
+	Source   string `json:"source"`
+	Received int64  `json:"received"`
+}
+
+// NewReadableForkBlock creates a readable fork block
+func NewReadableForkBlock(b blockdb.ForkBlock) ReadableForkBlock {
+	return ReadableForkBlock{
+		Seq:      b.Seq,
+		Hash:     b.Hash.Hex(),
+		PrevHash: b.PrevHash.Hex(),
+		Signer:   b.Signer.Hex(),
+		Source:   b.Source,
+		Received: b.Received,
+	}
+}
+
+// ReadableFork represents the competing blocks of a seq
+type ReadableFork struct {
+	Seq    uint64              `json:"seq"`
+	Blocks []ReadableForkBlock `json:"blocks"`
+}
+
+// NewReadableFork creates a readable fork
+func NewReadableFork(f blockdb.Fork) ReadableFork {
+	blocks := make([]ReadableForkBlock, 0, len(f.Blocks))
+	for _, b := range f.Blocks {
+		blocks = append(blocks, NewReadableForkBlock(b))
+	}
+
+	return ReadableFork{
+		Seq:    f.Seq,
+		Blocks: blocks,
+	}
+}
@@ -0,0 +1,96 @@
+package visor
+
+import (
+	"fmt"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/visor/historydb"
+)
+
+// HistoricalBalance is the balance of an address as of a block
+type HistoricalBalance struct {
+	Address cipher.Address
+	// Coins in droplets
+	Coins uint64
+	// Coin hours as of the time of the block
+	Hours uint64
+}
+
+// HistoricalBalances are the balances of addresses as of the block of seq Seq
+type HistoricalBalances struct {
+	Seq      uint64
+	Time     uint64
+	Balances []HistoricalBalance
+}
+
+// GetBalanceOfAddrsAt returns the balances of the addresses as of the block of given seq,
+// computed from the outputs of the history db which were created at or before the block and
+// not spent at or before it. The coin hours are computed as of the time of the block.
+func (vs *Visor) GetBalanceOfAddrsAt(addrs []cipher.Address, seq uint64) (*HistoricalBalances, error) {
+	b, err := vs.Blockchain.GetBlockBySeq(seq)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		return nil, fmt.Errorf("block %d does not exist", seq)
+	}
+
+	// The outputs of the blocks the history db has not parsed yet are missing
+	if h := vs.history.ParsedHeight(); h < int64(seq) {
+		return nil, fmt.Errorf("history db parsed height %d is below block %d", h, seq)
+	}
+
+	res := &HistoricalBalances{
+		Seq:      seq,
+		Time:     b.Head.Time,
+		Balances: make([]HistoricalBalance, 0, len(addrs)),
+	}
+
+	for _, addr := range addrs {
+		uxs, err := vs.history.GetAddrUxOuts(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		coins, hours, err := balanceAt(uxs, seq, b.Head.Time)
+		if err != nil {
+			return nil, err
+		}
+
+		res.Balances = append(res.Balances, HistoricalBalance{
+			Address: addr,
+			Coins:   coins,
+			Hours:   hours,
+		})
+	}
+
+	return res, nil
+}
+
+// balanceAt sums the outputs unspent as of the block of given seq, the hours as of time t
+func balanceAt(uxs []*historydb.UxOut, seq, t uint64) (uint64, uint64, error) {
+	var coins, hours uint64
+	for _, ux := range uxs {
+		if ux.Out.Head.BkSeq > seq {
+			continue
+		}
+
+		// SpentBlockSeq is 0 for unspent outputs, the genesis block spends nothing
+		if ux.SpentBlockSeq != 0 && ux.SpentBlockSeq <= seq {
+			continue
+		}
+
+		if coins+ux.Out.Body.Coins < coins {
+			return 0, 0, fmt.Errorf("coins of address %s overflow", ux.Out.Body.Address.String())
+		}
+		coins += ux.Out.Body.Coins
+
+		h := ux.Out.CoinHours(t)
+		if hours+h < hours {
+			return 0, 0, fmt.Errorf("coin hours of address %s overflow", ux.Out.Body.Address.String())
+		}
+		hours += h
+	}
+
+	return coins, hours, nil
+}
@@ -0,0 +1,106 @@
+package visor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/testutil"
+	"github.com/skycoin/skycoin/src/visor/historydb"
+)
+
+func TestBalanceAt(t *testing.T) {
+	addr := testutil.MakeAddress()
+
+	makeUx := func(seq, time, coins, hours, spentSeq uint64) *historydb.UxOut {
+		var ux historydb.UxOut
+		ux.Out.Head.BkSeq = seq
+		ux.Out.Head.Time = time
+		ux.Out.Body.Address = addr
+		ux.Out.Body.Coins = coins
+		ux.Out.Body.Hours = hours
+		ux.SpentBlockSeq = spentSeq
+		return &ux
+	}
+
+	uxs := []*historydb.UxOut{
+		// created in block 1, spent in block 3
+		makeUx(1, 1000, 10e6, 100, 3),
+		// created in block 2, unspent
+		makeUx(2, 2000, 5e6, 50, 0),
+		// created in block 3, unspent
+		makeUx(3, 3000, 1e6, 0, 0),
+	}
+
+	cases := []struct {
+		name  string
+		seq   uint64
+		time  uint64
+		coins uint64
+	}{
+		{"before", 0, 500, 0},
+		{"block 1", 1, 1000, 10e6},
+		{"block 2", 2, 2000, 15e6},
+		{"block 3", 3, 3000, 6e6},
+		{"later", 10, 10000, 6e6},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			coins, hours, err := balanceAt(uxs, tc.seq, tc.time)
+			require.NoError(t, err)
+			require.Equal(t, tc.coins, coins)
+
+			var expectedHours uint64
+			for _, ux := range uxs {
+				if ux.Out.Head.BkSeq <= tc.seq && (ux.SpentBlockSeq == 0 || ux.SpentBlockSeq > tc.seq) {
+					expectedHours += ux.Out.CoinHours(tc.time)
+				}
+			}
+			require.Equal(t, expectedHours, hours)
+		})
+	}
+
+	// The hours of an output grow with the time of the block
+	_, h1, err := balanceAt(uxs[1:2], 2, 2000)
+	require.NoError(t, err)
+	_, h2, err := balanceAt(uxs[1:2], 2, 2000+3600*24)
+	require.NoError(t, err)
+	require.True(t, h2 > h1)
+}
+
+func TestGetBalanceOfAddrsAt(t *testing.T) {
+	db, shutdown := testutil.PrepareDB(t)
+	defer shutdown()
+
+	db, bc, err := loadBlockchain(db, genPublic, false)
+	require.NoError(t, err)
+
+	history, err := historydb.New(db)
+	require.NoError(t, err)
+
+	v := &Visor{
+		Blockchain: bc,
+		history:    history,
+		db:         db,
+	}
+
+	gb := addGenesisBlock(t, bc)
+
+	// The history db has not parsed the genesis block
+	_, err = v.GetBalanceOfAddrsAt([]cipher.Address{genAddress}, 0)
+	testutil.RequireError(t, err, "history db parsed height -1 is below block 0")
+
+	require.NoError(t, history.ParseBlock(&gb.Block))
+
+	bals, err := v.GetBalanceOfAddrsAt([]cipher.Address{genAddress}, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), bals.Seq)
+	require.Equal(t, gb.Head.Time, bals.Time)
+	require.Len(t, bals.Balances, 1)
+	require.Equal(t, genCoins, bals.Balances[0].Coins)
+
+	_, err = v.GetBalanceOfAddrsAt([]cipher.Address{genAddress}, 1)
+	testutil.RequireError(t, err, "block 1 does not exist")
+}
@@ -0,0 +1,376 @@
+package visor
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/util/droplet"
+	"github.com/skycoin/skycoin/src/util/fee"
+	"github.com/skycoin/skycoin/src/visor/historydb"
+)
+
+// Directions of a history record, relative to the exported addresses
+const (
+	// HistoryReceived the transaction spends no output of the addresses
+	HistoryReceived = "received"
+	// HistorySent the transaction spends outputs of the addresses and sends coins to other addresses
+	HistorySent = "sent"
+	// HistorySelf the transaction spends outputs of the addresses and sends coins back to them only
+	HistorySelf = "self"
+)
+
+// Formats of a history export
+const (
+	// HistoryFormatCSV one row per transaction after a header row, counterparties separated by ";"
+	HistoryFormatCSV = "csv"
+	// HistoryFormatJSON one JSON object per line
+	HistoryFormatJSON = "json"
+)
+
+// historyExportPageSize is the number of transactions read from the address index at once by an export
+const historyExportPageSize = 1000
+
+// ErrInvalidHistoryFormat is returned when the history export format is neither csv nor json
+var ErrInvalidHistoryFormat = errors.New("format must be csv or json")
+
+// HistoryRecord is a confirmed transaction of a set of addresses, seen from these addresses
+type HistoryRecord struct {
+	Time     uint64
+	BlockSeq uint64
+	Txid     cipher.SHA256
+	// received, sent or self
+	Direction string
+	// Input addresses of a received transaction, output addresses of a sent one
+	Counterparties []cipher.Address
+	// Coins in droplets and coin hours received, sent to the counterparties, or moved for self
+	Coins uint64
+	Hours uint64
+	// Coin hours burned by the transaction, 0 for a received transaction
+	Fee uint64
+	// Note of the transaction, empty as the wallets have no transaction notes yet
+	Note string
+	// Number of blocks from the block of the transaction to the head, 1 in the head block
+	Confirmations uint64
+}
+
+// newHistoryRecord classifies the transaction relative to the own addresses, inUxs are the
+// outputs spent by the transaction and txnFee the coin hours it burns
+func newHistoryRecord(txn *coin.Transaction, inUxs coin.UxArray, own map[cipher.Address]struct{}, txnFee uint64) HistoryRecord {
+	r := HistoryRecord{
+		Txid:           txn.Hash(),
+		Counterparties: []cipher.Address{},
+	}
+
+	seen := make(map[cipher.Address]struct{})
+	addCounterparty := func(a cipher.Address) {
+		if _, ok := seen[a]; ok {
+			return
+		}
+		seen[a] = struct{}{}
+		r.Counterparties = append(r.Counterparties, a)
+	}
+
+	var ownIn bool
+	for _, ux := range inUxs {
+		if _, ok := own[ux.Body.Address]; ok {
+			ownIn = true
+			break
+		}
+	}
+
+	if !ownIn {
+		r.Direction = HistoryReceived
+		for _, ux := range inUxs {
+			addCounterparty(ux.Body.Address)
+		}
+		for _, o := range txn.Out {
+			if _, ok := own[o.Address]; ok {
+				r.Coins += o.Coins
+				r.Hours += o.Hours
+			}
+		}
+		return r
+	}
+
+	r.Fee = txnFee
+	for _, o := range txn.Out {
+		if _, ok := own[o.Address]; ok {
+			continue
+		}
+		addCounterparty(o.Address)
+		r.Coins += o.Coins
+		r.Hours += o.Hours
+	}
+
+	if len(r.Counterparties) > 0 {
+		r.Direction = HistorySent
+		return r
+	}
+
+	r.Direction = HistorySelf
+	for _, o := range txn.Out {
+		r.Coins += o.Coins
+		r.Hours += o.Hours
+	}
+
+	return r
+}
+
+// GetAddressesHistory returns the confirmed transactions of the addresses, ordered by
+// block seq then index in the block. A transaction between the addresses is returned once.
+func (vs *Visor) GetAddressesHistory(addrs []cipher.Address) ([]HistoryRecord, error) {
+	records := []HistoryRecord{}
+	var after *historydb.Cursor
+	for {
+		rs, next, err := vs.GetAddressesHistoryPage(addrs, after, historyExportPageSize)
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, rs...)
+		if next == nil {
+			return records, nil
+		}
+		after = next
+	}
+}
+
+// GetAddressesHistoryPage returns up to limit confirmed transactions of the addresses after the
+// cursor, ordered by block seq then index in the block, and the cursor of the next page, nil if
+// it is the last. A transaction between the addresses is returned once.
+func (vs *Visor) GetAddressesHistoryPage(addrs []cipher.Address, after *historydb.Cursor, limit int) ([]HistoryRecord, *historydb.Cursor, error) {
+	htxns, next, err := vs.history.GetAddrsTxnsPage(addrs, after, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	head, err := vs.Blockchain.Head()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	own := make(map[cipher.Address]struct{}, len(addrs))
+	for _, a := range addrs {
+		own[a] = struct{}{}
+	}
+
+	records := make([]HistoryRecord, 0, len(htxns))
+	for i := range htxns {
+		t := &htxns[i]
+		b, err := vs.Blockchain.GetBlockBySeq(t.BlockSeq)
+		if err != nil {
+			return nil, nil, err
+		}
+		if b == nil {
+			return nil, nil, fmt.Errorf("block %d of transaction %s does not exist", t.BlockSeq, t.Tx.Hash().Hex())
+		}
+
+		prevTime, err := vs.prevBlockTime(t.BlockSeq)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		r, err := vs.historyRecord(&t.Tx, b, prevTime, head.Seq(), own)
+		if err != nil {
+			return nil, nil, err
+		}
+		records = append(records, r)
+	}
+
+	return records, next, nil
+}
+
+// prevBlockTime returns the time of the block before seq, 0 for the genesis block.
+// The fees are paid with the coin hours of the inputs at the time of the previous block.
+func (vs *Visor) prevBlockTime(seq uint64) (uint64, error) {
+	if seq == 0 {
+		return 0, nil
+	}
+
+	prev, err := vs.Blockchain.GetBlockBySeq(seq - 1)
+	if err != nil {
+		return 0, err
+	}
+	if prev == nil {
+		return 0, fmt.Errorf("block %d does not exist", seq-1)
+	}
+
+	return prev.Head.Time, nil
+}
+
+// historyRecord returns the record of a transaction of block b, the outputs it spends are
+// read from the history db
+func (vs *Visor) historyRecord(txn *coin.Transaction, b *coin.SignedBlock, prevTime, headSeq uint64, own map[cipher.Address]struct{}) (HistoryRecord, error) {
+	inUxs := make(coin.UxArray, 0, len(txn.In))
+	for _, in := range txn.In {
+		ux, err := vs.history.GetUxout(in)
+		if err != nil {
+			return HistoryRecord{}, err
+		}
+		if ux == nil {
+			return HistoryRecord{}, fmt.Errorf("spent output %s does not exist in history db", in.Hex())
+		}
+		inUxs = append(inUxs, ux.Out)
+	}
+
+	// The genesis transaction has no inputs and pays no fee
+	var f uint64
+	if len(inUxs) > 0 {
+		var err error
+		f, err = fee.TransactionFee(txn, prevTime, inUxs)
+		if err != nil {
+			return HistoryRecord{}, err
+		}
+	}
+
+	r := newHistoryRecord(txn, inUxs, own, f)
+	r.Time = b.Head.Time
+	r.BlockSeq = b.Seq()
+	r.Confirmations = headSeq - b.Seq() + 1
+	return r, nil
+}
+
+// ReadableHistoryRecord is the exported form of a HistoryRecord
+type ReadableHistoryRecord struct {
+	// RFC3339 time of the block, UTC
+	Timestamp      string   `json:"timestamp"`
+	BlockSeq       uint64   `json:"block_seq"`
+	Txid           string   `json:"txid"`
+	Direction      string   `json:"direction"`
+	Counterparties []string `json:"counterparties"`
+	// Coins as a decimal string
+	Amount  string `json:"amount"`
+	Hours   uint64 `json:"hours"`
+	BurnFee uint64 `json:"burn_fee"`
+	Note    string `json:"note"`
+}
+
+// NewReadableHistoryRecords converts the history records to their exported form
+func NewReadableHistoryRecords(records []HistoryRecord) ([]ReadableHistoryRecord, error) {
+	rs := make([]ReadableHistoryRecord, 0, len(records))
+	for _, r := range records {
+		amount, err := droplet.ToString(r.Coins)
+		if err != nil {
+			return nil, err
+		}
+
+		counterparties := make([]string, 0, len(r.Counterparties))
+		for _, a := range r.Counterparties {
+			counterparties = append(counterparties, a.String())
+		}
+
+		rs = append(rs, ReadableHistoryRecord{
+			Timestamp:      time.Unix(int64(r.Time), 0).UTC().Format(time.RFC3339),
+			BlockSeq:       r.BlockSeq,
+			Txid:           r.Txid.Hex(),
+			Direction:      r.Direction,
+			Counterparties: counterparties,
+			Amount:         amount,
+			Hours:          r.Hours,
+			BurnFee:        r.Fee,
+			Note:           r.Note,
+		})
+	}
+
+	return rs, nil
+}
+
+// historyCSVHeader is the header row of the csv export, in the order of the record fields
+var historyCSVHeader = []string{
+	"timestamp",
+	"block_seq",
+	"txid",
+	"direction",
+	"counterparties",
+	"amount",
+	"hours",
+	"burn_fee",
+	"note",
+}
+
+// WriteHistory writes the history records in the csv or json format
+func WriteHistory(w io.Writer, format string, records []ReadableHistoryRecord) error {
+	hw, err := NewHistoryWriter(w, format)
+	if err != nil {
+		return err
+	}
+	return hw.Write(records)
+}
+
+// WriteHistoryCSV writes a header row then a row per history record
+func WriteHistoryCSV(w io.Writer, records []ReadableHistoryRecord) error {
+	return WriteHistory(w, HistoryFormatCSV, records)
+}
+
+// WriteHistoryJSONLines writes a JSON object per line per history record
+func WriteHistoryJSONLines(w io.Writer, records []ReadableHistoryRecord) error {
+	return WriteHistory(w, HistoryFormatJSON, records)
+}
+
+// HistoryWriter writes the history records in the csv or json format as they are read,
+// the csv header row is written with the first records
+type HistoryWriter struct {
+	w             io.Writer
+	format        string
+	headerWritten bool
+}
+
+// NewHistoryWriter creates a HistoryWriter of the format
+func NewHistoryWriter(w io.Writer, format string) (*HistoryWriter, error) {
+	if format != HistoryFormatCSV && format != HistoryFormatJSON {
+		return nil, ErrInvalidHistoryFormat
+	}
+
+	return &HistoryWriter{
+		w:      w,
+		format: format,
+	}, nil
+}
+
+// Write writes the records, a nil records writes the csv header row if not written yet
+func (hw *HistoryWriter) Write(records []ReadableHistoryRecord) error {
+	if hw.format == HistoryFormatJSON {
+		enc := json.NewEncoder(hw.w)
+		for _, r := range records {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	cw := csv.NewWriter(hw.w)
+	if !hw.headerWritten {
+		if err := cw.Write(historyCSVHeader); err != nil {
+			return err
+		}
+		hw.headerWritten = true
+	}
+
+	for _, r := range records {
+		if err := cw.Write([]string{
+			r.Timestamp,
+			strconv.FormatUint(r.BlockSeq, 10),
+			r.Txid,
+			r.Direction,
+			strings.Join(r.Counterparties, ";"),
+			r.Amount,
+			strconv.FormatUint(r.Hours, 10),
+			strconv.FormatUint(r.BurnFee, 10),
+			r.Note,
+		}); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
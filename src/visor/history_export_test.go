@@ -0,0 +1,191 @@
+package visor
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/testutil"
+)
+
+func TestNewHistoryRecord(t *testing.T) {
+	own1 := testutil.MakeAddress()
+	own2 := testutil.MakeAddress()
+	other1 := testutil.MakeAddress()
+	other2 := testutil.MakeAddress()
+
+	own := map[cipher.Address]struct{}{
+		own1: {},
+		own2: {},
+	}
+
+	makeUxs := func(addrs ...cipher.Address) coin.UxArray {
+		var uxs coin.UxArray
+		for _, a := range addrs {
+			var ux coin.UxOut
+			ux.Body.Address = a
+			ux.Body.Coins = 10e6
+			ux.Body.Hours = 100
+			uxs = append(uxs, ux)
+		}
+		return uxs
+	}
+
+	makeTxn := func(outs ...coin.TransactionOutput) coin.Transaction {
+		txn := coin.Transaction{Out: outs}
+		txn.UpdateHeader()
+		return txn
+	}
+
+	cases := []struct {
+		name  string
+		txn   coin.Transaction
+		inUxs coin.UxArray
+		want  HistoryRecord
+	}{
+		{
+			name: "received",
+			txn: makeTxn(
+				coin.TransactionOutput{Address: own1, Coins: 4e6, Hours: 10},
+				coin.TransactionOutput{Address: other1, Coins: 16e6, Hours: 20},
+			),
+			inUxs: makeUxs(other1, other2, other1),
+			want: HistoryRecord{
+				Direction:      HistoryReceived,
+				Counterparties: []cipher.Address{other1, other2},
+				Coins:          4e6,
+				Hours:          10,
+			},
+		},
+		{
+			name: "sent with change",
+			txn: makeTxn(
+				coin.TransactionOutput{Address: other1, Coins: 3e6, Hours: 5},
+				coin.TransactionOutput{Address: own2, Coins: 7e6, Hours: 15},
+			),
+			inUxs: makeUxs(own1),
+			want: HistoryRecord{
+				Direction:      HistorySent,
+				Counterparties: []cipher.Address{other1},
+				Coins:          3e6,
+				Hours:          5,
+				Fee:            80,
+			},
+		},
+		{
+			name: "self",
+			txn: makeTxn(
+				coin.TransactionOutput{Address: own1, Coins: 15e6, Hours: 20},
+				coin.TransactionOutput{Address: own2, Coins: 5e6, Hours: 20},
+			),
+			inUxs: makeUxs(own1, own2),
+			want: HistoryRecord{
+				Direction:      HistorySelf,
+				Counterparties: []cipher.Address{},
+				Coins:          20e6,
+				Hours:          40,
+				Fee:            80,
+			},
+		},
+		{
+			name: "genesis",
+			txn: makeTxn(
+				coin.TransactionOutput{Address: own1, Coins: 100e6, Hours: 100},
+			),
+			want: HistoryRecord{
+				Direction:      HistoryReceived,
+				Counterparties: []cipher.Address{},
+				Coins:          100e6,
+				Hours:          100,
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newHistoryRecord(&tc.txn, tc.inUxs, own, 80)
+			tc.want.Txid = tc.txn.Hash()
+			require.Equal(t, tc.want, r)
+		})
+	}
+}
+
+func TestWriteHistory(t *testing.T) {
+	addr1 := testutil.MakeAddress()
+	addr2 := testutil.MakeAddress()
+	txid := testutil.RandSHA256(t)
+
+	records, err := NewReadableHistoryRecords([]HistoryRecord{
+		{
+			Time:           1514764800,
+			BlockSeq:       10,
+			Txid:           txid,
+			Direction:      HistorySent,
+			Counterparties: []cipher.Address{addr1, addr2},
+			Coins:          1500000,
+			Hours:          7,
+			Fee:            3,
+		},
+		{
+			Time:           1514764810,
+			BlockSeq:       11,
+			Txid:           txid,
+			Direction:      HistorySelf,
+			Counterparties: []cipher.Address{},
+			Coins:          2e6,
+		},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, ReadableHistoryRecord{
+		Timestamp:      "2018-01-01T00:00:00Z",
+		BlockSeq:       10,
+		Txid:           txid.Hex(),
+		Direction:      HistorySent,
+		Counterparties: []string{addr1.String(), addr2.String()},
+		Amount:         "1.500000",
+		Hours:          7,
+		BurnFee:        3,
+	}, records[0])
+
+	var buf bytes.Buffer
+	err = WriteHistory(&buf, HistoryFormatCSV, records)
+	require.NoError(t, err)
+	require.Equal(t, "timestamp,block_seq,txid,direction,counterparties,amount,hours,burn_fee,note\n"+
+		"2018-01-01T00:00:00Z,10,"+txid.Hex()+",sent,"+addr1.String()+";"+addr2.String()+",1.500000,7,3,\n"+
+		"2018-01-01T00:00:10Z,11,"+txid.Hex()+",self,,2.000000,0,0,\n", buf.String())
+	csvExport := buf.String()
+
+	buf.Reset()
+	err = WriteHistory(&buf, HistoryFormatJSON, records)
+	require.NoError(t, err)
+	require.Equal(t, `{"timestamp":"2018-01-01T00:00:00Z","block_seq":10,"txid":"`+txid.Hex()+`","direction":"sent",`+
+		`"counterparties":["`+addr1.String()+`","`+addr2.String()+`"],"amount":"1.500000","hours":7,"burn_fee":3,"note":""}`+"\n"+
+		`{"timestamp":"2018-01-01T00:00:10Z","block_seq":11,"txid":"`+txid.Hex()+`","direction":"self",`+
+		`"counterparties":[],"amount":"2.000000","hours":0,"burn_fee":0,"note":""}`+"\n", buf.String())
+
+	err = WriteHistory(&buf, "xml", records)
+	require.Equal(t, ErrInvalidHistoryFormat, err)
+
+	// A history written a page at a time has a single header row
+	buf.Reset()
+	hw, err := NewHistoryWriter(&buf, HistoryFormatCSV)
+	require.NoError(t, err)
+	require.NoError(t, hw.Write(records[:1]))
+	require.NoError(t, hw.Write(records[1:]))
+	require.NoError(t, hw.Write(nil))
+	require.Equal(t, csvExport, buf.String())
+
+	// An empty history has the header row only
+	buf.Reset()
+	hw, err = NewHistoryWriter(&buf, HistoryFormatCSV)
+	require.NoError(t, err)
+	require.NoError(t, hw.Write(nil))
+	require.Equal(t, "timestamp,block_seq,txid,direction,counterparties,amount,hours,burn_fee,note\n", buf.String())
+
+	_, err = NewHistoryWriter(&buf, "xml")
+	require.Equal(t, ErrInvalidHistoryFormat, err)
+}
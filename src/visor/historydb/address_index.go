@@ -0,0 +1,446 @@
+package historydb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/encoder"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/visor/storage"
+)
+
+var (
+	// address transactions ordered by block seq then index of the transaction in the block,
+	// key as address + Cursor, value as transaction hash
+	addressTxnIndexBktName = []byte("address_txn_index")
+	// address outputs ordered by block seq then index of the output in the block,
+	// key as address + Cursor, value as output hash
+	addressUxIndexBktName = []byte("address_uxout_index")
+	// names of the buckets of the UxOuts and transactions wrappers, for the indexes
+	// which run on a storage.Tx instead of the wrappers
+	historyOutputsBktName = []byte("uxouts")
+	historyTxnsBktName    = []byte("transactions")
+)
+
+const cursorLen = 12
+
+// Cursor is the position of an entry of the ordered address indexes: the seq of
+// its block, then its index in the block
+type Cursor struct {
+	Seq   uint64
+	Index uint32
+}
+
+func (c Cursor) bytes() []byte {
+	b := make([]byte, cursorLen)
+	binary.BigEndian.PutUint64(b, c.Seq)
+	binary.BigEndian.PutUint32(b[8:], c.Index)
+	return b
+}
+
+func cursorFromBytes(b []byte) (Cursor, error) {
+	if len(b) != cursorLen {
+		return Cursor{}, fmt.Errorf("invalid cursor length %d", len(b))
+	}
+
+	return Cursor{
+		Seq:   binary.BigEndian.Uint64(b),
+		Index: binary.BigEndian.Uint32(b[8:]),
+	}, nil
+}
+
+// String encodes the cursor for the clients, decoded by ParseCursor
+func (c Cursor) String() string {
+	return hex.EncodeToString(c.bytes())
+}
+
+// ParseCursor decodes a cursor encoded by Cursor.String
+func ParseCursor(s string) (Cursor, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return Cursor{}, errors.New("invalid cursor")
+	}
+
+	c, err := cursorFromBytes(b)
+	if err != nil {
+		return Cursor{}, errors.New("invalid cursor")
+	}
+
+	return c, nil
+}
+
+func addressIndexKey(addr cipher.Address, c Cursor) []byte {
+	return append(addr.Bytes(), c.bytes()...)
+}
+
+// addressIndex is a bucket of hashes ordered by address, block seq and index in the block,
+// so the entries of an address can be read page by page
+type addressIndex struct {
+	db   storage.Store
+	name []byte
+}
+
+func newAddressIndex(db storage.Store, name []byte) (*addressIndex, error) {
+	if err := db.Update(func(tx storage.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(name)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return &addressIndex{
+		db:   db,
+		name: name,
+	}, nil
+}
+
+// page returns up to limit hashes of the address after the cursor, or from the first one if
+// after is nil, and the cursor of the last returned hash if there are more, nil otherwise
+func (ai *addressIndex) page(addr cipher.Address, after *Cursor, limit int) ([]cipher.SHA256, *Cursor, error) {
+	if limit <= 0 {
+		return nil, nil, errors.New("limit must be positive")
+	}
+
+	var hashes []cipher.SHA256
+	var cursors []Cursor
+	err := ai.db.View(func(tx storage.Tx) error {
+		var err error
+		cursors, hashes, err = ai.scanWithTx(tx, addr, after, limit+1)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(hashes) > limit {
+		return hashes[:limit], &cursors[limit-1], nil
+	}
+
+	return hashes, nil, nil
+}
+
+// mergedPage is page for a set of addresses: returns up to limit hashes of any of the addresses
+// after the cursor, in the order of the index. An entry shared by several addresses, like a
+// transaction between them, is returned once.
+func (ai *addressIndex) mergedPage(addrs []cipher.Address, after *Cursor, limit int) ([]cipher.SHA256, *Cursor, error) {
+	if limit <= 0 {
+		return nil, nil, errors.New("limit must be positive")
+	}
+
+	// The first limit+1 entries of each address hold the first limit+1 entries of the union
+	entries := make(map[Cursor]cipher.SHA256)
+	err := ai.db.View(func(tx storage.Tx) error {
+		for _, addr := range addrs {
+			cursors, hashes, err := ai.scanWithTx(tx, addr, after, limit+1)
+			if err != nil {
+				return err
+			}
+
+			for i, c := range cursors {
+				entries[c] = hashes[i]
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cursors := make([]Cursor, 0, len(entries))
+	for c := range entries {
+		cursors = append(cursors, c)
+	}
+	sort.Slice(cursors, func(i, j int) bool {
+		if cursors[i].Seq != cursors[j].Seq {
+			return cursors[i].Seq < cursors[j].Seq
+		}
+		return cursors[i].Index < cursors[j].Index
+	})
+
+	var next *Cursor
+	if len(cursors) > limit {
+		cursors = cursors[:limit]
+		next = &cursors[limit-1]
+	}
+
+	hashes := make([]cipher.SHA256, 0, len(cursors))
+	for _, c := range cursors {
+		hashes = append(hashes, entries[c])
+	}
+
+	return hashes, next, nil
+}
+
+// scanWithTx returns up to n entries of the address after the cursor, or from the first one
+// if after is nil
+func (ai *addressIndex) scanWithTx(tx storage.Tx, addr cipher.Address, after *Cursor, n int) ([]Cursor, []cipher.SHA256, error) {
+	bkt := tx.Bucket(ai.name)
+	if bkt == nil {
+		return nil, nil, fmt.Errorf("bucket %s does not exist", string(ai.name))
+	}
+
+	prefix := addr.Bytes()
+	c := bkt.Cursor()
+
+	var k, v []byte
+	if after == nil {
+		k, v = c.Seek(prefix)
+	} else {
+		start := addressIndexKey(addr, *after)
+		k, v = c.Seek(start)
+		if bytes.Equal(k, start) {
+			k, v = c.Next()
+		}
+	}
+
+	cursors := []Cursor{}
+	hashes := []cipher.SHA256{}
+	for ; k != nil && bytes.HasPrefix(k, prefix) && len(hashes) < n; k, v = c.Next() {
+		if len(v) != len(cipher.SHA256{}) {
+			return nil, nil, fmt.Errorf("invalid hash length %d in %s", len(v), string(ai.name))
+		}
+
+		ic, err := cursorFromBytes(k[len(prefix):])
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var h cipher.SHA256
+		copy(h[:], v)
+		hashes = append(hashes, h)
+		cursors = append(cursors, ic)
+	}
+
+	return cursors, hashes, nil
+}
+
+// Reset resets the bucket
+func (ai *addressIndex) Reset() error {
+	return ai.db.Update(func(tx storage.Tx) error {
+		if err := tx.DeleteBucket(ai.name); err != nil && err != storage.ErrBucketNotFound {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(ai.name)
+		return err
+	})
+}
+
+// indexBlockWithTx adds the transactions and the created outputs of a parsed block to
+// the ordered address indexes. Must be called by ParseBlock once the block's outputs
+// are stored, the addresses of the spent outputs are read from the outputs bucket.
+func indexBlockWithTx(tx storage.Tx, b *coin.Block) error {
+	txnIndexBkt := tx.Bucket(addressTxnIndexBktName)
+	uxIndexBkt := tx.Bucket(addressUxIndexBktName)
+	outputsBkt := tx.Bucket(historyOutputsBktName)
+	if txnIndexBkt == nil || uxIndexBkt == nil || outputsBkt == nil {
+		return errors.New("history db buckets do not exist")
+	}
+
+	var uxIndex uint32
+	for i, txn := range b.Body.Transactions {
+		txHash := txn.Hash()
+		c := Cursor{
+			Seq:   b.Seq(),
+			Index: uint32(i),
+		}
+
+		for _, in := range txn.In {
+			v := outputsBkt.Get(in[:])
+			if v == nil {
+				return fmt.Errorf("spent output %s does not exist in history db", in.Hex())
+			}
+
+			var out UxOut
+			if err := encoder.DeserializeRaw(v, &out); err != nil {
+				return err
+			}
+
+			if err := txnIndexBkt.Put(addressIndexKey(out.Out.Body.Address, c), txHash[:]); err != nil {
+				return err
+			}
+		}
+
+		for _, ux := range coin.CreateUnspents(b.Head, txn) {
+			addr := ux.Body.Address
+			if err := txnIndexBkt.Put(addressIndexKey(addr, c), txHash[:]); err != nil {
+				return err
+			}
+
+			uxHash := ux.Hash()
+			uc := Cursor{
+				Seq:   b.Seq(),
+				Index: uxIndex,
+			}
+			if err := uxIndexBkt.Put(addressIndexKey(addr, uc), uxHash[:]); err != nil {
+				return err
+			}
+			uxIndex++
+		}
+	}
+
+	return nil
+}
+
+// unindexBlockWithTx removes the entries of a parsed block from the ordered address indexes,
+// must be called before the block's outputs are removed from the outputs bucket
+func unindexBlockWithTx(tx storage.Tx, b *coin.Block) error {
+	txnIndexBkt := tx.Bucket(addressTxnIndexBktName)
+	uxIndexBkt := tx.Bucket(addressUxIndexBktName)
+	outputsBkt := tx.Bucket(historyOutputsBktName)
+	if txnIndexBkt == nil || uxIndexBkt == nil || outputsBkt == nil {
+		return errors.New("history db buckets do not exist")
+	}
+
+	addrs := make(map[cipher.Address]struct{})
+	for _, txn := range b.Body.Transactions {
+		for _, in := range txn.In {
+			v := outputsBkt.Get(in[:])
+			if v == nil {
+				continue
+			}
+
+			var out UxOut
+			if err := encoder.DeserializeRaw(v, &out); err != nil {
+				return err
+			}
+			addrs[out.Out.Body.Address] = struct{}{}
+		}
+
+		for _, o := range txn.Out {
+			addrs[o.Address] = struct{}{}
+		}
+	}
+
+	seq := make([]byte, 8)
+	binary.BigEndian.PutUint64(seq, b.Seq())
+
+	for addr := range addrs {
+		prefix := append(addr.Bytes(), seq...)
+		for _, bkt := range []storage.Bucket{txnIndexBkt, uxIndexBkt} {
+			if err := deletePrefix(bkt, prefix); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func deletePrefix(bkt storage.Bucket, prefix []byte) error {
+	var keys [][]byte
+	c := bkt.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		keys = append(keys, append([]byte{}, k...))
+	}
+
+	for _, k := range keys {
+		if err := bkt.Delete(k); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetAddrTxnsPage returns up to limit transactions of the address after the cursor, ordered
+// by block seq then index in the block, and the cursor of the next page, nil if it is the last
+func (hd *HistoryDB) GetAddrTxnsPage(addr cipher.Address, after *Cursor, limit int) ([]Transaction, *Cursor, error) {
+	hashes, next, err := hd.addrTxnIndex.page(addr, after, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	txns := make([]Transaction, 0, len(hashes))
+	for _, h := range hashes {
+		txn, err := hd.txns.Get(h)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if txn == nil {
+			return nil, nil, fmt.Errorf("transaction %s of the address index does not exist", h.Hex())
+		}
+
+		txns = append(txns, *txn)
+	}
+
+	return txns, next, nil
+}
+
+// GetAddrsTxnsPage returns up to limit transactions of any of the addresses after the cursor,
+// ordered by block seq then index in the block, and the cursor of the next page, nil if it is
+// the last. A transaction between the addresses is returned once.
+func (hd *HistoryDB) GetAddrsTxnsPage(addrs []cipher.Address, after *Cursor, limit int) ([]Transaction, *Cursor, error) {
+	hashes, next, err := hd.addrTxnIndex.mergedPage(addrs, after, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	txns := make([]Transaction, 0, len(hashes))
+	for _, h := range hashes {
+		txn, err := hd.txns.Get(h)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if txn == nil {
+			return nil, nil, fmt.Errorf("transaction %s of the address index does not exist", h.Hex())
+		}
+
+		txns = append(txns, *txn)
+	}
+
+	return txns, next, nil
+}
+
+// GetAddrUxOutsPage returns up to limit outputs of the address after the cursor, ordered
+// by block seq then index in the block, and the cursor of the next page, nil if it is the last
+func (hd *HistoryDB) GetAddrUxOutsPage(addr cipher.Address, after *Cursor, limit int) ([]*UxOut, *Cursor, error) {
+	hashes, next, err := hd.addrUxIndex.page(addr, after, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	uxs := make([]*UxOut, 0, len(hashes))
+	for _, h := range hashes {
+		ux, err := hd.outputs.Get(h)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if ux == nil {
+			return nil, nil, fmt.Errorf("output %s of the address index does not exist", h.Hex())
+		}
+
+		uxs = append(uxs, ux)
+	}
+
+	return uxs, next, nil
+}
+
+// ResetWithTx removes all parsed history, including the parsed height, like Reset
+func ResetWithTx(tx storage.Tx) error {
+	for _, name := range [][]byte{
+		addressTxnsBktName,
+		addressUxBktName,
+		addressTxnIndexBktName,
+		addressUxIndexBktName,
+		addressStatsBktName,
+		addressBalanceIndexBktName,
+		addressStatsMetaBktName,
+		historyOutputsBktName,
+		historyTxnsBktName,
+		historyMetaBkt,
+	} {
+		if err := tx.DeleteBucket(name); err != nil && err != storage.ErrBucketNotFound {
+			return err
+		}
+	}
+
+	return nil
+}
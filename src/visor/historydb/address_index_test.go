@@ -0,0 +1,197 @@
+package historydb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/encoder"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/testutil"
+	"github.com/skycoin/skycoin/src/visor/storage"
+)
+
+func TestCursor(t *testing.T) {
+	c := Cursor{Seq: 10, Index: 3}
+	parsed, err := ParseCursor(c.String())
+	require.NoError(t, err)
+	require.Equal(t, c, parsed)
+
+	for _, s := range []string{"", "xyz", "00ff"} {
+		_, err := ParseCursor(s)
+		require.Error(t, err)
+	}
+}
+
+// createBuckets creates the buckets in the store
+func createBuckets(t *testing.T, db storage.Store, names ...[]byte) {
+	err := db.Update(func(tx storage.Tx) error {
+		for _, name := range names {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestAddressIndex(t *testing.T) {
+	testutil.ForEachStore(t, testAddressIndex)
+}
+
+func testAddressIndex(t *testing.T, db storage.Store) {
+	txnIndex, err := newAddressIndex(db, addressTxnIndexBktName)
+	require.NoError(t, err)
+	uxIndex, err := newAddressIndex(db, addressUxIndexBktName)
+	require.NoError(t, err)
+	createBuckets(t, db, historyOutputsBktName)
+
+	addr := makeAddress()
+	other := makeAddress()
+
+	// Block 1 sends 3 outputs to addr and 1 to other
+	var txn1 coin.Transaction
+	txn1.PushInput(testutil.RandSHA256(t))
+	for i := 0; i < 3; i++ {
+		txn1.PushOutput(addr, 1e6, 1)
+	}
+	txn1.PushOutput(other, 1e6, 1)
+	txn1.UpdateHeader()
+
+	b1 := coin.Block{
+		Head: coin.BlockHeader{BkSeq: 1},
+		Body: coin.BlockBody{Transactions: coin.Transactions{txn1}},
+	}
+
+	// Block 2 sends to other, then other spends an output of addr back to other
+	var txn2 coin.Transaction
+	txn2.PushInput(testutil.RandSHA256(t))
+	txn2.PushOutput(other, 1e6, 1)
+	txn2.UpdateHeader()
+
+	spentUx := coin.CreateUnspents(b1.Head, txn1)[0]
+	var txn3 coin.Transaction
+	txn3.PushInput(spentUx.Hash())
+	txn3.PushOutput(other, 1e6, 1)
+	txn3.UpdateHeader()
+
+	b2 := coin.Block{
+		Head: coin.BlockHeader{BkSeq: 2},
+		Body: coin.BlockBody{Transactions: coin.Transactions{txn2, txn3}},
+	}
+
+	storeOutputs := func(tx storage.Tx, b *coin.Block) error {
+		bkt := tx.Bucket(historyOutputsBktName)
+		for _, txn := range b.Body.Transactions {
+			for _, in := range txn.In {
+				var ux UxOut
+				if in == spentUx.Hash() {
+					ux.Out = spentUx
+				} else {
+					ux.Out.Body.Address = makeAddress()
+				}
+				if err := bkt.Put(in[:], encoder.Serialize(ux)); err != nil {
+					return err
+				}
+			}
+			for _, ux := range coin.CreateUnspents(b.Head, txn) {
+				h := ux.Hash()
+				if err := bkt.Put(h[:], encoder.Serialize(UxOut{Out: ux})); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	for _, b := range []*coin.Block{&b1, &b2} {
+		err := db.Update(func(tx storage.Tx) error {
+			if err := storeOutputs(tx, b); err != nil {
+				return err
+			}
+			return indexBlockWithTx(tx, b)
+		})
+		require.NoError(t, err)
+	}
+
+	// addr has txn1 and txn3
+	hashes, next, err := txnIndex.page(addr, nil, 10)
+	require.NoError(t, err)
+	require.Nil(t, next)
+	require.Equal(t, []cipher.SHA256{txn1.Hash(), txn3.Hash()}, hashes)
+
+	// other has txn1, txn2 and txn3, read one by one
+	var all []cipher.SHA256
+	var after *Cursor
+	for {
+		hashes, next, err := txnIndex.page(other, after, 1)
+		require.NoError(t, err)
+		require.Len(t, hashes, 1)
+		all = append(all, hashes...)
+		if next == nil {
+			break
+		}
+		after = next
+	}
+	require.Equal(t, []cipher.SHA256{txn1.Hash(), txn2.Hash(), txn3.Hash()}, all)
+
+	// The outputs of addr in the order they were created
+	uxs := coin.CreateUnspents(b1.Head, txn1)
+	hashes, next, err = uxIndex.page(addr, nil, 2)
+	require.NoError(t, err)
+	require.Equal(t, []cipher.SHA256{uxs[0].Hash(), uxs[1].Hash()}, hashes)
+	require.Equal(t, &Cursor{Seq: 1, Index: 1}, next)
+
+	hashes, next, err = uxIndex.page(addr, next, 2)
+	require.NoError(t, err)
+	require.Nil(t, next)
+	require.Equal(t, []cipher.SHA256{uxs[2].Hash()}, hashes)
+
+	// Output 3 of block 1 is the one of other, outputs of block 2 follow
+	hashes, _, err = uxIndex.page(other, nil, 10)
+	require.NoError(t, err)
+	require.Len(t, hashes, 3)
+	require.Equal(t, uxs[3].Hash(), hashes[0])
+
+	_, _, err = uxIndex.page(addr, nil, 0)
+	require.Error(t, err)
+
+	// The transactions of both addresses, txn1 and txn3 are shared
+	hashes, next, err = txnIndex.mergedPage([]cipher.Address{addr, other}, nil, 2)
+	require.NoError(t, err)
+	require.Equal(t, []cipher.SHA256{txn1.Hash(), txn2.Hash()}, hashes)
+	require.Equal(t, &Cursor{Seq: 2, Index: 0}, next)
+
+	hashes, next, err = txnIndex.mergedPage([]cipher.Address{addr, other}, next, 2)
+	require.NoError(t, err)
+	require.Nil(t, next)
+	require.Equal(t, []cipher.SHA256{txn3.Hash()}, hashes)
+
+	hashes, next, err = txnIndex.mergedPage([]cipher.Address{addr, other}, nil, 3)
+	require.NoError(t, err)
+	require.Nil(t, next)
+	require.Equal(t, []cipher.SHA256{txn1.Hash(), txn2.Hash(), txn3.Hash()}, hashes)
+
+	_, _, err = txnIndex.mergedPage([]cipher.Address{addr}, nil, 0)
+	require.Error(t, err)
+
+	// Removing block 2 keeps block 1
+	err = db.Update(func(tx storage.Tx) error {
+		return unindexBlockWithTx(tx, &b2)
+	})
+	require.NoError(t, err)
+
+	hashes, _, err = txnIndex.page(addr, nil, 10)
+	require.NoError(t, err)
+	require.Equal(t, []cipher.SHA256{txn1.Hash()}, hashes)
+
+	hashes, _, err = txnIndex.page(other, nil, 10)
+	require.NoError(t, err)
+	require.Equal(t, []cipher.SHA256{txn1.Hash()}, hashes)
+
+	hashes, _, err = uxIndex.page(other, nil, 10)
+	require.NoError(t, err)
+	require.Equal(t, []cipher.SHA256{uxs[3].Hash()}, hashes)
+}
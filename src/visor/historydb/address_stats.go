@@ -0,0 +1,468 @@
+package historydb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/encoder"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/visor/storage"
+)
+
+var (
+	// address statistics, key as address, value as AddressStats
+	addressStatsBktName = []byte("address_stats")
+	// addresses with a non zero balance ordered by balance, key as balance + address
+	addressBalanceIndexBktName = []byte("address_balance_index")
+	// counters of the address statistics
+	addressStatsMetaBktName = []byte("address_stats_meta")
+	// number of addresses with a non zero balance, the size of the balance index
+	addressCountKey = []byte("address_count")
+)
+
+var addressStatsBktNames = [][]byte{
+	addressStatsBktName,
+	addressBalanceIndexBktName,
+	addressStatsMetaBktName,
+}
+
+// AddressStats are the statistics of an address, updated when blocks are parsed
+type AddressStats struct {
+	// Balance in droplets
+	Balance uint64
+	// Coins received and sent in droplets, change sent back to the address counts in both
+	Received uint64
+	Sent     uint64
+	// Number of transactions spending from or sending to the address
+	TxnCount uint64
+	// Seqs of the first and last blocks with a transaction of the address
+	FirstSeq uint64
+	LastSeq  uint64
+}
+
+// AddressBalance is an entry of the rich list
+type AddressBalance struct {
+	Address cipher.Address
+	// Balance in droplets
+	Balance uint64
+}
+
+// addressStats holds the address statistics and the balance ordered index of the rich list
+type addressStats struct {
+	db storage.Store
+}
+
+func newAddressStats(db storage.Store) (*addressStats, error) {
+	if err := db.Update(func(tx storage.Tx) error {
+		for _, name := range addressStatsBktNames {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+
+		// The balance index of a db created before the counter is counted once
+		metaBkt := tx.Bucket(addressStatsMetaBktName)
+		if metaBkt.Get(addressCountKey) != nil {
+			return nil
+		}
+
+		var n uint64
+		if err := tx.Bucket(addressBalanceIndexBktName).ForEach(func(k, v []byte) error {
+			n++
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		return putAddressCount(metaBkt, n)
+	}); err != nil {
+		return nil, err
+	}
+
+	return &addressStats{db: db}, nil
+}
+
+// get returns the statistics of the address, nil if it has no transaction
+func (as *addressStats) get(addr cipher.Address) (*AddressStats, error) {
+	var s *AddressStats
+	err := as.db.View(func(tx storage.Tx) error {
+		bkt := tx.Bucket(addressStatsBktName)
+		if bkt == nil {
+			return fmt.Errorf("bucket %s does not exist", string(addressStatsBktName))
+		}
+
+		var err error
+		s, err = getAddressStats(bkt, addr)
+		return err
+	})
+	return s, err
+}
+
+// richlist returns up to limit addresses with the highest balances, richest first,
+// skipping the excluded addresses
+func (as *addressStats) richlist(limit int, exclude map[cipher.Address]struct{}) ([]AddressBalance, error) {
+	if limit <= 0 {
+		return nil, errors.New("limit must be positive")
+	}
+
+	balances := []AddressBalance{}
+	err := as.db.View(func(tx storage.Tx) error {
+		bkt := tx.Bucket(addressBalanceIndexBktName)
+		if bkt == nil {
+			return fmt.Errorf("bucket %s does not exist", string(addressBalanceIndexBktName))
+		}
+
+		c := bkt.Cursor()
+		for k, _ := c.Last(); k != nil && len(balances) < limit; k, _ = c.Prev() {
+			addr, err := cipher.AddressFromBytes(k[8:])
+			if err != nil {
+				return err
+			}
+
+			if _, ok := exclude[addr]; ok {
+				continue
+			}
+
+			balances = append(balances, AddressBalance{
+				Address: addr,
+				Balance: binary.BigEndian.Uint64(k[:8]),
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return balances, nil
+}
+
+// count returns the number of addresses with a non zero balance
+func (as *addressStats) count() (uint64, error) {
+	var n uint64
+	err := as.db.View(func(tx storage.Tx) error {
+		bkt := tx.Bucket(addressStatsMetaBktName)
+		if bkt == nil {
+			return fmt.Errorf("bucket %s does not exist", string(addressStatsMetaBktName))
+		}
+
+		n = getAddressCount(bkt)
+		return nil
+	})
+	return n, err
+}
+
+// Reset resets the buckets
+func (as *addressStats) Reset() error {
+	return as.db.Update(func(tx storage.Tx) error {
+		for _, name := range addressStatsBktNames {
+			if err := tx.DeleteBucket(name); err != nil && err != storage.ErrBucketNotFound {
+				return err
+			}
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return putAddressCount(tx.Bucket(addressStatsMetaBktName), 0)
+	})
+}
+
+func getAddressStats(bkt storage.Bucket, addr cipher.Address) (*AddressStats, error) {
+	v := bkt.Get(addr.Bytes())
+	if v == nil {
+		return nil, nil
+	}
+
+	var s AddressStats
+	if err := encoder.DeserializeRaw(v, &s); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+func addressBalanceKey(addr cipher.Address, balance uint64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, balance)
+	return append(k, addr.Bytes()...)
+}
+
+// getAddressCount returns the number of addresses with a non zero balance
+func getAddressCount(metaBkt storage.Bucket) uint64 {
+	v := metaBkt.Get(addressCountKey)
+	if v == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(v)
+}
+
+func putAddressCount(metaBkt storage.Bucket, n uint64) error {
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint64(v, n)
+	return metaBkt.Put(addressCountKey, v)
+}
+
+// addressStatsBkts are the buckets updated with the address statistics
+type addressStatsBkts struct {
+	stats    storage.Bucket
+	balances storage.Bucket
+	meta     storage.Bucket
+	outputs  storage.Bucket
+}
+
+// putAddressStats replaces the statistics old of the address by s, s nil deletes them.
+// The address count is updated when the balance becomes zero or non zero.
+func putAddressStats(bkts addressStatsBkts, addr cipher.Address, old, s *AddressStats) error {
+	hadBalance := old != nil && old.Balance > 0
+	hasBalance := s != nil && s.Balance > 0
+
+	if hadBalance {
+		if err := bkts.balances.Delete(addressBalanceKey(addr, old.Balance)); err != nil {
+			return err
+		}
+	}
+
+	if hasBalance {
+		if err := bkts.balances.Put(addressBalanceKey(addr, s.Balance), []byte{}); err != nil {
+			return err
+		}
+	}
+
+	if hadBalance != hasBalance {
+		n := getAddressCount(bkts.meta)
+		if hasBalance {
+			n++
+		} else {
+			n--
+		}
+		if err := putAddressCount(bkts.meta, n); err != nil {
+			return err
+		}
+	}
+
+	if s == nil {
+		return bkts.stats.Delete(addr.Bytes())
+	}
+
+	return bkts.stats.Put(addr.Bytes(), encoder.Serialize(*s))
+}
+
+type addressDelta struct {
+	received uint64
+	sent     uint64
+}
+
+// txnAddressDeltas returns the coins received and sent by each address of the transaction,
+// the spent outputs are read from the outputs bucket
+func txnAddressDeltas(outputsBkt storage.Bucket, txn coin.Transaction) (map[cipher.Address]*addressDelta, error) {
+	deltas := make(map[cipher.Address]*addressDelta)
+	get := func(addr cipher.Address) *addressDelta {
+		d, ok := deltas[addr]
+		if !ok {
+			d = &addressDelta{}
+			deltas[addr] = d
+		}
+		return d
+	}
+
+	for _, in := range txn.In {
+		v := outputsBkt.Get(in[:])
+		if v == nil {
+			return nil, fmt.Errorf("spent output %s does not exist in history db", in.Hex())
+		}
+
+		var out UxOut
+		if err := encoder.DeserializeRaw(v, &out); err != nil {
+			return nil, err
+		}
+
+		get(out.Out.Body.Address).sent += out.Out.Body.Coins
+	}
+
+	for _, o := range txn.Out {
+		get(o.Address).received += o.Coins
+	}
+
+	return deltas, nil
+}
+
+func addressStatsBuckets(tx storage.Tx) (addressStatsBkts, error) {
+	bkts := addressStatsBkts{
+		stats:    tx.Bucket(addressStatsBktName),
+		balances: tx.Bucket(addressBalanceIndexBktName),
+		meta:     tx.Bucket(addressStatsMetaBktName),
+		outputs:  tx.Bucket(historyOutputsBktName),
+	}
+	if bkts.stats == nil || bkts.balances == nil || bkts.meta == nil || bkts.outputs == nil {
+		return addressStatsBkts{}, errors.New("history db buckets do not exist")
+	}
+	return bkts, nil
+}
+
+// updateAddressStatsWithTx applies the transactions of a parsed block to the address statistics.
+// Must be called by ParseBlock once the block's outputs are stored.
+func updateAddressStatsWithTx(tx storage.Tx, b *coin.Block) error {
+	bkts, err := addressStatsBuckets(tx)
+	if err != nil {
+		return err
+	}
+
+	for _, txn := range b.Body.Transactions {
+		deltas, err := txnAddressDeltas(bkts.outputs, txn)
+		if err != nil {
+			return err
+		}
+
+		for addr, d := range deltas {
+			old, err := getAddressStats(bkts.stats, addr)
+			if err != nil {
+				return err
+			}
+
+			s := AddressStats{
+				FirstSeq: b.Seq(),
+			}
+			if old != nil {
+				s = *old
+			}
+
+			if s.Balance+d.received < d.sent {
+				return fmt.Errorf("address %s spends more than its balance in block %d", addr.String(), b.Seq())
+			}
+
+			s.Balance = s.Balance + d.received - d.sent
+			s.Received += d.received
+			s.Sent += d.sent
+			s.TxnCount++
+			s.LastSeq = b.Seq()
+
+			if err := putAddressStats(bkts, addr, old, &s); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// revertAddressStatsWithTx undoes updateAddressStatsWithTx for the last parsed block,
+// must be called before the block's outputs are removed from the outputs bucket
+func revertAddressStatsWithTx(tx storage.Tx, b *coin.Block) error {
+	bkts, err := addressStatsBuckets(tx)
+	if err != nil {
+		return err
+	}
+
+	txnIndexBkt := tx.Bucket(addressTxnIndexBktName)
+	if txnIndexBkt == nil {
+		return errors.New("history db buckets do not exist")
+	}
+
+	touched := make(map[cipher.Address]struct{})
+	txns := b.Body.Transactions
+	for i := len(txns) - 1; i >= 0; i-- {
+		deltas, err := txnAddressDeltas(bkts.outputs, txns[i])
+		if err != nil {
+			return err
+		}
+
+		for addr, d := range deltas {
+			old, err := getAddressStats(bkts.stats, addr)
+			if err != nil {
+				return err
+			}
+
+			if old == nil || old.TxnCount == 0 || old.Balance+d.sent < d.received {
+				return fmt.Errorf("address stats of %s do not match block %d", addr.String(), b.Seq())
+			}
+
+			s := *old
+			s.Balance = s.Balance + d.sent - d.received
+			s.Received -= d.received
+			s.Sent -= d.sent
+			s.TxnCount--
+
+			if s.TxnCount == 0 {
+				if err := putAddressStats(bkts, addr, old, nil); err != nil {
+					return err
+				}
+				delete(touched, addr)
+				continue
+			}
+
+			if err := putAddressStats(bkts, addr, old, &s); err != nil {
+				return err
+			}
+			touched[addr] = struct{}{}
+		}
+	}
+
+	// The last block of the addresses which still have transactions is before this block
+	for addr := range touched {
+		s, err := getAddressStats(bkts.stats, addr)
+		if err != nil {
+			return err
+		}
+
+		seq, ok := lastSeqBefore(txnIndexBkt, addr, b.Seq())
+		if !ok {
+			return fmt.Errorf("address %s has no transaction before block %d", addr.String(), b.Seq())
+		}
+
+		s.LastSeq = seq
+		if err := bkts.stats.Put(addr.Bytes(), encoder.Serialize(*s)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// lastSeqBefore returns the seq of the last block before seq with a transaction of the address
+func lastSeqBefore(txnIndexBkt storage.Bucket, addr cipher.Address, seq uint64) (uint64, bool) {
+	prefix := addr.Bytes()
+	c := txnIndexBkt.Cursor()
+
+	k, _ := c.Seek(addressIndexKey(addr, Cursor{Seq: seq}))
+	if k == nil {
+		k, _ = c.Last()
+	} else {
+		k, _ = c.Prev()
+	}
+
+	if k == nil || !bytes.HasPrefix(k, prefix) {
+		return 0, false
+	}
+
+	ic, err := cursorFromBytes(k[len(prefix):])
+	if err != nil {
+		return 0, false
+	}
+
+	return ic.Seq, true
+}
+
+// GetAddressStats returns the statistics of the address, nil if it has no transaction
+func (hd *HistoryDB) GetAddressStats(addr cipher.Address) (*AddressStats, error) {
+	return hd.addrStats.get(addr)
+}
+
+// GetRichlist returns up to limit addresses with the highest balances, richest first,
+// skipping the excluded addresses
+func (hd *HistoryDB) GetRichlist(limit int, exclude []cipher.Address) ([]AddressBalance, error) {
+	ex := make(map[cipher.Address]struct{}, len(exclude))
+	for _, a := range exclude {
+		ex[a] = struct{}{}
+	}
+
+	return hd.addrStats.richlist(limit, ex)
+}
+
+// AddressCount returns the number of addresses with a non zero balance
+func (hd *HistoryDB) AddressCount() (uint64, error) {
+	return hd.addrStats.count()
+}
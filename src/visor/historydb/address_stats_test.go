@@ -0,0 +1,213 @@
+package historydb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/encoder"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/testutil"
+	"github.com/skycoin/skycoin/src/visor/storage"
+)
+
+func TestAddressStats(t *testing.T) {
+	testutil.ForEachStore(t, testAddressStats)
+}
+
+func testAddressStats(t *testing.T, db storage.Store) {
+	stats, err := newAddressStats(db)
+	require.NoError(t, err)
+	_, err = newAddressIndex(db, addressTxnIndexBktName)
+	require.NoError(t, err)
+	_, err = newAddressIndex(db, addressUxIndexBktName)
+	require.NoError(t, err)
+	createBuckets(t, db, historyOutputsBktName)
+
+	addr := makeAddress()
+	other := makeAddress()
+	third := makeAddress()
+
+	// Block 1 creates the first outputs
+	var txn1 coin.Transaction
+	txn1.PushOutput(addr, 10e6, 1)
+	txn1.PushOutput(other, 5e6, 1)
+	txn1.UpdateHeader()
+
+	b1 := coin.Block{
+		Head: coin.BlockHeader{BkSeq: 1},
+		Body: coin.BlockBody{Transactions: coin.Transactions{txn1}},
+	}
+	uxs1 := coin.CreateUnspents(b1.Head, txn1)
+
+	// Block 2 spends the output of addr to other, with change back to addr
+	var txn2 coin.Transaction
+	txn2.PushInput(uxs1[0].Hash())
+	txn2.PushOutput(other, 4e6, 1)
+	txn2.PushOutput(addr, 6e6, 1)
+	txn2.UpdateHeader()
+
+	b2 := coin.Block{
+		Head: coin.BlockHeader{BkSeq: 2},
+		Body: coin.BlockBody{Transactions: coin.Transactions{txn2}},
+	}
+	uxs2 := coin.CreateUnspents(b2.Head, txn2)
+
+	// Block 3 spends all the outputs of other to third
+	var txn3 coin.Transaction
+	txn3.PushInput(uxs1[1].Hash())
+	txn3.PushInput(uxs2[0].Hash())
+	txn3.PushOutput(third, 9e6, 1)
+	txn3.UpdateHeader()
+
+	b3 := coin.Block{
+		Head: coin.BlockHeader{BkSeq: 3},
+		Body: coin.BlockBody{Transactions: coin.Transactions{txn3}},
+	}
+
+	parse := func(b *coin.Block) {
+		err := db.Update(func(tx storage.Tx) error {
+			bkt := tx.Bucket(historyOutputsBktName)
+			for _, txn := range b.Body.Transactions {
+				for _, ux := range coin.CreateUnspents(b.Head, txn) {
+					h := ux.Hash()
+					if err := bkt.Put(h[:], encoder.Serialize(UxOut{Out: ux})); err != nil {
+						return err
+					}
+				}
+			}
+
+			if err := indexBlockWithTx(tx, b); err != nil {
+				return err
+			}
+			return updateAddressStatsWithTx(tx, b)
+		})
+		require.NoError(t, err)
+	}
+
+	rollback := func(b *coin.Block) {
+		err := db.Update(func(tx storage.Tx) error {
+			if err := revertAddressStatsWithTx(tx, b); err != nil {
+				return err
+			}
+			return unindexBlockWithTx(tx, b)
+		})
+		require.NoError(t, err)
+	}
+
+	requireStats := func(addr cipher.Address, expected *AddressStats) {
+		s, err := stats.get(addr)
+		require.NoError(t, err)
+		require.Equal(t, expected, s)
+	}
+
+	requireRichlist := func(exclude map[cipher.Address]struct{}, expected []AddressBalance) {
+		balances, err := stats.richlist(10, exclude)
+		require.NoError(t, err)
+		require.Equal(t, expected, balances)
+	}
+
+	requireCount := func(expected uint64) {
+		n, err := stats.count()
+		require.NoError(t, err)
+		require.Equal(t, expected, n)
+	}
+
+	parse(&b1)
+	parse(&b2)
+
+	requireStats(addr, &AddressStats{
+		Balance:  6e6,
+		Received: 16e6,
+		Sent:     10e6,
+		TxnCount: 2,
+		FirstSeq: 1,
+		LastSeq:  2,
+	})
+	requireStats(other, &AddressStats{
+		Balance:  9e6,
+		Received: 9e6,
+		TxnCount: 2,
+		FirstSeq: 1,
+		LastSeq:  2,
+	})
+	requireStats(third, nil)
+	requireCount(2)
+
+	// The counter of a db indexed before it existed is computed from the balance index
+	err = db.Update(func(tx storage.Tx) error {
+		return tx.Bucket(addressStatsMetaBktName).Delete(addressCountKey)
+	})
+	require.NoError(t, err)
+	stats, err = newAddressStats(db)
+	require.NoError(t, err)
+	requireCount(2)
+
+	requireRichlist(nil, []AddressBalance{
+		{Address: other, Balance: 9e6},
+		{Address: addr, Balance: 6e6},
+	})
+	requireRichlist(map[cipher.Address]struct{}{other: {}}, []AddressBalance{
+		{Address: addr, Balance: 6e6},
+	})
+
+	balances, err := stats.richlist(1, nil)
+	require.NoError(t, err)
+	require.Equal(t, []AddressBalance{{Address: other, Balance: 9e6}}, balances)
+
+	_, err = stats.richlist(0, nil)
+	require.Error(t, err)
+
+	// other has no balance left after block 3 and leaves the rich list
+	parse(&b3)
+
+	requireStats(other, &AddressStats{
+		Received: 9e6,
+		Sent:     9e6,
+		TxnCount: 3,
+		FirstSeq: 1,
+		LastSeq:  3,
+	})
+	requireCount(2)
+	requireRichlist(nil, []AddressBalance{
+		{Address: third, Balance: 9e6},
+		{Address: addr, Balance: 6e6},
+	})
+
+	// Rolling back restores the statistics of the previous blocks
+	rollback(&b3)
+
+	requireStats(third, nil)
+	requireCount(2)
+	requireStats(other, &AddressStats{
+		Balance:  9e6,
+		Received: 9e6,
+		TxnCount: 2,
+		FirstSeq: 1,
+		LastSeq:  2,
+	})
+	requireRichlist(nil, []AddressBalance{
+		{Address: other, Balance: 9e6},
+		{Address: addr, Balance: 6e6},
+	})
+
+	rollback(&b2)
+
+	requireStats(addr, &AddressStats{
+		Balance:  10e6,
+		Received: 10e6,
+		TxnCount: 1,
+		FirstSeq: 1,
+		LastSeq:  1,
+	})
+	requireRichlist(nil, []AddressBalance{
+		{Address: addr, Balance: 10e6},
+		{Address: other, Balance: 5e6},
+	})
+	requireCount(2)
+
+	require.NoError(t, stats.Reset())
+	requireCount(0)
+	requireStats(addr, nil)
+}
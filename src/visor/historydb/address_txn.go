@@ -6,6 +6,7 @@ import (
 	"github.com/skycoin/skycoin/src/cipher"
 	"github.com/skycoin/skycoin/src/cipher/encoder"
 	"github.com/skycoin/skycoin/src/visor/bucket"
+	"github.com/skycoin/skycoin/src/visor/storage"
 )
 
 var addressTxnsBktName = []byte("address_txns")
@@ -13,11 +14,15 @@ var addressTxnsBktName = []byte("address_txns")
 // addressTxn buckets for storing address related transactions
 // address as key, transaction id slice as value
 type addressTxns struct {
-	bkt *bucket.Bucket
+	bkt *bucket.StoreBucket
 }
 
 func newAddressTxnsBkt(db *bolt.DB) (*addressTxns, error) {
-	bkt, err := bucket.New(addressTxnsBktName, db)
+	return newAddressTxnsStore(storage.NewBolt(db))
+}
+
+func newAddressTxnsStore(db storage.Store) (*addressTxns, error) {
+	bkt, err := bucket.NewStoreBucket(addressTxnsBktName, db)
 	if err != nil {
 		return nil, err
 	}
@@ -51,6 +56,11 @@ func (atx *addressTxns) Reset() error {
 }
 
 func setAddressTxns(bkt *bolt.Bucket, addr cipher.Address, hash cipher.SHA256) error {
+	return addAddressHash(storage.BoltBucket(bkt), addr, hash)
+}
+
+// addAddressHash appends hash to the hash list of addr, unless the list already has it
+func addAddressHash(bkt storage.Bucket, addr cipher.Address, hash cipher.SHA256) error {
 	// get hashes
 	addrBytes := addr.Bytes()
 	v := bkt.Get(addrBytes)
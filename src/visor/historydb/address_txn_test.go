@@ -10,20 +10,20 @@ import (
 	"github.com/skycoin/skycoin/src/cipher"
 	"github.com/skycoin/skycoin/src/cipher/encoder"
 	"github.com/skycoin/skycoin/src/testutil"
+	"github.com/skycoin/skycoin/src/visor/storage"
 )
 
 func TestNewAddressTxns(t *testing.T) {
-	db, td := testutil.PrepareDB(t)
-	defer td()
+	testutil.ForEachStore(t, func(t *testing.T, s storage.Store) {
+		_, err := newAddressTxnsStore(s)
+		require.Nil(t, err)
 
-	_, err := newAddressTxnsBkt(db)
-	require.Nil(t, err)
-
-	// the address_txns bucket must be exist
-	db.View(func(tx *bolt.Tx) error {
-		bkt := tx.Bucket([]byte("address_txns"))
-		require.NotNil(t, bkt)
-		return nil
+		// the address_txns bucket must be exist
+		s.View(func(tx storage.Tx) error {
+			bkt := tx.Bucket([]byte("address_txns"))
+			require.NotNil(t, bkt)
+			return nil
+		})
 	})
 }
 
@@ -243,30 +243,28 @@ func TestGetAddressTxns(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			db, td := testutil.PrepareDB(t)
-			defer td()
-
-			addrTxnsBkt, err := newAddressTxnsBkt(db)
-			require.Nil(t, err)
+			testutil.ForEachStore(t, func(t *testing.T, s storage.Store) {
+				addrTxnsBkt, err := newAddressTxnsStore(s)
+				require.Nil(t, err)
 
-			require.Nil(t, db.Update(func(tx *bolt.Tx) error {
-				bkt := tx.Bucket(addressTxnsBktName)
+				require.Nil(t, s.Update(func(tx storage.Tx) error {
+					bkt := tx.Bucket(addressTxnsBktName)
 
-				for _, pr := range tc.addPairs {
-					if err := setAddressTxns(bkt, pr.addr, pr.txHash); err != nil {
-						return err
+					for _, pr := range tc.addPairs {
+						if err := addAddressHash(bkt, pr.addr, pr.txHash); err != nil {
+							return err
+						}
 					}
-				}
 
-				return nil
-			}))
-
-			for _, e := range tc.expect {
-				hashes, err := addrTxnsBkt.Get(e.addr)
-				require.Nil(t, err)
-				require.Equal(t, e.txs, hashes)
-			}
+					return nil
+				}))
 
+				for _, e := range tc.expect {
+					hashes, err := addrTxnsBkt.Get(e.addr)
+					require.Nil(t, err)
+					require.Equal(t, e.txs, hashes)
+				}
+			})
 		})
 	}
 }
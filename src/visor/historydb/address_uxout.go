@@ -6,16 +6,23 @@ import (
 	"github.com/skycoin/skycoin/src/cipher"
 	"github.com/skycoin/skycoin/src/cipher/encoder"
 	"github.com/skycoin/skycoin/src/visor/bucket"
+	"github.com/skycoin/skycoin/src/visor/storage"
 )
 
+var addressUxBktName = []byte("address_in")
+
 // bucket for storing address with UxOut, key as address, value as UxOut.
 type addressUx struct {
-	bkt *bucket.Bucket
+	bkt *bucket.StoreBucket
 }
 
 // create address affected UxOuts bucket.
 func newAddressUxBkt(db *bolt.DB) (*addressUx, error) {
-	bkt, err := bucket.New([]byte("address_in"), db)
+	return newAddressUxStore(storage.NewBolt(db))
+}
+
+func newAddressUxStore(db storage.Store) (*addressUx, error) {
+	bkt, err := bucket.NewStoreBucket(addressUxBktName, db)
 	if err != nil {
 		return nil, err
 	}
@@ -70,23 +77,5 @@ func (au *addressUx) Reset() error {
 }
 
 func setAddressUx(bkt *bolt.Bucket, addr cipher.Address, uxHash cipher.SHA256) error {
-	bin := bkt.Get(addr.Bytes())
-	if bin == nil {
-		return bkt.Put(addr.Bytes(), encoder.Serialize([]cipher.SHA256{uxHash}))
-	}
-
-	uxHashes := []cipher.SHA256{}
-	if err := encoder.DeserializeRaw(bin, &uxHashes); err != nil {
-		return err
-	}
-
-	// check dup
-	for _, u := range uxHashes {
-		if u == uxHash {
-			return nil
-		}
-	}
-
-	uxHashes = append(uxHashes, uxHash)
-	return bkt.Put(addr.Bytes(), encoder.Serialize(uxHashes))
+	return addAddressHash(storage.BoltBucket(bkt), addr, uxHash)
 }
@@ -6,6 +6,7 @@ import (
 	"github.com/boltdb/bolt"
 
 	"github.com/skycoin/skycoin/src/visor/bucket"
+	"github.com/skycoin/skycoin/src/visor/storage"
 )
 
 var (
@@ -15,11 +16,15 @@ var (
 
 // historyMeta bucket for storing block history meta info
 type historyMeta struct {
-	v *bucket.Bucket
+	v *bucket.StoreBucket
 }
 
 func newHistoryMeta(db *bolt.DB) (*historyMeta, error) {
-	bkt, err := bucket.New(historyMetaBkt, db)
+	return newHistoryMetaStore(storage.NewBolt(db))
+}
+
+func newHistoryMetaStore(db storage.Store) (*historyMeta, error) {
+	bkt, err := bucket.NewStoreBucket(historyMetaBkt, db)
 	if err != nil {
 		return nil, err
 	}
@@ -41,6 +46,10 @@ func (hm *historyMeta) SetParsedHeight(h uint64) error {
 
 // SetParsedHeightWithTx updates history parsed height with *bolt.Tx
 func (hm *historyMeta) SetParsedHeightWithTx(tx *bolt.Tx, h uint64) error {
+	return hm.setParsedHeightWithTx(storage.BoltTx(tx), h)
+}
+
+func (hm *historyMeta) setParsedHeightWithTx(tx storage.Tx, h uint64) error {
 	bkt := tx.Bucket(historyMetaBkt)
 	if bkt == nil {
 		return fmt.Errorf("set parsed height failed, bucket: %s does not exist", string(historyMetaBkt))
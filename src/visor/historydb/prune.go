@@ -0,0 +1,45 @@
+package historydb
+
+import (
+	"errors"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/visor/bucket"
+	"github.com/skycoin/skycoin/src/visor/storage"
+)
+
+// PruneBlockWithTx removes the history of a block whose body is pruned: its transactions and
+// the outputs they spent. The chain can't be rolled back below a pruned block, so the spent
+// outputs are not needed anymore. The address indexes are kept.
+// Returns false if the block is not parsed yet, since its body is needed to parse it.
+func (hd *HistoryDB) PruneBlockWithTx(tx *bolt.Tx, b *coin.Block) (bool, error) {
+	stx := storage.BoltTx(tx)
+	metaBkt := stx.Bucket(historyMetaBkt)
+	txnsBkt := stx.Bucket(historyTxnsBktName)
+	outputsBkt := stx.Bucket(historyOutputsBktName)
+	if metaBkt == nil || txnsBkt == nil || outputsBkt == nil {
+		return false, errors.New("history db buckets do not exist")
+	}
+
+	v := metaBkt.Get(parsedHeightKey)
+	if v == nil || bucket.Btoi(v) < b.Seq() {
+		return false, nil
+	}
+
+	for _, txn := range b.Body.Transactions {
+		for _, in := range txn.In {
+			if err := outputsBkt.Delete(in[:]); err != nil {
+				return false, err
+			}
+		}
+
+		txHash := txn.Hash()
+		if err := txnsBkt.Delete(txHash[:]); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
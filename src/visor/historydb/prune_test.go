@@ -0,0 +1,55 @@
+package historydb
+
+import (
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/testutil"
+)
+
+func TestPruneBlockWithTx(t *testing.T) {
+	db, teardown := testutil.PrepareDB(t)
+	defer teardown()
+
+	bc := newBlockchain(db)
+	gb := bc.CreateGenesisBlock(genAddress, _genCoins, _genTime)
+
+	hisDB, err := New(db)
+	require.NoError(t, err)
+
+	prune := func() bool {
+		var ok bool
+		err := db.Update(func(tx *bolt.Tx) error {
+			var err error
+			ok, err = hisDB.PruneBlockWithTx(tx, &gb)
+			return err
+		})
+		require.NoError(t, err)
+		return ok
+	}
+
+	// The block is not parsed yet
+	require.False(t, prune())
+
+	require.NoError(t, hisDB.ParseBlock(&gb))
+
+	txn := gb.Body.Transactions[0]
+	htxn, err := hisDB.txns.Get(txn.Hash())
+	require.NoError(t, err)
+	require.NotNil(t, htxn)
+
+	require.True(t, prune())
+
+	htxn, err = hisDB.txns.Get(txn.Hash())
+	require.NoError(t, err)
+	require.Nil(t, htxn)
+
+	// The unspent outputs created by the block are kept
+	ux := coin.CreateUnspents(gb.Head, txn)[0]
+	out, err := hisDB.GetUxout(ux.Hash())
+	require.NoError(t, err)
+	require.NotNil(t, out)
+}
@@ -0,0 +1,35 @@
+package historydb
+
+// Reset removes all parsed history, including the parsed height, so that the
+// blockchain has to be parsed again from the genesis block.
+func (hd *HistoryDB) Reset() error {
+	if err := hd.addrTxns.Reset(); err != nil {
+		return err
+	}
+
+	if err := hd.addrUx.Reset(); err != nil {
+		return err
+	}
+
+	if err := hd.addrTxnIndex.Reset(); err != nil {
+		return err
+	}
+
+	if err := hd.addrUxIndex.Reset(); err != nil {
+		return err
+	}
+
+	if err := hd.addrStats.Reset(); err != nil {
+		return err
+	}
+
+	if err := hd.outputs.Reset(); err != nil {
+		return err
+	}
+
+	if err := hd.txns.Reset(); err != nil {
+		return err
+	}
+
+	return hd.historyMeta.Reset()
+}
@@ -0,0 +1,135 @@
+package historydb
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/encoder"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/visor/storage"
+)
+
+// RollbackBlockWithTx undoes ParseBlock for the last parsed block: the transactions and the
+// outputs created by the block are removed, the outputs spent by the block are marked as
+// unspent again, the address indexes and statistics are reverted and the parsed height is moved back by one.
+// Returns the outputs that were spent by the block.
+func (hd *HistoryDB) RollbackBlockWithTx(tx *bolt.Tx, b *coin.Block) (coin.UxArray, error) {
+	if b.Seq() == 0 {
+		return nil, errors.New("can't roll back the genesis block")
+	}
+
+	if h := hd.ParsedHeight(); h != int64(b.Seq()) {
+		return nil, fmt.Errorf("block %d is not the last parsed block %d", b.Seq(), h)
+	}
+
+	stx := storage.BoltTx(tx)
+	outputsBkt := stx.Bucket(hd.outputs.bkt.Name)
+	txnsBkt := stx.Bucket(hd.txns.bkt.Name)
+	addrUxBkt := stx.Bucket(addressUxBktName)
+	addrTxnsBkt := stx.Bucket(addressTxnsBktName)
+	if outputsBkt == nil || txnsBkt == nil || addrUxBkt == nil || addrTxnsBkt == nil {
+		return nil, errors.New("history db buckets do not exist")
+	}
+
+	if err := revertAddressStatsWithTx(stx, b); err != nil {
+		return nil, err
+	}
+
+	if err := unindexBlockWithTx(stx, b); err != nil {
+		return nil, err
+	}
+
+	var spent coin.UxArray
+	txns := b.Body.Transactions
+	for i := len(txns) - 1; i >= 0; i-- {
+		txn := txns[i]
+		txHash := txn.Hash()
+
+		for _, ux := range coin.CreateUnspents(b.Head, txn) {
+			uxHash := ux.Hash()
+			if err := outputsBkt.Delete(uxHash[:]); err != nil {
+				return nil, err
+			}
+
+			if err := removeAddressUx(addrUxBkt, ux.Body.Address, uxHash); err != nil {
+				return nil, err
+			}
+
+			if err := removeAddressTxn(addrTxnsBkt, ux.Body.Address, txHash); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, in := range txn.In {
+			v := outputsBkt.Get(in[:])
+			if v == nil {
+				return nil, fmt.Errorf("spent output %s does not exist in history db", in.Hex())
+			}
+
+			var out UxOut
+			if err := encoder.DeserializeRaw(v, &out); err != nil {
+				return nil, err
+			}
+
+			out.SpentTxID = cipher.SHA256{}
+			out.SpentBlockSeq = 0
+			if err := outputsBkt.Put(in[:], encoder.Serialize(out)); err != nil {
+				return nil, err
+			}
+
+			if err := removeAddressTxn(addrTxnsBkt, out.Out.Body.Address, txHash); err != nil {
+				return nil, err
+			}
+
+			spent = append(spent, out.Out)
+		}
+
+		if err := txnsBkt.Delete(txHash[:]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := hd.SetParsedHeightWithTx(tx, b.Seq()-1); err != nil {
+		return nil, err
+	}
+
+	return spent, nil
+}
+
+func removeAddressTxn(bkt storage.Bucket, addr cipher.Address, hash cipher.SHA256) error {
+	return removeAddressHash(bkt, addr, hash)
+}
+
+func removeAddressUx(bkt storage.Bucket, addr cipher.Address, uxHash cipher.SHA256) error {
+	return removeAddressHash(bkt, addr, uxHash)
+}
+
+// removeAddressHash removes hash from the hash list of addr, the key is deleted if the list becomes empty
+func removeAddressHash(bkt storage.Bucket, addr cipher.Address, hash cipher.SHA256) error {
+	addrBytes := addr.Bytes()
+	v := bkt.Get(addrBytes)
+	if v == nil {
+		return nil
+	}
+
+	var hashes []cipher.SHA256
+	if err := encoder.DeserializeRaw(v, &hashes); err != nil {
+		return err
+	}
+
+	kept := hashes[:0]
+	for _, h := range hashes {
+		if h != hash {
+			kept = append(kept, h)
+		}
+	}
+
+	if len(kept) == 0 {
+		return bkt.Delete(addrBytes)
+	}
+
+	return bkt.Put(addrBytes, encoder.Serialize(kept))
+}
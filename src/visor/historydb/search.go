@@ -0,0 +1,61 @@
+package historydb
+
+import (
+	"fmt"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/visor/bucket"
+	"github.com/skycoin/skycoin/src/visor/storage"
+)
+
+// SearchTxns returns up to limit transactions whose hash starts with hexPrefix, in hash order
+func (hd *HistoryDB) SearchTxns(hexPrefix string, limit int) ([]Transaction, error) {
+	keys, err := bucket.HexPrefixKeys(storage.NewBolt(hd.db), hd.txns.bkt.Name, hexPrefix, limit, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	txns := make([]Transaction, 0, len(keys))
+	for _, k := range keys {
+		var h cipher.SHA256
+		copy(h[:], k)
+
+		txn, err := hd.txns.Get(h)
+		if err != nil {
+			return nil, err
+		}
+		if txn == nil {
+			return nil, fmt.Errorf("transaction %s does not exist", h.Hex())
+		}
+
+		txns = append(txns, *txn)
+	}
+
+	return txns, nil
+}
+
+// SearchUxOuts returns up to limit outputs whose hash starts with hexPrefix, in hash order
+func (hd *HistoryDB) SearchUxOuts(hexPrefix string, limit int) ([]*UxOut, error) {
+	keys, err := bucket.HexPrefixKeys(storage.NewBolt(hd.db), hd.outputs.bkt.Name, hexPrefix, limit, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	uxs := make([]*UxOut, 0, len(keys))
+	for _, k := range keys {
+		var h cipher.SHA256
+		copy(h[:], k)
+
+		ux, err := hd.outputs.Get(h)
+		if err != nil {
+			return nil, err
+		}
+		if ux == nil {
+			return nil, fmt.Errorf("output %s does not exist", h.Hex())
+		}
+
+		uxs = append(uxs, ux)
+	}
+
+	return uxs, nil
+}
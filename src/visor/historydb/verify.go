@@ -0,0 +1,106 @@
+package historydb
+
+import (
+	"fmt"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+)
+
+// VerifyBlock cross-checks the history of a parsed block against the block itself:
+// every transaction must be stored, every spent output must be marked as spent by the
+// spending transaction, and the address indexes must reference the transactions and
+// the created outputs. Returns a description for each inconsistency found.
+func (hd *HistoryDB) VerifyBlock(b *coin.Block) ([]string, error) {
+	var issues []string
+	addIssue := func(format string, args ...interface{}) {
+		issues = append(issues, fmt.Sprintf(format, args...))
+	}
+
+	for _, txn := range b.Body.Transactions {
+		txHash := txn.Hash()
+
+		tx, err := hd.txns.Get(txHash)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case tx == nil:
+			addIssue("transaction %s is not stored", txHash.Hex())
+		case tx.BlockSeq != b.Seq():
+			addIssue("transaction %s is stored with block seq %d", txHash.Hex(), tx.BlockSeq)
+		}
+
+		for _, in := range txn.In {
+			ux, err := hd.outputs.Get(in)
+			if err != nil {
+				return nil, err
+			}
+
+			if ux == nil {
+				addIssue("spent output %s of transaction %s is not stored", in.Hex(), txHash.Hex())
+				continue
+			}
+
+			if ux.SpentTxID != txHash || ux.SpentBlockSeq != b.Seq() {
+				addIssue("output %s is not marked as spent by transaction %s", in.Hex(), txHash.Hex())
+			}
+
+			if err := hd.verifyAddrTxn(ux.Out.Body.Address, txHash, addIssue); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, ux := range coin.CreateUnspents(b.Head, txn) {
+			uxHash := ux.Hash()
+
+			out, err := hd.outputs.Get(uxHash)
+			if err != nil {
+				return nil, err
+			}
+
+			if out == nil {
+				addIssue("output %s of transaction %s is not stored", uxHash.Hex(), txHash.Hex())
+			}
+
+			addr := ux.Body.Address
+			if err := hd.verifyAddrTxn(addr, txHash, addIssue); err != nil {
+				return nil, err
+			}
+
+			uxHashes, err := hd.addrUx.Get(addr)
+			if err != nil {
+				return nil, err
+			}
+
+			if !containsHash(uxHashes, uxHash) {
+				addIssue("address_in index of %s misses output %s", addr.String(), uxHash.Hex())
+			}
+		}
+	}
+
+	return issues, nil
+}
+
+func (hd *HistoryDB) verifyAddrTxn(addr cipher.Address, txHash cipher.SHA256, addIssue func(string, ...interface{})) error {
+	txHashes, err := hd.addrTxns.Get(addr)
+	if err != nil {
+		return err
+	}
+
+	if !containsHash(txHashes, txHash) {
+		addIssue("address_txns index of %s misses transaction %s", addr.String(), txHash.Hex())
+	}
+
+	return nil
+}
+
+func containsHash(hashes []cipher.SHA256, h cipher.SHA256) bool {
+	for _, v := range hashes {
+		if v == h {
+			return true
+		}
+	}
+	return false
+}
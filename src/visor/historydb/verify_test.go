@@ -0,0 +1,39 @@
+package historydb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/testutil"
+)
+
+func TestVerifyBlock(t *testing.T) {
+	db, teardown := testutil.PrepareDB(t)
+	defer teardown()
+
+	bc := newBlockchain(db)
+	gb := bc.CreateGenesisBlock(genAddress, _genCoins, _genTime)
+
+	hisDB, err := New(db)
+	require.NoError(t, err)
+
+	// Nothing is parsed yet
+	issues, err := hisDB.VerifyBlock(&gb)
+	require.NoError(t, err)
+	require.Len(t, issues, 4)
+
+	require.NoError(t, hisDB.ParseBlock(&gb))
+
+	issues, err = hisDB.VerifyBlock(&gb)
+	require.NoError(t, err)
+	require.Empty(t, issues)
+
+	// Drop the address transactions index
+	require.NoError(t, hisDB.addrTxns.Reset())
+
+	issues, err = hisDB.VerifyBlock(&gb)
+	require.NoError(t, err)
+	require.Len(t, issues, 1)
+	require.Contains(t, issues[0], "address_txns index of "+genAddress.String())
+}
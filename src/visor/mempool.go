@@ -0,0 +1,726 @@
+package visor
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/util/fee"
+	"github.com/skycoin/skycoin/src/util/utc"
+)
+
+var (
+	// ErrTxnFeeTooLow is returned when the unconfirmed pool is full and the transaction
+	// doesn't pay a higher fee per byte than the transactions it would evict
+	ErrTxnFeeTooLow = errors.New("Transaction fee per byte is too low to enter the full unconfirmed pool")
+	// ErrTxnTooLarge is returned when the transaction is larger than the unconfirmed pool
+	ErrTxnTooLarge = errors.New("Transaction is larger than the unconfirmed pool")
+	// ErrTxnChainTooDeep is returned when the transaction spends an output of an unconfirmed
+	// transaction which already has MaxChainDepth unconfirmed ancestors
+	ErrTxnChainTooDeep = errors.New("Transaction spends outputs of too many chained unconfirmed transactions")
+	// ErrTxnPoolFull is returned when the unconfirmed pool is full and evicting all the
+	// transactions which aren't ancestors of the transaction doesn't make room for it
+	ErrTxnPoolFull = errors.New("The unconfirmed pool is full of the transaction's unconfirmed ancestors")
+)
+
+// ErrTxnDoubleSpend is returned when a transaction spends an output which is already
+// spent by another transaction of the unconfirmed pool
+type ErrTxnDoubleSpend struct {
+	Input    cipher.SHA256
+	SpentBy  cipher.SHA256
+	Rejected cipher.SHA256
+}
+
+func (e ErrTxnDoubleSpend) Error() string {
+	return fmt.Sprintf("Transaction %s double spends output %s, already spent by unconfirmed transaction %s",
+		e.Rejected.Hex(), e.Input.Hex(), e.SpentBy.Hex())
+}
+
+// UnconfirmedPolicy limits the unconfirmed transaction pool
+type UnconfirmedPolicy struct {
+	// Maximum number of transactions, 0 is unlimited
+	MaxTxns int
+	// Maximum total size of the transactions in bytes, 0 is unlimited
+	MaxBytes int
+	// Transactions older than MaxAge are removed, 0 disables expiry
+	MaxAge time.Duration
+	// Maximum number of unconfirmed ancestors of a transaction spending unconfirmed
+	// outputs, 0 disables spending unconfirmed outputs
+	MaxChainDepth int
+}
+
+// NewUnconfirmedPolicy creates the default unconfirmed pool policy
+func NewUnconfirmedPolicy() UnconfirmedPolicy {
+	return UnconfirmedPolicy{
+		MaxTxns:       10000,
+		MaxBytes:      32 * 1024 * 1024,
+		MaxAge:        72 * time.Hour,
+		MaxChainDepth: 25,
+	}
+}
+
+type mempoolTxn struct {
+	hash     cipher.SHA256
+	inputs   []cipher.SHA256
+	outputs  []cipher.SHA256
+	size     int
+	fee      uint64
+	received time.Time
+}
+
+// lessFeeRate returns true if a pays a lower fee per byte than b
+func (a *mempoolTxn) lessFeeRate(b *mempoolTxn) bool {
+	x := new(big.Int).Mul(new(big.Int).SetUint64(a.fee), big.NewInt(int64(b.size)))
+	y := new(big.Int).Mul(new(big.Int).SetUint64(b.fee), big.NewInt(int64(a.size)))
+	return x.Cmp(y) < 0
+}
+
+// feePerKB returns the fee per kilobyte, math.MaxUint64 if it doesn't fit in a uint64
+func (a *mempoolTxn) feePerKB() uint64 {
+	if a.size <= 0 {
+		return 0
+	}
+
+	if f, ok := mulUint64(a.fee, 1024); ok {
+		return f / uint64(a.size)
+	}
+
+	q := new(big.Int).Mul(new(big.Int).SetUint64(a.fee), big.NewInt(1024))
+	q.Quo(q, big.NewInt(int64(a.size)))
+	if !q.IsUint64() {
+		return math.MaxUint64
+	}
+	return q.Uint64()
+}
+
+// mulUint64 returns a * b, and false if the product overflows
+func mulUint64(a, b uint64) (uint64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+
+	c := a * b
+	if c/b != a {
+		return 0, false
+	}
+	return c, true
+}
+
+// MempoolStats represents the unconfirmed pool statistics
+type MempoolStats struct {
+	Txns     int    `json:"txns"`
+	Bytes    int    `json:"bytes"`
+	MaxTxns  int    `json:"max_txns"`
+	MaxBytes int    `json:"max_bytes"`
+	MaxAge   string `json:"max_age"`
+	// Maximum number of unconfirmed ancestors of a transaction
+	MaxChainDepth int `json:"max_chain_depth"`
+	// Transactions spending the outputs of other unconfirmed transactions
+	ChainedTxns int `json:"chained_txns"`
+	// Lowest fee per byte in the pool, as coin hours per kilobyte
+	MinFeePerKB         uint64 `json:"min_fee_per_kb"`
+	Evicted             uint64 `json:"evicted"`
+	Expired             uint64 `json:"expired"`
+	RejectedDoubleSpend uint64 `json:"rejected_double_spend"`
+	RejectedLowFee      uint64 `json:"rejected_low_fee"`
+	RejectedChainDepth  uint64 `json:"rejected_chain_depth"`
+}
+
+// Mempool indexes the unconfirmed pool by spent outputs, created outputs and fee per byte
+// to enforce an UnconfirmedPolicy. A transaction spending the output of another
+// unconfirmed transaction is its child, children are dropped with their parent.
+type Mempool struct {
+	sync.Mutex
+	policy UnconfirmedPolicy
+	txns   map[cipher.SHA256]*mempoolTxn
+	// spent output hash to the hash of the transaction spending it
+	spends map[cipher.SHA256]cipher.SHA256
+	// created output hash to the hash of the transaction creating it
+	creates map[cipher.SHA256]cipher.SHA256
+	bytes   int
+	stats   MempoolStats
+}
+
+// NewMempool creates an empty Mempool
+func NewMempool(p UnconfirmedPolicy) *Mempool {
+	return &Mempool{
+		policy:  p,
+		txns:    make(map[cipher.SHA256]*mempoolTxn),
+		spends:  make(map[cipher.SHA256]cipher.SHA256),
+		creates: make(map[cipher.SHA256]cipher.SHA256),
+	}
+}
+
+func newMempoolTxn(txn coin.Transaction, fee uint64, received time.Time) *mempoolTxn {
+	return &mempoolTxn{
+		hash:     txn.Hash(),
+		inputs:   txn.In,
+		outputs:  txnOutputHashes(txn),
+		size:     txn.Size(),
+		fee:      fee,
+		received: received,
+	}
+}
+
+// Policy returns the pool policy
+func (m *Mempool) Policy() UnconfirmedPolicy {
+	m.Lock()
+	defer m.Unlock()
+	return m.policy
+}
+
+// parents returns the hashes of the pool transactions whose outputs mt spends
+func (m *Mempool) parents(mt *mempoolTxn) []cipher.SHA256 {
+	var parents []cipher.SHA256
+	for _, in := range mt.inputs {
+		if h, ok := m.creates[in]; ok {
+			parents = append(parents, h)
+		}
+	}
+	return parents
+}
+
+// children returns the hashes of the pool transactions spending the outputs of mt
+func (m *Mempool) children(mt *mempoolTxn) []cipher.SHA256 {
+	var children []cipher.SHA256
+	for _, out := range mt.outputs {
+		if h, ok := m.spends[out]; ok {
+			children = append(children, h)
+		}
+	}
+	return children
+}
+
+// depth returns the length of the longest chain of pool transactions mt descends from
+func (m *Mempool) depth(mt *mempoolTxn, memo map[cipher.SHA256]int) int {
+	if d, ok := memo[mt.hash]; ok {
+		return d
+	}
+
+	d := 0
+	for _, h := range m.parents(mt) {
+		if p, ok := m.txns[h]; ok {
+			if pd := m.depth(p, memo) + 1; pd > d {
+				d = pd
+			}
+		}
+	}
+
+	memo[mt.hash] = d
+	return d
+}
+
+// ancestors returns the set of pool transactions mt descends from
+func (m *Mempool) ancestors(mt *mempoolTxn) map[cipher.SHA256]struct{} {
+	set := make(map[cipher.SHA256]struct{})
+	queue := []*mempoolTxn{mt}
+	for len(queue) > 0 {
+		t := queue[0]
+		queue = queue[1:]
+		for _, h := range m.parents(t) {
+			if _, ok := set[h]; ok {
+				continue
+			}
+			if p, ok := m.txns[h]; ok {
+				set[h] = struct{}{}
+				queue = append(queue, p)
+			}
+		}
+	}
+	return set
+}
+
+// descendants returns the hashes of the pool transactions descending from h, parents first
+func (m *Mempool) descendants(h cipher.SHA256) []cipher.SHA256 {
+	var hashes []cipher.SHA256
+	seen := map[cipher.SHA256]struct{}{h: {}}
+	queue := []cipher.SHA256{h}
+	for len(queue) > 0 {
+		mt, ok := m.txns[queue[0]]
+		queue = queue[1:]
+		if !ok {
+			continue
+		}
+
+		for _, c := range m.children(mt) {
+			if _, ok := seen[c]; ok {
+				continue
+			}
+			seen[c] = struct{}{}
+			hashes = append(hashes, c)
+			queue = append(queue, c)
+		}
+	}
+	return hashes
+}
+
+// withDescendants returns the hashes followed by the hashes of their descendants, without duplicates
+func (m *Mempool) withDescendants(hashes []cipher.SHA256) []cipher.SHA256 {
+	all := make([]cipher.SHA256, 0, len(hashes))
+	seen := make(map[cipher.SHA256]struct{}, len(hashes))
+	for _, h := range hashes {
+		for _, d := range append([]cipher.SHA256{h}, m.descendants(h)...) {
+			if _, ok := seen[d]; ok {
+				continue
+			}
+			seen[d] = struct{}{}
+			all = append(all, d)
+		}
+	}
+	return all
+}
+
+// Admit checks the transaction against the policy without changing the pool.
+// Returns the hashes of the transactions which must be evicted to make room for it.
+func (m *Mempool) Admit(txn coin.Transaction, fee uint64) ([]cipher.SHA256, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	mt := newMempoolTxn(txn, fee, time.Time{})
+	if _, ok := m.txns[mt.hash]; ok {
+		return nil, nil
+	}
+
+	for _, in := range mt.inputs {
+		if spentBy, ok := m.spends[in]; ok {
+			m.stats.RejectedDoubleSpend++
+			return nil, ErrTxnDoubleSpend{
+				Input:    in,
+				SpentBy:  spentBy,
+				Rejected: mt.hash,
+			}
+		}
+	}
+
+	if len(m.parents(mt)) > 0 {
+		memo := make(map[cipher.SHA256]int)
+		if m.depth(mt, memo) > m.policy.MaxChainDepth {
+			m.stats.RejectedChainDepth++
+			return nil, ErrTxnChainTooDeep
+		}
+	}
+
+	if m.policy.MaxBytes > 0 && mt.size > m.policy.MaxBytes {
+		return nil, ErrTxnTooLarge
+	}
+
+	if !m.isFull(1, mt.size) {
+		return nil, nil
+	}
+
+	// Evict the transactions with the lowest fee per byte until the new one fits.
+	// The ancestors of the new transaction can't be evicted, the descendants of
+	// an evicted transaction are evicted with it, so a transaction is only evicted
+	// if it and all its descendants pay a lower fee per byte than the new one.
+	ancestors := m.ancestors(mt)
+	candidates := make([]*mempoolTxn, 0, len(m.txns))
+	for h, t := range m.txns {
+		if _, ok := ancestors[h]; !ok {
+			candidates = append(candidates, t)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].lessFeeRate(candidates[j])
+	})
+
+	var evict []cipher.SHA256
+	evicted := make(map[cipher.SHA256]struct{})
+	count, bytes := 0, 0
+	lowFee := false
+	for _, c := range candidates {
+		if !m.isFull(1-count, mt.size-bytes) {
+			break
+		}
+
+		if _, ok := evicted[c.hash]; ok {
+			continue
+		}
+
+		if !c.lessFeeRate(mt) {
+			lowFee = true
+			break
+		}
+
+		group := append([]cipher.SHA256{c.hash}, m.descendants(c.hash)...)
+		if !m.allLessFeeRate(group, mt) {
+			lowFee = true
+			continue
+		}
+
+		for _, h := range group {
+			if _, ok := evicted[h]; ok {
+				continue
+			}
+			evicted[h] = struct{}{}
+			evict = append(evict, h)
+			count++
+			bytes += m.txns[h].size
+		}
+	}
+
+	if m.isFull(1-count, mt.size-bytes) {
+		if lowFee {
+			m.stats.RejectedLowFee++
+			return nil, ErrTxnFeeTooLow
+		}
+
+		// Only ancestors of the new transaction are left
+		return nil, ErrTxnPoolFull
+	}
+
+	return evict, nil
+}
+
+// allLessFeeRate returns true if all the pool transactions of hashes pay a lower fee per byte than mt
+func (m *Mempool) allLessFeeRate(hashes []cipher.SHA256, mt *mempoolTxn) bool {
+	for _, h := range hashes {
+		if t, ok := m.txns[h]; ok && !t.lessFeeRate(mt) {
+			return false
+		}
+	}
+	return true
+}
+
+// isFull returns true if the pool can't take addTxns more transactions of addBytes
+func (m *Mempool) isFull(addTxns, addBytes int) bool {
+	if m.policy.MaxTxns > 0 && len(m.txns)+addTxns > m.policy.MaxTxns {
+		return true
+	}
+
+	return m.policy.MaxBytes > 0 && m.bytes+addBytes > m.policy.MaxBytes
+}
+
+// Add removes the evicted transactions returned by Admit and adds the transaction
+func (m *Mempool) Add(txn coin.Transaction, fee uint64, received time.Time, evicted []cipher.SHA256) {
+	m.Lock()
+	defer m.Unlock()
+
+	for _, h := range evicted {
+		if m.remove(h) {
+			m.stats.Evicted++
+		}
+	}
+
+	m.add(newMempoolTxn(txn, fee, received))
+}
+
+func (m *Mempool) add(mt *mempoolTxn) {
+	if _, ok := m.txns[mt.hash]; ok {
+		return
+	}
+
+	m.txns[mt.hash] = mt
+	for _, in := range mt.inputs {
+		m.spends[in] = mt.hash
+	}
+	for _, out := range mt.outputs {
+		m.creates[out] = mt.hash
+	}
+	m.bytes += mt.size
+}
+
+// Remove removes the transactions from the index, their children are kept.
+// Used for transactions confirmed by a block, their outputs are now unspent outputs.
+func (m *Mempool) Remove(hashes []cipher.SHA256) {
+	m.Lock()
+	defer m.Unlock()
+
+	for _, h := range hashes {
+		m.remove(h)
+	}
+}
+
+// Drop removes the transactions and their descendants from the index, and returns
+// the hashes of all removed transactions
+func (m *Mempool) Drop(hashes []cipher.SHA256) []cipher.SHA256 {
+	m.Lock()
+	defer m.Unlock()
+
+	var dropped []cipher.SHA256
+	for _, h := range m.withDescendants(hashes) {
+		if m.remove(h) {
+			dropped = append(dropped, h)
+		}
+	}
+	return dropped
+}
+
+func (m *Mempool) remove(h cipher.SHA256) bool {
+	mt, ok := m.txns[h]
+	if !ok {
+		return false
+	}
+
+	delete(m.txns, h)
+	for _, in := range mt.inputs {
+		if m.spends[in] == h {
+			delete(m.spends, in)
+		}
+	}
+	for _, out := range mt.outputs {
+		if m.creates[out] == h {
+			delete(m.creates, out)
+		}
+	}
+	m.bytes -= mt.size
+	return true
+}
+
+// Expire removes and returns the transactions received before now - MaxAge,
+// with their descendants
+func (m *Mempool) Expire(now time.Time) []cipher.SHA256 {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.policy.MaxAge == 0 {
+		return nil
+	}
+
+	var expired []cipher.SHA256
+	for h, mt := range m.txns {
+		if now.Sub(mt.received) > m.policy.MaxAge {
+			expired = append(expired, h)
+		}
+	}
+
+	expired = m.withDescendants(expired)
+	for _, h := range expired {
+		m.remove(h)
+	}
+	m.stats.Expired += uint64(len(expired))
+
+	return expired
+}
+
+// Contains returns true if the transaction is indexed
+func (m *Mempool) Contains(h cipher.SHA256) bool {
+	m.Lock()
+	defer m.Unlock()
+	_, ok := m.txns[h]
+	return ok
+}
+
+// Stats returns the pool statistics
+func (m *Mempool) Stats() MempoolStats {
+	m.Lock()
+	defer m.Unlock()
+
+	s := m.stats
+	s.Txns = len(m.txns)
+	s.Bytes = m.bytes
+	s.MaxTxns = m.policy.MaxTxns
+	s.MaxBytes = m.policy.MaxBytes
+	s.MaxAge = m.policy.MaxAge.String()
+	s.MaxChainDepth = m.policy.MaxChainDepth
+
+	for _, mt := range m.txns {
+		if len(m.parents(mt)) > 0 {
+			s.ChainedTxns++
+		}
+	}
+
+	var lowest *mempoolTxn
+	for _, mt := range m.txns {
+		if lowest == nil || mt.lessFeeRate(lowest) {
+			lowest = mt
+		}
+	}
+	if lowest != nil {
+		s.MinFeePerKB = lowest.feePerKB()
+	}
+
+	return s
+}
+
+// InjectTxnWithPolicy adds the transaction to the unconfirmed pool if it passes the
+// mempool policy. Transactions with a lower fee per byte are evicted, with their
+// descendants, if the pool is full. The transaction may spend the outputs of
+// unconfirmed transactions up to the policy's MaxChainDepth.
+func (vs *Visor) InjectTxnWithPolicy(txn coin.Transaction) (bool, error) {
+	if vs.Mempool == nil {
+		return vs.Unconfirmed.InjectTxn(vs.Blockchain, txn)
+	}
+
+	if _, ok := vs.Unconfirmed.Get(txn.Hash()); ok {
+		return true, nil
+	}
+
+	cu, err := vs.unconfirmedUnspents()
+	if err != nil {
+		return false, err
+	}
+
+	chained := cu.spendsCreated(txn)
+	if chained && vs.Mempool.Policy().MaxChainDepth == 0 {
+		return false, ErrTxnChainTooDeep
+	}
+
+	txnFee, err := cu.TransactionFee(&txn)
+	if err != nil {
+		return false, err
+	}
+
+	evicted, err := vs.Mempool.Admit(txn, txnFee)
+	if err != nil {
+		return false, err
+	}
+
+	var known bool
+	if chained {
+		// The unconfirmed pool verifies transactions against the unspent pool only
+		if err := vs.verifyChainedTxn(txn, cu); err != nil {
+			return false, err
+		}
+
+		if err := fee.VerifyTransactionFee(&txn, txnFee); err != nil {
+			return false, err
+		}
+
+		known, err = vs.Unconfirmed.InjectVerifiedTxn(txn)
+	} else {
+		known, err = vs.Unconfirmed.InjectTxn(vs.Blockchain, txn)
+	}
+	if err != nil {
+		return known, err
+	}
+
+	if len(evicted) > 0 {
+		logger.Info("Unconfirmed pool is full, evicting %d transactions", len(evicted))
+		if err := vs.Unconfirmed.RemoveTransactions(evicted); err != nil {
+			return known, err
+		}
+	}
+
+	vs.Mempool.Add(txn, txnFee, utc.Now(), evicted)
+	return known, nil
+}
+
+// ExpireUnconfirmedTxns removes the transactions which stayed in the unconfirmed pool
+// longer than the policy's MaxAge, with their descendants
+func (vs *Visor) ExpireUnconfirmedTxns() ([]cipher.SHA256, error) {
+	if vs.Mempool == nil {
+		return nil, nil
+	}
+
+	expired := vs.Mempool.Expire(utc.Now())
+	if len(expired) == 0 {
+		return nil, nil
+	}
+
+	logger.Info("Removing %d expired unconfirmed transactions", len(expired))
+	return expired, vs.Unconfirmed.RemoveTransactions(expired)
+}
+
+// DropUnconfirmedTxns removes the transactions and their descendants from the unconfirmed pool.
+// Returns the hashes of all removed transactions.
+func (vs *Visor) DropUnconfirmedTxns(hashes []cipher.SHA256) ([]cipher.SHA256, error) {
+	if vs.Mempool == nil {
+		return hashes, vs.Unconfirmed.RemoveTransactions(hashes)
+	}
+
+	dropped := vs.Mempool.Drop(hashes)
+	return dropped, vs.Unconfirmed.RemoveTransactions(dropped)
+}
+
+// SyncMempool brings the mempool index in line with the unconfirmed pool, after
+// the pool was loaded from the db or transactions were confirmed by a block.
+// Children of confirmed transactions stay in the pool, children of transactions
+// removed for another reason are dropped from the pool.
+func (vs *Visor) SyncMempool() {
+	if vs.Mempool == nil {
+		return
+	}
+
+	uts := vs.Unconfirmed.GetTxns(func(UnconfirmedTxn) bool { return true })
+
+	inPool := make(map[cipher.SHA256]struct{}, len(uts))
+	received := make(map[cipher.SHA256]time.Time, len(uts))
+	txns := make(coin.Transactions, 0, len(uts))
+	for _, ut := range uts {
+		h := ut.Hash()
+		inPool[h] = struct{}{}
+		received[h] = time.Unix(0, ut.Received)
+		txns = append(txns, ut.Txn)
+	}
+
+	cu, err := vs.unconfirmedUnspents()
+	if err != nil {
+		logger.Error("SyncMempool: %v", err)
+		return
+	}
+
+	vs.Mempool.Lock()
+	defer vs.Mempool.Unlock()
+
+	var removed []cipher.SHA256
+	orphans := make(map[cipher.SHA256]struct{})
+	for h, mt := range vs.Mempool.txns {
+		if _, ok := inPool[h]; ok {
+			continue
+		}
+		removed = append(removed, h)
+
+		if !vs.isConfirmed(mt) {
+			// Dropped, the children can't be confirmed anymore
+			for _, c := range vs.Mempool.descendants(h) {
+				if _, ok := inPool[c]; ok {
+					orphans[c] = struct{}{}
+				}
+			}
+		}
+	}
+
+	for _, h := range removed {
+		vs.Mempool.remove(h)
+	}
+	for h := range orphans {
+		vs.Mempool.remove(h)
+	}
+
+	// Parents are added before their children to link them
+	for _, txn := range sortTxnsByDependency(txns) {
+		h := txn.Hash()
+		if _, ok := orphans[h]; ok {
+			continue
+		}
+		if _, ok := vs.Mempool.txns[h]; ok {
+			continue
+		}
+
+		txnFee, err := cu.TransactionFee(&txn)
+		if err != nil {
+			// The transaction's inputs may be spent by now, it is removed on the next refresh
+			txnFee = 0
+		}
+
+		vs.Mempool.add(newMempoolTxn(txn, txnFee, received[h]))
+	}
+
+	if len(orphans) > 0 {
+		hashes := make([]cipher.SHA256, 0, len(orphans))
+		for h := range orphans {
+			hashes = append(hashes, h)
+		}
+
+		logger.Info("Removing %d unconfirmed transactions whose parent was dropped", len(hashes))
+		if err := vs.Unconfirmed.RemoveTransactions(hashes); err != nil {
+			logger.Error("Remove orphan unconfirmed transactions failed: %v", err)
+		}
+	}
+}
+
+// isConfirmed returns true if the outputs of the mempool transaction are in the unspent pool
+func (vs *Visor) isConfirmed(mt *mempoolTxn) bool {
+	for _, h := range mt.outputs {
+		if vs.Blockchain.Unspent().Contains(h) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,273 @@
+package visor
+
+import (
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/testutil"
+)
+
+func makeMempoolTxn(t *testing.T, inputs ...cipher.SHA256) coin.Transaction {
+	txn := coin.Transaction{}
+	for _, in := range inputs {
+		txn.PushInput(in)
+	}
+	txn.PushOutput(testutil.MakeAddress(), 1e6, 1)
+	txn.UpdateHeader()
+	return txn
+}
+
+func TestMempoolDoubleSpend(t *testing.T) {
+	m := NewMempool(UnconfirmedPolicy{})
+
+	in := testutil.RandSHA256(t)
+	txn := makeMempoolTxn(t, in)
+	evicted, err := m.Admit(txn, 10)
+	require.NoError(t, err)
+	require.Empty(t, evicted)
+	m.Add(txn, 10, time.Now(), evicted)
+
+	// Admitting the same txn again is fine
+	_, err = m.Admit(txn, 10)
+	require.NoError(t, err)
+
+	txn2 := makeMempoolTxn(t, testutil.RandSHA256(t), in)
+	_, err = m.Admit(txn2, 100)
+	require.Equal(t, ErrTxnDoubleSpend{
+		Input:    in,
+		SpentBy:  txn.Hash(),
+		Rejected: txn2.Hash(),
+	}, err)
+	require.Equal(t, uint64(1), m.Stats().RejectedDoubleSpend)
+
+	// Once the first txn is removed the output can be spent again
+	m.Remove([]cipher.SHA256{txn.Hash()})
+	_, err = m.Admit(txn2, 100)
+	require.NoError(t, err)
+}
+
+func TestMempoolEviction(t *testing.T) {
+	m := NewMempool(UnconfirmedPolicy{MaxTxns: 2})
+
+	low := makeMempoolTxn(t, testutil.RandSHA256(t))
+	mid := makeMempoolTxn(t, testutil.RandSHA256(t))
+	for _, tc := range []struct {
+		txn coin.Transaction
+		fee uint64
+	}{
+		{low, 10},
+		{mid, 20},
+	} {
+		evicted, err := m.Admit(tc.txn, tc.fee)
+		require.NoError(t, err)
+		require.Empty(t, evicted)
+		m.Add(tc.txn, tc.fee, time.Now(), evicted)
+	}
+
+	// Not paying more than the cheapest txn
+	cheap := makeMempoolTxn(t, testutil.RandSHA256(t))
+	_, err := m.Admit(cheap, 10)
+	require.Equal(t, ErrTxnFeeTooLow, err)
+	require.Equal(t, uint64(1), m.Stats().RejectedLowFee)
+
+	// Paying more evicts the cheapest txn
+	high := makeMempoolTxn(t, testutil.RandSHA256(t))
+	evicted, err := m.Admit(high, 30)
+	require.NoError(t, err)
+	require.Equal(t, []cipher.SHA256{low.Hash()}, evicted)
+	m.Add(high, 30, time.Now(), evicted)
+
+	require.False(t, m.Contains(low.Hash()))
+	require.True(t, m.Contains(mid.Hash()))
+	require.True(t, m.Contains(high.Hash()))
+
+	stats := m.Stats()
+	require.Equal(t, 2, stats.Txns)
+	require.Equal(t, mid.Size()+high.Size(), stats.Bytes)
+	require.Equal(t, uint64(1), stats.Evicted)
+	require.Equal(t, uint64(20*1024/mid.Size()), stats.MinFeePerKB)
+}
+
+func TestMempoolMaxBytes(t *testing.T) {
+	txn1 := makeMempoolTxn(t, testutil.RandSHA256(t))
+	txn2 := makeMempoolTxn(t, testutil.RandSHA256(t))
+	big := makeMempoolTxn(t, testutil.RandSHA256(t), testutil.RandSHA256(t), testutil.RandSHA256(t))
+	require.True(t, big.Size() > txn1.Size())
+
+	m := NewMempool(UnconfirmedPolicy{MaxBytes: txn1.Size() + txn2.Size()})
+	m.Add(txn1, 10, time.Now(), nil)
+	m.Add(txn2, 20, time.Now(), nil)
+
+	// Making room for the bigger txn evicts both
+	evicted, err := m.Admit(big, 1000)
+	require.NoError(t, err)
+	require.Equal(t, []cipher.SHA256{txn1.Hash(), txn2.Hash()}, evicted)
+
+	// Larger than the whole pool
+	m = NewMempool(UnconfirmedPolicy{MaxBytes: txn1.Size()})
+	_, err = m.Admit(big, 1000)
+	require.Equal(t, ErrTxnTooLarge, err)
+}
+
+func TestMempoolExpire(t *testing.T) {
+	m := NewMempool(UnconfirmedPolicy{MaxAge: time.Hour})
+
+	now := time.Now()
+	old := makeMempoolTxn(t, testutil.RandSHA256(t))
+	fresh := makeMempoolTxn(t, testutil.RandSHA256(t))
+	m.Add(old, 10, now.Add(-2*time.Hour), nil)
+	m.Add(fresh, 10, now.Add(-time.Minute), nil)
+
+	expired := m.Expire(now)
+	require.Equal(t, []cipher.SHA256{old.Hash()}, expired)
+	require.False(t, m.Contains(old.Hash()))
+	require.True(t, m.Contains(fresh.Hash()))
+	require.Equal(t, uint64(1), m.Stats().Expired)
+
+	// Expiry disabled
+	m = NewMempool(UnconfirmedPolicy{})
+	m.Add(old, 10, now.Add(-1000*time.Hour), nil)
+	require.Empty(t, m.Expire(now))
+}
+
+func addMempoolTxn(t *testing.T, m *Mempool, txn coin.Transaction, fee uint64, received time.Time) {
+	evicted, err := m.Admit(txn, fee)
+	require.NoError(t, err)
+	m.Add(txn, fee, received, evicted)
+}
+
+func TestMempoolChainDepth(t *testing.T) {
+	m := NewMempool(UnconfirmedPolicy{MaxChainDepth: 2})
+
+	a := makeMempoolTxn(t, testutil.RandSHA256(t))
+	b := makeMempoolTxn(t, txnOutputHashes(a)[0])
+	c := makeMempoolTxn(t, txnOutputHashes(b)[0])
+	d := makeMempoolTxn(t, txnOutputHashes(c)[0])
+
+	addMempoolTxn(t, m, a, 10, time.Now())
+	addMempoolTxn(t, m, b, 10, time.Now())
+	addMempoolTxn(t, m, c, 10, time.Now())
+
+	// d would have 3 unconfirmed ancestors
+	_, err := m.Admit(d, 10)
+	require.Equal(t, ErrTxnChainTooDeep, err)
+
+	stats := m.Stats()
+	require.Equal(t, 2, stats.ChainedTxns)
+	require.Equal(t, 2, stats.MaxChainDepth)
+	require.Equal(t, uint64(1), stats.RejectedChainDepth)
+
+	// Once a is confirmed the chain is shorter, b and c stay in the pool
+	m.Remove([]cipher.SHA256{a.Hash()})
+	require.True(t, m.Contains(b.Hash()))
+	require.True(t, m.Contains(c.Hash()))
+	_, err = m.Admit(d, 10)
+	require.NoError(t, err)
+
+	// Spending unconfirmed outputs is disabled
+	m = NewMempool(UnconfirmedPolicy{})
+	addMempoolTxn(t, m, a, 10, time.Now())
+	_, err = m.Admit(b, 10)
+	require.Equal(t, ErrTxnChainTooDeep, err)
+}
+
+func TestMempoolDropDescendants(t *testing.T) {
+	m := NewMempool(UnconfirmedPolicy{MaxChainDepth: 10})
+
+	a := makeMempoolTxn(t, testutil.RandSHA256(t))
+	b := makeMempoolTxn(t, txnOutputHashes(a)[0])
+	c := makeMempoolTxn(t, txnOutputHashes(b)[0])
+	other := makeMempoolTxn(t, testutil.RandSHA256(t))
+	for _, txn := range []coin.Transaction{a, b, c, other} {
+		addMempoolTxn(t, m, txn, 10, time.Now())
+	}
+
+	dropped := m.Drop([]cipher.SHA256{b.Hash()})
+	require.Equal(t, []cipher.SHA256{b.Hash(), c.Hash()}, dropped)
+	require.True(t, m.Contains(a.Hash()))
+	require.True(t, m.Contains(other.Hash()))
+	require.Equal(t, 0, m.Stats().ChainedTxns)
+}
+
+func TestMempoolEvictDescendants(t *testing.T) {
+	m := NewMempool(UnconfirmedPolicy{MaxTxns: 3, MaxChainDepth: 10})
+
+	parent := makeMempoolTxn(t, testutil.RandSHA256(t))
+	child := makeMempoolTxn(t, txnOutputHashes(parent)[0])
+	other := makeMempoolTxn(t, testutil.RandSHA256(t))
+	addMempoolTxn(t, m, parent, 10, time.Now())
+	addMempoolTxn(t, m, child, 20, time.Now())
+	addMempoolTxn(t, m, other, 50, time.Now())
+
+	// Evicting the cheapest txn evicts its child
+	txn := makeMempoolTxn(t, testutil.RandSHA256(t))
+	evicted, err := m.Admit(txn, 60)
+	require.NoError(t, err)
+	require.Equal(t, []cipher.SHA256{parent.Hash(), child.Hash()}, evicted)
+
+	// A child paying a higher fee rate than the txn keeps its parent in the pool
+	m = NewMempool(UnconfirmedPolicy{MaxTxns: 3, MaxChainDepth: 10})
+	addMempoolTxn(t, m, parent, 10, time.Now())
+	addMempoolTxn(t, m, child, 100, time.Now())
+	addMempoolTxn(t, m, other, 50, time.Now())
+	evicted, err = m.Admit(txn, 60)
+	require.NoError(t, err)
+	require.Equal(t, []cipher.SHA256{other.Hash()}, evicted)
+
+	_, err = m.Admit(txn, 40)
+	require.Equal(t, ErrTxnFeeTooLow, err)
+
+	// The ancestors of a txn are not evicted to make room for it
+	m = NewMempool(UnconfirmedPolicy{MaxTxns: 2, MaxChainDepth: 10})
+	addMempoolTxn(t, m, parent, 10, time.Now())
+	addMempoolTxn(t, m, other, 50, time.Now())
+	evicted, err = m.Admit(child, 100)
+	require.NoError(t, err)
+	require.Equal(t, []cipher.SHA256{other.Hash()}, evicted)
+
+	// The pool is full of the ancestors of the txn
+	m = NewMempool(UnconfirmedPolicy{MaxTxns: 1, MaxChainDepth: 10})
+	addMempoolTxn(t, m, parent, 10, time.Now())
+	_, err = m.Admit(child, 100)
+	require.Equal(t, ErrTxnPoolFull, err)
+}
+
+func TestMempoolExpireDescendants(t *testing.T) {
+	m := NewMempool(UnconfirmedPolicy{MaxAge: time.Hour, MaxChainDepth: 10})
+
+	now := time.Now()
+	parent := makeMempoolTxn(t, testutil.RandSHA256(t))
+	child := makeMempoolTxn(t, txnOutputHashes(parent)[0])
+	addMempoolTxn(t, m, parent, 10, now.Add(-2*time.Hour))
+	addMempoolTxn(t, m, child, 10, now.Add(-time.Minute))
+
+	expired := m.Expire(now)
+	require.Equal(t, []cipher.SHA256{parent.Hash(), child.Hash()}, expired)
+	require.Equal(t, uint64(2), m.Stats().Expired)
+}
+
+func TestMempoolStatsMinFeePerKB(t *testing.T) {
+	m := NewMempool(UnconfirmedPolicy{MaxChainDepth: 10})
+	txn := makeMempoolTxn(t, testutil.RandSHA256(t))
+	addMempoolTxn(t, m, txn, 1000, time.Now())
+	require.Equal(t, uint64(1000*1024/txn.Size()), m.Stats().MinFeePerKB)
+
+	// fee * 1024 overflows a uint64 but the fee per kilobyte doesn't
+	fee := uint64(math.MaxUint64/1024 + 1)
+	m = NewMempool(UnconfirmedPolicy{MaxChainDepth: 10})
+	addMempoolTxn(t, m, txn, fee, time.Now())
+	feePerKB := new(big.Int).Mul(new(big.Int).SetUint64(fee), big.NewInt(1024))
+	feePerKB.Quo(feePerKB, big.NewInt(int64(txn.Size())))
+	require.Equal(t, feePerKB.Uint64(), m.Stats().MinFeePerKB)
+
+	// The fee per kilobyte doesn't fit in a uint64
+	m = NewMempool(UnconfirmedPolicy{MaxChainDepth: 10})
+	addMempoolTxn(t, m, txn, math.MaxUint64, time.Now())
+	require.Equal(t, uint64(math.MaxUint64), m.Stats().MinFeePerKB)
+}
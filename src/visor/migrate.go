@@ -0,0 +1,215 @@
+package visor
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/skycoin/skycoin/src/visor/bucket"
+	"github.com/skycoin/skycoin/src/visor/historydb"
+	"github.com/skycoin/skycoin/src/visor/storage"
+)
+
+var (
+	// dbMetaBkt stores the db schema meta info
+	dbMetaBkt = []byte("db_meta")
+	// schemaVersionKey is the key of the db schema version
+	schemaVersionKey = []byte("schema_version")
+)
+
+// Migration upgrades the db schema from Version-1 to Version.
+// Migrate is run in the same transaction that updates the schema version,
+// so a failed migration leaves the db untouched. A nil Migrate only stamps the version.
+type Migration struct {
+	Version uint64
+	Name    string
+	Migrate func(tx storage.Tx) error
+}
+
+// migrations is the ordered registry of db schema migrations.
+// New migrations must be appended with Version incremented by one.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "add db schema version",
+	},
+	{
+		// The history db is parsed again from the genesis block when the node starts,
+		// filling the ordered address indexes used for pagination
+		Version: 2,
+		Name:    "index address history by block seq",
+		Migrate: historydb.ResetWithTx,
+	},
+	{
+		// Parsed again to fill the address statistics and the rich list index
+		Version: 3,
+		Name:    "add address statistics",
+		Migrate: historydb.ResetWithTx,
+	},
+}
+
+// SchemaVersion returns the db schema version this build expects
+func SchemaVersion() uint64 {
+	return migrations[len(migrations)-1].Version
+}
+
+// GetSchemaVersion returns the db schema version, dbs created before
+// schema versioning was introduced have version 0
+func GetSchemaVersion(db *bolt.DB) (uint64, error) {
+	var v uint64
+	err := db.View(func(tx *bolt.Tx) error {
+		var err error
+		v, err = getSchemaVersionWithTx(tx)
+		return err
+	})
+	return v, err
+}
+
+func getSchemaVersionWithTx(tx *bolt.Tx) (uint64, error) {
+	bkt := tx.Bucket(dbMetaBkt)
+	if bkt == nil {
+		return 0, nil
+	}
+
+	v := bkt.Get(schemaVersionKey)
+	if v == nil {
+		return 0, nil
+	}
+
+	if len(v) != 8 {
+		return 0, fmt.Errorf("invalid schema version length %d", len(v))
+	}
+
+	return bucket.Btoi(v), nil
+}
+
+func setSchemaVersionWithTx(tx *bolt.Tx, v uint64) error {
+	bkt, err := tx.CreateBucketIfNotExists(dbMetaBkt)
+	if err != nil {
+		return err
+	}
+
+	return bkt.Put(schemaVersionKey, bucket.Itob(v))
+}
+
+// PendingMigrations returns the migrations that have not been applied to the db
+func PendingMigrations(db *bolt.DB) ([]Migration, error) {
+	v, err := GetSchemaVersion(db)
+	if err != nil {
+		return nil, err
+	}
+
+	return pendingMigrations(v)
+}
+
+func pendingMigrations(v uint64) ([]Migration, error) {
+	if v > SchemaVersion() {
+		return nil, fmt.Errorf("db schema version %d is newer than the supported version %d", v, SchemaVersion())
+	}
+
+	var ms []Migration
+	for _, m := range migrations {
+		if m.Version > v {
+			ms = append(ms, m)
+		}
+	}
+
+	return ms, nil
+}
+
+// MigrateDB applies all pending migrations in order, each in its own transaction.
+// A new db is stamped with the current schema version without running any migration.
+// If backup is true, the db is copied to a backup file before the first migration runs,
+// unless every pending migration only stamps the version.
+// Returns the path of the backup file, or "" if no backup was made.
+func MigrateDB(db *bolt.DB, backup bool) (string, error) {
+	isNew, err := isEmptyDB(db)
+	if err != nil {
+		return "", err
+	}
+
+	if isNew {
+		return "", db.Update(func(tx *bolt.Tx) error {
+			return setSchemaVersionWithTx(tx, SchemaVersion())
+		})
+	}
+
+	ms, err := PendingMigrations(db)
+	if err != nil {
+		return "", err
+	}
+
+	if len(ms) == 0 {
+		return "", nil
+	}
+
+	var backupPath string
+	if backup && changesData(ms) {
+		backupPath, err = backupDB(db)
+		if err != nil {
+			return "", fmt.Errorf("backup db failed: %v", err)
+		}
+		logger.Info("Backed up db to %s", backupPath)
+	}
+
+	for _, m := range ms {
+		logger.Info("Migrating db schema to version %d: %s", m.Version, m.Name)
+		if err := db.Update(func(tx *bolt.Tx) error {
+			if m.Migrate != nil {
+				if err := m.Migrate(storage.BoltTx(tx)); err != nil {
+					return err
+				}
+			}
+			return setSchemaVersionWithTx(tx, m.Version)
+		}); err != nil {
+			return backupPath, fmt.Errorf("migration to schema version %d (%s) failed: %v", m.Version, m.Name, err)
+		}
+	}
+
+	return backupPath, nil
+}
+
+// changesData returns true if any of the migrations changes the data of the db
+func changesData(ms []Migration) bool {
+	for _, m := range ms {
+		if m.Migrate != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmptyDB returns true if the db has no buckets
+func isEmptyDB(db *bolt.DB) (bool, error) {
+	empty := true
+	err := db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			empty = false
+			return nil
+		})
+	})
+	return empty, err
+}
+
+// backupDB copies the db into $FILE.backup.v$VERSION.$TIMESTAMP
+func backupDB(db *bolt.DB) (string, error) {
+	v, err := GetSchemaVersion(db)
+	if err != nil {
+		return "", err
+	}
+
+	path := fmt.Sprintf("%s.backup.v%d.%d", db.Path(), v, time.Now().Unix())
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("backup file %s already exists", path)
+	}
+
+	if err := db.View(func(tx *bolt.Tx) error {
+		return tx.CopyFile(path, 0600)
+	}); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
@@ -0,0 +1,189 @@
+package visor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/testutil"
+	"github.com/skycoin/skycoin/src/visor/bucket"
+	"github.com/skycoin/skycoin/src/visor/storage"
+)
+
+func createLegacyBucket(t *testing.T, db *bolt.DB) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte("blocks"))
+		return err
+	})
+	require.NoError(t, err)
+}
+
+func TestMigrateDBNew(t *testing.T) {
+	db, teardown := testutil.PrepareDB(t)
+	defer teardown()
+
+	backup, err := MigrateDB(db, true)
+	require.NoError(t, err)
+	require.Empty(t, backup)
+
+	v, err := GetSchemaVersion(db)
+	require.NoError(t, err)
+	require.Equal(t, SchemaVersion(), v)
+
+	ms, err := PendingMigrations(db)
+	require.NoError(t, err)
+	require.Empty(t, ms)
+}
+
+func TestMigrateDBLegacy(t *testing.T) {
+	db, teardown := testutil.PrepareDB(t)
+	defer teardown()
+
+	createLegacyBucket(t, db)
+
+	v, err := GetSchemaVersion(db)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), v)
+
+	ms, err := PendingMigrations(db)
+	require.NoError(t, err)
+	require.Len(t, ms, len(migrations))
+
+	backup, err := MigrateDB(db, true)
+	require.NoError(t, err)
+	require.NotEmpty(t, backup)
+	defer os.Remove(backup)
+
+	_, err = os.Stat(backup)
+	require.NoError(t, err)
+
+	v, err = GetSchemaVersion(db)
+	require.NoError(t, err)
+	require.Equal(t, SchemaVersion(), v)
+
+	// Migrating again is a no-op
+	backup, err = MigrateDB(db, true)
+	require.NoError(t, err)
+	require.Empty(t, backup)
+}
+
+func TestMigrateDBNoBackupForVersionOnly(t *testing.T) {
+	db, teardown := testutil.PrepareDB(t)
+	defer teardown()
+
+	createLegacyBucket(t, db)
+
+	origMigrations := migrations
+	defer func() {
+		migrations = origMigrations
+	}()
+
+	migrations = append(migrations, Migration{
+		Version: SchemaVersion() + 1,
+		Name:    "version only",
+	})
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		return setSchemaVersionWithTx(tx, SchemaVersion()-1)
+	})
+	require.NoError(t, err)
+
+	// Only the version is stamped, no backup is made
+	backup, err := MigrateDB(db, true)
+	require.NoError(t, err)
+	require.Empty(t, backup)
+
+	v, err := GetSchemaVersion(db)
+	require.NoError(t, err)
+	require.Equal(t, SchemaVersion(), v)
+}
+
+func TestMigrateDBNewerVersion(t *testing.T) {
+	db, teardown := testutil.PrepareDB(t)
+	defer teardown()
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		return setSchemaVersionWithTx(tx, SchemaVersion()+1)
+	})
+	require.NoError(t, err)
+
+	_, err = MigrateDB(db, false)
+	testutil.RequireError(t, err, fmt.Sprintf("db schema version %d is newer than the supported version %d",
+		SchemaVersion()+1, SchemaVersion()))
+}
+
+func TestMigrateDBFailureRollsBack(t *testing.T) {
+	db, teardown := testutil.PrepareDB(t)
+	defer teardown()
+
+	createLegacyBucket(t, db)
+
+	origMigrations := migrations
+	defer func() {
+		migrations = origMigrations
+	}()
+
+	migrations = append(migrations, Migration{
+		Version: SchemaVersion() + 1,
+		Name:    "failing migration",
+		Migrate: func(tx storage.Tx) error {
+			bkt, err := tx.CreateBucketIfNotExists([]byte("migration_test"))
+			if err != nil {
+				return err
+			}
+			if err := bkt.Put([]byte("k"), bucket.Itob(1)); err != nil {
+				return err
+			}
+			return errors.New("failed")
+		},
+	})
+
+	version := SchemaVersion()
+	_, err := MigrateDB(db, false)
+	testutil.RequireError(t, err, fmt.Sprintf("migration to schema version %d (failing migration) failed: failed", version))
+
+	// The registered migrations are applied, the failing one is rolled back
+	v, err := GetSchemaVersion(db)
+	require.NoError(t, err)
+	require.Equal(t, version-1, v)
+
+	err = db.View(func(tx *bolt.Tx) error {
+		require.Nil(t, tx.Bucket([]byte("migration_test")))
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestMigrateDBResetsHistory(t *testing.T) {
+	db, teardown := testutil.PrepareDB(t)
+	defer teardown()
+
+	createLegacyBucket(t, db)
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, name := range []string{"address_txns", "address_in", "uxouts", "transactions", "history_meta"} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return err
+			}
+		}
+		return setSchemaVersionWithTx(tx, 1)
+	})
+	require.NoError(t, err)
+
+	_, err = MigrateDB(db, false)
+	require.NoError(t, err)
+
+	// The history is parsed again with the ordered address indexes, the blocks are kept
+	err = db.View(func(tx *bolt.Tx) error {
+		for _, name := range []string{"address_txns", "address_in", "uxouts", "transactions", "history_meta"} {
+			require.Nil(t, tx.Bucket([]byte(name)), name)
+		}
+		require.NotNil(t, tx.Bucket([]byte("blocks")))
+		return nil
+	})
+	require.NoError(t, err)
+}
@@ -0,0 +1,52 @@
+package visor
+
+import (
+	"fmt"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/visor/historydb"
+)
+
+// GetAddressTxnsPage returns up to limit confirmed transactions of the address after the cursor,
+// ordered by block seq then index in the block, and the cursor of the next page, nil if it is the last
+func (vs *Visor) GetAddressTxnsPage(addr cipher.Address, after *historydb.Cursor, limit int) ([]Transaction, *historydb.Cursor, error) {
+	htxns, next, err := vs.history.GetAddrTxnsPage(addr, after, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	head, err := vs.Blockchain.Head()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	txns := make([]Transaction, 0, len(htxns))
+	for _, t := range htxns {
+		b, err := vs.Blockchain.GetBlockBySeq(t.BlockSeq)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if b == nil {
+			return nil, nil, fmt.Errorf("block %d of transaction %s does not exist", t.BlockSeq, t.Tx.Hash().Hex())
+		}
+
+		txns = append(txns, Transaction{
+			Txn: t.Tx,
+			Status: TransactionStatus{
+				Confirmed: true,
+				Height:    head.Seq() - t.BlockSeq + 1,
+				BlockSeq:  t.BlockSeq,
+			},
+			Time: b.Time(),
+		})
+	}
+
+	return txns, next, nil
+}
+
+// GetAddrUxOutsPage returns up to limit outputs received by the address after the cursor,
+// ordered by block seq then index in the block, and the cursor of the next page, nil if it is the last
+func (vs *Visor) GetAddrUxOutsPage(addr cipher.Address, after *historydb.Cursor, limit int) ([]*historydb.UxOut, *historydb.Cursor, error) {
+	return vs.history.GetAddrUxOutsPage(addr, after, limit)
+}
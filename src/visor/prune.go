@@ -0,0 +1,60 @@
+package visor
+
+import (
+	"errors"
+
+	"github.com/skycoin/skycoin/src/visor/blockdb"
+)
+
+// ErrPruningUnsupported is returned if the block store does not support pruning
+var ErrPruningUnsupported = errors.New("block store does not support pruning")
+
+// blockPruner is implemented by block stores that can prune block bodies
+type blockPruner interface {
+	EnablePruning(keep uint64, hook blockdb.PruneHook)
+	PruneDepth() uint64
+	PrunedSeq() uint64
+	IsPruned(seq uint64) bool
+}
+
+// EnablePruning keeps the bodies of the last keep blocks only. Block headers,
+// signatures and the unspent pool are kept, so the chain can still be verified
+// and extended, but the history db will be incomplete. hook is called before
+// the body of a block is pruned, it may be nil.
+func (bc *Blockchain) EnablePruning(keep uint64, hook blockdb.PruneHook) error {
+	p, ok := bc.store.(blockPruner)
+	if !ok {
+		return ErrPruningUnsupported
+	}
+
+	p.EnablePruning(keep, hook)
+	return nil
+}
+
+// EnablePruning keeps the bodies of the last keep blocks only. A block is pruned once
+// the history db has parsed it, and its transactions are then removed from the history db.
+func (vs *Visor) EnablePruning(keep uint64) error {
+	return vs.Blockchain.EnablePruning(keep, vs.history.PruneBlockWithTx)
+}
+
+// IsPruningEnabled returns true if the node prunes block bodies, or if block bodies
+// were pruned by a previous run of the node
+func (bc *Blockchain) IsPruningEnabled() bool {
+	p, ok := bc.store.(blockPruner)
+	return ok && (p.PruneDepth() > 0 || p.PrunedSeq() > 0)
+}
+
+// PrunedSeq returns the highest seq of the blocks whose body was pruned, 0 if none was pruned
+func (bc *Blockchain) PrunedSeq() uint64 {
+	p, ok := bc.store.(blockPruner)
+	if !ok {
+		return 0
+	}
+	return p.PrunedSeq()
+}
+
+// IsPruned returns true if the body of the block of given seq was pruned
+func (bc *Blockchain) IsPruned(seq uint64) bool {
+	p, ok := bc.store.(blockPruner)
+	return ok && p.IsPruned(seq)
+}
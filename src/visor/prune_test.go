@@ -0,0 +1,36 @@
+package visor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/testutil"
+	"github.com/skycoin/skycoin/src/visor/blockdb"
+)
+
+func TestIsPruningEnabled(t *testing.T) {
+	db, closeDB := testutil.PrepareDB(t)
+	defer closeDB()
+
+	bc := makeReindexChain(t, db, 4, 2)
+	require.True(t, bc.IsPruningEnabled())
+	require.Equal(t, uint64(2), bc.PrunedSeq())
+
+	// Pruned by a previous run, pruning is not enabled on this one
+	store, err := blockdb.NewBlockchain(db, DefaultWalker)
+	require.NoError(t, err)
+	bc2 := &Blockchain{
+		db:    db,
+		store: store,
+	}
+	require.True(t, bc2.IsPruningEnabled())
+	require.Equal(t, uint64(2), bc2.PrunedSeq())
+
+	db2, closeDB2 := testutil.PrepareDB(t)
+	defer closeDB2()
+
+	bc3 := makeReindexChain(t, db2, 4, 0)
+	require.False(t, bc3.IsPruningEnabled())
+	require.Equal(t, uint64(0), bc3.PrunedSeq())
+}
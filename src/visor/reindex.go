@@ -0,0 +1,115 @@
+package visor
+
+import (
+	"fmt"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/skycoin/skycoin/src/visor/blockdb"
+	"github.com/skycoin/skycoin/src/visor/historydb"
+)
+
+// ReindexConfig configures Reindex
+type ReindexConfig struct {
+	// HistoryOnly rebuilds the historydb buckets only, the unspent pool is kept
+	HistoryOnly bool
+	// Progress is called after each block is replayed, can be nil
+	Progress func(seq, headSeq uint64)
+}
+
+// Reindex rebuilds the buckets derived from the blocks (the unspent pool and the historydb
+// buckets) by replaying every stored block. The blocks, block signatures and blockchain meta
+// buckets are kept. The unspent pool is rebuilt into scratch buckets which replace the live
+// pool once every block is replayed, so that a failure leaves the live pool untouched.
+// A pruned db can't be reindexed since the bodies of the pruned blocks are gone.
+// The node must not be running.
+func Reindex(db *bolt.DB, signers blockdb.SignerSchedule, cfg ReindexConfig) error {
+	chain, err := blockdb.NewBlockchain(db, DefaultWalker)
+	if err != nil {
+		return err
+	}
+
+	if prunedSeq := chain.PrunedSeq(); prunedSeq > 0 {
+		return fmt.Errorf("the bodies of the blocks up to %d are pruned, a pruned db can't be reindexed", prunedSeq)
+	}
+
+	var unspent *blockdb.Unspents
+	if !cfg.HistoryOnly {
+		unspent, err = blockdb.NewReindexUnspentPool(db)
+		if err != nil {
+			return err
+		}
+	}
+
+	history, err := historydb.New(db)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Dropping history db")
+	if err := history.Reset(); err != nil {
+		return err
+	}
+
+	if chain.GetGenesisBlock() == nil {
+		logger.Info("No blocks in db, nothing to reindex")
+		if cfg.HistoryOnly {
+			return nil
+		}
+		return blockdb.ReplaceUnspentPool(db)
+	}
+
+	headSeq := chain.HeadSeq()
+	logger.Info("Reindexing %d blocks", headSeq+1)
+
+	for seq := uint64(0); seq <= headSeq; seq++ {
+		b, err := chain.GetBlockBySeq(seq)
+		if err != nil {
+			return err
+		}
+
+		if b == nil {
+			return fmt.Errorf("block %d does not exist", seq)
+		}
+
+		if err := signers.VerifyBlockSignature(b); err != nil {
+			return fmt.Errorf("verify signature of block %d failed: %v", seq, err)
+		}
+
+		if !cfg.HistoryOnly {
+			// The block's uxhash is the unspent pool checksum before the block is applied
+			if uxHash := unspent.GetUxHash(); uxHash != b.Head.UxHash {
+				return fmt.Errorf("uxhash mismatch at block %d, rebuilt: %s, block: %s",
+					seq, uxHash.Hex(), b.Head.UxHash.Hex())
+			}
+
+			if err := db.Update(func(tx *bolt.Tx) error {
+				rb, err := unspent.ProcessBlock(b)(tx)
+				if err != nil {
+					rb()
+				}
+				return err
+			}); err != nil {
+				return fmt.Errorf("rebuild unspent pool at block %d failed: %v", seq, err)
+			}
+		}
+
+		if err := history.ParseBlock(&b.Block); err != nil {
+			return fmt.Errorf("parse block %d into history db failed: %v", seq, err)
+		}
+
+		if cfg.Progress != nil {
+			cfg.Progress(seq, headSeq)
+		}
+	}
+
+	if !cfg.HistoryOnly {
+		logger.Info("Replacing unspent pool")
+		if err := blockdb.ReplaceUnspentPool(db); err != nil {
+			return fmt.Errorf("replace unspent pool failed: %v", err)
+		}
+	}
+
+	logger.Info("Reindex finished, head seq: %d", headSeq)
+	return nil
+}
@@ -0,0 +1,120 @@
+package visor
+
+import (
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/testutil"
+	"github.com/skycoin/skycoin/src/visor/blockdb"
+)
+
+// makeReindexChain executes the genesis block and n blocks, each spending the first
+// output of the previous block, keeping the bodies of the last pruneKeep blocks only
+func makeReindexChain(t *testing.T, db *bolt.DB, n int, pruneKeep uint64) *Blockchain {
+	store, err := blockdb.NewBlockchain(db, DefaultWalker)
+	require.NoError(t, err)
+	store.EnablePruning(pruneKeep, nil)
+
+	bc := &Blockchain{
+		db:    db,
+		store: store,
+	}
+
+	gb, err := coin.NewGenesisBlock(genAddress, genCoins, genTime)
+	require.NoError(t, err)
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		return bc.ExecuteBlockWithTx(tx, &coin.SignedBlock{
+			Block: *gb,
+			Sig:   cipher.SignHash(gb.HashHeader(), genSecret),
+		})
+	})
+	require.NoError(t, err)
+
+	prev := gb
+	uxs := coin.CreateUnspents(gb.Head, gb.Body.Transactions[0])
+	for i := 0; i < n; i++ {
+		txn := makeSpendTx(t, uxs[:1], []cipher.SecKey{genSecret}, genAddress, 10e6)
+		b, err := coin.NewBlock(*prev, prev.Head.Time+100, bc.Unspent().GetUxHash(), coin.Transactions{txn}, feeCalc)
+		require.NoError(t, err)
+
+		err = db.Update(func(tx *bolt.Tx) error {
+			return bc.ExecuteBlockWithTx(tx, &coin.SignedBlock{
+				Block: *b,
+				Sig:   cipher.SignHash(b.HashHeader(), genSecret),
+			})
+		})
+		require.NoError(t, err)
+
+		prev = b
+		uxs = coin.CreateUnspents(b.Head, txn)
+	}
+
+	return bc
+}
+
+// requireUnspentPool checks the unspent pool stored in db
+func requireUnspentPool(t *testing.T, db *bolt.DB, uxHash cipher.SHA256, n uint64) {
+	up, err := blockdb.NewUnspentPool(db)
+	require.NoError(t, err)
+	require.Equal(t, uxHash, up.GetUxHash())
+	require.Equal(t, n, up.Len())
+}
+
+func TestReindex(t *testing.T) {
+	db, closeDB := testutil.PrepareDB(t)
+	defer closeDB()
+
+	bc := makeReindexChain(t, db, 3, 0)
+	uxHash := bc.Unspent().GetUxHash()
+	n := bc.Unspent().Len()
+
+	// A failed replay leaves the live unspent pool untouched
+	otherPubkey, _ := cipher.GenerateKeyPair()
+	err := Reindex(db, blockdb.NewSignerSchedule(otherPubkey), ReindexConfig{})
+	require.Error(t, err)
+	requireUnspentPool(t, db, uxHash, n)
+
+	var replayed []uint64
+	err = Reindex(db, blockdb.NewSignerSchedule(genPublic), ReindexConfig{
+		Progress: func(seq, headSeq uint64) {
+			require.Equal(t, uint64(3), headSeq)
+			replayed = append(replayed, seq)
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{0, 1, 2, 3}, replayed)
+	requireUnspentPool(t, db, uxHash, n)
+
+	// The scratch buckets are dropped
+	err = db.View(func(tx *bolt.Tx) error {
+		require.Nil(t, tx.Bucket([]byte("unspent_pool_reindex")))
+		require.Nil(t, tx.Bucket([]byte("unspent_meta_reindex")))
+		return nil
+	})
+	require.NoError(t, err)
+
+	// The history db is rebuilt only, the unspent pool is kept
+	err = Reindex(db, blockdb.NewSignerSchedule(genPublic), ReindexConfig{HistoryOnly: true})
+	require.NoError(t, err)
+	requireUnspentPool(t, db, uxHash, n)
+}
+
+func TestReindexPruned(t *testing.T) {
+	db, closeDB := testutil.PrepareDB(t)
+	defer closeDB()
+
+	bc := makeReindexChain(t, db, 4, 2)
+	require.True(t, bc.IsPruned(2))
+	require.False(t, bc.IsPruned(3))
+	uxHash := bc.Unspent().GetUxHash()
+	n := bc.Unspent().Len()
+
+	err := Reindex(db, blockdb.NewSignerSchedule(genPublic), ReindexConfig{})
+	testutil.RequireError(t, err, "the bodies of the blocks up to 2 are pruned, a pruned db can't be reindexed")
+	requireUnspentPool(t, db, uxHash, n)
+}
@@ -0,0 +1,99 @@
+package visor
+
+import (
+	"fmt"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/visor/blockdb"
+	"github.com/skycoin/skycoin/src/visor/historydb"
+)
+
+// Rollback removes all blocks above toSeq. For each block, from the head down, the outputs
+// it created are removed from the unspent pool, the outputs it spent are restored from the
+// history db, and the history db and head seq are rewound, in a single transaction. The
+// block is then removed from the block tree. Finally the unspent pool checksum is verified
+// against the uxhash of the first removed block. The node must not be running.
+func Rollback(db *bolt.DB, toSeq uint64, progress func(seq uint64)) error {
+	chain, err := blockdb.NewBlockchain(db, DefaultWalker)
+	if err != nil {
+		return err
+	}
+
+	history, err := historydb.New(db)
+	if err != nil {
+		return err
+	}
+
+	if chain.GetGenesisBlock() == nil {
+		return fmt.Errorf("no blocks in db")
+	}
+
+	headSeq := chain.HeadSeq()
+
+	// Remove a block left in the tree by an interrupted rollback
+	if b, err := chain.GetBlockBySeq(headSeq + 1); err != nil {
+		return err
+	} else if b != nil {
+		logger.Info("Removing block %d left above head", b.Seq())
+		if err := chain.RemoveBlock(b); err != nil {
+			return err
+		}
+	}
+
+	if toSeq >= headSeq {
+		return fmt.Errorf("target seq %d must be lower than head seq %d", toSeq, headSeq)
+	}
+
+	if chain.IsPruned(toSeq + 1) {
+		return fmt.Errorf("body of block %d was pruned, can't roll back below seq %d", toSeq+1, chain.PrunedSeq())
+	}
+
+	if h := history.ParsedHeight(); h != int64(headSeq) {
+		return fmt.Errorf("history db parsed height %d does not match head seq %d, run reindex first", h, headSeq)
+	}
+
+	var expectUxHash cipher.SHA256
+	for seq := headSeq; seq > toSeq; seq-- {
+		b, err := chain.GetBlockBySeq(seq)
+		if err != nil {
+			return err
+		}
+
+		if b == nil {
+			return fmt.Errorf("block %d does not exist", seq)
+		}
+
+		if err := db.Update(func(tx *bolt.Tx) error {
+			spent, err := history.RollbackBlockWithTx(tx, &b.Block)
+			if err != nil {
+				return err
+			}
+
+			return chain.RollbackHeadWithTx(tx, b, coin.UxArray(spent))
+		}); err != nil {
+			return fmt.Errorf("roll back block %d failed: %v", seq, err)
+		}
+
+		if err := chain.RemoveBlock(b); err != nil {
+			return fmt.Errorf("remove block %d failed: %v", seq, err)
+		}
+
+		// The uxhash of a block is the unspent pool checksum before it was applied
+		expectUxHash = b.Head.UxHash
+
+		if progress != nil {
+			progress(seq)
+		}
+	}
+
+	if uxHash := chain.UnspentPool().GetUxHash(); uxHash != expectUxHash {
+		return fmt.Errorf("uxhash %s after rollback does not match the uxhash %s of block %d",
+			uxHash.Hex(), expectUxHash.Hex(), toSeq+1)
+	}
+
+	logger.Info("Rolled back to block %d", toSeq)
+	return nil
+}
@@ -0,0 +1,65 @@
+package visor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/testutil"
+	"github.com/skycoin/skycoin/src/visor/blockdb"
+)
+
+func TestRollback(t *testing.T) {
+	db, closeDB := testutil.PrepareDB(t)
+	defer closeDB()
+
+	makeReindexChain(t, db, 3, 0)
+	signers := blockdb.NewSignerSchedule(genPublic)
+
+	// The history db is not parsed
+	err := Rollback(db, 1, nil)
+	testutil.RequireError(t, err, "history db parsed height -1 does not match head seq 3, run reindex first")
+
+	require.NoError(t, Reindex(db, signers, ReindexConfig{HistoryOnly: true}))
+
+	err = Rollback(db, 3, nil)
+	testutil.RequireError(t, err, "target seq 3 must be lower than head seq 3")
+
+	chain, err := blockdb.NewBlockchain(db, DefaultWalker)
+	require.NoError(t, err)
+	b2, err := chain.GetBlockBySeq(2)
+	require.NoError(t, err)
+	require.NotNil(t, b2)
+
+	var removed []uint64
+	err = Rollback(db, 1, func(seq uint64) {
+		removed = append(removed, seq)
+	})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{3, 2}, removed)
+
+	chain, err = blockdb.NewBlockchain(db, DefaultWalker)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), chain.HeadSeq())
+	require.Equal(t, b2.Head.UxHash, chain.UnspentPool().GetUxHash())
+
+	b, err := chain.GetBlockBySeq(2)
+	require.NoError(t, err)
+	require.Nil(t, b)
+
+	// The rolled back db verifies
+	res, err := VerifyDB(db, signers)
+	require.NoError(t, err)
+	require.Empty(t, res.Issues)
+	require.Equal(t, uint64(1), res.HeadSeq)
+}
+
+func TestRollbackPruned(t *testing.T) {
+	db, closeDB := testutil.PrepareDB(t)
+	defer closeDB()
+
+	makeReindexChain(t, db, 4, 2)
+
+	err := Rollback(db, 1, nil)
+	testutil.RequireError(t, err, "body of block 2 was pruned, can't roll back below seq 2")
+}
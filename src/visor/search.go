@@ -0,0 +1,177 @@
+package visor
+
+import (
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+)
+
+// Search result types
+const (
+	SearchResultAddress     = "address"
+	SearchResultBlock       = "block"
+	SearchResultTransaction = "transaction"
+	SearchResultUxOut       = "uxout"
+)
+
+// MinSearchPrefixLen is the minimum number of hex characters of a hash prefix search
+const MinSearchPrefixLen = 4
+
+// ErrSearchUnsupported is returned if the block store does not support searching by hash prefix
+var ErrSearchUnsupported = errors.New("block store does not support search")
+
+// blockSearcher is implemented by block stores that can search blocks by hash prefix
+type blockSearcher interface {
+	SearchBlocks(hexPrefix string, limit int) ([]*coin.SignedBlock, error)
+}
+
+// SearchBlocks returns up to limit blocks of the chain whose hash starts with hexPrefix
+func (bc *Blockchain) SearchBlocks(hexPrefix string, limit int) ([]*coin.SignedBlock, error) {
+	s, ok := bc.store.(blockSearcher)
+	if !ok {
+		return nil, ErrSearchUnsupported
+	}
+
+	return s.SearchBlocks(hexPrefix, limit)
+}
+
+// SearchResult is a block, transaction, output or address matching a search query
+type SearchResult struct {
+	Type string
+	// Hex hash of the block, transaction or output, or the address
+	ID string
+	// Seq of the block, of the block of a confirmed transaction or of the block which created the output
+	BlockSeq uint64
+	// Confirmed is false for unconfirmed transactions
+	Confirmed bool
+	// Exact is false for the hashes matched by a prefix of the query
+	Exact bool
+}
+
+// Search classifies the query and returns the matching blocks, transactions, outputs and
+// addresses, at most limit of each type. The query is matched as a block seq, as an
+// address, and as a block, transaction or output hash or hash prefix of at least
+// MinSearchPrefixLen hex characters.
+func (vs *Visor) Search(q string, limit int) ([]SearchResult, error) {
+	if limit <= 0 {
+		return nil, errors.New("limit must be positive")
+	}
+
+	q = strings.TrimSpace(q)
+	if q == "" {
+		return nil, errors.New("query is empty")
+	}
+
+	results := []SearchResult{}
+
+	if seq, err := strconv.ParseUint(q, 10, 64); err == nil {
+		b, err := vs.Blockchain.GetBlockBySeq(seq)
+		if err != nil {
+			return nil, err
+		}
+
+		if b != nil {
+			results = append(results, SearchResult{
+				Type:      SearchResultBlock,
+				ID:        b.HashHeader().Hex(),
+				BlockSeq:  seq,
+				Confirmed: true,
+				Exact:     true,
+			})
+		}
+	}
+
+	if addr, err := cipher.DecodeBase58Address(q); err == nil {
+		results = append(results, SearchResult{
+			Type:  SearchResultAddress,
+			ID:    addr.String(),
+			Exact: true,
+		})
+	}
+
+	if !isHashPrefix(q) {
+		return results, nil
+	}
+
+	q = strings.ToLower(q)
+	exact := len(q) == len(cipher.SHA256{})*2
+
+	blocks, err := vs.Blockchain.SearchBlocks(q, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, b := range blocks {
+		results = append(results, SearchResult{
+			Type:      SearchResultBlock,
+			ID:        b.HashHeader().Hex(),
+			BlockSeq:  b.Seq(),
+			Confirmed: true,
+			Exact:     exact,
+		})
+	}
+
+	txns, err := vs.history.SearchTxns(q, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, txn := range txns {
+		results = append(results, SearchResult{
+			Type:      SearchResultTransaction,
+			ID:        txn.Tx.Hash().Hex(),
+			BlockSeq:  txn.BlockSeq,
+			Confirmed: true,
+			Exact:     exact,
+		})
+	}
+
+	// Room is left for the unconfirmed transactions if less than limit confirmed ones match
+	var unconfirmed []string
+	for _, txn := range vs.Unconfirmed.RawTxns() {
+		if h := txn.Hash().Hex(); strings.HasPrefix(h, q) {
+			unconfirmed = append(unconfirmed, h)
+		}
+	}
+	sort.Strings(unconfirmed)
+
+	for i := 0; i < len(unconfirmed) && len(txns)+i < limit; i++ {
+		results = append(results, SearchResult{
+			Type:  SearchResultTransaction,
+			ID:    unconfirmed[i],
+			Exact: exact,
+		})
+	}
+
+	uxs, err := vs.history.SearchUxOuts(q, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ux := range uxs {
+		results = append(results, SearchResult{
+			Type:      SearchResultUxOut,
+			ID:        ux.Out.Hash().Hex(),
+			BlockSeq:  ux.Out.Head.BkSeq,
+			Confirmed: true,
+			Exact:     exact,
+		})
+	}
+
+	return results, nil
+}
+
+// isHashPrefix returns true if s is the hex prefix of a hash, at least MinSearchPrefixLen characters long
+func isHashPrefix(s string) bool {
+	if len(s) < MinSearchPrefixLen || len(s) > len(cipher.SHA256{})*2 {
+		return false
+	}
+
+	_, err := hex.DecodeString(s + strings.Repeat("0", len(s)%2))
+	return err == nil
+}
@@ -0,0 +1,31 @@
+package visor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsHashPrefix(t *testing.T) {
+	cases := []struct {
+		s      string
+		prefix bool
+	}{
+		{"", false},
+		{"abc", false},
+		{"abcd", true},
+		{"ABCDE", true},
+		{"12345", true},
+		{"abcx", false},
+		{strings.Repeat("a", 64), true},
+		{strings.Repeat("a", 65), false},
+		{"2GgFvqoyk9RjwVzj8tqfcXVXB4orBwoc9qv", false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.s, func(t *testing.T) {
+			require.Equal(t, tc.prefix, isHashPrefix(tc.s))
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// Bolt is a Store backed by a boltdb file
+type Bolt struct {
+	db *bolt.DB
+}
+
+// OpenBolt opens or creates the boltdb file at path
+func OpenBolt(path string) (*Bolt, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{
+		Timeout: 500 * time.Millisecond,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Open boltdb failed, %v", err)
+	}
+
+	return NewBolt(db), nil
+}
+
+// NewBolt wraps an opened *bolt.DB
+func NewBolt(db *bolt.DB) *Bolt {
+	return &Bolt{db: db}
+}
+
+// DB returns the underlying *bolt.DB
+func (b *Bolt) DB() *bolt.DB {
+	return b.db
+}
+
+// View runs f in a read only transaction
+func (b *Bolt) View(f func(Tx) error) error {
+	return convertBoltErr(b.db.View(func(tx *bolt.Tx) error {
+		return f(boltTx{tx})
+	}))
+}
+
+// Update runs f in a read-write transaction
+func (b *Bolt) Update(f func(Tx) error) error {
+	return convertBoltErr(b.db.Update(func(tx *bolt.Tx) error {
+		return f(boltTx{tx})
+	}))
+}
+
+// Path returns the db file path
+func (b *Bolt) Path() string {
+	return b.db.Path()
+}
+
+// Close closes the db
+func (b *Bolt) Close() error {
+	return b.db.Close()
+}
+
+// BoltTx wraps a transaction of a *bolt.DB, for the code ported to Tx that runs
+// in a transaction opened by code still written against boltdb
+func BoltTx(tx *bolt.Tx) Tx {
+	return boltTx{tx}
+}
+
+type boltTx struct {
+	tx *bolt.Tx
+}
+
+func (t boltTx) Bucket(name []byte) Bucket {
+	bkt := t.tx.Bucket(name)
+	if bkt == nil {
+		return nil
+	}
+	return boltBucket{bkt}
+}
+
+func (t boltTx) CreateBucketIfNotExists(name []byte) (Bucket, error) {
+	bkt, err := t.tx.CreateBucketIfNotExists(name)
+	if err != nil {
+		return nil, convertBoltErr(err)
+	}
+	return boltBucket{bkt}, nil
+}
+
+func (t boltTx) DeleteBucket(name []byte) error {
+	return convertBoltErr(t.tx.DeleteBucket(name))
+}
+
+func (t boltTx) ForEachBucket(f func(name []byte) error) error {
+	return t.tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+		return f(name)
+	})
+}
+
+// BoltBucket wraps a bucket of a *bolt.Tx, like BoltTx
+func BoltBucket(bkt *bolt.Bucket) Bucket {
+	return boltBucket{bkt}
+}
+
+type boltBucket struct {
+	bkt *bolt.Bucket
+}
+
+func (b boltBucket) Get(key []byte) []byte {
+	return b.bkt.Get(key)
+}
+
+func (b boltBucket) Put(key, value []byte) error {
+	return convertBoltErr(b.bkt.Put(key, value))
+}
+
+func (b boltBucket) Delete(key []byte) error {
+	return convertBoltErr(b.bkt.Delete(key))
+}
+
+func (b boltBucket) ForEach(f func(k, v []byte) error) error {
+	return b.bkt.ForEach(f)
+}
+
+func (b boltBucket) Cursor() Cursor {
+	return b.bkt.Cursor()
+}
+
+// convertBoltErr maps bolt errors to the storage errors
+func convertBoltErr(err error) error {
+	switch err {
+	case bolt.ErrTxNotWritable:
+		return ErrTxNotWritable
+	case bolt.ErrBucketNotFound:
+		return ErrBucketNotFound
+	case bolt.ErrBucketNameRequired:
+		return ErrBucketNameRequired
+	case bolt.ErrKeyRequired:
+		return ErrKeyRequired
+	case bolt.ErrDatabaseNotOpen, bolt.ErrTxClosed:
+		return ErrClosed
+	default:
+		return err
+	}
+}
@@ -0,0 +1,320 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// The buckets of a LevelDB store are key prefixes. The names of the buckets are
+// kept under bucketNamePrefix, and the keys of a bucket are stored under
+// bucketDataPrefix followed by the length and the name of the bucket.
+const (
+	bucketNamePrefix byte = 0
+	bucketDataPrefix byte = 1
+)
+
+// LevelDB is a Store backed by a leveldb database directory
+type LevelDB struct {
+	db   *leveldb.DB
+	path string
+}
+
+// OpenLevelDB opens or creates the leveldb database at path
+func OpenLevelDB(path string) (*LevelDB, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Open leveldb failed, %v", err)
+	}
+
+	return &LevelDB{
+		db:   db,
+		path: path,
+	}, nil
+}
+
+// View runs f on a snapshot of the db
+func (l *LevelDB) View(f func(Tx) error) error {
+	snap, err := l.db.GetSnapshot()
+	if err != nil {
+		return convertLevelDBErr(err)
+	}
+	defer snap.Release()
+
+	tx := &levelTx{r: snap}
+	defer tx.releaseIterators()
+
+	if err := f(tx); err != nil {
+		return err
+	}
+	return tx.err
+}
+
+// Update runs f in a leveldb transaction, which blocks the other writers until it ends
+func (l *LevelDB) Update(f func(Tx) error) error {
+	tr, err := l.db.OpenTransaction()
+	if err != nil {
+		return convertLevelDBErr(err)
+	}
+	defer tr.Discard()
+
+	tx := &levelTx{r: tr, w: tr}
+	defer tx.releaseIterators()
+
+	if err := f(tx); err != nil {
+		return err
+	}
+
+	if tx.err != nil {
+		return tx.err
+	}
+
+	tx.releaseIterators()
+	return convertLevelDBErr(tr.Commit())
+}
+
+// Path returns the db directory path
+func (l *LevelDB) Path() string {
+	return l.path
+}
+
+// Close closes the db
+func (l *LevelDB) Close() error {
+	return convertLevelDBErr(l.db.Close())
+}
+
+// levelReader is implemented by *leveldb.Snapshot and *leveldb.Transaction
+type levelReader interface {
+	Get(key []byte, ro *opt.ReadOptions) ([]byte, error)
+	NewIterator(slice *util.Range, ro *opt.ReadOptions) iterator.Iterator
+}
+
+type levelTx struct {
+	r levelReader
+	w *leveldb.Transaction // nil in read only transactions
+
+	iters []iterator.Iterator
+	// err is the first read error, Get and the cursors can't return it,
+	// so it fails the transaction instead
+	err error
+}
+
+func (tx *levelTx) get(key []byte) []byte {
+	v, err := tx.r.Get(key, nil)
+	switch err {
+	case nil:
+		return copyBytes(v)
+	case leveldb.ErrNotFound:
+		return nil
+	default:
+		tx.setErr(err)
+		return nil
+	}
+}
+
+func (tx *levelTx) setErr(err error) {
+	if tx.err == nil {
+		tx.err = convertLevelDBErr(err)
+	}
+}
+
+func (tx *levelTx) iterator(prefix []byte) iterator.Iterator {
+	it := tx.r.NewIterator(util.BytesPrefix(prefix), nil)
+	tx.iters = append(tx.iters, it)
+	return it
+}
+
+func (tx *levelTx) releaseIterators() {
+	for _, it := range tx.iters {
+		if err := it.Error(); err != nil {
+			tx.setErr(err)
+		}
+		it.Release()
+	}
+	tx.iters = nil
+}
+
+func (tx *levelTx) Bucket(name []byte) Bucket {
+	if len(name) == 0 || tx.get(bucketNameKey(name)) == nil {
+		return nil
+	}
+	return &levelBucket{tx: tx, name: copyBytes(name), prefix: bucketKeyPrefix(name)}
+}
+
+func (tx *levelTx) CreateBucketIfNotExists(name []byte) (Bucket, error) {
+	if tx.w == nil {
+		return nil, ErrTxNotWritable
+	}
+
+	if len(name) == 0 {
+		return nil, ErrBucketNameRequired
+	}
+
+	if tx.get(bucketNameKey(name)) == nil {
+		if err := tx.w.Put(bucketNameKey(name), []byte{}, nil); err != nil {
+			return nil, convertLevelDBErr(err)
+		}
+	}
+
+	return tx.Bucket(name), nil
+}
+
+func (tx *levelTx) DeleteBucket(name []byte) error {
+	if tx.w == nil {
+		return ErrTxNotWritable
+	}
+
+	if tx.Bucket(name) == nil {
+		return ErrBucketNotFound
+	}
+
+	// collect the keys first, the iterator must not see its own deletes
+	var keys [][]byte
+	it := tx.iterator(bucketKeyPrefix(name))
+	for it.Next() {
+		keys = append(keys, copyBytes(it.Key()))
+	}
+	if err := it.Error(); err != nil {
+		return convertLevelDBErr(err)
+	}
+
+	for _, k := range keys {
+		if err := tx.w.Delete(k, nil); err != nil {
+			return convertLevelDBErr(err)
+		}
+	}
+
+	return convertLevelDBErr(tx.w.Delete(bucketNameKey(name), nil))
+}
+
+func (tx *levelTx) ForEachBucket(f func(name []byte) error) error {
+	it := tx.iterator([]byte{bucketNamePrefix})
+	for it.Next() {
+		if err := f(copyBytes(it.Key()[1:])); err != nil {
+			return err
+		}
+	}
+	return convertLevelDBErr(it.Error())
+}
+
+type levelBucket struct {
+	tx     *levelTx
+	name   []byte
+	prefix []byte
+}
+
+func (b *levelBucket) key(k []byte) []byte {
+	return append(copyBytes(b.prefix), k...)
+}
+
+func (b *levelBucket) Get(key []byte) []byte {
+	return b.tx.get(b.key(key))
+}
+
+func (b *levelBucket) Put(key, value []byte) error {
+	if b.tx.w == nil {
+		return ErrTxNotWritable
+	}
+
+	if len(key) == 0 {
+		return ErrKeyRequired
+	}
+
+	if b.tx.get(bucketNameKey(b.name)) == nil {
+		return ErrBucketNotFound
+	}
+
+	return convertLevelDBErr(b.tx.w.Put(b.key(key), value, nil))
+}
+
+func (b *levelBucket) Delete(key []byte) error {
+	if b.tx.w == nil {
+		return ErrTxNotWritable
+	}
+
+	if b.tx.get(bucketNameKey(b.name)) == nil {
+		return ErrBucketNotFound
+	}
+
+	return convertLevelDBErr(b.tx.w.Delete(b.key(key), nil))
+}
+
+func (b *levelBucket) ForEach(f func(k, v []byte) error) error {
+	c := &levelCursor{
+		it:     b.tx.iterator(b.prefix),
+		prefix: b.prefix,
+	}
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		if err := f(k, v); err != nil {
+			return err
+		}
+	}
+	return convertLevelDBErr(c.it.Error())
+}
+
+func (b *levelBucket) Cursor() Cursor {
+	return &levelCursor{
+		it:     b.tx.iterator(b.prefix),
+		prefix: b.prefix,
+	}
+}
+
+// levelCursor is a Cursor over the keys of one bucket
+type levelCursor struct {
+	it     iterator.Iterator
+	prefix []byte
+}
+
+func (c *levelCursor) current(ok bool) ([]byte, []byte) {
+	if !ok {
+		return nil, nil
+	}
+	return copyBytes(c.it.Key()[len(c.prefix):]), copyBytes(c.it.Value())
+}
+
+func (c *levelCursor) First() ([]byte, []byte) {
+	return c.current(c.it.First())
+}
+
+func (c *levelCursor) Last() ([]byte, []byte) {
+	return c.current(c.it.Last())
+}
+
+func (c *levelCursor) Next() ([]byte, []byte) {
+	return c.current(c.it.Next())
+}
+
+func (c *levelCursor) Prev() ([]byte, []byte) {
+	return c.current(c.it.Prev())
+}
+
+func (c *levelCursor) Seek(seek []byte) ([]byte, []byte) {
+	return c.current(c.it.Seek(append(copyBytes(c.prefix), seek...)))
+}
+
+func bucketNameKey(name []byte) []byte {
+	return append([]byte{bucketNamePrefix}, name...)
+}
+
+// bucketKeyPrefix returns the prefix of the keys of a bucket, the name length
+// keeps the key ranges of two buckets from overlapping when one name is a prefix of the other
+func bucketKeyPrefix(name []byte) []byte {
+	p := make([]byte, 1+binary.MaxVarintLen64, 1+binary.MaxVarintLen64+len(name))
+	p[0] = bucketDataPrefix
+	n := binary.PutUvarint(p[1:], uint64(len(name)))
+	return append(p[:1+n], name...)
+}
+
+// convertLevelDBErr maps leveldb errors to the storage errors
+func convertLevelDBErr(err error) error {
+	switch err {
+	case leveldb.ErrClosed, leveldb.ErrSnapshotReleased, leveldb.ErrIterReleased:
+		return ErrClosed
+	default:
+		return err
+	}
+}
@@ -0,0 +1,318 @@
+package storage
+
+import (
+	"sort"
+	"sync"
+)
+
+// Memory is a Store that keeps all data in memory, it is meant for tests.
+// Update transactions are serialized, and copy the buckets they write to,
+// so that readers and failed updates never see partial writes.
+type Memory struct {
+	mu      sync.RWMutex // protects buckets and closed
+	writeMu sync.Mutex   // serializes updates
+	buckets map[string]*memBucket
+	closed  bool
+}
+
+// NewMemory creates an empty in-memory store
+func NewMemory() *Memory {
+	return &Memory{
+		buckets: make(map[string]*memBucket),
+	}
+}
+
+// View runs f in a read only transaction
+func (m *Memory) View(f func(Tx) error) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+
+	return f(&memTx{buckets: m.buckets})
+}
+
+// Update runs f in a read-write transaction
+func (m *Memory) Update(f func(Tx) error) error {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+
+	m.mu.RLock()
+	closed := m.closed
+	buckets := make(map[string]*memBucket, len(m.buckets))
+	for k, v := range m.buckets {
+		buckets[k] = v
+	}
+	m.mu.RUnlock()
+
+	if closed {
+		return ErrClosed
+	}
+
+	tx := &memTx{
+		writable: true,
+		buckets:  buckets,
+		copied:   make(map[string]bool),
+	}
+
+	if err := f(tx); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.buckets = tx.buckets
+	m.mu.Unlock()
+	return nil
+}
+
+// Path returns an empty string, the store has no file
+func (m *Memory) Path() string {
+	return ""
+}
+
+// Close closes the store
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	return nil
+}
+
+type memTx struct {
+	writable bool
+	buckets  map[string]*memBucket
+	copied   map[string]bool // buckets already copied by this tx
+}
+
+func (tx *memTx) Bucket(name []byte) Bucket {
+	bkt, ok := tx.buckets[string(name)]
+	if !ok {
+		return nil
+	}
+	return &memTxBucket{tx: tx, name: string(name), bkt: bkt}
+}
+
+func (tx *memTx) CreateBucketIfNotExists(name []byte) (Bucket, error) {
+	if !tx.writable {
+		return nil, ErrTxNotWritable
+	}
+
+	if len(name) == 0 {
+		return nil, ErrBucketNameRequired
+	}
+
+	if _, ok := tx.buckets[string(name)]; !ok {
+		tx.buckets[string(name)] = newMemBucket()
+		tx.copied[string(name)] = true
+	}
+
+	return tx.Bucket(name), nil
+}
+
+func (tx *memTx) DeleteBucket(name []byte) error {
+	if !tx.writable {
+		return ErrTxNotWritable
+	}
+
+	if _, ok := tx.buckets[string(name)]; !ok {
+		return ErrBucketNotFound
+	}
+
+	delete(tx.buckets, string(name))
+	delete(tx.copied, string(name))
+	return nil
+}
+
+func (tx *memTx) ForEachBucket(f func(name []byte) error) error {
+	names := make([]string, 0, len(tx.buckets))
+	for name := range tx.buckets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := f([]byte(name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writableBucket returns the bucket of given name, copying it first
+// if this tx has not written to it yet
+func (tx *memTx) writableBucket(name string) *memBucket {
+	bkt := tx.buckets[name]
+	if !tx.copied[name] {
+		bkt = bkt.clone()
+		tx.buckets[name] = bkt
+		tx.copied[name] = true
+	}
+	return bkt
+}
+
+// memTxBucket is a bucket accessed through a transaction
+type memTxBucket struct {
+	tx   *memTx
+	name string
+	bkt  *memBucket
+}
+
+func (b *memTxBucket) current() *memBucket {
+	// the bucket may have been copied by a write after this handle was created
+	if bkt, ok := b.tx.buckets[b.name]; ok {
+		return bkt
+	}
+	return b.bkt
+}
+
+func (b *memTxBucket) Get(key []byte) []byte {
+	return b.current().get(key)
+}
+
+func (b *memTxBucket) Put(key, value []byte) error {
+	if !b.tx.writable {
+		return ErrTxNotWritable
+	}
+
+	if len(key) == 0 {
+		return ErrKeyRequired
+	}
+
+	if _, ok := b.tx.buckets[b.name]; !ok {
+		return ErrBucketNotFound
+	}
+
+	b.tx.writableBucket(b.name).put(copyBytes(key), copyBytes(value))
+	return nil
+}
+
+func (b *memTxBucket) Delete(key []byte) error {
+	if !b.tx.writable {
+		return ErrTxNotWritable
+	}
+
+	if _, ok := b.tx.buckets[b.name]; !ok {
+		return ErrBucketNotFound
+	}
+
+	if !b.current().has(key) {
+		return nil
+	}
+
+	b.tx.writableBucket(b.name).delete(key)
+	return nil
+}
+
+func (b *memTxBucket) ForEach(f func(k, v []byte) error) error {
+	bkt := b.current()
+	for _, k := range bkt.keys {
+		if err := f([]byte(k), bkt.vals[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *memTxBucket) Cursor() Cursor {
+	return &memCursor{bkt: b.current(), i: -1}
+}
+
+// memBucket holds the sorted keys and the values of a bucket
+type memBucket struct {
+	keys []string
+	vals map[string][]byte
+}
+
+func newMemBucket() *memBucket {
+	return &memBucket{
+		vals: make(map[string][]byte),
+	}
+}
+
+func (b *memBucket) clone() *memBucket {
+	c := &memBucket{
+		keys: make([]string, len(b.keys)),
+		vals: make(map[string][]byte, len(b.vals)),
+	}
+	copy(c.keys, b.keys)
+	for k, v := range b.vals {
+		c.vals[k] = v
+	}
+	return c
+}
+
+func (b *memBucket) get(key []byte) []byte {
+	return b.vals[string(key)]
+}
+
+func (b *memBucket) has(key []byte) bool {
+	_, ok := b.vals[string(key)]
+	return ok
+}
+
+func (b *memBucket) put(key, value []byte) {
+	k := string(key)
+	if _, ok := b.vals[k]; !ok {
+		i := sort.SearchStrings(b.keys, k)
+		b.keys = append(b.keys, "")
+		copy(b.keys[i+1:], b.keys[i:])
+		b.keys[i] = k
+	}
+	b.vals[k] = value
+}
+
+func (b *memBucket) delete(key []byte) {
+	k := string(key)
+	if _, ok := b.vals[k]; !ok {
+		return
+	}
+	delete(b.vals, k)
+	i := sort.SearchStrings(b.keys, k)
+	b.keys = append(b.keys[:i], b.keys[i+1:]...)
+}
+
+// memCursor iterates a snapshot of a memBucket
+type memCursor struct {
+	bkt *memBucket
+	i   int
+}
+
+func (c *memCursor) at(i int) ([]byte, []byte) {
+	if i < 0 || i >= len(c.bkt.keys) {
+		if i < 0 {
+			c.i = -1
+		} else {
+			c.i = len(c.bkt.keys)
+		}
+		return nil, nil
+	}
+	c.i = i
+	k := c.bkt.keys[i]
+	return []byte(k), c.bkt.vals[k]
+}
+
+func (c *memCursor) First() ([]byte, []byte) {
+	return c.at(0)
+}
+
+func (c *memCursor) Last() ([]byte, []byte) {
+	return c.at(len(c.bkt.keys) - 1)
+}
+
+func (c *memCursor) Next() ([]byte, []byte) {
+	return c.at(c.i + 1)
+}
+
+func (c *memCursor) Prev() ([]byte, []byte) {
+	return c.at(c.i - 1)
+}
+
+func (c *memCursor) Seek(seek []byte) ([]byte, []byte) {
+	return c.at(sort.SearchStrings(c.bkt.keys, string(seek)))
+}
+
+func copyBytes(b []byte) []byte {
+	return append([]byte{}, b...)
+}
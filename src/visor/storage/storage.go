@@ -0,0 +1,98 @@
+// Package storage defines an ordered key-value store abstraction for the blockchain
+// databases, with a boltdb implementation, a leveldb implementation, and an in-memory
+// implementation for tests.
+package storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Engine names a storage engine
+type Engine string
+
+const (
+	// EngineBolt stores the data in a boltdb file, it is the default engine
+	EngineBolt Engine = "bolt"
+	// EngineLevelDB stores the data in a leveldb database directory
+	EngineLevelDB Engine = "leveldb"
+	// EngineMemory stores the data in memory only
+	EngineMemory Engine = "memory"
+)
+
+var (
+	// ErrTxNotWritable is returned when writing in a read only transaction
+	ErrTxNotWritable = errors.New("tx not writable")
+	// ErrBucketNotFound is returned when deleting a bucket that does not exist
+	ErrBucketNotFound = errors.New("bucket not found")
+	// ErrBucketNameRequired is returned when creating a bucket with an empty name
+	ErrBucketNameRequired = errors.New("bucket name required")
+	// ErrKeyRequired is returned when putting an empty key
+	ErrKeyRequired = errors.New("key required")
+	// ErrClosed is returned when using a closed store
+	ErrClosed = errors.New("store closed")
+)
+
+// Store is a key-value store organized in buckets, with keys iterated in byte order.
+// All writes done in an Update are applied atomically, or not at all if it returns an error.
+type Store interface {
+	// View runs f in a read only transaction
+	View(f func(Tx) error) error
+	// Update runs f in a read-write transaction, the changes are discarded if f returns an error
+	Update(f func(Tx) error) error
+	// Path returns the file path of the store, empty for in-memory stores
+	Path() string
+	// Close closes the store
+	Close() error
+}
+
+// Tx is a store transaction, the values returned by a Tx are only valid until the transaction ends
+type Tx interface {
+	// Bucket returns the bucket of given name, or nil if it does not exist
+	Bucket(name []byte) Bucket
+	// CreateBucketIfNotExists creates the bucket if it does not exist and returns it
+	CreateBucketIfNotExists(name []byte) (Bucket, error)
+	// DeleteBucket deletes the bucket and all its keys
+	DeleteBucket(name []byte) error
+	// ForEachBucket calls f for the name of each bucket, in byte order
+	ForEachBucket(f func(name []byte) error) error
+}
+
+// Bucket is a collection of key-value pairs
+type Bucket interface {
+	// Get returns the value of key, or nil if it does not exist
+	Get(key []byte) []byte
+	// Put sets the value of key
+	Put(key, value []byte) error
+	// Delete removes key, deleting a missing key is not an error
+	Delete(key []byte) error
+	// ForEach calls f for each key-value pair in key order
+	ForEach(f func(k, v []byte) error) error
+	// Cursor returns a cursor for iterating the bucket in key order
+	Cursor() Cursor
+}
+
+// Cursor iterates a bucket in key order. All methods return a nil key
+// once the cursor moves past either end of the bucket.
+type Cursor interface {
+	First() (key, value []byte)
+	Last() (key, value []byte)
+	Next() (key, value []byte)
+	Prev() (key, value []byte)
+	// Seek moves the cursor to the first key that is greater than or equal to seek
+	Seek(seek []byte) (key, value []byte)
+}
+
+// Open opens the store of given engine at path, path is ignored by EngineMemory
+func Open(engine Engine, path string) (Store, error) {
+	switch engine {
+	case EngineBolt, "":
+		return OpenBolt(path)
+	case EngineLevelDB:
+		return OpenLevelDB(path)
+	case EngineMemory:
+		return NewMemory(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage engine %q", engine)
+	}
+}
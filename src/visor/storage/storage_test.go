@@ -0,0 +1,278 @@
+package storage_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/testutil"
+	"github.com/skycoin/skycoin/src/visor/storage"
+)
+
+func putAll(t *testing.T, s storage.Store, bkt string, kvs map[string]string) {
+	err := s.Update(func(tx storage.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bkt))
+		if err != nil {
+			return err
+		}
+		for k, v := range kvs {
+			if err := b.Put([]byte(k), []byte(v)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func get(t *testing.T, s storage.Store, bkt, k string) []byte {
+	var v []byte
+	err := s.View(func(tx storage.Tx) error {
+		b := tx.Bucket([]byte(bkt))
+		if b == nil {
+			return nil
+		}
+		if val := b.Get([]byte(k)); val != nil {
+			v = append([]byte{}, val...)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	return v
+}
+
+func TestStorePutGetDelete(t *testing.T) {
+	testutil.ForEachStore(t, func(t *testing.T, s storage.Store) {
+		putAll(t, s, "b1", map[string]string{"k1": "v1", "k2": "v2"})
+		require.Equal(t, []byte("v1"), get(t, s, "b1", "k1"))
+		require.Equal(t, []byte("v2"), get(t, s, "b1", "k2"))
+		require.Nil(t, get(t, s, "b1", "k3"))
+		require.Nil(t, get(t, s, "b2", "k1"))
+
+		err := s.Update(func(tx storage.Tx) error {
+			return tx.Bucket([]byte("b1")).Delete([]byte("k1"))
+		})
+		require.NoError(t, err)
+		require.Nil(t, get(t, s, "b1", "k1"))
+
+		// deleting a missing key is not an error
+		err = s.Update(func(tx storage.Tx) error {
+			return tx.Bucket([]byte("b1")).Delete([]byte("k1"))
+		})
+		require.NoError(t, err)
+
+		err = s.Update(func(tx storage.Tx) error {
+			return tx.Bucket([]byte("b1")).Put(nil, []byte("v"))
+		})
+		require.Equal(t, storage.ErrKeyRequired, err)
+	})
+}
+
+func TestStoreReadOnlyTx(t *testing.T) {
+	testutil.ForEachStore(t, func(t *testing.T, s storage.Store) {
+		putAll(t, s, "b1", map[string]string{"k1": "v1"})
+
+		err := s.View(func(tx storage.Tx) error {
+			return tx.Bucket([]byte("b1")).Put([]byte("k2"), []byte("v2"))
+		})
+		require.Equal(t, storage.ErrTxNotWritable, err)
+
+		err = s.View(func(tx storage.Tx) error {
+			_, err := tx.CreateBucketIfNotExists([]byte("b2"))
+			return err
+		})
+		require.Equal(t, storage.ErrTxNotWritable, err)
+	})
+}
+
+func TestStoreUpdateIsAtomic(t *testing.T) {
+	testutil.ForEachStore(t, func(t *testing.T, s storage.Store) {
+		putAll(t, s, "b1", map[string]string{"k1": "v1"})
+
+		err := s.Update(func(tx storage.Tx) error {
+			b := tx.Bucket([]byte("b1"))
+			if err := b.Put([]byte("k1"), []byte("changed")); err != nil {
+				return err
+			}
+			if err := b.Put([]byte("k2"), []byte("v2")); err != nil {
+				return err
+			}
+			if _, err := tx.CreateBucketIfNotExists([]byte("b2")); err != nil {
+				return err
+			}
+			// the writes are visible inside the tx
+			require.Equal(t, []byte("changed"), b.Get([]byte("k1")))
+			return errors.New("abort")
+		})
+		require.EqualError(t, err, "abort")
+
+		require.Equal(t, []byte("v1"), get(t, s, "b1", "k1"))
+		require.Nil(t, get(t, s, "b1", "k2"))
+
+		err = s.View(func(tx storage.Tx) error {
+			require.Nil(t, tx.Bucket([]byte("b2")))
+			return nil
+		})
+		require.NoError(t, err)
+	})
+}
+
+func TestStoreOrderedIteration(t *testing.T) {
+	testutil.ForEachStore(t, func(t *testing.T, s storage.Store) {
+		kvs := map[string]string{}
+		for i := 9; i >= 0; i-- {
+			kvs[fmt.Sprintf("k%02d", i*2)] = fmt.Sprintf("v%d", i)
+		}
+		putAll(t, s, "b1", kvs)
+
+		err := s.View(func(tx storage.Tx) error {
+			b := tx.Bucket([]byte("b1"))
+
+			var keys []string
+			require.NoError(t, b.ForEach(func(k, v []byte) error {
+				keys = append(keys, string(k))
+				return nil
+			}))
+			require.Equal(t, []string{"k00", "k02", "k04", "k06", "k08", "k10", "k12", "k14", "k16", "k18"}, keys)
+
+			c := b.Cursor()
+			k, v := c.First()
+			require.Equal(t, "k00", string(k))
+			require.Equal(t, "v0", string(v))
+
+			k, _ = c.Next()
+			require.Equal(t, "k02", string(k))
+
+			k, _ = c.Seek([]byte("k05"))
+			require.Equal(t, "k06", string(k))
+
+			k, _ = c.Prev()
+			require.Equal(t, "k04", string(k))
+
+			k, _ = c.Seek([]byte("k10"))
+			require.Equal(t, "k10", string(k))
+
+			k, _ = c.Last()
+			require.Equal(t, "k18", string(k))
+
+			k, _ = c.Next()
+			require.Nil(t, k)
+
+			k, _ = c.Seek([]byte("k99"))
+			require.Nil(t, k)
+
+			return nil
+		})
+		require.NoError(t, err)
+	})
+}
+
+func TestStoreDeleteBucket(t *testing.T) {
+	testutil.ForEachStore(t, func(t *testing.T, s storage.Store) {
+		putAll(t, s, "b1", map[string]string{"k1": "v1"})
+		putAll(t, s, "b2", map[string]string{"k1": "v1"})
+
+		err := s.Update(func(tx storage.Tx) error {
+			return tx.DeleteBucket([]byte("b1"))
+		})
+		require.NoError(t, err)
+
+		err = s.Update(func(tx storage.Tx) error {
+			return tx.DeleteBucket([]byte("b1"))
+		})
+		require.Equal(t, storage.ErrBucketNotFound, err)
+
+		var names []string
+		err = s.View(func(tx storage.Tx) error {
+			return tx.ForEachBucket(func(name []byte) error {
+				names = append(names, string(name))
+				return nil
+			})
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"b2"}, names)
+	})
+}
+
+func TestStoreBucketNamePrefix(t *testing.T) {
+	testutil.ForEachStore(t, func(t *testing.T, s storage.Store) {
+		putAll(t, s, "b", map[string]string{"k1": "v1"})
+		putAll(t, s, "bb", map[string]string{"k1": "v2", "k2": "v3"})
+
+		err := s.View(func(tx storage.Tx) error {
+			var keys []string
+			require.NoError(t, tx.Bucket([]byte("b")).ForEach(func(k, v []byte) error {
+				keys = append(keys, string(k))
+				return nil
+			}))
+			require.Equal(t, []string{"k1"}, keys)
+
+			k, _ := tx.Bucket([]byte("b")).Cursor().Last()
+			require.Equal(t, "k1", string(k))
+			return nil
+		})
+		require.NoError(t, err)
+
+		err = s.Update(func(tx storage.Tx) error {
+			return tx.DeleteBucket([]byte("b"))
+		})
+		require.NoError(t, err)
+		require.Equal(t, []byte("v2"), get(t, s, "bb", "k1"))
+		require.Equal(t, []byte("v3"), get(t, s, "bb", "k2"))
+	})
+}
+
+func TestStorePersistence(t *testing.T) {
+	for _, engine := range testutil.StorageEngines {
+		if engine == storage.EngineMemory {
+			continue
+		}
+
+		t.Run(string(engine), func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "storage")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "data.db")
+
+			s, err := storage.Open(engine, path)
+			require.NoError(t, err)
+			putAll(t, s, "b1", map[string]string{"k1": "v1", "k2": "v2"})
+			err = s.Update(func(tx storage.Tx) error {
+				return tx.Bucket([]byte("b1")).Delete([]byte("k2"))
+			})
+			require.NoError(t, err)
+			require.NoError(t, s.Close())
+
+			s, err = storage.Open(engine, path)
+			require.NoError(t, err)
+			defer s.Close()
+			require.Equal(t, []byte("v1"), get(t, s, "b1", "k1"))
+			require.Nil(t, get(t, s, "b1", "k2"))
+		})
+	}
+}
+
+func TestStoreClosed(t *testing.T) {
+	testutil.ForEachStore(t, func(t *testing.T, s storage.Store) {
+		require.NoError(t, s.Close())
+
+		err := s.View(func(tx storage.Tx) error {
+			return nil
+		})
+		require.Equal(t, storage.ErrClosed, err)
+	})
+}
+
+func TestOpen(t *testing.T) {
+	_, err := storage.Open("foo", "")
+	require.EqualError(t, err, `unknown storage engine "foo"`)
+
+	s, err := storage.Open(storage.EngineMemory, "")
+	require.NoError(t, err)
+	require.IsType(t, &storage.Memory{}, s)
+}
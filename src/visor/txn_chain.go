@@ -0,0 +1,284 @@
+package visor
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/util/fee"
+	"github.com/skycoin/skycoin/src/visor/blockdb"
+)
+
+// txnOutputHashes returns the hashes of the outputs created by the transaction.
+// The hash of an output doesn't depend on the block which confirms it, unless it is
+// the genesis block, whose outputs have no source transaction.
+func txnOutputHashes(txn coin.Transaction) []cipher.SHA256 {
+	uxs := coin.CreateUnspents(coin.BlockHeader{BkSeq: 1}, txn)
+	hashes := make([]cipher.SHA256, len(uxs))
+	for i := range uxs {
+		hashes[i] = uxs[i].Hash()
+	}
+	return hashes
+}
+
+// sortTxnsByDependency orders the transactions so that a transaction spending the output
+// of another one comes after it, otherwise the original order is kept
+func sortTxnsByDependency(txns coin.Transactions) coin.Transactions {
+	creators := make(map[cipher.SHA256]int)
+	for i := range txns {
+		for _, h := range txnOutputHashes(txns[i]) {
+			creators[h] = i
+		}
+	}
+
+	sorted := make(coin.Transactions, 0, len(txns))
+	visited := make([]bool, len(txns))
+	var visit func(i int)
+	visit = func(i int) {
+		if visited[i] {
+			return
+		}
+		visited[i] = true
+
+		for _, in := range txns[i].In {
+			if j, ok := creators[in]; ok {
+				visit(j)
+			}
+		}
+		sorted = append(sorted, txns[i])
+	}
+
+	for i := range txns {
+		visit(i)
+	}
+
+	return sorted
+}
+
+// dropChildren removes the transactions spending an output created by another transaction
+// of txns. They can't be in the same block as their parent before blockdb.ChainedSpendsForkSeq,
+// and stay unconfirmed until their parent is confirmed.
+func dropChildren(txns coin.Transactions) coin.Transactions {
+	created := make(map[cipher.SHA256]struct{})
+	for i := range txns {
+		for _, h := range txnOutputHashes(txns[i]) {
+			created[h] = struct{}{}
+		}
+	}
+
+	kept := make(coin.Transactions, 0, len(txns))
+	for _, txn := range txns {
+		child := false
+		for _, in := range txn.In {
+			if _, ok := created[in]; ok {
+				child = true
+				break
+			}
+		}
+
+		if !child {
+			kept = append(kept, txn)
+		}
+	}
+
+	return kept
+}
+
+// chainedUnspents resolves outputs from the unspent pool and from the outputs created
+// by unconfirmed transactions, so that unconfirmed outputs can be spent
+type chainedUnspents struct {
+	unspent blockdb.UnspentPool
+	head    coin.BlockHeader
+	// outputs created by the unconfirmed transactions
+	created map[cipher.SHA256]coin.UxOut
+	// outputs spent by the unconfirmed transactions, with the hash of the transaction spending them
+	spent map[cipher.SHA256]cipher.SHA256
+}
+
+func newChainedUnspents(unspent blockdb.UnspentPool, head coin.BlockHeader) *chainedUnspents {
+	return &chainedUnspents{
+		unspent: unspent,
+		head:    head,
+		created: make(map[cipher.SHA256]coin.UxOut),
+		spent:   make(map[cipher.SHA256]cipher.SHA256),
+	}
+}
+
+// addTxn marks the inputs of the transaction as spent and makes its outputs spendable.
+// The outputs are created as if the transaction was confirmed by the next block at the
+// head block time.
+func (cu *chainedUnspents) addTxn(txn coin.Transaction) {
+	txnHash := txn.Hash()
+	for _, in := range txn.In {
+		cu.spent[in] = txnHash
+	}
+
+	bh := coin.BlockHeader{
+		BkSeq: cu.head.BkSeq + 1,
+		Time:  cu.head.Time,
+	}
+	for _, ux := range coin.CreateUnspents(bh, txn) {
+		cu.created[ux.Hash()] = ux
+	}
+}
+
+// isCreated returns true if the output is created by an unconfirmed transaction
+func (cu *chainedUnspents) isCreated(h cipher.SHA256) bool {
+	_, ok := cu.created[h]
+	return ok
+}
+
+// spendsCreated returns true if the transaction spends an output created by an unconfirmed transaction
+func (cu *chainedUnspents) spendsCreated(txn coin.Transaction) bool {
+	for _, in := range txn.In {
+		if cu.isCreated(in) {
+			return true
+		}
+	}
+	return false
+}
+
+// Get returns an unspent output, outputs spent by unconfirmed transactions are not returned
+func (cu *chainedUnspents) Get(h cipher.SHA256) (coin.UxOut, bool) {
+	if _, ok := cu.spent[h]; ok {
+		return coin.UxOut{}, false
+	}
+
+	if ux, ok := cu.created[h]; ok {
+		return ux, true
+	}
+
+	return cu.unspent.Get(h)
+}
+
+// GetArray returns the unspent outputs of given hashes, returns an error if one doesn't
+// exist or is spent by an unconfirmed transaction
+func (cu *chainedUnspents) GetArray(hashes []cipher.SHA256) (coin.UxArray, error) {
+	return cu.getArray(hashes, cipher.SHA256{})
+}
+
+// getArray is GetArray where the outputs spent by the transaction spender are returned,
+// for a transaction already added with addTxn. Pass a null hash to reject all spent outputs.
+func (cu *chainedUnspents) getArray(hashes []cipher.SHA256, spender cipher.SHA256) (coin.UxArray, error) {
+	uxs := make(coin.UxArray, 0, len(hashes))
+	for _, h := range hashes {
+		if spentBy, ok := cu.spent[h]; ok && spentBy != spender {
+			return nil, fmt.Errorf("unspent output of %s is spent by unconfirmed transaction %s", h.Hex(), spentBy.Hex())
+		}
+
+		ux, ok := cu.created[h]
+		if !ok {
+			ux, ok = cu.unspent.Get(h)
+		}
+		if !ok {
+			return nil, fmt.Errorf("unspent output of %s does not exist", h.Hex())
+		}
+		uxs = append(uxs, ux)
+	}
+	return uxs, nil
+}
+
+// GetUnspentsOfAddrs returns the unspent outputs of the addresses, including the outputs
+// created by unconfirmed transactions and excluding the ones they spend
+func (cu *chainedUnspents) GetUnspentsOfAddrs(addrs []cipher.Address) coin.AddressUxOuts {
+	uxo := cu.unspent.GetUnspentsOfAddrs(addrs)
+
+	wanted := make(map[cipher.Address]struct{}, len(addrs))
+	for _, a := range addrs {
+		wanted[a] = struct{}{}
+	}
+
+	res := make(coin.AddressUxOuts, len(addrs))
+	for _, a := range addrs {
+		for _, ux := range uxo[a] {
+			if _, ok := cu.spent[ux.Hash()]; !ok {
+				res[a] = append(res[a], ux)
+			}
+		}
+	}
+
+	for h, ux := range cu.created {
+		if _, ok := wanted[ux.Body.Address]; !ok {
+			continue
+		}
+		if _, ok := cu.spent[h]; ok {
+			continue
+		}
+		res[ux.Body.Address] = append(res[ux.Body.Address], ux)
+	}
+
+	return res
+}
+
+// TransactionFee calculates the fee of a transaction which may spend unconfirmed outputs,
+// the transaction may have been added with addTxn
+func (cu *chainedUnspents) TransactionFee(txn *coin.Transaction) (uint64, error) {
+	inUxs, err := cu.getArray(txn.In, txn.Hash())
+	if err != nil {
+		return 0, err
+	}
+
+	return fee.TransactionFee(txn, cu.head.Time, inUxs)
+}
+
+// unconfirmedUnspents returns the unspent outputs with the outputs created and spent
+// by the unconfirmed transactions applied
+func (vs *Visor) unconfirmedUnspents() (*chainedUnspents, error) {
+	head, err := vs.Blockchain.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	cu := newChainedUnspents(vs.Blockchain.Unspent(), head.Head)
+	for _, txn := range sortTxnsByDependency(vs.Unconfirmed.RawTxns()) {
+		cu.addTxn(txn)
+	}
+
+	return cu, nil
+}
+
+// verifyChainedTxn verifies a transaction against the unspent outputs, where the
+// outputs of unconfirmed transactions already added to cu are spendable
+func (vs *Visor) verifyChainedTxn(txn coin.Transaction, cu *chainedUnspents) error {
+	if !cu.spendsCreated(txn) {
+		return vs.Blockchain.VerifyTransaction(txn)
+	}
+
+	if err := txn.Verify(); err != nil {
+		return err
+	}
+
+	uxIn, err := cu.GetArray(txn.In)
+	if err != nil {
+		return err
+	}
+
+	if err := txn.VerifyInput(uxIn); err != nil {
+		return err
+	}
+
+	uxOut := coin.CreateUnspents(cu.head, txn)
+	if uxOut.HasDupes() {
+		return errors.New("Duplicate unspent outputs in transaction")
+	}
+
+	for i := range uxOut {
+		if _, ok := cu.Get(uxOut[i].Hash()); ok {
+			return errors.New("New unspent collides with existing unspent")
+		}
+	}
+
+	return coin.VerifyTransactionSpending(cu.head.Time, uxIn, uxOut)
+}
+
+// SpendableUnspents returns the outputs a wallet may spend. When chained transactions
+// are allowed, this includes the outputs created by unconfirmed transactions and
+// excludes the outputs they spend.
+func (vs *Visor) SpendableUnspents() (blockdb.UnspentGetter, error) {
+	if vs.Mempool == nil || vs.Mempool.Policy().MaxChainDepth == 0 {
+		return vs.Blockchain.Unspent(), nil
+	}
+
+	return vs.unconfirmedUnspents()
+}
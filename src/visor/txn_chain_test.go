@@ -0,0 +1,58 @@
+package visor
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/testutil"
+)
+
+func TestChainedUnspentsGetArray(t *testing.T) {
+	db, closeDB := testutil.PrepareDB(t)
+	defer closeDB()
+
+	bc := makeReindexChain(t, db, 1, 0)
+	head, err := bc.Head()
+	require.NoError(t, err)
+	uxs, err := bc.Unspent().GetAll()
+	require.NoError(t, err)
+	ux := uxs[0]
+
+	parent := makeMempoolTxn(t, ux.Hash())
+	child := makeMempoolTxn(t, txnOutputHashes(parent)[0])
+
+	cu := newChainedUnspents(bc.Unspent(), head.Head)
+	cu.addTxn(parent)
+
+	// The output of the parent is spendable
+	inUxs, err := cu.GetArray(child.In)
+	require.NoError(t, err)
+	require.Len(t, inUxs, 1)
+	require.Equal(t, txnOutputHashes(parent)[0], inUxs[0].Hash())
+
+	// The output spent by the parent is not
+	_, err = cu.GetArray(parent.In)
+	testutil.RequireError(t, err, fmt.Sprintf("unspent output of %s is spent by unconfirmed transaction %s",
+		ux.Hash().Hex(), parent.Hash().Hex()))
+
+	// The fee of the parent is calculated from the outputs it spends
+	_, err = cu.TransactionFee(&parent)
+	require.NoError(t, err)
+}
+
+func TestDropChildren(t *testing.T) {
+	parent := makeMempoolTxn(t, testutil.RandSHA256(t))
+	child := makeMempoolTxn(t, txnOutputHashes(parent)[0])
+	grandchild := makeMempoolTxn(t, txnOutputHashes(child)[0])
+	other := makeMempoolTxn(t, testutil.RandSHA256(t))
+
+	kept := dropChildren(coin.Transactions{grandchild, parent, other, child})
+	require.Equal(t, coin.Transactions{parent, other}, kept)
+
+	// A child whose parent is not in the list is kept, its parent is confirmed
+	kept = dropChildren(coin.Transactions{child, other})
+	require.Equal(t, coin.Transactions{child, other}, kept)
+}
@@ -0,0 +1,234 @@
+package visor
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/visor/blockdb"
+	"github.com/skycoin/skycoin/src/visor/historydb"
+)
+
+// Kinds of inconsistencies reported by VerifyDB
+const (
+	IssueMissingBlock = "missing_block"
+	IssueSignature    = "signature"
+	IssueBlockSeq     = "block_seq"
+	IssuePrevHash     = "prev_hash"
+	IssueBodyHash     = "body_hash"
+	IssueUxHash       = "uxhash"
+	IssueUnspent      = "unspent"
+	IssueHistory      = "history"
+)
+
+// DBIssue is an inconsistency found by VerifyDB
+type DBIssue struct {
+	Seq  uint64 `json:"seq"`
+	Kind string `json:"kind"`
+	Msg  string `json:"msg"`
+}
+
+// DBVerifyResult is the report of VerifyDB
+type DBVerifyResult struct {
+	HeadSeq       uint64    `json:"head_seq"`
+	PrunedSeq     uint64    `json:"pruned_seq"`
+	BlocksChecked uint64    `json:"blocks_checked"`
+	UnspentCount  uint64    `json:"unspent_count"`
+	UxHash        string    `json:"uxhash"`
+	Issues        []DBIssue `json:"issues"`
+}
+
+// BlocksCorrupted returns true if the blocks themselves are inconsistent,
+// in which case the derived buckets can't be repaired by reindexing
+func (r DBVerifyResult) BlocksCorrupted() bool {
+	for _, i := range r.Issues {
+		switch i.Kind {
+		case IssueUnspent, IssueHistory:
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// UnspentCorrupted returns true if the stored unspent pool does not match the blocks
+func (r DBVerifyResult) UnspentCorrupted() bool {
+	for _, i := range r.Issues {
+		if i.Kind == IssueUnspent {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyDB walks every block of the db, checking the block signatures, the prev hash
+// chaining, the body hashes and the uxhash of each block against an unspent pool recomputed
+// in memory. The recomputed pool is then compared with the stored unspent pool, and the
+// historydb address indexes are cross-checked against the block transactions.
+// The bodies of pruned blocks are gone, so only their headers and signatures are checked,
+// and the unspent pool can't be recomputed from a pruned db.
+// All inconsistencies are collected in the result, an error is only returned if the db
+// can't be read.
+func VerifyDB(db *bolt.DB, signers blockdb.SignerSchedule) (*DBVerifyResult, error) {
+	chain, err := blockdb.NewBlockchain(db, DefaultWalker)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := historydb.New(db)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &DBVerifyResult{
+		HeadSeq:   chain.HeadSeq(),
+		PrunedSeq: chain.PrunedSeq(),
+		Issues:    []DBIssue{},
+	}
+
+	addIssue := func(seq uint64, kind, format string, args ...interface{}) {
+		res.Issues = append(res.Issues, DBIssue{
+			Seq:  seq,
+			Kind: kind,
+			Msg:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	if chain.GetGenesisBlock() == nil {
+		return res, nil
+	}
+
+	unspent := make(map[cipher.SHA256]coin.UxOut)
+	var uxHash cipher.SHA256
+	var prev *coin.SignedBlock
+	parsedHeight := history.ParsedHeight()
+
+	for seq := uint64(0); seq <= res.HeadSeq; seq++ {
+		b, err := chain.GetHeaderBySeq(seq)
+		if err != nil {
+			switch err.(type) {
+			case blockdb.ErrMissingSignature:
+				addIssue(seq, IssueSignature, "%v", err)
+				prev = nil
+				continue
+			default:
+				return nil, err
+			}
+		}
+
+		if b == nil {
+			addIssue(seq, IssueMissingBlock, "block does not exist")
+			prev = nil
+			continue
+		}
+
+		res.BlocksChecked++
+
+		if err := signers.VerifyBlockSignature(b); err != nil {
+			addIssue(seq, IssueSignature, "invalid signature: %v", err)
+		}
+
+		if b.Seq() != seq {
+			addIssue(seq, IssueBlockSeq, "block header has seq %d", b.Seq())
+		}
+
+		if prev != nil && b.Head.PrevHash != prev.HashHeader() {
+			addIssue(seq, IssuePrevHash, "prev hash %s does not match block %d hash %s",
+				b.Head.PrevHash.Hex(), prev.Seq(), prev.HashHeader().Hex())
+		}
+
+		prev = b
+
+		// The genesis block is never pruned
+		pruned := seq > 0 && seq <= res.PrunedSeq
+		if pruned {
+			continue
+		}
+
+		if bodyHash := b.Body.Hash(); b.Head.BodyHash != bodyHash {
+			addIssue(seq, IssueBodyHash, "body hash %s does not match header %s",
+				bodyHash.Hex(), b.Head.BodyHash.Hex())
+		}
+
+		if res.PrunedSeq == 0 {
+			// The block's uxhash is the unspent pool checksum before the block is applied
+			if b.Head.UxHash != uxHash {
+				addIssue(seq, IssueUxHash, "uxhash %s does not match recomputed %s",
+					b.Head.UxHash.Hex(), uxHash.Hex())
+			}
+
+			for _, txn := range b.Body.Transactions {
+				for _, in := range txn.In {
+					ux, ok := unspent[in]
+					if !ok {
+						addIssue(seq, IssueUnspent, "transaction %s spends unknown output %s",
+							txn.Hash().Hex(), in.Hex())
+						continue
+					}
+					uxHash = uxHash.Xor(ux.SnapshotHash())
+					delete(unspent, in)
+				}
+
+				for _, ux := range coin.CreateUnspents(b.Head, txn) {
+					uxHash = uxHash.Xor(ux.SnapshotHash())
+					unspent[ux.Hash()] = ux
+				}
+			}
+		}
+
+		if int64(seq) <= parsedHeight {
+			msgs, err := history.VerifyBlock(&b.Block)
+			if err != nil {
+				return nil, err
+			}
+			for _, msg := range msgs {
+				addIssue(seq, IssueHistory, "%s", msg)
+			}
+		}
+	}
+
+	if parsedHeight != int64(res.HeadSeq) {
+		addIssue(res.HeadSeq, IssueHistory, "history parsed height %d does not match head seq %d",
+			parsedHeight, res.HeadSeq)
+	}
+
+	// compare the recomputed unspent pool with the stored one
+	pool := chain.UnspentPool()
+	res.UnspentCount = pool.Len()
+	res.UxHash = pool.GetUxHash().Hex()
+
+	// The unspent pool can't be recomputed from a pruned db
+	if res.PrunedSeq == 0 {
+		if pool.GetUxHash() != uxHash {
+			addIssue(res.HeadSeq, IssueUnspent, "stored uxhash %s does not match recomputed %s",
+				pool.GetUxHash().Hex(), uxHash.Hex())
+		}
+
+		stored, err := pool.GetAll()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ux := range stored {
+			if _, ok := unspent[ux.Hash()]; !ok {
+				addIssue(ux.Head.BkSeq, IssueUnspent, "stored unspent output %s is not in the recomputed pool",
+					ux.Hash().Hex())
+			}
+		}
+
+		for h, ux := range unspent {
+			if !pool.Contains(h) {
+				addIssue(ux.Head.BkSeq, IssueUnspent, "unspent output %s is missing from the stored pool", h.Hex())
+			}
+		}
+	}
+
+	sort.SliceStable(res.Issues, func(i, j int) bool {
+		return res.Issues[i].Seq < res.Issues[j].Seq
+	})
+
+	return res, nil
+}
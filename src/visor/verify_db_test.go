@@ -0,0 +1,87 @@
+package visor
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/testutil"
+	"github.com/skycoin/skycoin/src/visor/blockdb"
+)
+
+func TestVerifyDB(t *testing.T) {
+	db, closeDB := testutil.PrepareDB(t)
+	defer closeDB()
+
+	bc := makeReindexChain(t, db, 3, 0)
+	signers := blockdb.NewSignerSchedule(genPublic)
+
+	// Parse the blocks into the history db
+	require.NoError(t, Reindex(db, signers, ReindexConfig{HistoryOnly: true}))
+
+	res, err := VerifyDB(db, signers)
+	require.NoError(t, err)
+	require.Empty(t, res.Issues)
+	require.Equal(t, uint64(3), res.HeadSeq)
+	require.Equal(t, uint64(0), res.PrunedSeq)
+	require.Equal(t, uint64(4), res.BlocksChecked)
+	require.Equal(t, bc.Unspent().Len(), res.UnspentCount)
+	require.Equal(t, bc.Unspent().GetUxHash().Hex(), res.UxHash)
+
+	// Blocks signed by another key
+	otherPubkey, _ := cipher.GenerateKeyPair()
+	res, err = VerifyDB(db, blockdb.NewSignerSchedule(otherPubkey))
+	require.NoError(t, err)
+	require.Len(t, res.Issues, 4)
+	for i, issue := range res.Issues {
+		require.Equal(t, uint64(i), issue.Seq)
+		require.Equal(t, IssueSignature, issue.Kind)
+	}
+	require.True(t, res.BlocksCorrupted())
+
+	// An output missing from the stored unspent pool
+	uxs, err := bc.Unspent().GetAll()
+	require.NoError(t, err)
+	h := uxs[0].Hash()
+	err = db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("unspent_pool")).Delete(h[:])
+	})
+	require.NoError(t, err)
+
+	res, err = VerifyDB(db, signers)
+	require.NoError(t, err)
+	require.Equal(t, []DBIssue{
+		{
+			Seq:  uxs[0].Head.BkSeq,
+			Kind: IssueUnspent,
+			Msg:  fmt.Sprintf("unspent output %s is missing from the stored pool", h.Hex()),
+		},
+	}, res.Issues)
+	require.False(t, res.BlocksCorrupted())
+	require.True(t, res.UnspentCorrupted())
+}
+
+func TestVerifyDBPruned(t *testing.T) {
+	db, closeDB := testutil.PrepareDB(t)
+	defer closeDB()
+
+	makeReindexChain(t, db, 4, 2)
+
+	// The pruned bodies and the unspent pool are not checked
+	res, err := VerifyDB(db, blockdb.NewSignerSchedule(genPublic))
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), res.PrunedSeq)
+	require.Equal(t, uint64(5), res.BlocksChecked)
+	require.Equal(t, []DBIssue{
+		{
+			Seq:  4,
+			Kind: IssueHistory,
+			Msg:  "history parsed height -1 does not match head seq 4",
+		},
+	}, res.Issues)
+	require.False(t, res.BlocksCorrupted())
+	require.False(t, res.UnspentCorrupted())
+}
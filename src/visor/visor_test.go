@@ -67,6 +67,15 @@ func removeCorruptDBFiles(t *testing.T, badDBFile string) {
 	}
 }
 
+func removeBackupDBFiles(t *testing.T, dbFile string) {
+	backupFiles, err := filepath.Glob(dbFile + ".backup.*")
+	require.NoError(t, err)
+	for _, m := range backupFiles {
+		err := os.Remove(m)
+		require.NoError(t, err)
+	}
+}
+
 func TestErrSignatureLostRecreateDB(t *testing.T) {
 	badDBFile := "./testdata/data.db.nosig" // about 8MB size
 	badDBData := readAll(t, badDBFile)
@@ -84,6 +93,8 @@ func TestErrSignatureLostRecreateDB(t *testing.T) {
 		writeDBFile(t, badDBFile, badDBData)
 		// Remove leftover corrupt db copies
 		removeCorruptDBFiles(t, badDBFile)
+		// Remove the backups made before migrating the db schema
+		removeBackupDBFiles(t, badDBFile)
 	}()
 
 	// Make sure that the database file causes ErrMissingSignature error
@@ -11,8 +11,17 @@ import (
 	"github.com/skycoin/skycoin/src/visor/blockdb"
 )
 
+// ErrWalletNotExist is returned when the wallet is not loaded
+type ErrWalletNotExist struct {
+	ID string
+}
+
+func (e ErrWalletNotExist) Error() string {
+	return fmt.Sprintf("wallet %s doesn't exist", e.ID)
+}
+
 func errWalletNotExist(wltName string) error {
-	return fmt.Errorf("wallet %s doesn't exist", wltName)
+	return ErrWalletNotExist{ID: wltName}
 }
 
 // BalanceGetter interface for getting the balance of given addresses